@@ -27,6 +27,7 @@ create table group_assets
     group_id uuid not null,
     asset_id uuid not null,
     asset_kind smallint default 0 not null,
+    expires_at timestamp with time zone,
     constraint group_assets_pk
         primary key (group_id, asset_id, asset_kind)
 );
@@ -39,6 +40,10 @@ create index group_assets_asset_id_index
 create index group_assets_group_id_index
     on group_assets (group_id);
 
+create index group_assets_expires_at_index
+    on group_assets (expires_at)
+    where (expires_at is not null);
+
 create table accesspolicy_roster
 (
     policy_id uuid not null,
@@ -46,6 +51,9 @@ create table accesspolicy_roster
     actor_id uuid not null,
     access bigint not null,
     access_explained text,
+    denied bigint default 0 not null,
+    grantable bigint default 0 not null,
+    expires_at timestamp with time zone,
     constraint accesspolicy_roster_pk
         primary key (policy_id, actor_kind, actor_id)
 );
@@ -58,6 +66,52 @@ create index accesspolicy_roster_policy_id_actor_kind_index
 create index accesspolicy_roster_policy_id_index
     on accesspolicy_roster (policy_id);
 
+create index accesspolicy_roster_expires_at_index
+    on accesspolicy_roster (expires_at)
+    where (expires_at is not null);
+
+create table accesspolicy_owner
+(
+    policy_id uuid not null,
+    owner_id uuid not null,
+    constraint accesspolicy_owner_pk
+        primary key (policy_id, owner_id)
+);
+
+alter table accesspolicy_owner owner to postgres;
+
+create table accesspolicy_request
+(
+    id uuid not null
+        constraint accesspolicy_request_pk
+            primary key,
+    policy_id uuid not null,
+    requester_kind smallint not null,
+    requester_id uuid not null,
+    rights bigint not null,
+    justification text default ''::text not null,
+    status smallint default 0 not null,
+    resolver_kind smallint default 0 not null,
+    resolver_id uuid default '00000000-0000-0000-0000-000000000000'::uuid not null,
+    created_at timestamp with time zone not null,
+    resolved_at timestamp with time zone
+);
+
+alter table accesspolicy_request owner to postgres;
+
+create index accesspolicy_request_policy_id_status_index
+    on accesspolicy_request (policy_id, status);
+
+create table accesspolicy_suspension
+(
+    actor_id uuid not null
+        constraint accesspolicy_suspension_pk
+            primary key,
+    suspended_at timestamp with time zone not null
+);
+
+alter table accesspolicy_suspension owner to postgres;
+
 create table password
 (
     kind smallint not null,
@@ -73,6 +127,22 @@ create table password
 
 alter table password owner to postgres;
 
+create table password_history
+(
+    id bigserial not null
+        constraint password_history_pk
+            primary key,
+    kind smallint not null,
+    owner_id uuid not null,
+    hash bytea not null,
+    created_at timestamp with time zone not null
+);
+
+alter table password_history owner to postgres;
+
+create index password_history_owner_index
+    on password_history (kind, owner_id);
+
 create table user_email
 (
     user_id uuid not null,
@@ -265,23 +335,33 @@ create table accesspolicy
             primary key,
     parent_id uuid,
     owner_id uuid not null,
-    key text not null
-        constraint accesspolicy_pk
-            unique,
+    key text not null,
     object_name text,
     object_id uuid,
-    flags smallint default 0 not null
+    flags smallint default 0 not null,
+    scope_id uuid default '00000000-0000-0000-0000-000000000000'::uuid not null,
+    version bigint default 0 not null,
+    deleted_at timestamp with time zone
 );
 
 alter table accesspolicy owner to postgres;
 
+create index accesspolicy_scope_id_index
+    on accesspolicy (scope_id);
+
+create index accesspolicy_owner_id_index
+    on accesspolicy (owner_id);
+
+create index accesspolicy_object_name_index
+    on accesspolicy (object_name);
+
 create unique index accesspolicy__key_uindex
     on accesspolicy (key)
-    where (btrim(key) <> ''::text);
+    where ((btrim(key) <> ''::text) and (deleted_at is null));
 
 create unique index accesspolicy_pk_object_name_id
     on accesspolicy (key)
-    where (btrim(object_name) <> ''::text);
+    where ((btrim(object_name) <> ''::text) and (deleted_at is null));
 
 create table auth_session
 (
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// Config describes a database connection and its pool,
+// zero pool values keep the defaults of the underlying pool
+// NOTE: the driver must be registered for Open, i.e. "sqlite3" or "mysql",
+// whereas PostgreSQL pools are opened by PostgreSQLPool
+type Config struct {
+	Driver string
+	DSN    string
+
+	// maximum number of open connections, zero means unlimited
+	MaxOpenConns int
+
+	// maximum number of idle connections, negative means none are kept
+	MaxIdleConns int
+
+	// maximum lifetime of a connection, zero means connections are reused forever
+	ConnMaxLifetime time.Duration
+}
+
+// TestingConfig returns a small pool configuration for a given driver and DSN,
+// meant for the test helpers
+func TestingConfig(driver, dsn string) Config {
+	return Config{
+		Driver:          driver,
+		DSN:             dsn,
+		MaxOpenConns:    4,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: time.Minute,
+	}
+}
+
+// Open opens a database handle and applies the pool settings
+func Open(cfg Config) (*sql.DB, error) {
+	if cfg.Driver == "" {
+		return nil, ErrEmptyDriver
+	}
+
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open database: driver=%s", cfg.Driver)
+	}
+
+	if cfg.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+
+	if cfg.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+
+	if cfg.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	return db, nil
+}
+
+// PostgreSQLPoolConfig translates a given config into the configuration
+// of a pgx connection pool, the DSN is parsed by pgx.ParseDSN
+// NOTE: pgx pool neither closes idle connections nor limits their lifetime,
+// so only MaxOpenConns applies, and zero keeps the default of pgx (5)
+func PostgreSQLPoolConfig(cfg Config) (pgx.ConnPoolConfig, error) {
+	conf, err := pgx.ParseDSN(cfg.DSN)
+	if err != nil {
+		return pgx.ConnPoolConfig{}, errors.Wrap(err, "failed to parse DSN")
+	}
+
+	return pgx.ConnPoolConfig{
+		ConnConfig:     conf,
+		MaxConnections: cfg.MaxOpenConns,
+	}, nil
+}
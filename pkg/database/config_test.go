@@ -0,0 +1,64 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agubarev/hometown/pkg/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	a := assert.New(t)
+
+	cfg := database.TestingConfig("sqlite3", ":memory:")
+	cfg.MaxOpenConns = 3
+	cfg.ConnMaxLifetime = time.Second
+
+	db, err := database.Open(cfg)
+	a.NoError(err)
+	defer db.Close()
+
+	a.NoError(db.Ping())
+	a.Equal(3, db.Stats().MaxOpenConnections)
+
+	// zero values keep the defaults
+	db2, err := database.Open(database.Config{Driver: "sqlite3", DSN: ":memory:"})
+	a.NoError(err)
+	defer db2.Close()
+
+	a.Zero(db2.Stats().MaxOpenConnections)
+
+	// sqlite is always limited to a single connection
+	db3, err := database.SQLiteForTesting()
+	a.NoError(err)
+	defer db3.Close()
+
+	a.Equal(1, db3.Stats().MaxOpenConnections)
+
+	_, err = database.Open(database.Config{DSN: ":memory:"})
+	a.Equal(database.ErrEmptyDriver, err)
+}
+
+func TestPostgreSQLPoolConfig(t *testing.T) {
+	a := assert.New(t)
+
+	cfg := database.TestingConfig("pgx", "host=localhost port=5432 dbname=hometown_test user=hometown")
+
+	conf, err := database.PostgreSQLPoolConfig(cfg)
+	a.NoError(err)
+	a.Equal(4, conf.MaxConnections)
+	a.Equal("localhost", conf.Host)
+	a.Equal(uint16(5432), conf.Port)
+	a.Equal("hometown_test", conf.Database)
+
+	// zero keeps the default of pgx
+	cfg.MaxOpenConns = 0
+
+	conf, err = database.PostgreSQLPoolConfig(cfg)
+	a.NoError(err)
+	a.Zero(conf.MaxConnections)
+
+	_, err = database.PostgreSQLPoolConfig(database.Config{DSN: "port=not-a-number"})
+	a.Error(err)
+}
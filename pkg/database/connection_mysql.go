@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -50,6 +51,11 @@ func MySQLForTesting() (conn *dbr.Connection, err error) {
 		return nil, errors.Wrap(err, "failed to connect to test data")
 	}
 
+	// making sure the schema is in place
+	if err = Migrate(context.Background(), conn.DB, DialectMySQL); err != nil {
+		return nil, errors.Wrap(err, "failed to migrate test data")
+	}
+
 	tx, err := conn.NewSession(nil).Begin()
 	if err != nil {
 		return nil, err
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -9,6 +10,7 @@ import (
 	"github.com/agubarev/hometown/pkg/util"
 	"github.com/jackc/pgx"
 	"github.com/jackc/pgx/log/zapadapter"
+	"github.com/jackc/pgx/stdlib"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
@@ -53,6 +55,28 @@ func PostgreSQLConnection(logger *zap.Logger) *pgx.Conn {
 	return postgresConn
 }
 
+// PostgreSQLPool opens a pgx connection pool limited by the pool settings
+// of a given config, see PostgreSQLPoolConfig
+func PostgreSQLPool(cfg Config, logger *zap.Logger) (*pgx.ConnPool, error) {
+	conf, err := PostgreSQLPoolConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// injecting logger into data instance
+	if logger != nil {
+		conf.Logger = zapadapter.NewLogger(logger)
+		conf.LogLevel = pgx.LogLevelDebug
+	}
+
+	pool, err := pgx.NewConnPool(conf)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to database")
+	}
+
+	return pool, nil
+}
+
 // PostgreSQLForTesting simply returns a data mysqlConn
 func PostgreSQLForTesting(logger *zap.Logger) (conn *pgx.Conn) {
 	if !util.IsTestMode() {
@@ -81,6 +105,14 @@ func PostgreSQLForTesting(logger *zap.Logger) (conn *pgx.Conn) {
 		conf.LogLevel = pgx.LogLevelDebug
 	}
 
+	// making sure the schema is in place
+	migrationDB := stdlib.OpenDB(conf)
+	if err = Migrate(context.Background(), migrationDB, DialectPostgres); err != nil {
+		log.Fatalf("failed to migrate database: %s", err)
+	}
+
+	migrationDB.Close()
+
 	// initializing connection to postgres data
 	conn, err = pgx.Connect(conf)
 	if err != nil {
@@ -105,7 +137,11 @@ func PostgreSQLForTesting(logger *zap.Logger) (conn *pgx.Conn) {
 		"group_assets",
 		"accesspolicy",
 		"accesspolicy_roster",
+		"accesspolicy_owner",
+		"accesspolicy_request",
+		"accesspolicy_suspension",
 		"password",
+		"password_history",
 		"token",
 		"user",
 		"user_email",
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/agubarev/hometown/pkg/util"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"github.com/pkg/errors"
+)
+
+// SQLiteSchema is the schema of the tables that have SQLite-backed stores
+// NOTE: mirrors the PostgreSQL schema, UUIDs are kept as text
+const SQLiteSchema = `
+create table if not exists accesspolicy
+(
+    id text not null
+        constraint accesspolicy_id_pk
+            primary key,
+    parent_id text,
+    owner_id text not null,
+    key text not null,
+    object_name text,
+    object_id text,
+    flags integer default 0 not null,
+    scope_id text default '00000000-0000-0000-0000-000000000000' not null,
+    version integer default 0 not null,
+    deleted_at datetime
+);
+
+create index if not exists accesspolicy_scope_id_index
+    on accesspolicy (scope_id);
+
+create index if not exists accesspolicy_owner_id_index
+    on accesspolicy (owner_id);
+
+create index if not exists accesspolicy_object_name_index
+    on accesspolicy (object_name);
+
+create unique index if not exists accesspolicy__key_uindex
+    on accesspolicy (key)
+    where (trim(key) <> '' and deleted_at is null);
+
+create table if not exists accesspolicy_roster
+(
+    policy_id text not null,
+    actor_kind integer not null,
+    actor_id text not null,
+    access integer not null,
+    access_explained text,
+    denied integer default 0 not null,
+    grantable integer default 0 not null,
+    expires_at datetime,
+    constraint accesspolicy_roster_pk
+        primary key (policy_id, actor_kind, actor_id)
+);
+
+create index if not exists accesspolicy_roster_policy_id_index
+    on accesspolicy_roster (policy_id);
+
+create index if not exists accesspolicy_roster_expires_at_index
+    on accesspolicy_roster (expires_at)
+    where (expires_at is not null);
+
+create table if not exists accesspolicy_owner
+(
+    policy_id text not null,
+    owner_id text not null,
+    constraint accesspolicy_owner_pk
+        primary key (policy_id, owner_id)
+);
+
+create table if not exists accesspolicy_request
+(
+    id text not null
+        constraint accesspolicy_request_pk
+            primary key,
+    policy_id text not null,
+    requester_kind integer not null,
+    requester_id text not null,
+    rights integer not null,
+    justification text default '' not null,
+    status integer default 0 not null,
+    resolver_kind integer default 0 not null,
+    resolver_id text default '00000000-0000-0000-0000-000000000000' not null,
+    created_at datetime not null,
+    resolved_at datetime
+);
+
+create index if not exists accesspolicy_request_policy_id_status_index
+    on accesspolicy_request (policy_id, status);
+`
+
+// SQLiteConnection opens an SQLite database and makes sure its schema is in place,
+// meant for embedded deployments which can't depend on an external database
+func SQLiteConnection(dsn string) (*sql.DB, error) {
+	// SQLite doesn't handle concurrent writers, besides, every connection
+	// to an in-memory database would otherwise get a database of its own
+	db, err := Open(Config{Driver: "sqlite3", DSN: dsn, MaxOpenConns: 1})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open sqlite database")
+	}
+
+	if err = Migrate(context.Background(), db, DialectSQLite); err != nil {
+		return nil, errors.Wrap(err, "failed to initialize sqlite schema")
+	}
+
+	return db, nil
+}
+
+// SQLiteForTesting returns a fresh in-memory SQLite database
+func SQLiteForTesting() (*sql.DB, error) {
+	if !util.IsTestMode() {
+		log.Fatal("SQLiteForTesting() can only be called during testing")
+		return nil, nil
+	}
+
+	return SQLiteConnection(":memory:")
+}
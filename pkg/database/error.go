@@ -4,4 +4,7 @@ import "github.com/pkg/errors"
 
 var (
 	ErrDuplicateEntry = errors.New("duplicate entry")
+	ErrEmptyDriver    = errors.New("database driver is empty")
+	ErrNilDatabase    = errors.New("database is nil")
+	ErrUnknownDialect = errors.New("unknown database dialect")
 )
@@ -0,0 +1,421 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// supported migration dialects
+const (
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+	DialectSQLite   = "sqlite3"
+)
+
+// Migration is a single versioned schema change,
+// every statement must be safe to run more than once
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// migrations of the access policy and group tables by dialect,
+// applied in order and never changed once released
+var migrations = map[string][]Migration{
+	DialectPostgres: {
+		{
+			Version: 1,
+			Name:    "accesspolicy",
+			Statements: []string{
+				`create table if not exists accesspolicy
+				(
+					id uuid not null
+						constraint accesspolicy_id_pk
+							primary key,
+					parent_id uuid,
+					owner_id uuid not null,
+					key text not null,
+					object_name text,
+					object_id uuid,
+					flags smallint default 0 not null,
+					scope_id uuid default '00000000-0000-0000-0000-000000000000'::uuid not null,
+					version bigint default 0 not null,
+					deleted_at timestamp with time zone
+				)`,
+				`create index if not exists accesspolicy_owner_id_index on accesspolicy (owner_id)`,
+				`create index if not exists accesspolicy_object_name_index on accesspolicy (object_name)`,
+				`create table if not exists accesspolicy_roster
+				(
+					policy_id uuid not null,
+					actor_kind smallint not null,
+					actor_id uuid not null,
+					access bigint not null,
+					access_explained text,
+					denied bigint default 0 not null,
+					grantable bigint default 0 not null,
+					expires_at timestamp with time zone,
+					constraint accesspolicy_roster_pk
+						primary key (policy_id, actor_kind, actor_id)
+				)`,
+				`create index if not exists accesspolicy_roster_policy_id_actor_kind_index on accesspolicy_roster (policy_id, actor_kind)`,
+				`create index if not exists accesspolicy_roster_policy_id_index on accesspolicy_roster (policy_id)`,
+				`create table if not exists accesspolicy_owner
+				(
+					policy_id uuid not null,
+					owner_id uuid not null,
+					constraint accesspolicy_owner_pk
+						primary key (policy_id, owner_id)
+				)`,
+				`create table if not exists accesspolicy_request
+				(
+					id uuid not null
+						constraint accesspolicy_request_pk
+							primary key,
+					policy_id uuid not null,
+					requester_kind smallint not null,
+					requester_id uuid not null,
+					rights bigint not null,
+					justification text default ''::text not null,
+					status smallint default 0 not null,
+					resolver_kind smallint default 0 not null,
+					resolver_id uuid default '00000000-0000-0000-0000-000000000000'::uuid not null,
+					created_at timestamp with time zone not null,
+					resolved_at timestamp with time zone
+				)`,
+				`create index if not exists accesspolicy_request_policy_id_status_index on accesspolicy_request (policy_id, status)`,
+			},
+		},
+		{
+			Version: 2,
+			Name:    "group",
+			Statements: []string{
+				`create table if not exists "group"
+				(
+					id uuid not null,
+					parent_id uuid not null,
+					name text not null
+						constraint group_unique_name
+							unique,
+					flags integer default 0 not null,
+					key text not null,
+					constraint group_pk
+						unique (id, parent_id)
+				)`,
+				`create unique index if not exists group_id_uindex on "group" (id)`,
+				`create index if not exists group_flags_index on "group" (flags)`,
+				`create unique index if not exists group_key_uindex on "group" (key)`,
+				`create table if not exists group_assets
+				(
+					group_id uuid not null,
+					asset_id uuid not null,
+					asset_kind smallint default 0 not null,
+					expires_at timestamp with time zone,
+					constraint group_assets_pk
+						primary key (group_id, asset_id, asset_kind)
+				)`,
+				`create index if not exists group_assets_asset_id_index on group_assets (asset_id)`,
+				`create index if not exists group_assets_group_id_index on group_assets (group_id)`,
+			},
+		},
+		{
+			Version: 3,
+			Name:    "accesspolicy_suspension",
+			Statements: []string{
+				`create table if not exists accesspolicy_suspension
+				(
+					actor_id uuid not null
+						constraint accesspolicy_suspension_pk
+							primary key,
+					suspended_at timestamp with time zone not null
+				)`,
+			},
+		},
+		{
+			// NOTE: tables created before the migrations were introduced lack
+			// these columns, hence the indexes on them are only created here
+			Version: 4,
+			Name:    "accesspolicy_and_group_columns",
+			Statements: []string{
+				`alter table accesspolicy
+					add column if not exists scope_id uuid default '00000000-0000-0000-0000-000000000000'::uuid not null,
+					add column if not exists version bigint default 0 not null,
+					add column if not exists deleted_at timestamp with time zone`,
+				`alter table accesspolicy_roster
+					add column if not exists denied bigint default 0 not null,
+					add column if not exists grantable bigint default 0 not null,
+					add column if not exists expires_at timestamp with time zone`,
+				`alter table group_assets
+					add column if not exists expires_at timestamp with time zone`,
+				`create index if not exists accesspolicy_scope_id_index on accesspolicy (scope_id)`,
+				`create unique index if not exists accesspolicy__key_uindex
+					on accesspolicy (key)
+					where ((btrim(key) <> ''::text) and (deleted_at is null))`,
+				`create index if not exists accesspolicy_roster_expires_at_index
+					on accesspolicy_roster (expires_at)
+					where (expires_at is not null)`,
+				`create index if not exists group_assets_expires_at_index
+					on group_assets (expires_at)
+					where (expires_at is not null)`,
+			},
+		},
+	},
+
+	// NOTE: MySQL has neither partial indexes nor "create index if not exists",
+	// so the indexes are declared along with the tables
+	DialectMySQL: {
+		{
+			Version: 1,
+			Name:    "accesspolicy",
+			Statements: []string{
+				"create table if not exists `accesspolicy`" + `
+				(
+					id binary(16) not null primary key,
+					parent_id binary(16),
+					owner_id binary(16) not null,
+					` + "`key`" + ` varchar(255) not null,
+					object_name varchar(255),
+					object_id binary(16),
+					flags smallint default 0 not null,
+					scope_id binary(16) default 0x00000000000000000000000000000000 not null,
+					version bigint default 0 not null,
+					deleted_at datetime,
+					index accesspolicy_scope_id_index (scope_id),
+					index accesspolicy_owner_id_index (owner_id),
+					index accesspolicy_object_name_index (object_name),
+					index accesspolicy__key_index (` + "`key`" + `)
+				)`,
+				"create table if not exists `accesspolicy_roster`" + `
+				(
+					policy_id binary(16) not null,
+					actor_kind smallint not null,
+					actor_id binary(16) not null,
+					access bigint not null,
+					access_explained text,
+					denied bigint default 0 not null,
+					grantable bigint default 0 not null,
+					expires_at datetime,
+					primary key (policy_id, actor_kind, actor_id),
+					index accesspolicy_roster_policy_id_index (policy_id),
+					index accesspolicy_roster_expires_at_index (expires_at)
+				)`,
+				"create table if not exists `accesspolicy_owner`" + `
+				(
+					policy_id binary(16) not null,
+					owner_id binary(16) not null,
+					primary key (policy_id, owner_id)
+				)`,
+				"create table if not exists `accesspolicy_request`" + `
+				(
+					id binary(16) not null primary key,
+					policy_id binary(16) not null,
+					requester_kind smallint not null,
+					requester_id binary(16) not null,
+					rights bigint not null,
+					justification text not null,
+					status smallint default 0 not null,
+					resolver_kind smallint default 0 not null,
+					resolver_id binary(16) default 0x00000000000000000000000000000000 not null,
+					created_at datetime not null,
+					resolved_at datetime,
+					index accesspolicy_request_policy_id_status_index (policy_id, status)
+				)`,
+			},
+		},
+		{
+			Version: 2,
+			Name:    "group",
+			Statements: []string{
+				"create table if not exists `group`" + `
+				(
+					id binary(16) not null,
+					parent_id binary(16) not null,
+					name varchar(255) not null,
+					flags integer default 0 not null,
+					` + "`key`" + ` varchar(255) not null,
+					unique key group_pk (id, parent_id),
+					unique key group_unique_name (name),
+					unique key group_id_uindex (id),
+					unique key group_key_uindex (` + "`key`" + `),
+					index group_flags_index (flags)
+				)`,
+				"create table if not exists `group_assets`" + `
+				(
+					group_id binary(16) not null,
+					asset_id binary(16) not null,
+					asset_kind smallint default 0 not null,
+					expires_at datetime,
+					primary key (group_id, asset_id, asset_kind),
+					index group_assets_asset_id_index (asset_id),
+					index group_assets_group_id_index (group_id),
+					index group_assets_expires_at_index (expires_at)
+				)`,
+			},
+		},
+		{
+			Version: 3,
+			Name:    "accesspolicy_suspension",
+			Statements: []string{
+				"create table if not exists `accesspolicy_suspension`" + `
+				(
+					actor_id binary(16) not null primary key,
+					suspended_at datetime not null
+				)`,
+			},
+		},
+	},
+
+	DialectSQLite: {
+		{
+			Version:    1,
+			Name:       "accesspolicy",
+			Statements: []string{SQLiteSchema},
+		},
+		{
+			Version: 2,
+			Name:    "group",
+			Statements: []string{
+				`create table if not exists "group"
+				(
+					id text not null
+						constraint group_id_pk
+							primary key,
+					parent_id text not null,
+					name text not null
+						constraint group_unique_name
+							unique,
+					flags integer default 0 not null,
+					key text not null
+				)`,
+				`create index if not exists group_flags_index on "group" (flags)`,
+				`create unique index if not exists group_key_uindex on "group" (key)`,
+				`create table if not exists group_assets
+				(
+					group_id text not null,
+					asset_id text not null,
+					asset_kind integer default 0 not null,
+					expires_at datetime,
+					constraint group_assets_pk
+						primary key (group_id, asset_id, asset_kind)
+				)`,
+				`create index if not exists group_assets_asset_id_index on group_assets (asset_id)`,
+				`create index if not exists group_assets_group_id_index on group_assets (group_id)`,
+			},
+		},
+		{
+			Version: 3,
+			Name:    "accesspolicy_suspension",
+			Statements: []string{
+				`create table if not exists accesspolicy_suspension
+				(
+					actor_id text not null
+						constraint accesspolicy_suspension_pk
+							primary key,
+					suspended_at datetime not null
+				)`,
+			},
+		},
+	},
+}
+
+// Migrations returns the migrations of a given dialect
+func Migrations(dialect string) ([]Migration, error) {
+	ms, ok := migrations[dialect]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownDialect, "dialect=%s", dialect)
+	}
+
+	return ms, nil
+}
+
+// Migrate brings the schema of the access policy and group tables up to date,
+// the applied versions are kept in the schema_migrations table, so that
+// every migration is applied only once
+func Migrate(ctx context.Context, db *sql.DB, dialect string) error {
+	if db == nil {
+		return ErrNilDatabase
+	}
+
+	ms, err := Migrations(dialect)
+	if err != nil {
+		return err
+	}
+
+	createVersions := `
+	create table if not exists schema_migrations
+	(
+		version integer not null primary key,
+		name varchar(255) not null,
+		applied_at timestamp not null default current_timestamp
+	)`
+
+	if _, err = db.ExecContext(ctx, createVersions); err != nil {
+		return errors.Wrap(err, "failed to create schema_migrations table")
+	}
+
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	insertVersion := `INSERT INTO schema_migrations(version, name) VALUES (?, ?)`
+	if dialect == DialectPostgres {
+		insertVersion = `INSERT INTO schema_migrations(version, name) VALUES ($1, $2)`
+	}
+
+	for _, m := range ms {
+		if applied[m.Version] {
+			continue
+		}
+
+		if err = migrate(ctx, db, m, insertVersion); err != nil {
+			return errors.Wrapf(err, "failed to apply migration: version=%d, name=%s", m.Version, m.Name)
+		}
+	}
+
+	return nil
+}
+
+func appliedVersions(ctx context.Context, db *sql.DB) (map[int]bool, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch applied migrations")
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err = rows.Scan(&version); err != nil {
+			return nil, errors.Wrap(err, "failed to scan migration version")
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+func migrate(ctx context.Context, db *sql.DB, m Migration, insertVersion string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+
+	for _, stmt := range m.Statements {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "failed to execute statement: %s", strings.TrimSpace(stmt))
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, insertVersion, m.Version, m.Name); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to record migration version")
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,43 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agubarev/hometown/pkg/database"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	db, err := database.Open(database.Config{Driver: "sqlite3", DSN: ":memory:", MaxOpenConns: 1})
+	a.NoError(err)
+	defer db.Close()
+
+	// running twice, the second run must be a no-op
+	a.NoError(database.Migrate(ctx, db, database.DialectSQLite))
+	a.NoError(database.Migrate(ctx, db, database.DialectSQLite))
+
+	ms, err := database.Migrations(database.DialectSQLite)
+	a.NoError(err)
+
+	var versions int
+	a.NoError(db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&versions))
+	a.Equal(len(ms), versions)
+
+	for _, table := range []string{"accesspolicy", "accesspolicy_roster", "accesspolicy_suspension", "group", "group_assets", "schema_migrations"} {
+		var count int
+		a.NoError(db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&count))
+		a.Equal(1, count, table)
+	}
+
+	// unknown dialect
+	err = database.Migrate(ctx, db, "oracle")
+	a.Equal(database.ErrUnknownDialect, errors.Cause(err))
+
+	// nil database
+	a.Equal(database.ErrNilDatabase, database.Migrate(ctx, nil, database.DialectSQLite))
+}
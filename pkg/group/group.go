@@ -15,6 +15,7 @@ const (
 	FDefault
 	FGroup
 	FRole
+	FArchived
 	FAllGroups = FGroup | FRole
 
 	// this flag is used for group flags without translation
@@ -31,6 +32,8 @@ func (flags Flags) Translate() string {
 		return "group"
 	case FAllGroups:
 		return "groups and roles"
+	case FArchived:
+		return "archived"
 	default:
 		return APUnrecognizedFlag
 	}
@@ -121,6 +124,13 @@ func (g Group) IsEnabled() bool { return g.Flags&FEnabled == FEnabled }
 func (g Group) IsGroup() bool   { return g.Flags&FGroup == FGroup }
 func (g Group) IsRole() bool    { return g.Flags&FRole == FRole }
 
+// IsArchived tells whether this group is archived, archived groups
+// keep their relations but must not be granted any new rights
+func (g Group) IsArchived() bool { return g.Flags&FArchived == FArchived }
+
+// Kind returns only the kind flags of this group
+func (g Group) Kind() Flags { return g.Flags & FAllGroups }
+
 func (ak AssetKind) Value() (driver.Value, error) {
 	return ak, nil
 }
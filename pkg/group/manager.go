@@ -47,8 +47,18 @@ var (
 	ErrInvalidGroupName       = errors.New("invalid group name")
 	ErrEmptyGroupKey          = errors.New("group key is empty")
 	ErrAmbiguousKind          = errors.New("group kind is ambiguous")
+	ErrSelfMerge              = errors.New("group cannot be merged into itself")
+	ErrGroupInactive          = errors.New("group is inactive")
+	ErrExpiredRelation        = errors.New("relation expiration time is in the past")
+	ErrGroupTooDeep           = errors.New("group hierarchy is too deep")
+	ErrCircularGroup          = errors.New("group cannot descend from itself")
+	ErrUnknownParentRole      = errors.New("parent role is not defined earlier")
 )
 
+// MaxGroupDepth is the maximum number of levels the group
+// hierarchy is traversed through, either way
+const MaxGroupDepth = 64
+
 type AssetKind uint8
 
 const (
@@ -75,6 +85,9 @@ func UserAsset(id uuid.UUID) Asset             { return Asset{Kind: AKUser, ID:
 type Relation struct {
 	GroupID uuid.UUID
 	Asset   Asset
+
+	// ExpiresAt is when the membership ends, zero means never
+	ExpiresAt time.Time
 }
 
 func NewRelation(gid uuid.UUID, k AssetKind, aid uuid.UUID) Relation {
@@ -87,6 +100,19 @@ func NewRelation(gid uuid.UUID, k AssetKind, aid uuid.UUID) Relation {
 	}
 }
 
+// NewRelationUntil returns a relation which expires at a given time
+func NewRelationUntil(gid uuid.UUID, k AssetKind, aid uuid.UUID, expiresAt time.Time) Relation {
+	rel := NewRelation(gid, k, aid)
+	rel.ExpiresAt = expiresAt
+
+	return rel
+}
+
+// IsExpired tells whether the membership has ended by a given time
+func (rel Relation) IsExpired(now time.Time) bool {
+	return !rel.ExpiresAt.IsZero() && !rel.ExpiresAt.After(now)
+}
+
 // Registry is a typed slice of groups to make sorting easier
 type List []Group
 
@@ -109,11 +135,50 @@ type Manager struct {
 	assetGroups map[Asset][]uuid.UUID // asset -> slice of group IDs
 	groupAssets map[uuid.UUID][]Asset // group ActorID -> slice of asset IDs
 
+	// expiration time of temporary relations, keyed by relations
+	// without the expiration time
+	expiries map[Relation]time.Time
+
+	// callbacks which are invoked whenever groups are merged
+	mergeHooks []MergeHook
+
+	// callbacks which are invoked whenever group membership changes
+	membershipHooks []MembershipHook
+
+	// callbacks which are invoked whenever a group is re-parented
+	parentHooks []ParentHook
+
+	// callbacks which are invoked whenever a group is deleted
+	deleteHooks []DeleteHook
+
+	// whether the children of a deleted group are detached from
+	// the hierarchy instead of being moved under its parent
+	orphanOnDelete bool
+
 	store  Store
 	logger *zap.Logger
 	sync.RWMutex
 }
 
+// MergeHook is called when a merged group is about to be deleted,
+// after its relations and children have been moved to the group it
+// was merged into; used to rewrite external references to the merged group
+type MergeHook func(ctx context.Context, keep, merged Group) error
+
+// MembershipHook is called after an asset has been added to or removed
+// from a group, including the removal of expired relations; used to
+// discard whatever has been derived from the previous membership
+type MembershipHook func(ctx context.Context, rel Relation)
+
+// ParentHook is called after a group has been moved under another parent,
+// which changes whatever its members inherit through the ancestors
+type ParentHook func(ctx context.Context, g Group)
+
+// DeleteHook is called when a group is about to be deleted, before its
+// relations and children are detached, so that a failing hook leaves
+// the group intact; used to discard external references to the deleted group
+type DeleteHook func(ctx context.Context, g Group) error
+
 // NewManager initializing a new group manager
 func NewManager(ctx context.Context, s Store) (m *Manager, err error) {
 	if s == nil {
@@ -126,6 +191,7 @@ func NewManager(ctx context.Context, s Store) (m *Manager, err error) {
 		defaultIDs:  make([]uuid.UUID, 0),
 		assetGroups: make(map[Asset][]uuid.UUID),
 		groupAssets: make(map[uuid.UUID][]Asset),
+		expiries:    make(map[Relation]time.Time),
 		store:       s,
 	}
 
@@ -205,7 +271,11 @@ func (m *Manager) Init(ctx context.Context) error {
 				zap.String("asset_kind", rel.Asset.Kind.String()),
 				zap.Error(err),
 			)
+
+			continue
 		}
+
+		m.setExpiry(rel)
 	}
 
 	return nil
@@ -353,24 +423,52 @@ func (m *Manager) Remove(ctx context.Context, groupID uuid.UUID) error {
 	// and eventually discarding the group to assets relation
 	delete(m.groupAssets, groupID)
 
+	// along with the expiration of its relations
+	for rel := range m.expiries {
+		if rel.GroupID == groupID {
+			delete(m.expiries, rel)
+		}
+	}
+
 	m.Unlock()
 
 	return nil
 }
 
-// Registry returns all groups inside a manager
-func (m *Manager) List(kind Flags) (gs []Group) {
-	gs = make([]Group, 0)
+// List returns a page of groups of a given kind, ordered by key,
+// non-positive limit means no limit
+func (m *Manager) List(ctx context.Context, flags Flags, limit, offset int) (gs []Group, err error) {
+	s, err := m.Store()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to obtain group store")
+	}
 
-	m.RLock()
-	for _, g := range m.groups {
-		if g.Flags&kind != 0 {
-			gs = append(gs, g)
-		}
+	if gs, err = s.ListGroups(ctx, flags, limit, offset); err != nil {
+		return nil, errors.Wrapf(err, "failed to list groups: flags=%s", flags)
 	}
-	m.RUnlock()
 
-	return gs
+	return gs, nil
+}
+
+// SearchByName returns groups whose names contain a given substring,
+// regardless of the case, ordered by name; non-positive limit means no limit
+func (m *Manager) SearchByName(ctx context.Context, substr string, limit int) (gs []Group, err error) {
+	substr = strings.TrimSpace(substr)
+
+	if substr == "" {
+		return nil, ErrEmptyGroupName
+	}
+
+	s, err := m.Store()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to obtain group store")
+	}
+
+	if gs, err = s.SearchGroupsByName(ctx, substr, limit); err != nil {
+		return nil, errors.Wrapf(err, "failed to search groups by name: %s", substr)
+	}
+
+	return gs, nil
 }
 
 // GroupByID returns a group by ActorID
@@ -427,9 +525,9 @@ func (m *Manager) GroupByName(ctx context.Context, name string) (g Group, err er
 	return m.store.FetchGroupByName(ctx, name)
 }
 
-// DeletePolicy returns an accesspolicy policy by its ObjectID
-// NOTE: also deletes all relations and nested groups (should asset have
-// sufficient accesspolicy rights to do that)
+// DeleteGroup invokes delete hooks (i.e. to discard access policy roster
+// entries), then deletes a group along with all of its relations, its children
+// are moved under its parent (or detached, see SetOrphanOnDelete)
 // TODO: implement recursive deletion
 func (m *Manager) DeleteGroup(ctx context.Context, groupID uuid.UUID) (err error) {
 	g, err := m.GroupByID(ctx, groupID)
@@ -442,31 +540,327 @@ func (m *Manager) DeleteGroup(ctx context.Context, groupID uuid.UUID) (err error
 		return err
 	}
 
+	//---------------------------------------------------------------------------
+	// discarding external references
+	//---------------------------------------------------------------------------
+	m.RLock()
+	hooks := make([]DeleteHook, len(m.deleteHooks))
+	copy(hooks, m.deleteHooks)
+	m.RUnlock()
+
+	for _, fn := range hooks {
+		if err = fn(ctx, g); err != nil {
+			return errors.Wrapf(err, "delete hook failed: group_id=%s", g.ID)
+		}
+	}
+
+	//---------------------------------------------------------------------------
+	// deleting relations
+	//---------------------------------------------------------------------------
+	relations, err := s.FetchGroupRelations(ctx, g.ID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch relations of deleted group: %s", g.ID)
+	}
+
+	for _, rel := range relations {
+		if err = s.DeleteRelation(ctx, rel); err != nil {
+			return errors.Wrapf(err, "failed to delete relation of deleted group: asset_id=%s", rel.Asset.ID)
+		}
+	}
+
+	//---------------------------------------------------------------------------
+	// reparenting children
+	//---------------------------------------------------------------------------
+	m.RLock()
+	parentID := g.ParentID
+	if m.orphanOnDelete {
+		parentID = uuid.Nil
+	}
+
+	children := make([]Group, 0)
+	for _, c := range m.groups {
+		if c.ParentID == g.ID {
+			children = append(children, c)
+		}
+	}
+	m.RUnlock()
+
+	for i, child := range children {
+		child.ParentID = parentID
+
+		if children[i], err = s.UpsertGroup(ctx, child); err != nil {
+			return errors.Wrapf(err, "failed to reparent child group: %s", child.ID)
+		}
+
+		m.Lock()
+		m.groups[child.ID] = children[i]
+		m.Unlock()
+	}
+
 	// deleting from the store backend
 	if err = s.DeleteByID(ctx, g.ID); err != nil {
-		return errors.Wrapf(err, "failed to delete group: %d", groupID)
+		return errors.Wrapf(err, "failed to delete group: %s", groupID)
 	}
 
 	// removing from internal cache
 	if err = m.Remove(ctx, g.ID); err != nil {
-		return errors.Wrapf(err, "failed to remove cached group after deletion: %d", g.ID)
+		return errors.Wrapf(err, "failed to remove cached group after deletion: %s", g.ID)
+	}
+
+	// whatever was derived from the former memberships
+	// and ancestry no longer holds
+	for _, rel := range relations {
+		m.membershipChanged(ctx, rel)
+	}
+
+	for _, child := range children {
+		m.parentChanged(ctx, child)
+	}
+
+	return nil
+}
+
+// SetOrphanOnDelete sets whether the children of a deleted group are
+// detached from the hierarchy, by default they're moved under its parent
+func (m *Manager) SetOrphanOnDelete(enabled bool) {
+	m.Lock()
+	m.orphanOnDelete = enabled
+	m.Unlock()
+}
+
+// Archive marks a group as archived, such group retains its
+// relations and existing grants, but cannot be granted anything new
+func (m *Manager) Archive(ctx context.Context, groupID uuid.UUID) error {
+	return m.setArchived(ctx, groupID, true)
+}
+
+// Unarchive reverts the archival of a group
+func (m *Manager) Unarchive(ctx context.Context, groupID uuid.UUID) error {
+	return m.setArchived(ctx, groupID, false)
+}
+
+func (m *Manager) setArchived(ctx context.Context, groupID uuid.UUID, archived bool) (err error) {
+	g, err := m.GroupByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	if g.IsArchived() == archived {
+		return nil
 	}
 
+	g.Flags ^= FArchived
+
+	s, err := m.Store()
+	if err != nil {
+		return errors.Wrap(err, "failed to obtain group store")
+	}
+
+	if g, err = s.UpsertGroup(ctx, g); err != nil {
+		return errors.Wrapf(err, "failed to save group: %s", groupID)
+	}
+
+	m.Lock()
+	m.groups[g.ID] = g
+	m.Unlock()
+
 	return nil
 }
 
+// OnMerge registers a callback which is invoked whenever groups are merged
+func (m *Manager) OnMerge(fn MergeHook) {
+	if fn == nil {
+		return
+	}
+
+	m.Lock()
+	m.mergeHooks = append(m.mergeHooks, fn)
+	m.Unlock()
+}
+
+// OnDelete registers a callback which is invoked whenever a group is deleted
+func (m *Manager) OnDelete(fn DeleteHook) {
+	if fn == nil {
+		return
+	}
+
+	m.Lock()
+	m.deleteHooks = append(m.deleteHooks, fn)
+	m.Unlock()
+}
+
+// OnMembershipChange registers a callback which is invoked whenever
+// an asset is added to or removed from a group
+func (m *Manager) OnMembershipChange(fn MembershipHook) {
+	if fn == nil {
+		return
+	}
+
+	m.Lock()
+	m.membershipHooks = append(m.membershipHooks, fn)
+	m.Unlock()
+}
+
+// membershipChanged invokes membership hooks
+func (m *Manager) membershipChanged(ctx context.Context, rel Relation) {
+	m.RLock()
+	hooks := make([]MembershipHook, len(m.membershipHooks))
+	copy(hooks, m.membershipHooks)
+	m.RUnlock()
+
+	for _, fn := range hooks {
+		fn(ctx, rel)
+	}
+}
+
+// OnParentChange registers a callback which is invoked whenever
+// a group is moved under another parent
+func (m *Manager) OnParentChange(fn ParentHook) {
+	if fn == nil {
+		return
+	}
+
+	m.Lock()
+	m.parentHooks = append(m.parentHooks, fn)
+	m.Unlock()
+}
+
+// parentChanged invokes parent hooks
+func (m *Manager) parentChanged(ctx context.Context, g Group) {
+	m.RLock()
+	hooks := make([]ParentHook, len(m.parentHooks))
+	copy(hooks, m.parentHooks)
+	m.RUnlock()
+
+	for _, fn := range hooks {
+		fn(ctx, g)
+	}
+}
+
+// Merge merges one group into another: all relations of the merged group
+// are moved to the kept group (skipping those it already has), its children
+// are reparented to the kept group, merge hooks are invoked (i.e. to rewrite
+// access policy rosters) and eventually the merged group is deleted
+// NOTE: both groups must be of the same kind, the kept group must be active
+// and must not descend from the merged group
+func (m *Manager) Merge(ctx context.Context, keepID, mergeID uuid.UUID) (err error) {
+	if keepID == mergeID {
+		return ErrSelfMerge
+	}
+
+	keep, err := m.GroupByID(ctx, keepID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain kept group: %s", keepID)
+	}
+
+	merged, err := m.GroupByID(ctx, mergeID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain merged group: %s", mergeID)
+	}
+
+	if keep.Kind() != merged.Kind() {
+		return ErrGroupKindMismatch
+	}
+
+	// merging into an archived group would leave its new grants dead
+	if keep.IsArchived() {
+		return errors.Wrapf(ErrGroupInactive, "group_id=%s", keep.ID)
+	}
+
+	// the kept group must not descend from the merged group,
+	// otherwise reparenting would circuit the hierarchy
+	for pg, err := m.Parent(ctx, keep); err == nil && pg.ID != uuid.Nil; pg, err = m.Parent(ctx, pg) {
+		if pg.ID == merged.ID {
+			return ErrCircuitedParent
+		}
+
+		if pg.ParentID == uuid.Nil {
+			break
+		}
+	}
+
+	s, err := m.Store()
+	if err != nil {
+		return errors.Wrap(err, "failed to obtain group store")
+	}
+
+	//---------------------------------------------------------------------------
+	// moving relations
+	//---------------------------------------------------------------------------
+	relations, err := s.FetchGroupRelations(ctx, merged.ID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch relations of merged group: %s", merged.ID)
+	}
+
+	for _, rel := range relations {
+		// expired memberships aren't carried over
+		if !rel.IsExpired(time.Now()) && !m.IsAsset(ctx, keep.ID, rel.Asset) {
+			if err = m.CreateRelation(ctx, NewRelationUntil(keep.ID, rel.Asset.Kind, rel.Asset.ID, rel.ExpiresAt)); err != nil {
+				return errors.Wrapf(err, "failed to move relation: asset_id=%s", rel.Asset.ID)
+			}
+		}
+
+		// through the manager, so that membership hooks fire for the former members
+		if err = m.DeleteRelation(ctx, rel); err != nil {
+			return errors.Wrapf(err, "failed to delete relation of merged group: asset_id=%s", rel.Asset.ID)
+		}
+	}
+
+	//---------------------------------------------------------------------------
+	// reparenting children
+	//---------------------------------------------------------------------------
+	m.RLock()
+	children := make([]Group, 0)
+	for _, g := range m.groups {
+		if g.ParentID == merged.ID {
+			children = append(children, g)
+		}
+	}
+	m.RUnlock()
+
+	for _, child := range children {
+		child.ParentID = keep.ID
+
+		if child, err = s.UpsertGroup(ctx, child); err != nil {
+			return errors.Wrapf(err, "failed to reparent child group: %s", child.ID)
+		}
+
+		m.Lock()
+		m.groups[child.ID] = child
+		m.Unlock()
+	}
+
+	//---------------------------------------------------------------------------
+	// rewriting external references
+	//---------------------------------------------------------------------------
+	m.RLock()
+	hooks := make([]MergeHook, len(m.mergeHooks))
+	copy(hooks, m.mergeHooks)
+	m.RUnlock()
+
+	for _, fn := range hooks {
+		if err = fn(ctx, keep, merged); err != nil {
+			return errors.Wrapf(err, "merge hook failed: keep_id=%s, merge_id=%s", keep.ID, merged.ID)
+		}
+	}
+
+	return m.DeleteGroup(ctx, merged.ID)
+}
+
 // GroupsByAssetID returns a slice of groups to which a given asset belongs
+// NOTE: expired memberships are excluded
 func (m *Manager) GroupsByAssetID(ctx context.Context, mask Flags, asset Asset) (gs []Group) {
 	if asset.ID == uuid.Nil {
 		return gs
 	}
 
 	gs = make([]Group, 0)
+	now := time.Now()
 
 	m.RLock()
 	for _, g := range m.groups {
 		if g.Flags&mask != 0 {
-			if m.IsAsset(ctx, g.ID, asset) {
+			if m.isLinked(g.ID, asset) && !m.isExpired(g.ID, asset, now) {
 				gs = append(gs, g)
 			}
 		}
@@ -476,6 +870,57 @@ func (m *Manager) GroupsByAssetID(ctx context.Context, mask Flags, asset Asset)
 	return gs
 }
 
+// GroupsByAssetIDs returns the groups to which each of the given assets belong,
+// including all ancestors of such groups, mapped by asset ID, except for
+// the groups of expired memberships
+// NOTE: resolved at once from the manager's registry, without querying the store
+func (m *Manager) GroupsByAssetIDs(ctx context.Context, mask Flags, assets []Asset) (_ map[uuid.UUID][]Group, err error) {
+	result := make(map[uuid.UUID][]Group, len(assets))
+	now := time.Now()
+
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, asset := range assets {
+		if asset.ID == uuid.Nil {
+			continue
+		}
+
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// visited set also guards against circuited parenting
+		visited := make(map[uuid.UUID]bool)
+		gs := make([]Group, 0)
+
+		for _, gid := range m.assetGroups[asset] {
+			if m.isExpired(gid, asset, now) {
+				continue
+			}
+
+			for id := gid; id != uuid.Nil && !visited[id]; {
+				visited[id] = true
+
+				g, ok := m.groups[id]
+				if !ok {
+					return nil, errors.Wrapf(ErrGroupNotFound, "asset_id=%s, group_id=%s", asset.ID, id)
+				}
+
+				if g.Flags&mask != 0 {
+					gs = append(gs, g)
+				}
+
+				id = g.ParentID
+			}
+		}
+
+		result[asset.ID] = append(result[asset.ID], gs...)
+	}
+
+	return result, nil
+}
+
 // Groups to which the asset belongs
 func (m *Manager) Groups(ctx context.Context, mask Flags) []Group {
 	if m.groups == nil {
@@ -492,36 +937,83 @@ func (m *Manager) Groups(ctx context.Context, mask Flags) []Group {
 	return groups
 }
 
-func (m *Manager) setupDefaultGroups(ctx context.Context) error {
-	if m.groups == nil {
-		return ErrNilManager
-	}
+// RoleTemplate describes a role to be created by SetupRoles
+type RoleTemplate struct {
+	Key  string
+	Name string
 
-	//---------------------------------------------------------------------------
-	// roles
-	//---------------------------------------------------------------------------
+	// key of the parent role, which must be defined earlier
+	// in the same set, empty for a top-level role
+	ParentKey string
+}
 
-	// regular user
-	regularRole, err := m.Create(ctx, FRole, uuid.Nil, "regular", "Regular User")
+// DefaultRoles is the role set created unless another one is given
+var DefaultRoles = []RoleTemplate{
+	{Key: "regular", Name: "Regular User"},
+	{Key: "manager", Name: "Manager", ParentKey: "regular"},
+	{Key: "superuser", Name: "Super User", ParentKey: "manager"},
+}
 
-	if err != nil {
-		return errors.Wrap(err, "failed to create regular user role")
+// ValidateRoleTemplates makes sure that every role of a given set has a unique
+// key and that its parent key references a role defined earlier in the set
+func ValidateRoleTemplates(roles []RoleTemplate) error {
+	defined := make(map[string]bool, len(roles))
+
+	for _, rt := range roles {
+		if strings.TrimSpace(rt.Key) == "" {
+			return ErrEmptyGroupKey
+		}
+
+		if defined[rt.Key] {
+			return errors.Wrapf(ErrDuplicateGroup, "key=%s", rt.Key)
+		}
+
+		if rt.ParentKey != "" && !defined[rt.ParentKey] {
+			return errors.Wrapf(ErrUnknownParentRole, "key=%s, parent_key=%s", rt.Key, rt.ParentKey)
+		}
+
+		defined[rt.Key] = true
 	}
 
-	// manager
-	managerRole, err := m.Create(ctx, FRole, regularRole.ID, "manager", "Manager")
+	return nil
+}
 
-	if err != nil {
-		return fmt.Errorf("failed to create manager role: %s", err)
+// SetupRoles creates the roles of a given set in order, DefaultRoles if nil,
+// returning the created roles in the same order
+// NOTE: the whole set is validated before anything is created
+func (m *Manager) SetupRoles(ctx context.Context, roles []RoleTemplate) ([]Group, error) {
+	if m.groups == nil {
+		return nil, ErrNilManager
 	}
 
-	// super user
-	_, err = m.Create(ctx, FRole, managerRole.ID, "superuser", "Super User")
-	if err != nil {
-		return fmt.Errorf("failed to create superuser role: %s", err)
+	if roles == nil {
+		roles = DefaultRoles
 	}
 
-	return nil
+	if err := ValidateRoleTemplates(roles); err != nil {
+		return nil, errors.Wrap(err, "invalid role templates")
+	}
+
+	// role key -> role id
+	created := make(map[string]uuid.UUID, len(roles))
+	gs := make([]Group, 0, len(roles))
+
+	for _, rt := range roles {
+		g, err := m.Create(ctx, FRole, created[rt.ParentKey], rt.Key, rt.Name)
+		if err != nil {
+			return gs, errors.Wrapf(err, "failed to create role: key=%s", rt.Key)
+		}
+
+		created[rt.Key] = g.ID
+		gs = append(gs, g)
+	}
+
+	return gs, nil
+}
+
+func (m *Manager) setupDefaultGroups(ctx context.Context) error {
+	_, err := m.SetupRoles(ctx, nil)
+	return err
 }
 
 // Parent returns a parent of a given group
@@ -533,6 +1025,165 @@ func (m *Manager) Parent(ctx context.Context, g Group) (p Group, err error) {
 	return m.GroupByID(ctx, g.ParentID)
 }
 
+// Ancestors returns the parents of a given group, from the nearest one
+// up to the root
+func (m *Manager) Ancestors(ctx context.Context, groupID uuid.UUID) (gs []Group, err error) {
+	g, err := m.GroupByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	gs = make([]Group, 0)
+	visited := map[uuid.UUID]bool{g.ID: true}
+
+	for g.ParentID != uuid.Nil {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if visited[g.ParentID] {
+			return nil, errors.Wrapf(ErrCircuitedParent, "group_id=%s, parent_id=%s", g.ID, g.ParentID)
+		}
+
+		if len(gs) == MaxGroupDepth {
+			return nil, errors.Wrapf(ErrGroupTooDeep, "group_id=%s", groupID)
+		}
+
+		if g, err = m.Parent(ctx, g); err != nil {
+			return nil, errors.Wrapf(err, "failed to obtain parent group: group_id=%s", g.ID)
+		}
+
+		visited[g.ID] = true
+		gs = append(gs, g)
+	}
+
+	return gs, nil
+}
+
+// Descendants returns the whole subtree of a given group, level by level,
+// i.e. its children first, then their children and so on
+// NOTE: resolved from the manager's registry, without querying the store
+func (m *Manager) Descendants(ctx context.Context, groupID uuid.UUID) (gs []Group, err error) {
+	if _, err = m.GroupByID(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	// parent group id -> children
+	children := make(map[uuid.UUID][]Group)
+	for _, g := range m.groups {
+		if g.ParentID != uuid.Nil {
+			children[g.ParentID] = append(children[g.ParentID], g)
+		}
+	}
+
+	gs = make([]Group, 0)
+	visited := map[uuid.UUID]bool{groupID: true}
+	level := []uuid.UUID{groupID}
+
+	for depth := 0; len(level) > 0; depth++ {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		next := make([]uuid.UUID, 0)
+		for _, id := range level {
+			for _, child := range children[id] {
+				if depth == MaxGroupDepth {
+					return nil, errors.Wrapf(ErrGroupTooDeep, "group_id=%s", groupID)
+				}
+
+				if visited[child.ID] {
+					return nil, errors.Wrapf(ErrCircuitedParent, "group_id=%s, parent_id=%s", child.ID, id)
+				}
+
+				visited[child.ID] = true
+				gs = append(gs, child)
+				next = append(next, child.ID)
+			}
+		}
+
+		level = next
+	}
+
+	return gs, nil
+}
+
+// MembersOf returns the IDs of users who belong to a given group, along with
+// the members of all its descendant groups if transitive is set, each ID once
+// NOTE: expired memberships are excluded
+func (m *Manager) MembersOf(ctx context.Context, groupID uuid.UUID, transitive bool) ([]uuid.UUID, error) {
+	if _, err := m.GroupByID(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	groupIDs := []uuid.UUID{groupID}
+
+	if transitive {
+		descendants, err := m.Descendants(ctx, groupID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to obtain descendant groups: %s", groupID)
+		}
+
+		for _, g := range descendants {
+			groupIDs = append(groupIDs, g.ID)
+		}
+	}
+
+	now := time.Now()
+	seen := make(map[uuid.UUID]bool)
+	ids := make([]uuid.UUID, 0)
+
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, gid := range groupIDs {
+		for _, asset := range m.groupAssets[gid] {
+			if asset.Kind != AKUser || seen[asset.ID] || m.isExpired(gid, asset, now) {
+				continue
+			}
+
+			seen[asset.ID] = true
+			ids = append(ids, asset.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// IsMemberTransitive tells whether a user belongs to a given group either
+// directly or through any of its descendant groups
+// NOTE: membership flows up the hierarchy, same as the rights granted to
+// a group flow down to the members of its descendants, so a member of a child
+// group is a transitive member of the parent, but not the other way around;
+// expired memberships are excluded
+func (m *Manager) IsMemberTransitive(ctx context.Context, groupID, userID uuid.UUID) (bool, error) {
+	if userID == uuid.Nil {
+		return false, ErrNilAssetID
+	}
+
+	descendants, err := m.Descendants(ctx, groupID)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to obtain descendant groups: %s", groupID)
+	}
+
+	user := NewAsset(AKUser, userID)
+
+	if m.IsAsset(ctx, groupID, user) {
+		return true, nil
+	}
+
+	for _, g := range descendants {
+		if m.IsAsset(ctx, g.ID, user) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 // Validate performs an integrity check on a given group
 func (m *Manager) Validate(ctx context.Context, groupID uuid.UUID) (err error) {
 	g, err := m.GroupByID(ctx, groupID)
@@ -581,47 +1232,50 @@ func (m *Manager) IsCircuited(ctx context.Context, groupID uuid.UUID) (bool, err
 }
 
 // SetParent assigns a new parent ActorID
+// NOTE: same as SetGroupParent
 func (m *Manager) SetParent(ctx context.Context, groupID, newParentID uuid.UUID) (err error) {
+	return m.SetGroupParent(ctx, groupID, newParentID)
+}
+
+// SetGroupParent assigns a new parent to a given group, nil parent ID makes
+// it a top-level group; the group must not become its own ancestor
+func (m *Manager) SetGroupParent(ctx context.Context, groupID, parentID uuid.UUID) (err error) {
 	g, err := m.GroupByID(ctx, groupID)
 	if err != nil {
 		return err
 	}
 
-	newParent, err := m.GroupByID(ctx, newParentID)
-	if err != nil {
-		return errors.Wrap(err, "parent group not found")
-	}
-
-	// since new parent could be zero then its kind is irrelevant
-	if newParent.ID != uuid.Nil {
-		// checking whether new parent already is set somewhere along the parenthood
-		// by tracing backwards until a no-parent is met; at this point only a
-		// requested parent is searched and not tested whether the relations
-		// are circuited among themselves
-		if newParent.ParentID != uuid.Nil {
-			for pg, err := m.Parent(ctx, g); err == nil && pg.ID != uuid.Nil; pg, err = m.Parent(ctx, pg) {
-				// testing equality by comparing each group's ObjectID
-				if pg.ID == newParent.ID {
-					return ErrDuplicateParent
-				}
+	if parentID != uuid.Nil {
+		if parentID == g.ID {
+			return ErrCircularGroup
+		}
 
-				// no more parents, breaking
-				if pg.ParentID == uuid.Nil {
-					break
-				}
-			}
+		parent, err := m.GroupByID(ctx, parentID)
+		if err != nil {
+			return errors.Wrap(err, "parent group not found")
 		}
 
 		// group kind must be the same all the way back to the top
-		if g.Flags != newParent.Flags {
+		if g.Kind() != parent.Kind() {
 			return ErrGroupKindMismatch
 		}
+
+		// the group must not be among the ancestors of its new parent
+		ancestors, err := m.Ancestors(ctx, parent.ID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to obtain ancestors of parent group: %s", parent.ID)
+		}
+
+		for _, ancestor := range ancestors {
+			if ancestor.ID == g.ID {
+				return ErrCircularGroup
+			}
+		}
 	}
 
-	// previous checks have passed, thus assingning a new parent ActorID
 	// NOTE: ParentID is used to rebuild parent-child connections after
 	// loading groups from the store
-	g.ParentID = newParent.ID
+	g.ParentID = parentID
 
 	// obtaining store
 	s, err := m.Store()
@@ -635,28 +1289,115 @@ func (m *Manager) SetParent(ctx context.Context, groupID, newParentID uuid.UUID)
 		return errors.Wrap(err, "failed to save group after changing new parent")
 	}
 
+	// following the store
+	m.Lock()
+	m.groups[g.ID] = g
+	m.Unlock()
+
+	m.parentChanged(ctx, g)
+
+	return nil
+}
+
+// Rename changes the key and the display name of a given group,
+// the new key must not be taken by any other group
+func (m *Manager) Rename(ctx context.Context, groupID uuid.UUID, newKey, newName string) (err error) {
+	g, err := m.GroupByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	oldKey := g.Key
+	g.Key = strings.TrimSpace(newKey)
+	g.DisplayName = strings.TrimSpace(newName)
+
+	if err = g.Validate(); err != nil {
+		return errors.Wrap(err, "renamed group validation failed")
+	}
+
+	// checking whether the new key is taken by some other group
+	// NOTE: the group may be returned along with ErrGroupNotFound
+	// if it has just been fetched from the store
+	if g.Key != oldKey {
+		existing, err := m.GroupByKey(ctx, g.Key)
+		if existing.ID != uuid.Nil && existing.ID != g.ID {
+			return ErrGroupKeyTaken
+		}
+
+		if err != nil && errors.Cause(err) != ErrGroupNotFound {
+			return err
+		}
+	}
+
+	// obtaining store
+	s, err := m.Store()
+	if err != nil {
+		return errors.Wrap(err, "failed to obtain group store")
+	}
+
+	// saving renamed group
+	g, err = s.UpsertGroup(ctx, g)
+	if err != nil {
+		return errors.Wrap(err, "failed to save group after renaming")
+	}
+
+	// following the store
+	m.Lock()
+	delete(m.keyMap, oldKey)
+	m.groups[g.ID] = g
+	m.keyMap[g.Key] = g.ID
+	m.Unlock()
+
 	return nil
 }
 
-// IsAsset tests whether a given asset belongs to a given group
+// IsAsset tests whether a given asset belongs to a given group,
+// the membership which has expired doesn't count
 func (m *Manager) IsAsset(ctx context.Context, groupID uuid.UUID, asset Asset) bool {
+	m.RLock()
+	defer m.RUnlock()
+
+	return m.isLinked(groupID, asset) && !m.isExpired(groupID, asset, time.Now())
+}
+
+// isLinked tells whether an asset is linked to a group, regardless
+// of whether the membership has expired
+// NOTE: must be called under the lock
+func (m *Manager) isLinked(groupID uuid.UUID, asset Asset) bool {
 	if groupID == uuid.Nil || asset.ID == uuid.Nil {
 		return false
 	}
 
-	m.RLock()
 	for _, gid := range m.assetGroups[asset] {
 		if gid == groupID {
-			m.RUnlock()
 			return true
 		}
 	}
-	m.RUnlock()
 
 	return false
 }
 
-// CreateRelation adding asset to a group
+// isExpired must be called under the lock
+func (m *Manager) isExpired(groupID uuid.UUID, asset Asset, now time.Time) bool {
+	expiresAt, ok := m.expiries[NewRelation(groupID, asset.Kind, asset.ID)]
+	return ok && !expiresAt.After(now)
+}
+
+// setExpiry keeps track of the expiration time of a given relation
+func (m *Manager) setExpiry(rel Relation) {
+	key := NewRelation(rel.GroupID, rel.Asset.Kind, rel.Asset.ID)
+
+	m.Lock()
+	if rel.ExpiresAt.IsZero() {
+		delete(m.expiries, key)
+	} else {
+		m.expiries[key] = rel.ExpiresAt
+	}
+	m.Unlock()
+}
+
+// CreateRelation adding asset to a group, the relation with an expiration
+// time makes a temporary membership, which also renews an expired one
 // NOTE: storing relation only if group has a store set is implicit and should at least
 // log/print about the occurrence
 func (m *Manager) CreateRelation(ctx context.Context, rel Relation) (err error) {
@@ -669,6 +1410,15 @@ func (m *Manager) CreateRelation(ctx context.Context, rel Relation) (err error)
 		return ErrNilAssetID
 	}
 
+	if rel.IsExpired(time.Now()) {
+		return ErrExpiredRelation
+	}
+
+	// an expired membership is renewed rather than duplicated
+	m.RLock()
+	renewal := m.isLinked(rel.GroupID, rel.Asset) && m.isExpired(rel.GroupID, rel.Asset, time.Now())
+	m.RUnlock()
+
 	s, err := m.Store()
 	if err != nil && err != ErrNilStore {
 		return errors.Wrap(err, "failed to obtain group store")
@@ -706,13 +1456,55 @@ func (m *Manager) CreateRelation(ctx context.Context, rel Relation) (err error)
 		)
 	}
 
-	if err = m.LinkAsset(ctx, rel.GroupID, rel.Asset); err != nil {
-		return err
+	if !renewal {
+		if err = m.LinkAsset(ctx, rel.GroupID, rel.Asset); err != nil {
+			return err
+		}
 	}
 
+	m.setExpiry(rel)
+	m.membershipChanged(ctx, rel)
+
 	return nil
 }
 
+// PurgeExpiredRelations deletes the relations which have expired by now,
+// returns the number of relations deleted from the store
+func (m *Manager) PurgeExpiredRelations(ctx context.Context) (n int64, err error) {
+	now := time.Now()
+
+	s, err := m.Store()
+	if err != nil && err != ErrNilStore {
+		return 0, errors.Wrap(err, "failed to obtain group store")
+	}
+
+	if s != nil {
+		if n, err = s.DeleteExpiredRelations(ctx, now); err != nil {
+			return 0, errors.Wrap(err, "failed to purge expired relations")
+		}
+	}
+
+	// following the store
+	m.RLock()
+	expired := make([]Relation, 0)
+	for rel, expiresAt := range m.expiries {
+		if !expiresAt.After(now) {
+			expired = append(expired, rel)
+		}
+	}
+	m.RUnlock()
+
+	for _, rel := range expired {
+		if err = m.UnlinkAsset(ctx, rel.GroupID, rel.Asset); err != nil {
+			return n, errors.Wrapf(err, "failed to unlink expired relation: group_id=%s, asset_id=%s", rel.GroupID, rel.Asset.ID)
+		}
+
+		m.membershipChanged(ctx, rel)
+	}
+
+	return n, nil
+}
+
 // DeleteRelation removes asset from a group
 func (m *Manager) DeleteRelation(ctx context.Context, rel Relation) (err error) {
 	_, err = m.GroupByID(ctx, rel.GroupID)
@@ -755,6 +1547,8 @@ func (m *Manager) DeleteRelation(ctx context.Context, rel Relation) (err error)
 		)
 	}
 
+	m.membershipChanged(ctx, rel)
+
 	return nil
 }
 
@@ -770,12 +1564,13 @@ func (m *Manager) LinkAsset(ctx context.Context, groupID uuid.UUID, asset Asset)
 		return ErrNilAssetID
 	}
 
-	if m.IsAsset(ctx, groupID, asset) {
+	m.Lock()
+
+	if m.isLinked(groupID, asset) {
+		m.Unlock()
 		return ErrAlreadyAsset
 	}
 
-	m.Lock()
-
 	// group ActorID -> asset IDs
 	if m.groupAssets[groupID] == nil {
 		m.groupAssets[groupID] = []Asset{asset}
@@ -811,7 +1606,7 @@ func (m *Manager) UnlinkAsset(ctx context.Context, groupID uuid.UUID, asset Asse
 
 	if m.groupAssets[groupID] != nil {
 		for i, _asset := range m.groupAssets[groupID] {
-			if _asset == _asset {
+			if _asset == asset {
 				m.groupAssets[groupID] = append(m.groupAssets[groupID][0:i], m.groupAssets[groupID][i+1:]...)
 				break
 			}
@@ -827,6 +1622,8 @@ func (m *Manager) UnlinkAsset(ctx context.Context, groupID uuid.UUID, asset Asse
 		}
 	}
 
+	delete(m.expiries, NewRelation(groupID, asset.Kind, asset.ID))
+
 	m.Unlock()
 
 	return nil
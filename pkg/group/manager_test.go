@@ -2,11 +2,14 @@ package group_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/agubarev/hometown/pkg/database"
 	"github.com/agubarev/hometown/pkg/group"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -104,3 +107,704 @@ func TestManager_Create(t *testing.T) {
 	a.False(m.IsAsset(ctx, r1.ID, group.NewAsset(group.AKUser, uid3)))
 	a.True(m.IsAsset(ctx, r2.ID, group.NewAsset(group.AKUser, uid3)))
 }
+
+// countingStore counts the group fetches which reach the store
+type countingStore struct {
+	group.Store
+	fetches int
+}
+
+func (s *countingStore) FetchGroupByID(ctx context.Context, groupID uuid.UUID) (group.Group, error) {
+	s.fetches++
+	return s.Store.FetchGroupByID(ctx, groupID)
+}
+
+func (s *countingStore) HasRelation(ctx context.Context, rel group.Relation) (bool, error) {
+	s.fetches++
+	return s.Store.HasRelation(ctx, rel)
+}
+
+func (s *countingStore) FetchGroupRelations(ctx context.Context, groupID uuid.UUID) ([]group.Relation, error) {
+	s.fetches++
+	return s.Store.FetchGroupRelations(ctx, groupID)
+}
+
+func TestManager_GroupsByAssetIDs(t *testing.T) {
+	a := assert.New(t)
+
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	ps, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(ps)
+
+	s := &countingStore{Store: ps}
+
+	m, err := group.NewManager(context.Background(), s)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// blank context
+	ctx := context.Background()
+
+	g1, err := m.Create(ctx, group.FGroup, uuid.Nil, "batch_group_1", "Batch Group 1")
+	a.NoError(err)
+
+	g2, err := m.Create(ctx, group.FGroup, g1.ID, "batch_group_2", "Batch Group 2 (sub-group of Batch Group 1)")
+	a.NoError(err)
+
+	r1, err := m.Create(ctx, group.FRole, uuid.Nil, "batch_role_1", "Batch Role 1")
+	a.NoError(err)
+
+	// even users belong to group 2, odd users to role 1
+	assets := make([]group.Asset, 100)
+	for i := range assets {
+		assets[i] = group.UserAsset(uuid.New())
+
+		if i%2 == 0 {
+			a.NoError(m.CreateRelation(ctx, group.NewRelation(g2.ID, group.AKUser, assets[i].ID)))
+		} else {
+			a.NoError(m.CreateRelation(ctx, group.NewRelation(r1.ID, group.AKUser, assets[i].ID)))
+		}
+	}
+
+	// resolving everything at once
+	s.fetches = 0
+	result, err := m.GroupsByAssetIDs(ctx, group.FAllGroups, assets)
+	a.NoError(err)
+	a.Len(result, len(assets))
+	a.Zero(s.fetches)
+
+	for i, asset := range assets {
+		// must include everything the per-asset lookup returns
+		for _, g := range m.GroupsByAssetID(ctx, group.FAllGroups, asset) {
+			a.Contains(result[asset.ID], g)
+		}
+
+		if i%2 == 0 {
+			// ancestor group must be included
+			a.Len(result[asset.ID], 2)
+			a.Contains(result[asset.ID], g1)
+			a.Contains(result[asset.ID], g2)
+		} else {
+			a.Len(result[asset.ID], 1)
+			a.Contains(result[asset.ID], r1)
+		}
+	}
+
+	// filtering by kind
+	result, err = m.GroupsByAssetIDs(ctx, group.FRole, assets)
+	a.NoError(err)
+	a.Empty(result[assets[0].ID])
+	a.Len(result[assets[1].ID], 1)
+}
+
+func TestManager_RelationExpiry(t *testing.T) {
+	a := assert.New(t)
+
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	s, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	m, err := group.NewManager(context.Background(), s)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// blank context
+	ctx := context.Background()
+
+	g1, err := m.Create(ctx, group.FGroup, uuid.Nil, "expiry_group_1", "Expiry Group 1")
+	a.NoError(err)
+
+	g2, err := m.Create(ctx, group.FGroup, g1.ID, "expiry_group_2", "Expiry Group 2 (sub-group of Expiry Group 1)")
+	a.NoError(err)
+
+	temporary := group.UserAsset(uuid.New())
+	permanent := group.UserAsset(uuid.New())
+
+	// expiration time must be in the future
+	a.Equal(group.ErrExpiredRelation, m.CreateRelation(ctx, group.NewRelationUntil(g2.ID, group.AKUser, temporary.ID, time.Now().Add(-time.Second))))
+
+	a.NoError(m.CreateRelation(ctx, group.NewRelationUntil(g2.ID, group.AKUser, temporary.ID, time.Now().Add(50*time.Millisecond))))
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(g2.ID, group.AKUser, permanent.ID)))
+
+	a.True(m.IsAsset(ctx, g2.ID, temporary))
+	a.Len(m.GroupsByAssetID(ctx, group.FAllGroups, temporary), 1)
+
+	// expired membership no longer counts
+	time.Sleep(60 * time.Millisecond)
+
+	a.False(m.IsAsset(ctx, g2.ID, temporary))
+	a.True(m.IsAsset(ctx, g2.ID, permanent))
+	a.Empty(m.GroupsByAssetID(ctx, group.FAllGroups, temporary))
+
+	result, err := m.GroupsByAssetIDs(ctx, group.FAllGroups, []group.Asset{temporary, permanent})
+	a.NoError(err)
+	a.Empty(result[temporary.ID])
+	a.Len(result[permanent.ID], 2)
+
+	// renewing
+	a.NoError(m.CreateRelation(ctx, group.NewRelationUntil(g2.ID, group.AKUser, temporary.ID, time.Now().Add(50*time.Millisecond))))
+	a.True(m.IsAsset(ctx, g2.ID, temporary))
+	a.Len(m.GroupsByAssetID(ctx, group.FAllGroups, temporary), 1)
+
+	// purging
+	time.Sleep(60 * time.Millisecond)
+
+	n, err := m.PurgeExpiredRelations(ctx)
+	a.NoError(err)
+	a.Equal(int64(1), n)
+
+	ok, err := s.HasRelation(ctx, group.NewRelation(g2.ID, group.AKUser, temporary.ID))
+	a.NoError(err)
+	a.False(ok)
+
+	ok, err = s.HasRelation(ctx, group.NewRelation(g2.ID, group.AKUser, permanent.ID))
+	a.NoError(err)
+	a.True(ok)
+
+	// the purged membership may be created anew
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(g2.ID, group.AKUser, temporary.ID)))
+	a.True(m.IsAsset(ctx, g2.ID, temporary))
+
+	// a failing delete hook leaves the group and its relations intact
+	g3, err := m.Create(ctx, group.FGroup, uuid.Nil, "expiry_group_3", "Expiry Group 3")
+	a.NoError(err)
+	a.NoError(m.CreateRelation(ctx, group.NewRelationUntil(g3.ID, group.AKUser, temporary.ID, time.Now().Add(50*time.Millisecond))))
+
+	failing := true
+	m.OnDelete(func(ctx context.Context, g group.Group) error {
+		if failing && g.ID == g3.ID {
+			return errors.New("hook failure")
+		}
+
+		return nil
+	})
+
+	a.Error(m.DeleteGroup(ctx, g3.ID))
+	a.True(m.IsAsset(ctx, g3.ID, temporary))
+
+	// expiration of the deleted group's relations is forgotten along with it
+	failing = false
+	a.NoError(m.DeleteGroup(ctx, g3.ID))
+
+	time.Sleep(60 * time.Millisecond)
+
+	n, err = m.PurgeExpiredRelations(ctx)
+	a.NoError(err)
+	a.Equal(int64(0), n)
+}
+
+func TestManager_AncestorsAndDescendants(t *testing.T) {
+	a := assert.New(t)
+
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	s, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	m, err := group.NewManager(context.Background(), s)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// blank context
+	ctx := context.Background()
+
+	root, err := m.Create(ctx, group.FGroup, uuid.Nil, "lineage_root", "Lineage Root")
+	a.NoError(err)
+
+	mid1, err := m.Create(ctx, group.FGroup, root.ID, "lineage_mid_1", "Lineage Mid 1")
+	a.NoError(err)
+
+	mid2, err := m.Create(ctx, group.FGroup, root.ID, "lineage_mid_2", "Lineage Mid 2")
+	a.NoError(err)
+
+	leaf1, err := m.Create(ctx, group.FGroup, mid1.ID, "lineage_leaf_1", "Lineage Leaf 1")
+	a.NoError(err)
+
+	leaf2, err := m.Create(ctx, group.FGroup, mid1.ID, "lineage_leaf_2", "Lineage Leaf 2")
+	a.NoError(err)
+
+	// ancestors, from the nearest one
+	gs, err := m.Ancestors(ctx, leaf1.ID)
+	a.NoError(err)
+	a.Equal([]group.Group{mid1, root}, gs)
+
+	gs, err = m.Ancestors(ctx, mid2.ID)
+	a.NoError(err)
+	a.Equal([]group.Group{root}, gs)
+
+	gs, err = m.Ancestors(ctx, root.ID)
+	a.NoError(err)
+	a.Empty(gs)
+
+	// descendants, level by level
+	gs, err = m.Descendants(ctx, root.ID)
+	a.NoError(err)
+	if a.Len(gs, 4) {
+		a.ElementsMatch([]group.Group{mid1, mid2}, gs[:2])
+		a.ElementsMatch([]group.Group{leaf1, leaf2}, gs[2:])
+	}
+
+	gs, err = m.Descendants(ctx, mid1.ID)
+	a.NoError(err)
+	a.ElementsMatch([]group.Group{leaf1, leaf2}, gs)
+
+	gs, err = m.Descendants(ctx, leaf2.ID)
+	a.NoError(err)
+	a.Empty(gs)
+
+	_, err = m.Ancestors(ctx, uuid.New())
+	a.Error(err)
+
+	// circuited parenting must not loop forever
+	// NOTE: such groups are only put into the registry
+	c1 := group.Group{ID: uuid.New(), Key: "circuit_1", DisplayName: "Circuit 1", Flags: group.FGroup}
+	c2 := group.Group{ID: uuid.New(), Key: "circuit_2", DisplayName: "Circuit 2", Flags: group.FGroup}
+	c1.ParentID, c2.ParentID = c2.ID, c1.ID
+
+	a.NoError(m.Put(ctx, c1))
+	a.NoError(m.Put(ctx, c2))
+
+	_, err = m.Ancestors(ctx, c1.ID)
+	a.Equal(group.ErrCircuitedParent, errors.Cause(err))
+
+	_, err = m.Descendants(ctx, c1.ID)
+	a.Equal(group.ErrCircuitedParent, errors.Cause(err))
+}
+
+func TestManager_SetGroupParent(t *testing.T) {
+	a := assert.New(t)
+
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	s, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	m, err := group.NewManager(context.Background(), s)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// blank context
+	ctx := context.Background()
+
+	// parent must exist
+	_, err = m.Create(ctx, group.FGroup, uuid.New(), "orphan_group", "Orphan Group")
+	a.Equal(group.ErrGroupNotFound, errors.Cause(err))
+
+	// A -> B -> C
+	ga, err := m.Create(ctx, group.FGroup, uuid.Nil, "nested_group_a", "Nested Group A")
+	a.NoError(err)
+
+	gb, err := m.Create(ctx, group.FGroup, ga.ID, "nested_group_b", "Nested Group B")
+	a.NoError(err)
+
+	gc, err := m.Create(ctx, group.FGroup, gb.ID, "nested_group_c", "Nested Group C")
+	a.NoError(err)
+
+	// making A descend from C or itself
+	a.Equal(group.ErrCircularGroup, m.SetGroupParent(ctx, ga.ID, gc.ID))
+	a.Equal(group.ErrCircularGroup, m.SetGroupParent(ctx, ga.ID, gb.ID))
+	a.Equal(group.ErrCircularGroup, m.SetGroupParent(ctx, ga.ID, ga.ID))
+
+	// nothing has changed
+	gs, err := m.Ancestors(ctx, gc.ID)
+	a.NoError(err)
+	a.Equal([]group.Group{gb, ga}, gs)
+
+	ga, err = m.GroupByID(ctx, ga.ID)
+	a.NoError(err)
+	a.Equal(uuid.Nil, ga.ParentID)
+
+	// moving C right under A
+	a.NoError(m.SetGroupParent(ctx, gc.ID, ga.ID))
+
+	gs, err = m.Ancestors(ctx, gc.ID)
+	a.NoError(err)
+	a.Equal([]group.Group{ga}, gs)
+
+	fetched, err := s.FetchGroupByID(ctx, gc.ID)
+	a.NoError(err)
+	a.Equal(ga.ID, fetched.ParentID)
+
+	// now B may become a child of C
+	a.NoError(m.SetGroupParent(ctx, gb.ID, gc.ID))
+
+	// and C a top-level group, along with B
+	a.NoError(m.SetGroupParent(ctx, gc.ID, uuid.Nil))
+
+	gs, err = m.Ancestors(ctx, gb.ID)
+	a.NoError(err)
+	if a.Len(gs, 1) {
+		a.Equal(gc.ID, gs[0].ID)
+	}
+
+	// only the kinds must match, not the rest of the flags
+	gd, err := m.Create(ctx, group.FGroup|group.FDefault, uuid.Nil, "default_group_d", "Default Group D")
+	a.NoError(err)
+	a.NoError(m.SetGroupParent(ctx, gd.ID, gc.ID))
+
+	role, err := m.Create(ctx, group.FRole, uuid.Nil, "nested_role", "Nested Role")
+	a.NoError(err)
+	a.Equal(group.ErrGroupKindMismatch, m.SetGroupParent(ctx, role.ID, gc.ID))
+}
+
+func TestManager_MembersOf(t *testing.T) {
+	a := assert.New(t)
+
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	s, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	m, err := group.NewManager(context.Background(), s)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// blank context
+	ctx := context.Background()
+
+	parent, err := m.Create(ctx, group.FGroup, uuid.Nil, "members_parent", "Members Parent")
+	a.NoError(err)
+
+	child, err := m.Create(ctx, group.FGroup, parent.ID, "members_child", "Members Child")
+	a.NoError(err)
+
+	grandchild, err := m.Create(ctx, group.FGroup, child.ID, "members_grandchild", "Members Grandchild")
+	a.NoError(err)
+
+	both := uuid.New()
+	parentOnly := uuid.New()
+	childOnly := uuid.New()
+	grandchildOnly := uuid.New()
+	removed := uuid.New()
+
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(parent.ID, group.AKUser, both)))
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(parent.ID, group.AKUser, parentOnly)))
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(child.ID, group.AKUser, both)))
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(child.ID, group.AKUser, removed)))
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(child.ID, group.AKUser, childOnly)))
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(grandchild.ID, group.AKUser, grandchildOnly)))
+	a.NoError(m.DeleteRelation(ctx, group.NewRelation(child.ID, group.AKUser, removed)))
+
+	// direct members only
+	ids, err := m.MembersOf(ctx, parent.ID, false)
+	a.NoError(err)
+	a.ElementsMatch([]uuid.UUID{both, parentOnly}, ids)
+
+	ids, err = m.MembersOf(ctx, child.ID, false)
+	a.NoError(err)
+	a.ElementsMatch([]uuid.UUID{both, childOnly}, ids)
+
+	// including nested groups, each member once
+	ids, err = m.MembersOf(ctx, parent.ID, true)
+	a.NoError(err)
+	a.ElementsMatch([]uuid.UUID{both, parentOnly, childOnly, grandchildOnly}, ids)
+
+	ids, err = m.MembersOf(ctx, grandchild.ID, true)
+	a.NoError(err)
+	a.Equal([]uuid.UUID{grandchildOnly}, ids)
+
+	_, err = m.MembersOf(ctx, uuid.New(), true)
+	a.Equal(group.ErrGroupNotFound, errors.Cause(err))
+}
+
+func TestManager_SetupRoles(t *testing.T) {
+	a := assert.New(t)
+
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	s, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	m, err := group.NewManager(context.Background(), s)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// blank context
+	ctx := context.Background()
+
+	// parent defined after its child
+	_, err = m.SetupRoles(ctx, []group.RoleTemplate{
+		{Key: "role_child", Name: "Child", ParentKey: "role_parent"},
+		{Key: "role_parent", Name: "Parent"},
+	})
+	a.Equal(group.ErrUnknownParentRole, errors.Cause(err))
+
+	// nothing has been created
+	_, err = m.GroupByKey(ctx, "role_parent")
+	a.Equal(group.ErrGroupNotFound, errors.Cause(err))
+
+	roles, err := m.SetupRoles(ctx, []group.RoleTemplate{
+		{Key: "role_viewer", Name: "Viewer"},
+		{Key: "role_editor", Name: "Editor", ParentKey: "role_viewer"},
+		{Key: "role_admin", Name: "Admin", ParentKey: "role_editor"},
+		{Key: "role_auditor", Name: "Auditor", ParentKey: "role_viewer"},
+	})
+	a.NoError(err)
+	a.Len(roles, 4)
+
+	viewer, editor, admin, auditor := roles[0], roles[1], roles[2], roles[3]
+	for _, g := range roles {
+		a.True(g.IsRole())
+	}
+
+	a.Equal(uuid.Nil, viewer.ParentID)
+	a.Equal(viewer.ID, editor.ParentID)
+	a.Equal(editor.ID, admin.ParentID)
+	a.Equal(viewer.ID, auditor.ParentID)
+
+	ancestors, err := m.Ancestors(ctx, admin.ID)
+	a.NoError(err)
+	a.Len(ancestors, 2)
+	a.Equal(editor.ID, ancestors[0].ID)
+	a.Equal(viewer.ID, ancestors[1].ID)
+
+	// falling back to the default roles
+	roles, err = m.SetupRoles(ctx, nil)
+	a.NoError(err)
+	a.Len(roles, len(group.DefaultRoles))
+	a.Equal("superuser", roles[2].Key)
+	a.Equal(roles[1].ID, roles[2].ParentID)
+}
+
+func TestManager_Rename(t *testing.T) {
+	a := assert.New(t)
+
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	s, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	m, err := group.NewManager(context.Background(), s)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// blank context
+	ctx := context.Background()
+
+	g1, err := m.Create(ctx, group.FGroup, uuid.Nil, "rename_1", "Rename 1")
+	a.NoError(err)
+
+	g2, err := m.Create(ctx, group.FGroup, uuid.Nil, "rename_2", "Rename 2")
+	a.NoError(err)
+
+	// taken by another group
+	a.Equal(group.ErrGroupKeyTaken, m.Rename(ctx, g1.ID, g2.Key, "Renamed"))
+
+	// empty key
+	a.Equal(group.ErrEmptyKey, errors.Cause(m.Rename(ctx, g1.ID, " ", "Renamed")))
+
+	// nothing has changed
+	fg, err := m.GroupByID(ctx, g1.ID)
+	a.NoError(err)
+	a.Equal("rename_1", fg.Key)
+	a.Equal("Rename 1", fg.DisplayName)
+
+	// free key
+	a.NoError(m.Rename(ctx, g1.ID, "renamed_1", "Renamed 1"))
+
+	fg, err = m.GroupByID(ctx, g1.ID)
+	a.NoError(err)
+	a.Equal("renamed_1", fg.Key)
+	a.Equal("Renamed 1", fg.DisplayName)
+
+	fg, err = m.GroupByKey(ctx, "renamed_1")
+	a.NoError(err)
+	a.Equal(g1.ID, fg.ID)
+
+	// the old key is free now
+	_, err = m.GroupByKey(ctx, "rename_1")
+	a.Error(err)
+
+	g3, err := m.Create(ctx, group.FGroup, uuid.Nil, "rename_1", "Rename 1 again")
+	a.NoError(err)
+	a.NotEqual(g1.ID, g3.ID)
+
+	// keeping the key while changing the name only
+	a.NoError(m.Rename(ctx, g2.ID, g2.Key, "Rename 2 changed"))
+
+	fg, err = m.GroupByKey(ctx, g2.Key)
+	a.NoError(err)
+	a.Equal("Rename 2 changed", fg.DisplayName)
+
+	// the store follows
+	stored, err := s.FetchGroupByID(ctx, g1.ID)
+	a.NoError(err)
+	a.Equal("renamed_1", stored.Key)
+}
+
+func TestManager_ListAndSearch(t *testing.T) {
+	a := assert.New(t)
+
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	s, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	m, err := group.NewManager(context.Background(), s)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// blank context
+	ctx := context.Background()
+
+	// whatever has been there before, i.e. default groups
+	groupsBefore, err := m.List(ctx, group.FGroup, 0, 0)
+	a.NoError(err)
+
+	rolesBefore, err := m.List(ctx, group.FRole, 0, 0)
+	a.NoError(err)
+
+	for i := 0; i < 8; i++ {
+		_, err = m.Create(ctx, group.FGroup, uuid.Nil, fmt.Sprintf("paged_group_%02d", i), fmt.Sprintf("Paged Group %02d", i))
+		a.NoError(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		_, err = m.Create(ctx, group.FRole, uuid.Nil, fmt.Sprintf("paged_role_%02d", i), fmt.Sprintf("Paged Role %02d", i))
+		a.NoError(err)
+	}
+
+	// paging through groups, every group is listed exactly once
+	total := len(groupsBefore) + 8
+	seen := make(map[uuid.UUID]bool)
+
+	for offset := 0; offset < total; offset += 3 {
+		gs, err := m.List(ctx, group.FGroup, 3, offset)
+		a.NoError(err)
+		a.NotEmpty(gs)
+		a.True(len(gs) <= 3)
+
+		for _, g := range gs {
+			a.True(g.IsGroup())
+			a.False(seen[g.ID])
+			seen[g.ID] = true
+		}
+	}
+	a.Len(seen, total)
+
+	// past the end
+	gs, err := m.List(ctx, group.FGroup, 3, total)
+	a.NoError(err)
+	a.Empty(gs)
+
+	// roles only
+	gs, err = m.List(ctx, group.FRole, 0, 0)
+	a.NoError(err)
+	a.Len(gs, len(rolesBefore)+4)
+
+	for _, g := range gs {
+		a.True(g.IsRole())
+	}
+
+	// both kinds
+	gs, err = m.List(ctx, group.FGroup|group.FRole, 0, 0)
+	a.NoError(err)
+	a.Len(gs, len(groupsBefore)+len(rolesBefore)+12)
+
+	// searching is case-insensitive
+	gs, err = m.SearchByName(ctx, "pAgEd", 0)
+	a.NoError(err)
+	a.Len(gs, 12)
+
+	gs, err = m.SearchByName(ctx, "paged role", 0)
+	a.NoError(err)
+	a.Len(gs, 4)
+
+	for _, g := range gs {
+		a.True(g.IsRole())
+	}
+
+	// limited and ordered by name
+	gs, err = m.SearchByName(ctx, "PAGED GROUP", 5)
+	a.NoError(err)
+	a.Len(gs, 5)
+	a.Equal("Paged Group 00", gs[0].DisplayName)
+	a.Equal("Paged Group 04", gs[4].DisplayName)
+
+	// wildcards are taken literally
+	gs, err = m.SearchByName(ctx, "%", 0)
+	a.NoError(err)
+	a.Empty(gs)
+
+	_, err = m.SearchByName(ctx, " ", 0)
+	a.Equal(group.ErrEmptyGroupName, err)
+}
+
+func TestManager_IsMemberTransitive(t *testing.T) {
+	a := assert.New(t)
+
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	s, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	m, err := group.NewManager(context.Background(), s)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// blank context
+	ctx := context.Background()
+
+	parent, err := m.Create(ctx, group.FGroup, uuid.Nil, "transitive_parent", "Transitive Parent")
+	a.NoError(err)
+
+	child, err := m.Create(ctx, group.FGroup, parent.ID, "transitive_child", "Transitive Child")
+	a.NoError(err)
+
+	unrelated, err := m.Create(ctx, group.FGroup, uuid.Nil, "transitive_unrelated", "Transitive Unrelated")
+	a.NoError(err)
+
+	direct := uuid.New()
+	nested := uuid.New()
+	outsider := uuid.New()
+
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(parent.ID, group.AKUser, direct)))
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(child.ID, group.AKUser, nested)))
+	a.NoError(m.CreateRelation(ctx, group.NewRelation(unrelated.ID, group.AKUser, outsider)))
+
+	// direct membership
+	ok, err := m.IsMemberTransitive(ctx, parent.ID, direct)
+	a.NoError(err)
+	a.True(ok)
+
+	// member of a child group belongs to the parent
+	ok, err = m.IsMemberTransitive(ctx, parent.ID, nested)
+	a.NoError(err)
+	a.True(ok)
+
+	// but not the other way around
+	ok, err = m.IsMemberTransitive(ctx, child.ID, direct)
+	a.NoError(err)
+	a.False(ok)
+
+	// unrelated membership
+	ok, err = m.IsMemberTransitive(ctx, parent.ID, outsider)
+	a.NoError(err)
+	a.False(ok)
+
+	_, err = m.IsMemberTransitive(ctx, uuid.New(), direct)
+	a.Equal(group.ErrGroupNotFound, errors.Cause(err))
+
+	_, err = m.IsMemberTransitive(ctx, parent.ID, uuid.Nil)
+	a.Equal(group.ErrNilAssetID, err)
+}
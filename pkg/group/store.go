@@ -2,6 +2,7 @@ package group
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -14,10 +15,13 @@ type Store interface {
 	FetchGroupByKey(ctx context.Context, key string) (g Group, err error)
 	FetchGroupByName(ctx context.Context, name string) (g Group, err error)
 	FetchGroupsByName(ctx context.Context, isPartial bool, name string) (gs []Group, err error)
+	ListGroups(ctx context.Context, mask Flags, limit, offset int) (gs []Group, err error)
+	SearchGroupsByName(ctx context.Context, substr string, limit int) (gs []Group, err error)
 	HasRelation(ctx context.Context, rel Relation) (bool, error)
 	FetchAllGroups(ctx context.Context) (gs []Group, err error)
 	FetchAllRelations(ctx context.Context) ([]Relation, error)
 	FetchGroupRelations(ctx context.Context, groupID uuid.UUID) ([]Relation, error)
 	DeleteByID(ctx context.Context, groupID uuid.UUID) error
 	DeleteRelation(ctx context.Context, rel Relation) error
+	DeleteExpiredRelations(ctx context.Context, now time.Time) (n int64, err error)
 }
@@ -2,6 +2,7 @@ package group
 
 import (
 	"context"
+	"time"
 
 	"github.com/gocql/gocql"
 	"github.com/google/uuid"
@@ -35,6 +36,14 @@ func (c CassandraStore) FetchGroupsByName(ctx context.Context, isPartial bool, n
 	panic("implement me")
 }
 
+func (c CassandraStore) ListGroups(ctx context.Context, mask Flags, limit, offset int) (gs []Group, err error) {
+	panic("implement me")
+}
+
+func (c CassandraStore) SearchGroupsByName(ctx context.Context, substr string, limit int) (gs []Group, err error) {
+	panic("implement me")
+}
+
 func (c CassandraStore) HasRelation(ctx context.Context, rel Relation) (bool, error) {
 	panic("implement me")
 }
@@ -58,3 +67,7 @@ func (c CassandraStore) DeleteByID(ctx context.Context, groupID uuid.UUID) error
 func (c CassandraStore) DeleteRelation(ctx context.Context, rel Relation) error {
 	panic("implement me")
 }
+
+func (c CassandraStore) DeleteExpiredRelations(ctx context.Context, now time.Time) (n int64, err error) {
+	panic("implement me")
+}
@@ -2,6 +2,8 @@ package group
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx"
@@ -56,12 +58,27 @@ func (s *PostgreSQLStore) manyGroups(ctx context.Context, q string, args ...inte
 	return gs, nil
 }
 
+// nullTime turns zero time into NULL
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
 func (s *PostgreSQLStore) oneRelation(ctx context.Context, q string, args ...interface{}) (rel Relation, err error) {
+	var expiresAt *time.Time
+
 	err = s.db.QueryRowEx(ctx, q, nil, args...).
-		Scan(&rel.GroupID, &rel.Asset.Kind, &rel.Asset.ID)
+		Scan(&rel.GroupID, &rel.Asset.Kind, &rel.Asset.ID, &expiresAt)
 
 	switch err {
 	case nil:
+		if expiresAt != nil {
+			rel.ExpiresAt = *expiresAt
+		}
+
 		return rel, nil
 	case pgx.ErrNoRows:
 		return rel, ErrRelationNotFound
@@ -81,11 +98,16 @@ func (s *PostgreSQLStore) manyRelations(ctx context.Context, q string, args ...i
 
 	for rows.Next() {
 		var rel Relation
+		var expiresAt *time.Time
 
-		if err = rows.Scan(&rel.GroupID, &rel.Asset.Kind, &rel.Asset.ID); err != nil {
+		if err = rows.Scan(&rel.GroupID, &rel.Asset.Kind, &rel.Asset.ID, &expiresAt); err != nil {
 			return relations, errors.Wrap(err, "failed to scan relations")
 		}
 
+		if expiresAt != nil {
+			rel.ExpiresAt = *expiresAt
+		}
+
 		relations = append(relations, rel)
 	}
 
@@ -130,18 +152,19 @@ func (s *PostgreSQLStore) CreateRelation(ctx context.Context, rel Relation) (err
 		return ErrNilAssetID
 	}
 
+	// NOTE: creating an existing relation only renews its expiration time
 	q := `
-	INSERT INTO group_assets(group_id, asset_kind, asset_id) 
-	VALUES($1, $2, $3)
+	INSERT INTO group_assets(group_id, asset_kind, asset_id, expires_at) 
+	VALUES($1, $2, $3, $4)
 	ON CONFLICT ON CONSTRAINT group_assets_pk 
-	DO NOTHING
+	DO UPDATE SET expires_at = EXCLUDED.expires_at
 	`
 
 	_, err = s.db.ExecEx(
 		ctx,
 		q,
 		nil,
-		rel.GroupID, rel.Asset.Kind, rel.Asset.ID,
+		rel.GroupID, rel.Asset.Kind, rel.Asset.ID, nullTime(rel.ExpiresAt),
 	)
 
 	if err != nil {
@@ -171,21 +194,62 @@ func (s *PostgreSQLStore) FetchGroupsByName(ctx context.Context, isPartial bool,
 	return s.manyGroups(ctx, `SELECT id, parent_id, name, key, flags FROM "group" WHERE name = $1`, name)
 }
 
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *PostgreSQLStore) ListGroups(ctx context.Context, mask Flags, limit, offset int) (gs []Group, err error) {
+	q := `
+	SELECT id, parent_id, name, key, flags 
+	FROM "group" 
+	WHERE flags & $1 <> 0
+	ORDER BY key, id
+	LIMIT $2 OFFSET $3`
+
+	// NULL limit is the same as no limit at all
+	var lim interface{}
+	if limit > 0 {
+		lim = limit
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.manyGroups(ctx, q, mask, lim, offset)
+}
+
+func (s *PostgreSQLStore) SearchGroupsByName(ctx context.Context, substr string, limit int) (gs []Group, err error) {
+	q := `
+	SELECT id, parent_id, name, key, flags 
+	FROM "group" 
+	WHERE name ILIKE '%' || $1 || '%'
+	ORDER BY name, id
+	LIMIT $2`
+
+	// NULL limit is the same as no limit at all
+	var lim interface{}
+	if limit > 0 {
+		lim = limit
+	}
+
+	return s.manyGroups(ctx, q, likeEscaper.Replace(substr), lim)
+}
+
 func (s *PostgreSQLStore) FetchAllGroups(ctx context.Context) (gs []Group, err error) {
 	return s.manyGroups(ctx, `SELECT id, parent_id, name, key, flags FROM "group"`)
 }
 
 func (s *PostgreSQLStore) FetchAllRelations(ctx context.Context) (relations []Relation, err error) {
-	return s.manyRelations(ctx, `SELECT group_id, asset_kind, asset_id FROM group_assets`)
+	return s.manyRelations(ctx, `SELECT group_id, asset_kind, asset_id, expires_at FROM group_assets`)
 }
 
 func (s *PostgreSQLStore) FetchGroupRelations(ctx context.Context, groupID uuid.UUID) ([]Relation, error) {
-	return s.manyRelations(ctx, `SELECT group_id, asset_kind, asset_id FROM group_assets WHERE group_id = $1`, groupID)
+	return s.manyRelations(ctx, `SELECT group_id, asset_kind, asset_id, expires_at FROM group_assets WHERE group_id = $1`, groupID)
 }
 
 func (s *PostgreSQLStore) HasRelation(ctx context.Context, rel Relation) (bool, error) {
 	q := `
-	SELECT group_id, asset_kind, asset_id 
+	SELECT group_id, asset_kind, asset_id, expires_at 
 	FROM group_assets
 	WHERE 
 		group_id		= $1 
@@ -202,7 +266,7 @@ func (s *PostgreSQLStore) HasRelation(ctx context.Context, rel Relation) (bool,
 		return false, err
 	}
 
-	return rel == _rel, nil
+	return rel.GroupID == _rel.GroupID && rel.Asset == _rel.Asset, nil
 }
 
 func (s *PostgreSQLStore) DeleteByID(ctx context.Context, groupID uuid.UUID) (err error) {
@@ -229,3 +293,18 @@ func (s *PostgreSQLStore) DeleteRelation(ctx context.Context, rel Relation) (err
 
 	return nil
 }
+
+func (s *PostgreSQLStore) DeleteExpiredRelations(ctx context.Context, now time.Time) (n int64, err error) {
+	q := `
+	DELETE FROM group_assets 
+	WHERE 
+		expires_at IS NOT NULL 
+		AND expires_at <= $1`
+
+	cmd, err := s.db.ExecEx(ctx, q, nil, now)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete expired group relations")
+	}
+
+	return cmd.RowsAffected(), nil
+}
@@ -0,0 +1,62 @@
+package accesspolicy
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditAction describes what has been done to a policy
+type AuditAction string
+
+const (
+	AADeletePolicy AuditAction = "delete_policy"
+	AAGrant        AuditAction = "grant"
+	AARevoke       AuditAction = "revoke"
+	AADeny         AuditAction = "deny"
+)
+
+// AuditRecord is a record of rights granted to or revoked from an actor,
+// or of a deleted policy, in which case the grantor is the deleting actor
+// NOTE: Timestamp is the moment the change has been made, not when it's been stored
+type AuditRecord struct {
+	Timestamp time.Time   `json:"timestamp"`
+	PolicyID  uuid.UUID   `json:"policy_id"`
+	Grantor   Actor       `json:"grantor"`
+	Grantee   Actor       `json:"grantee"`
+	Action    AuditAction `json:"action"`
+	Rights    Right       `json:"rights"`
+}
+
+// AuditSink receives the records of every grant, revocation and policy
+// deletion, but only once the changes have been stored
+type AuditSink interface {
+	Record(ctx context.Context, rec AuditRecord)
+}
+
+// SetAuditSink sets an optional receiver of grant, revocation and deletion records
+func (m *Manager) SetAuditSink(sink AuditSink) {
+	m.Lock()
+	m.auditSink = sink
+	m.Unlock()
+}
+
+// flushAudit passes the records of stored roster changes to the audit sink
+func (m *Manager) flushAudit(ctx context.Context, records []AuditRecord) {
+	if len(records) == 0 {
+		return
+	}
+
+	m.RLock()
+	sink := m.auditSink
+	m.RUnlock()
+
+	if sink == nil {
+		return
+	}
+
+	for _, rec := range records {
+		sink.Record(ctx, rec)
+	}
+}
@@ -0,0 +1,157 @@
+package accesspolicy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/agubarev/hometown/pkg/group"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// AccessCheck is a single inquiry of a batch rights check
+type AccessCheck struct {
+	Actor  Actor `json:"actor"`
+	Rights Right `json:"rights"`
+}
+
+// Grant is a single grant of a batch
+type Grant struct {
+	Grantee Actor `json:"grantee"`
+	Rights  Right `json:"rights"`
+}
+
+type groupMemoKey struct {
+	policyID uuid.UUID
+	groupID  uuid.UUID
+}
+
+type groupMemoEntry struct {
+	granted Right
+	denied  Right
+}
+
+// groupMemo holds the group rights resolved within a single batch,
+// so that the group hierarchy isn't walked again for every actor
+type groupMemo struct {
+	entries map[groupMemoKey]groupMemoEntry
+	sync.RWMutex
+}
+
+func (gm *groupMemo) get(pid, groupID uuid.UUID) (groupMemoEntry, bool) {
+	gm.RLock()
+	e, ok := gm.entries[groupMemoKey{pid, groupID}]
+	gm.RUnlock()
+
+	return e, ok
+}
+
+func (gm *groupMemo) put(pid, groupID uuid.UUID, granted, denied Right) {
+	gm.Lock()
+	gm.entries[groupMemoKey{pid, groupID}] = groupMemoEntry{granted: granted, denied: denied}
+	gm.Unlock()
+}
+
+type groupMemoContextKey struct{}
+
+func withGroupMemo(ctx context.Context) context.Context {
+	if groupMemoFromContext(ctx) != nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, groupMemoContextKey{}, &groupMemo{
+		entries: make(map[groupMemoKey]groupMemoEntry),
+	})
+}
+
+func groupMemoFromContext(ctx context.Context) *groupMemo {
+	gm, _ := ctx.Value(groupMemoContextKey{}).(*groupMemo)
+	return gm
+}
+
+// HasRightsBatch performs multiple rights checks against a single policy,
+// the rights of every distinct actor are resolved only once, as well as
+// the rights of the groups along the way
+// NOTE: results are returned in the same order as the checks,
+// fails closed the same way HasRights does
+func (m *Manager) HasRightsBatch(ctx context.Context, pid uuid.UUID, checks []AccessCheck) []bool {
+	results := make([]bool, len(checks))
+
+	if pid == uuid.Nil {
+		m.reportError(ctx, ErrNilPolicyID)
+		return results
+	}
+
+	ctx = withGroupMemo(ctx)
+
+	resolved := make(map[Actor]Right, len(checks))
+	for i, c := range checks {
+		access, ok := resolved[c.Actor]
+		if !ok {
+			var err error
+
+			if access, err = m.actorAccess(ctx, pid, c.Actor); err != nil {
+				m.reportError(ctx, err)
+				access = APNoAccess
+			}
+
+			resolved[c.Actor] = access
+		}
+
+		results[i] = (access & c.Rights) == c.Rights
+		m.recorder().IncHasRights(results[i])
+	}
+
+	return results
+}
+
+// GrantAccessBatch grants rights to several grantees on a single policy
+// by the same grantor, either all of them or none: should any grant fail,
+// only the changes of this batch are rolled back, whereas the changes
+// made before it remain pending
+// NOTE: changes are not persisted unless the policy is updated
+func (m *Manager) GrantAccessBatch(ctx context.Context, pid uuid.UUID, grantor Actor, grants []Grant) (err error) {
+	r, err := m.RosterByPolicyID(ctx, pid)
+	if err != nil {
+		return errors.Wrap(err, "failed to obtain rights roster")
+	}
+
+	r.pushBackup()
+
+	for _, g := range grants {
+		if err = m.GrantAccess(ctx, pid, grantor, g.Grantee, g.Rights); err != nil {
+			r.popBackup(true)
+			m.cache.clear()
+
+			return errors.Wrapf(err, "failed to grant access: kind=%s, id=%s", g.Grantee.Kind, g.Grantee.ID)
+		}
+	}
+
+	r.popBackup(false)
+
+	return nil
+}
+
+// actorAccess calculates the resulting rights of any kind of actor
+func (m *Manager) actorAccess(ctx context.Context, pid uuid.UUID, actor Actor) (Right, error) {
+	switch actor.Kind {
+	case AKEveryone:
+		return m.publicAccess(ctx, pid)
+	case AKUser:
+		return m.userAccess(ctx, pid, actor.ID)
+	case AKRoleGroup, AKGroup:
+		// the actor kind must match the kind of the group itself
+		kind := group.FGroup
+		if actor.Kind == AKRoleGroup {
+			kind = group.FRole
+		}
+
+		if !m.isGroupKind(ctx, actor.ID, kind) {
+			return APNoAccess, nil
+		}
+
+		return m.groupAccess(ctx, pid, actor.ID)
+	}
+
+	return APNoAccess, errors.Wrapf(ErrUnrecognizedActorKind, "kind=%d", actor.Kind)
+}
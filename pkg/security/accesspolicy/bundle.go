@@ -0,0 +1,268 @@
+package accesspolicy
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// PolicyBundle is a self-contained, portable representation of a policy
+// along with its roster, which is meant to be moved between environments
+// NOTE: group and role actors are referenced by their keys instead of IDs,
+// because group IDs most likely differ between environments
+type PolicyBundle struct {
+	Key        string        `json:"key"`
+	ObjectName string        `json:"object_name"`
+	ObjectID   uuid.UUID     `json:"object_id"`
+	ParentID   uuid.UUID     `json:"parent_id"`
+	OwnerID    uuid.UUID     `json:"owner_id"`
+	CoOwners   []uuid.UUID   `json:"co_owners,omitempty"`
+	Flags      uint8         `json:"flags"`
+	Everyone   Right         `json:"everyone"`
+	Entries    []BundleEntry `json:"entries"`
+}
+
+// BundleEntry represents a single roster entry of a policy bundle
+// NOTE: ActorID is only used for user actors, GroupKey is used for groups and roles
+type BundleEntry struct {
+	ActorKind ActorKind `json:"actor_kind"`
+	ActorID   uuid.UUID `json:"actor_id,omitempty"`
+	GroupKey  string    `json:"group_key,omitempty"`
+	Rights    Right     `json:"rights"`
+	Denied    Right     `json:"denied,omitempty"`
+	Grantable Right     `json:"grantable,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// ImportOptions alters the way a bundle is imported
+type ImportOptions struct {
+	// overrides the bundled key if not empty
+	Key string
+
+	// overrides the bundled parent policy if not nil, the parent is
+	// mandatory if the bundled policy inherits or extends
+	ParentID uuid.UUID
+
+	// overrides the bundled owner if not nil
+	OwnerID uuid.UUID
+
+	// skip group and role entries whose keys cannot be resolved,
+	// otherwise the import fails
+	SkipUnresolved bool
+}
+
+// ExportPolicy exports a policy and its roster as a portable bundle
+func (m *Manager) ExportPolicy(ctx context.Context, pid uuid.UUID) (b PolicyBundle, err error) {
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return b, errors.Wrapf(err, "failed to obtain policy: policy_id=%s", pid)
+	}
+
+	r, err := m.RosterByPolicyID(ctx, pid)
+	if err != nil {
+		return b, errors.Wrapf(err, "failed to obtain rights roster: policy_id=%s", pid)
+	}
+
+	b = PolicyBundle{
+		Key:        p.Key,
+		ObjectName: p.ObjectName,
+		ObjectID:   p.ObjectID,
+		ParentID:   p.ParentID,
+		OwnerID:    p.OwnerID,
+		CoOwners:   p.CoOwners,
+		Flags:      p.Flags,
+		Entries:    make([]BundleEntry, 0),
+	}
+
+	now := time.Now()
+
+	r.Range(func(actor Actor, rights Right) bool {
+		entry := BundleEntry{
+			ActorKind: actor.Kind,
+			Rights:    rights,
+		}
+
+		// expired entries grant nothing, thus not worth moving
+		if cell, ok := r.cell(actor); ok {
+			if cell.IsExpired(now) {
+				return true
+			}
+
+			entry.Denied = cell.Denied
+			entry.Grantable = cell.Grantable
+			entry.ExpiresAt = cell.ExpiresAt
+		}
+
+		switch actor.Kind {
+		case AKEveryone:
+			b.Everyone = rights
+			return true
+		case AKUser:
+			entry.ActorID = actor.ID
+		case AKGroup, AKRoleGroup:
+			if m.groups == nil {
+				err = ErrNilGroupManager
+				return false
+			}
+
+			g, gerr := m.groups.GroupByID(ctx, actor.ID)
+			if gerr != nil {
+				err = errors.Wrapf(gerr, "failed to obtain %s: id=%s", actor.Kind, actor.ID)
+				return false
+			}
+
+			entry.GroupKey = g.Key
+		default:
+			return true
+		}
+
+		b.Entries = append(b.Entries, entry)
+
+		return true
+	})
+
+	if err != nil {
+		return b, err
+	}
+
+	return b, nil
+}
+
+// ImportPolicy creates a new policy from a bundle, resolving
+// its group and role references by their keys
+func (m *Manager) ImportPolicy(ctx context.Context, b PolicyBundle, opts ImportOptions) (p Policy, err error) {
+	key := b.Key
+	if opts.Key != "" {
+		key = opts.Key
+	}
+
+	ownerID := b.OwnerID
+	if opts.OwnerID != uuid.Nil {
+		ownerID = opts.OwnerID
+	}
+
+	parentID := b.ParentID
+	if opts.ParentID != uuid.Nil {
+		parentID = opts.ParentID
+	}
+
+	// resolving actors before anything is created
+	cells := make([]Cell, 0, len(b.Entries))
+	for _, entry := range b.Entries {
+		switch entry.ActorKind {
+		case AKUser:
+			if entry.ActorID == uuid.Nil {
+				return p, ErrNilActorID
+			}
+
+			cells = append(cells, entry.cell(UserActor(entry.ActorID)))
+		case AKGroup, AKRoleGroup:
+			if m.groups == nil {
+				return p, ErrNilGroupManager
+			}
+
+			g, err := m.groups.GroupByKey(ctx, entry.GroupKey)
+			if err != nil {
+				if opts.SkipUnresolved {
+					continue
+				}
+
+				return p, errors.Wrapf(err, "failed to resolve %s by key: %s", entry.ActorKind, entry.GroupKey)
+			}
+
+			cells = append(cells, entry.cell(NewActor(entry.ActorKind, g.ID)))
+		default:
+			return p, errors.Wrapf(ErrUnrecognizedActorKind, "kind=%d", entry.ActorKind)
+		}
+	}
+
+	p, err = m.Create(ctx, key, ownerID, parentID, NewObject(b.ObjectID, b.ObjectName), b.Flags)
+	if err != nil {
+		return p, errors.Wrap(err, "failed to create imported policy")
+	}
+
+	r, err := m.RosterByPolicyID(ctx, p.ID)
+	if err != nil {
+		return p, errors.Wrapf(err, "failed to obtain rights roster: policy_id=%s", p.ID)
+	}
+
+	// the roster is restored as is, bypassing grantor checks
+	r.change(RSet, PublicActor(), b.Everyone)
+	for _, cell := range cells {
+		r.changeUntil(RSet, cell.Key, cell.Rights, cell.ExpiresAt)
+
+		if cell.Denied != APNoAccess {
+			r.changeDenied(cell.Key, cell.Denied)
+		}
+
+		if cell.Grantable != APNoAccess {
+			r.changeGrantable(cell.Key, cell.Grantable)
+		}
+	}
+
+	// the owner set is persisted along with the roster
+	for _, coOwnerID := range b.CoOwners {
+		if coOwnerID != uuid.Nil && !p.IsOwner(coOwnerID) {
+			p.CoOwners = append(p.CoOwners, coOwnerID)
+		}
+	}
+
+	sort.Slice(p.CoOwners, func(i, j int) bool {
+		return p.CoOwners[i].String() < p.CoOwners[j].String()
+	})
+
+	if err = m.Update(ctx, p); err != nil {
+		r.restoreBackup()
+
+		// not leaving a half-imported policy behind
+		if derr := m.DeletePolicy(ctx, p); derr != nil {
+			return p, errors.Wrapf(err, "failed to clean up after failed import: %s", derr)
+		}
+
+		return p, errors.Wrap(err, "failed to persist imported roster")
+	}
+
+	return p, nil
+}
+
+// cell returns the roster cell of this entry for a given resolved actor
+func (e BundleEntry) cell(actor Actor) Cell {
+	return Cell{
+		Key:       actor,
+		Rights:    e.Rights,
+		Denied:    e.Denied,
+		Grantable: e.Grantable,
+		ExpiresAt: e.ExpiresAt,
+	}
+}
+
+// ExportPolicyJSON is the same as ExportPolicy, but the bundle is encoded as JSON
+func (m *Manager) ExportPolicyJSON(ctx context.Context, pid uuid.UUID) ([]byte, error) {
+	b, err := m.ExportPolicy(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode policy bundle: policy_id=%s", pid)
+	}
+
+	return data, nil
+}
+
+// ImportPolicyJSON decodes a JSON-encoded bundle and imports it as a new policy,
+// keeping its key and parent, thus it fails if either key or object is taken
+func (m *Manager) ImportPolicyJSON(ctx context.Context, data []byte) (p Policy, err error) {
+	var b PolicyBundle
+
+	if err = json.Unmarshal(data, &b); err != nil {
+		return p, errors.Wrap(err, "failed to decode policy bundle")
+	}
+
+	return m.ImportPolicy(ctx, b, ImportOptions{})
+}
@@ -0,0 +1,191 @@
+package accesspolicy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// accessCacheSweepSize is the number of entries at which the expired
+// entries are swept out of the cache, and accessCacheLimit is the number
+// of entries past which the cache is discarded as a whole
+const (
+	accessCacheSweepSize = 1024
+	accessCacheLimit     = 1 << 16
+)
+
+type accessCacheKey struct {
+	policyID uuid.UUID
+	userID   uuid.UUID
+
+	// summarized rights are kept apart from the calculated ones
+	summary bool
+}
+
+type accessCacheEntry struct {
+	rights    Right
+	expiresAt time.Time
+}
+
+// accessCache is a short-lived cache of calculated and summarized
+// user rights, it is meant to spare repeated resolution of the same
+// rights within a single request
+// NOTE: zero TTL disables caching of the respective rights
+type accessCache struct {
+	ttl        time.Duration
+	summaryTTL time.Duration
+	entries    map[accessCacheKey]accessCacheEntry
+
+	// number of entries at which the next sweep takes place
+	sweepAt int
+
+	sync.RWMutex
+}
+
+func newAccessCache(ttl, summaryTTL time.Duration) *accessCache {
+	return &accessCache{
+		ttl:        ttl,
+		summaryTTL: summaryTTL,
+		entries:    make(map[accessCacheKey]accessCacheEntry),
+		sweepAt:    accessCacheSweepSize,
+	}
+}
+
+func (c *accessCache) setTTL(ttl time.Duration) {
+	c.Lock()
+	c.ttl = ttl
+	c.entries = make(map[accessCacheKey]accessCacheEntry)
+	c.sweepAt = accessCacheSweepSize
+	c.Unlock()
+}
+
+func (c *accessCache) setSummaryTTL(ttl time.Duration) {
+	c.Lock()
+	c.summaryTTL = ttl
+	c.entries = make(map[accessCacheKey]accessCacheEntry)
+	c.sweepAt = accessCacheSweepSize
+	c.Unlock()
+}
+
+// lifespan returns the TTL of a given kind of entries
+// NOTE: must be called under the lock
+func (c *accessCache) lifespan(key accessCacheKey) time.Duration {
+	if key.summary {
+		return c.summaryTTL
+	}
+
+	return c.ttl
+}
+
+func (c *accessCache) get(pid, userID uuid.UUID) (Right, bool) {
+	return c.lookup(accessCacheKey{policyID: pid, userID: userID})
+}
+
+func (c *accessCache) getSummary(pid, userID uuid.UUID) (Right, bool) {
+	return c.lookup(accessCacheKey{policyID: pid, userID: userID, summary: true})
+}
+
+func (c *accessCache) lookup(key accessCacheKey) (Right, bool) {
+	c.RLock()
+	if c.lifespan(key) <= 0 {
+		c.RUnlock()
+		return APNoAccess, false
+	}
+
+	e, ok := c.entries[key]
+	c.RUnlock()
+
+	if !ok {
+		return APNoAccess, false
+	}
+
+	if now := time.Now(); now.After(e.expiresAt) {
+		// the entry might have been renewed in the meantime
+		c.Lock()
+		if e, ok = c.entries[key]; ok && now.After(e.expiresAt) {
+			delete(c.entries, key)
+		}
+		c.Unlock()
+
+		return APNoAccess, false
+	}
+
+	return e.rights, true
+}
+
+func (c *accessCache) put(pid, userID uuid.UUID, rights Right) {
+	c.store(accessCacheKey{policyID: pid, userID: userID}, rights)
+}
+
+func (c *accessCache) putSummary(pid, userID uuid.UUID, rights Right) {
+	c.store(accessCacheKey{policyID: pid, userID: userID, summary: true}, rights)
+}
+
+func (c *accessCache) store(key accessCacheKey, rights Right) {
+	c.Lock()
+	defer c.Unlock()
+
+	ttl := c.lifespan(key)
+	if ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	if len(c.entries) >= c.sweepAt {
+		c.sweep(now)
+	}
+
+	c.entries[key] = accessCacheEntry{
+		rights:    rights,
+		expiresAt: now.Add(ttl),
+	}
+}
+
+// sweep discards the expired entries, and all of them if the cache
+// is still too large, then sets the size of the next sweep
+// NOTE: must be called under the write lock
+func (c *accessCache) sweep(now time.Time) {
+	for k, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+
+	if len(c.entries) >= accessCacheLimit {
+		c.entries = make(map[accessCacheKey]accessCacheEntry)
+	}
+
+	// sweeping again once the cache doubles in size, so that
+	// a cache full of fresh entries isn't swept on every put
+	switch c.sweepAt = 2 * len(c.entries); {
+	case c.sweepAt < accessCacheSweepSize:
+		c.sweepAt = accessCacheSweepSize
+	case c.sweepAt > accessCacheLimit:
+		c.sweepAt = accessCacheLimit
+	}
+}
+
+// clear discards all cached rights
+// NOTE: everything is discarded, because the rights of the
+// descendant policies may depend on the policy that has changed
+func (c *accessCache) clear() {
+	c.Lock()
+	if len(c.entries) > 0 {
+		c.entries = make(map[accessCacheKey]accessCacheEntry)
+		c.sweepAt = accessCacheSweepSize
+	}
+	c.Unlock()
+}
+
+// forget discards the cached rights of a given user, both calculated and summarized
+func (c *accessCache) forget(userID uuid.UUID) {
+	c.Lock()
+	for k := range c.entries {
+		if k.userID == userID {
+			delete(c.entries, k)
+		}
+	}
+	c.Unlock()
+}
@@ -0,0 +1,132 @@
+package accesspolicy
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// eventBufferSize is the capacity of each subscriber's channel
+const eventBufferSize = 100
+
+// PolicyEventKind describes what has happened to a policy
+type PolicyEventKind uint8
+
+const (
+	PEGrant PolicyEventKind = iota + 1
+	PERevoke
+)
+
+func (k PolicyEventKind) String() string {
+	switch k {
+	case PEGrant:
+		return "grant"
+	case PERevoke:
+		return "revoke"
+	default:
+		return "unrecognized policy event kind"
+	}
+}
+
+// PolicyEvent describes a single roster change that has been committed,
+// i.e. rights that have been granted to or revoked from an actor
+// NOTE: Rights and Denied carry the complete entry as it has been stored
+type PolicyEvent struct {
+	Kind      PolicyEventKind `json:"kind"`
+	PolicyID  uuid.UUID       `json:"policy_id"`
+	Actor     Actor           `json:"actor"`
+	Rights    Right           `json:"rights"`
+	Denied    Right           `json:"denied"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// Subscribe returns a channel which receives an event for every roster change
+// committed by Update, in the order the changes were made
+// NOTE: the channel is buffered, and if the subscriber doesn't keep up
+// then its oldest events are dropped, so that updates never block
+func (m *Manager) Subscribe() <-chan PolicyEvent {
+	ch := make(chan PolicyEvent, eventBufferSize)
+
+	m.eventLock.Lock()
+	m.subscribers = append(m.subscribers, ch)
+	m.eventLock.Unlock()
+
+	return ch
+}
+
+// Unsubscribe stops sending events to a given channel and closes it
+func (m *Manager) Unsubscribe(sub <-chan PolicyEvent) {
+	m.eventLock.Lock()
+	defer m.eventLock.Unlock()
+
+	for i, ch := range m.subscribers {
+		if ch == sub {
+			m.subscribers = append(m.subscribers[:i], m.subscribers[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// rosterEvents turns pending roster changes into events
+func rosterEvents(pid uuid.UUID, r *Roster) []PolicyEvent {
+	r.changeLock.RLock()
+	defer r.changeLock.RUnlock()
+
+	if len(r.changes) == 0 {
+		return nil
+	}
+
+	events := make([]PolicyEvent, 0, len(r.changes))
+	for _, c := range r.changes {
+		e := PolicyEvent{
+			Kind:     PEGrant,
+			PolicyID: pid,
+			Actor:    c.key,
+		}
+
+		switch c.action {
+		case RSet:
+			e.Rights = c.accessRight
+			e.Denied = c.denied
+			e.ExpiresAt = c.expiresAt
+		case RUnset:
+			e.Kind = PERevoke
+		}
+
+		events = append(events, e)
+	}
+
+	return events
+}
+
+// emitEvents sends events to every subscriber without blocking
+func (m *Manager) emitEvents(events []PolicyEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	m.eventLock.Lock()
+	defer m.eventLock.Unlock()
+
+	for _, ch := range m.subscribers {
+		for _, e := range events {
+			select {
+			case ch <- e:
+				continue
+			default:
+			}
+
+			// the buffer is full, dropping the oldest event to make room
+			select {
+			case <-ch:
+			default:
+			}
+
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}
+}
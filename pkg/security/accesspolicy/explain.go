@@ -0,0 +1,113 @@
+package accesspolicy
+
+import (
+	"context"
+	"time"
+
+	"github.com/agubarev/hometown/pkg/group"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// AccessExplanation is a breakdown of where the rights
+// of a user on a given policy come from
+// NOTE: only the policy's own roster is broken down, the rights
+// inherited from its parents are reflected by Access alone
+type AccessExplanation struct {
+	PolicyID uuid.UUID `json:"policy_id"`
+	UserID   uuid.UUID `json:"user_id"`
+
+	// rights granted to everyone
+	Public Right `json:"public"`
+
+	// rights granted to the standard groups and roles
+	// which the user is a member of, keyed by group ID
+	Groups map[uuid.UUID]Right `json:"groups"`
+	Roles  map[uuid.UUID]Right `json:"roles"`
+
+	// rights granted to the user directly
+	User Right `json:"user"`
+
+	// rights explicitly denied to the user, its groups and roles
+	Denied Right `json:"denied"`
+
+	// whether the user has full access because it owns the policy or its domain
+	Owner bool `json:"owner"`
+
+	// whether the user is suspended, thus has no access regardless of the above
+	Suspended bool `json:"suspended"`
+
+	// resulting rights, the same as returned by Access
+	Access Right `json:"access"`
+}
+
+// AccessBreakdown returns the rights of a given user on a given policy
+// broken down by their source, meant for debugging unexpected grants
+func (m *Manager) AccessBreakdown(ctx context.Context, pid, userID uuid.UUID) (e AccessExplanation, err error) {
+	if err = ctx.Err(); err != nil {
+		return e, err
+	}
+
+	if pid == uuid.Nil {
+		return e, ErrNilPolicyID
+	}
+
+	if userID == uuid.Nil {
+		return e, ErrNilActorID
+	}
+
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return e, errors.Wrapf(err, "failed to obtain policy: policy_id=%s", pid)
+	}
+
+	r, err := m.RosterByPolicyID(ctx, p.ID)
+	if err != nil {
+		return e, errors.Wrapf(err, "failed to obtain rights roster: policy_id=%s", p.ID)
+	}
+
+	e = AccessExplanation{
+		PolicyID: p.ID,
+		UserID:   userID,
+		Public:   r.Everyone,
+		Groups:   make(map[uuid.UUID]Right),
+		Roles:    make(map[uuid.UUID]Right),
+	}
+
+	if m.groups != nil {
+		for _, g := range m.groups.GroupsByAssetID(ctx, group.FRole|group.FGroup, group.NewAsset(group.AKUser, userID)) {
+			granted, denied, err := m.groupRights(ctx, p.ID, g.ID)
+			if err != nil {
+				return e, errors.Wrapf(err, "failed to obtain group rights: group_id=%s", g.ID)
+			}
+
+			if g.IsRole() {
+				e.Roles[g.ID] = granted
+			} else {
+				e.Groups[g.ID] = granted
+			}
+
+			e.Denied |= denied
+		}
+	}
+
+	cell := r.activeCell(UserActor(userID), time.Now())
+	e.User = cell.Rights
+	e.Denied |= cell.Denied
+
+	if e.Owner = p.IsOwner(userID); !e.Owner {
+		if e.Owner, err = m.isDomainOwner(ctx, p, userID); err != nil {
+			return e, errors.Wrapf(err, "failed to check domain ownership: policy_id=%s", p.ID)
+		}
+	}
+
+	if e.Suspended, err = m.IsActorSuspended(ctx, userID); err != nil {
+		return e, errors.Wrapf(err, "failed to check suspension: user_id=%s", userID)
+	}
+
+	if e.Access, err = m.AccessE(ctx, p.ID, userID); err != nil {
+		return e, errors.Wrapf(err, "failed to obtain access: policy_id=%s, user_id=%s", p.ID, userID)
+	}
+
+	return e, nil
+}
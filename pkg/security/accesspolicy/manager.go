@@ -2,8 +2,11 @@ package accesspolicy
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/agubarev/hometown/pkg/group"
 	"github.com/google/uuid"
@@ -23,6 +26,7 @@ var (
 	ErrPolicyObjectConflict         = errors.New("id of a kind is taken")
 	ErrEmptyKey                     = errors.New("key is empty")
 	ErrEmptyObjectName              = errors.New("object name is empty")
+	ErrNilObjectID                  = errors.New("object id is nil")
 	ErrNilRoster                    = errors.New("rights rosters is nil")
 	ErrCacheMiss                    = errors.New("roster cache miss")
 	ErrEmptyRoster                  = errors.New("rights rosters is empty")
@@ -45,21 +49,92 @@ var (
 	ErrNilPolicyID                  = errors.New("policy id is nil")
 	ErrNothingChanged               = errors.New("nothing changed")
 	ErrNilActorID                   = errors.New("actor id is nil")
+	ErrNilGroupManager              = errors.New("group manager is nil")
+	ErrUnrecognizedActorKind        = errors.New("unrecognized actor kind")
+	ErrIncompatibleParentType       = errors.New("parent policy concerns an incompatible object type")
+	ErrConflictingFlags             = errors.New("policy flags are conflicting")
+	ErrScopeNotSupported            = errors.New("store does not support scoping")
+	ErrOwnerQuotaExceeded           = errors.New("owner has reached the maximum number of policies")
+	ErrNoRightsRequested            = errors.New("no rights requested")
+	ErrAccessRequestNotFound        = errors.New("access request not found")
+	ErrAccessRequestResolved        = errors.New("access request is already resolved")
+	ErrPolicyHasChildren            = errors.New("policy has child policies")
+	ErrPublicDenial                 = errors.New("public rights cannot be denied")
+	ErrReservedRight                = errors.New("reserved bit is set, use DenyAccess to deny rights")
+	ErrExpiredGrant                 = errors.New("grant expiration time is in the past")
+	ErrCircularParent               = errors.New("policy cannot descend from itself")
+	ErrNilOwnerID                   = errors.New("owner id is nil")
+	ErrPrimaryOwner                 = errors.New("primary owner can't be removed from the owner set")
+	ErrPublicGrantLimit             = errors.New("public cannot grant rights, thus cannot be limited")
+	ErrInheritanceTooDeep           = errors.New("inheritance chain is too deep")
+	ErrEmptyRightName               = errors.New("right name is empty")
+	ErrNoFreeRights                 = errors.New("no free bits left to register a right")
+	ErrStaleUpdate                  = errors.New("policy has been updated elsewhere since it was loaded")
+	ErrMalformedRoster              = errors.New("malformed binary roster")
+	ErrRosterChecksumMismatch       = errors.New("roster checksum mismatch")
+	ErrUnrelatedChanges             = errors.New("roster has unsaved changes unrelated to the actor being replaced")
 )
 
+// DefaultMaxInheritanceDepth is the default number of steps a single rights
+// check may take through policy and group ancestors
+const DefaultMaxInheritanceDepth = 64
+
+// DefaultSummaryCacheTTL is the default lifespan of summarized user rights
+const DefaultSummaryCacheTTL = 10 * time.Millisecond
+
 // Manager is the accesspolicy policy registry
 // NOTE: resolver determines the final access rights if policy has a parent
 type Manager struct {
-	policies   map[uuid.UUID]Policy
-	keyMap     map[string]uuid.UUID
-	roster     map[uuid.UUID]*Roster
-	groups     *group.Manager
-	resolver   AccessResolver
-	store      Store
+	policies map[uuid.UUID]Policy
+	keyMap   map[string]uuid.UUID
+	objMap   map[Object]uuid.UUID
+	roster   map[uuid.UUID]*Roster
+	groups   *group.Manager
+	resolver AccessResolver
+	domains  DomainOwnershipResolver
+	store    Store
+	scope    uuid.UUID
+	onError  ErrorReporter
+	metrics  Recorder
+	cache    *accessCache
+
+	// receiver of the records of committed grants and revocations
+	auditSink AuditSink
+
+	// object type compatibility between parent and child policies,
+	// object name -> set of its declared supertypes
+	checkTypes bool
+	supertypes map[string]map[string]bool
+
+	// maximum number of policies a single owner may have, zero means unlimited
+	maxPerOwner int
+
+	// maximum number of steps a single rights check may take
+	// through policy and group ancestors, zero means unlimited
+	maxDepth int
+
 	rosterLock sync.RWMutex
+
+	// serializes EnsurePolicyForObject's check and creation
+	ensureLock sync.Mutex
+
+	// suspended users, reloaded from the store once they're older than the TTL
+	suspended          map[uuid.UUID]bool
+	suspensionLoadedAt time.Time
+	suspensionTTL      time.Duration
+	suspensionLock     sync.RWMutex
+
+	// channels of the roster change event subscribers
+	subscribers []chan PolicyEvent
+	eventLock   sync.Mutex
+
 	sync.RWMutex
 }
 
+// ErrorReporter is called whenever a rights check fails due to an error,
+// which otherwise is silently treated as no access
+type ErrorReporter func(ctx context.Context, err error)
+
 // NewManager initializes a new accesspolicy policy container
 func NewManager(store Store, gm *group.Manager) (*Manager, error) {
 	if store == nil {
@@ -67,16 +142,242 @@ func NewManager(store Store, gm *group.Manager) (*Manager, error) {
 	}
 
 	c := &Manager{
-		policies: make(map[uuid.UUID]Policy),
-		roster:   make(map[uuid.UUID]*Roster),
-		keyMap:   make(map[string]uuid.UUID),
-		groups:   gm,
-		store:    store,
+		policies:      make(map[uuid.UUID]Policy),
+		roster:        make(map[uuid.UUID]*Roster),
+		keyMap:        make(map[string]uuid.UUID),
+		objMap:        make(map[Object]uuid.UUID),
+		groups:        gm,
+		store:         store,
+		cache:         newAccessCache(0, DefaultSummaryCacheTTL),
+		supertypes:    make(map[string]map[string]bool),
+		maxDepth:      DefaultMaxInheritanceDepth,
+		suspensionTTL: DefaultSuspensionTTL,
+		metrics:       nopRecorder{},
+	}
+
+	// rewriting roster entries of the merged groups, discarding those
+	// of the deleted groups and the cached rights which depend on group membership
+	// NOTE: this couples both managers, so the group manager
+	// must be shared by everything that changes memberships
+	if gm != nil {
+		gm.OnMerge(c.groupMerged)
+		gm.OnDelete(c.groupDeleted)
+		gm.OnMembershipChange(c.groupMembershipChanged)
+		gm.OnParentChange(c.groupReparented)
 	}
 
 	return c, nil
 }
 
+// NewScopedManager returns a new access policy manager which only operates
+// on the policies of a given scope, policies outside of it are treated as
+// non-existent
+// NOTE: the store must implement Scoper
+func NewScopedManager(store Store, gm *group.Manager, scope uuid.UUID) (*Manager, error) {
+	if store == nil {
+		return nil, ErrNilStore
+	}
+
+	scoper, ok := store.(Scoper)
+	if !ok {
+		return nil, ErrScopeNotSupported
+	}
+
+	m, err := NewManager(scoper.WithScope(scope), gm)
+	if err != nil {
+		return nil, err
+	}
+
+	m.scope = scope
+
+	return m, nil
+}
+
+// Scope returns the scope of this manager, nil if it's not scoped
+func (m *Manager) Scope() uuid.UUID {
+	return m.scope
+}
+
+// SetErrorReporter sets an optional callback to observe the errors
+// which occur during rights checks
+func (m *Manager) SetErrorReporter(fn ErrorReporter) {
+	m.Lock()
+	m.onError = fn
+	m.Unlock()
+}
+
+// SetAccessCacheTTL sets the lifespan of calculated user rights cache,
+// zero TTL disables caching (default)
+// NOTE: the cache is discarded whenever any policy or roster changes, and
+// the rights of a user whenever their group membership changes
+func (m *Manager) SetAccessCacheTTL(ttl time.Duration) {
+	m.cache.setTTL(ttl)
+}
+
+// SetSummaryCacheTTL sets the lifespan of the user rights summarized
+// by SummarizedUserAccess, zero TTL disables caching
+// NOTE: the cache is discarded whenever any policy or roster changes, and
+// the rights of a user whenever their group membership changes
+func (m *Manager) SetSummaryCacheTTL(ttl time.Duration) {
+	m.cache.setSummaryTTL(ttl)
+}
+
+// SetDomainOwnershipResolver sets an optional resolver which makes
+// domain owners have full access to all policies within their domains
+// NOTE: nil disables domain ownership (default)
+func (m *Manager) SetDomainOwnershipResolver(dr DomainOwnershipResolver) {
+	m.Lock()
+	m.domains = dr
+	m.Unlock()
+}
+
+// isDomainOwner tells whether a given user owns the domain of a given policy
+func (m *Manager) isDomainOwner(ctx context.Context, p Policy, userID uuid.UUID) (bool, error) {
+	m.RLock()
+	dr := m.domains
+	m.RUnlock()
+
+	if dr == nil || userID == uuid.Nil {
+		return false, nil
+	}
+
+	domainID, err := dr.PolicyDomain(ctx, p)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to resolve policy domain: policy_id=%s", p.ID)
+	}
+
+	if domainID == uuid.Nil {
+		return false, nil
+	}
+
+	ok, err := dr.IsDomainOwner(ctx, domainID, userID)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check domain ownership: domain_id=%s", domainID)
+	}
+
+	return ok, nil
+}
+
+// SetMaxPoliciesPerOwner limits the number of policies
+// a single owner may have, zero means unlimited (default)
+func (m *Manager) SetMaxPoliciesPerOwner(n int) {
+	m.Lock()
+	m.maxPerOwner = n
+	m.Unlock()
+}
+
+// SetMaxInheritanceDepth limits the number of steps a single rights check
+// may take through policy and group ancestors, zero means unlimited
+func (m *Manager) SetMaxInheritanceDepth(n int) {
+	m.Lock()
+	m.maxDepth = n
+	m.Unlock()
+}
+
+type depthContextKey struct{}
+
+// descend counts a single step up the policy or group hierarchy
+// within the current rights check and returns the context to carry on with
+// NOTE: the resolution stops here if the context has been cancelled
+func (m *Manager) descend(ctx context.Context) (context.Context, error) {
+	if err := ctx.Err(); err != nil {
+		return ctx, err
+	}
+
+	m.RLock()
+	limit := m.maxDepth
+	m.RUnlock()
+
+	depth, _ := ctx.Value(depthContextKey{}).(int)
+	depth++
+
+	if limit > 0 && depth > limit {
+		return ctx, errors.Wrapf(ErrInheritanceTooDeep, "max_depth=%d", limit)
+	}
+
+	return context.WithValue(ctx, depthContextKey{}, depth), nil
+}
+
+// EnforceParentObjectType enables or disables the requirement for a child policy
+// to concern the same object type as its parent, or its declared subtype
+// NOTE: disabled by default
+func (m *Manager) EnforceParentObjectType(enabled bool) {
+	m.Lock()
+	m.checkTypes = enabled
+	m.Unlock()
+}
+
+// DeclareObjectSubtype declares an object type as a subtype of another,
+// so that its policies are allowed to have a parent policy of the supertype
+func (m *Manager) DeclareObjectSubtype(subtype, supertype string) {
+	m.Lock()
+	if m.supertypes[subtype] == nil {
+		m.supertypes[subtype] = make(map[string]bool)
+	}
+
+	m.supertypes[subtype][supertype] = true
+	m.Unlock()
+}
+
+// isCompatibleType checks whether a child object type is compatible with a parent's
+// NOTE: policies without an object name are generic, thus compatible with anything
+func (m *Manager) isCompatibleType(child, parent string) bool {
+	if child == "" || parent == "" || child == parent {
+		return true
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	// tracing back through declared supertypes
+	visited := map[string]bool{child: true}
+	queue := []string{child}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		for super := range m.supertypes[name] {
+			if super == parent {
+				return true
+			}
+
+			if !visited[super] {
+				visited[super] = true
+				queue = append(queue, super)
+			}
+		}
+	}
+
+	return false
+}
+
+// checkParentType returns an error if the type check is
+// enabled and a given parent concerns an incompatible object type
+func (m *Manager) checkParentType(child, parent Policy) error {
+	m.RLock()
+	enabled := m.checkTypes
+	m.RUnlock()
+
+	if enabled && !m.isCompatibleType(child.ObjectName, parent.ObjectName) {
+		return errors.Wrapf(
+			ErrIncompatibleParentType,
+			"object_name=%s, parent_object_name=%s", child.ObjectName, parent.ObjectName,
+		)
+	}
+
+	return nil
+}
+
+func (m *Manager) reportError(ctx context.Context, err error) {
+	m.RLock()
+	fn := m.onError
+	m.RUnlock()
+
+	if fn != nil {
+		fn(ctx, err)
+	}
+}
+
 func (m *Manager) putPolicy(p Policy, r *Roster) (err error) {
 	if err = p.Validate(); err != nil {
 		return err
@@ -87,6 +388,9 @@ func (m *Manager) putPolicy(p Policy, r *Roster) (err error) {
 	m.policies[p.ID] = p
 	m.roster[p.ID] = r
 	m.keyMap[p.Key] = p.ID
+	if p.ObjectName != "" && p.ObjectID != uuid.Nil {
+		m.objMap[NewObject(p.ObjectID, p.ObjectName)] = p.ID
+	}
 	m.Unlock()
 
 	return nil
@@ -120,6 +424,7 @@ func (m *Manager) removePolicy(policyID uuid.UUID) (err error) {
 	delete(m.policies, ap.ID)
 	delete(m.roster, ap.ID)
 	delete(m.keyMap, ap.Key)
+	delete(m.objMap, NewObject(ap.ObjectID, ap.ObjectName))
 	m.Unlock()
 
 	return nil
@@ -132,6 +437,75 @@ func (m *Manager) Create(ctx context.Context, key string, ownerID, parentID uuid
 		return p, errors.Wrap(err, "failed to initialize new accesspolicy policy")
 	}
 
+	return m.create(ctx, p, NewRoster(0))
+}
+
+// ClonePolicy creates a copy of a given policy under a new key, along with
+// its owners, parent, flags and the entries of its rights roster
+// NOTE: the copy isn't bound to any object, because an object
+// can only be protected by a single policy
+// NOTE: the roster is copied as it is, including the changes which
+// haven't been saved yet
+func (m *Manager) ClonePolicy(ctx context.Context, srcID uuid.UUID, newKey string) (p Policy, err error) {
+	src, err := m.PolicyByID(ctx, srcID, false)
+	if err != nil {
+		return p, errors.Wrapf(err, "failed to obtain source policy: policy_id=%s", srcID)
+	}
+
+	r, err := m.RosterByPolicyID(ctx, srcID)
+	if err != nil {
+		return p, errors.Wrapf(err, "failed to obtain source policy roster: policy_id=%s", srcID)
+	}
+
+	p, err = NewPolicy(newKey, src.OwnerID, src.ParentID, NilObject(), src.Flags)
+	if err != nil {
+		return p, errors.Wrap(err, "failed to initialize policy clone")
+	}
+
+	if len(src.CoOwners) > 0 {
+		p.CoOwners = append([]uuid.UUID(nil), src.CoOwners...)
+	}
+
+	return m.create(ctx, p, r.clone())
+}
+
+// EnsurePolicyForObject returns the policy of a given object,
+// creating it if the object has none yet
+// NOTE: concurrent calls within this manager are safe, as creation
+// is serialized and losing a race re-fetches the winner's policy
+func (m *Manager) EnsurePolicyForObject(ctx context.Context, key string, ownerID uuid.UUID, obj Object, flags uint8) (p Policy, err error) {
+	if obj.Name == "" {
+		return p, ErrEmptyObjectName
+	}
+
+	if obj.ID == uuid.Nil {
+		return p, ErrNilObjectID
+	}
+
+	p, err = m.PolicyByObject(ctx, obj, false)
+	if errors.Cause(err) != ErrPolicyNotFound {
+		return p, err
+	}
+
+	m.ensureLock.Lock()
+	defer m.ensureLock.Unlock()
+
+	// it may have been created while waiting
+	p, err = m.PolicyByObject(ctx, obj, false)
+	if errors.Cause(err) != ErrPolicyNotFound {
+		return p, err
+	}
+
+	p, err = m.Create(ctx, key, ownerID, uuid.Nil, obj, flags)
+	if errors.Cause(err) == ErrPolicyObjectConflict {
+		return m.PolicyByObject(ctx, obj, false)
+	}
+
+	return p, err
+}
+
+// create stores a new policy along with its initial rights roster
+func (m *Manager) create(ctx context.Context, p Policy, r *Roster) (_ Policy, err error) {
 	// validating new policy object
 	if err = p.Validate(); err != nil {
 		return p, errors.Wrap(err, "new policy validation failed")
@@ -139,7 +513,7 @@ func (m *Manager) Create(ctx context.Context, key string, ownerID, parentID uuid
 
 	// checking whether the key is available in general
 	if p.Key != "" {
-		_, err = m.PolicyByKey(ctx, p.Key)
+		_, err = m.PolicyByKey(ctx, p.Key, false)
 		if err == nil {
 			return p, ErrPolicyKeyTaken
 		}
@@ -151,7 +525,7 @@ func (m *Manager) Create(ctx context.Context, key string, ownerID, parentID uuid
 
 	// checking by an object type and ActorID
 	if p.ObjectName != "" && p.ObjectID != uuid.Nil {
-		_, err = m.PolicyByObject(ctx, obj)
+		_, err = m.PolicyByObject(ctx, NewObject(p.ObjectID, p.ObjectName), false)
 		if err == nil {
 			return p, ErrPolicyObjectConflict
 		}
@@ -161,19 +535,32 @@ func (m *Manager) Create(ctx context.Context, key string, ownerID, parentID uuid
 		}
 	}
 
+	// checking owner's quota
+	// NOTE: concurrent creation may slightly overshoot the limit
+	if err = m.checkOwnerQuota(ctx, p.OwnerID); err != nil {
+		return p, err
+	}
+
 	// initializing or re-using rights rosters, depending
 	// on whether this policy has a parent from which it inherits
-	if parentID != uuid.Nil {
-		if _, err = m.PolicyByID(ctx, p.ParentID); err != nil {
+	if p.ParentID != uuid.Nil {
+		parent, err := m.PolicyByID(ctx, p.ParentID, false)
+		if err != nil {
 			return p, errors.Wrapf(err, "failed to obtain parent policy despite having parent id")
 		}
+
+		if err = m.checkParentType(p, parent); err != nil {
+			return p, err
+		}
 	}
 
 	// generating Name for the new policy
 	p.ID = uuid.New()
 
 	// creating in the store
-	p, r, err := m.store.CreatePolicy(ctx, p, NewRoster(0))
+	start := time.Now()
+	p, r, err = m.store.CreatePolicy(ctx, p, r)
+	m.observeStore("CreatePolicy", start)
 	if err != nil {
 		return p, errors.Wrap(err, "failed to create new accesspolicy policy")
 	}
@@ -188,43 +575,138 @@ func (m *Manager) Create(ctx context.Context, key string, ownerID, parentID uuid
 
 // Update updates given accesspolicy policy
 func (m *Manager) Update(ctx context.Context, p Policy) (err error) {
+	u, err := m.prepareUpdate(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	// making changes to the store backend
+	start := time.Now()
+	err = m.store.UpdatePolicy(ctx, u.policy, u.roster)
+	m.observeStore("UpdatePolicy", start)
+	if err != nil {
+		// discarding the outdated policy, so that it's reloaded next time
+		if errors.Cause(err) == ErrStaleUpdate {
+			m.removePolicy(p.ID)
+			m.cache.clear()
+		}
+
+		return errors.Wrap(err, "failed to save updated accesspolicy policy")
+	}
+
+	return m.commitUpdate(ctx, u)
+}
+
+// UpdateBatch updates several policies within a single store transaction,
+// so that either every policy is updated or none of them is
+// NOTE: nothing is stored unless every policy passes validation, and
+// if storing fails, then the unsaved roster changes of every policy
+// in the batch are discarded
+func (m *Manager) UpdateBatch(ctx context.Context, ps []Policy) (err error) {
+	updates := make([]pendingUpdate, 0, len(ps))
+
+	for _, p := range ps {
+		u, err := m.prepareUpdate(ctx, p)
+		if err != nil {
+			return errors.Wrapf(err, "policy_id=%s", p.ID)
+		}
+
+		updates = append(updates, u)
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	// the policy whose update has failed
+	var failed uuid.UUID
+
+	start := time.Now()
+	err = m.store.WithTx(ctx, func(tx Store) error {
+		for _, u := range updates {
+			if err := tx.UpdatePolicy(ctx, u.policy, u.roster); err != nil {
+				failed = u.policy.ID
+				return err
+			}
+		}
+
+		return nil
+	})
+	m.observeStore("WithTx", start)
+	if err != nil {
+		// restoring the rosters, as the store has rolled everything back
+		for _, u := range updates {
+			u.roster.restoreBackup()
+		}
+
+		// discarding the outdated policy, so that it's reloaded next time
+		if errors.Cause(err) == ErrStaleUpdate {
+			m.removePolicy(failed)
+		}
+
+		m.cache.clear()
+
+		return errors.Wrapf(err, "failed to save updated accesspolicy policies: policy_id=%s", failed)
+	}
+
+	for _, u := range updates {
+		if err = m.commitUpdate(ctx, u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pendingUpdate is a validated policy update which is yet to be stored
+type pendingUpdate struct {
+	policy  Policy
+	roster  *Roster
+	events  []PolicyEvent
+	records []AuditRecord
+}
+
+// prepareUpdate validates a policy update and collects everything
+// which is needed to store it
+func (m *Manager) prepareUpdate(ctx context.Context, p Policy) (u pendingUpdate, err error) {
 	if err = p.Validate(); err != nil {
-		return errors.Wrap(err, "failed to validate accesspolicy policy before updating")
+		return u, errors.Wrap(err, "failed to validate accesspolicy policy before updating")
 	}
 
-	currentPolicy, err := m.PolicyByID(ctx, p.ID)
+	// NOTE: comparing against the persisted state rather than the cached
+	// policy, because the cached value may have already been altered by the caller
+	start := time.Now()
+	currentPolicy, err := m.store.FetchPolicyByID(ctx, p.ID)
+	m.observeStore("FetchPolicyByID", start)
 	if err != nil {
-		return errors.Wrap(err, "failed to obtain current policy")
+		return u, errors.Wrap(err, "failed to obtain current policy")
+	}
+
+	// soft-deleted policy must be restored first
+	if currentPolicy.IsDeleted() {
+		return u, errors.Wrapf(ErrPolicyNotFound, "policy is deleted: policy_id=%s", p.ID)
 	}
 
 	//-!!!-[ WARNING ]-----------------------------------------------------------
 	// !!! KEY, OBJECT NAME AND ID ARE NOT ALLOWED TO CHANGE BECAUSE CURRENT
 	// !!! VALUES ARE/COULD BE RELYING UPON ELSEWHERE AND MUST REMAIN THE SAME
 	//-!!!-----------------------------------------------------------------------
-	if p.Key != currentPolicy.Key {
-		return ErrForbiddenChange
-	}
-
-	if p.ObjectID != currentPolicy.ObjectID {
-		return ErrForbiddenChange
-	}
-
-	if p.ObjectName != currentPolicy.ObjectName {
-		return ErrForbiddenChange
+	if changes := forbiddenChanges(currentPolicy, p); len(changes) > 0 {
+		return u, ForbiddenChangeError{Changes: changes}
 	}
 
 	// checking whether name is available, and if it already
 	// exists and doesn't belong to this accesspolicy policy, then
 	// returning an error
 	if p.Key != "" {
-		existingPolicy, err := m.PolicyByKey(ctx, p.Key)
+		existingPolicy, err := m.PolicyByKey(ctx, p.Key, false)
 		if err != nil {
 			if err != ErrPolicyNotFound {
-				return errors.Wrapf(err, "failed to obtain policy by key: %s", p.Key)
+				return u, errors.Wrapf(err, "failed to obtain policy by key: %s", p.Key)
 			}
 		} else {
 			if existingPolicy.ID != p.ID {
-				return ErrPolicyKeyTaken
+				return u, ErrPolicyKeyTaken
 			}
 		}
 	}
@@ -232,36 +714,58 @@ func (m *Manager) Update(ctx context.Context, p Policy) (err error) {
 	// checking by an object, just in case kind and id changes,
 	// and new kind and object is already attached to a different accesspolicy policy
 	if p.ObjectName != "" && p.ObjectID != uuid.Nil {
-		anotherPolicy, err := m.PolicyByObject(ctx, NewObject(p.ObjectID, p.ObjectName))
+		anotherPolicy, err := m.PolicyByObject(ctx, NewObject(p.ObjectID, p.ObjectName), false)
 		if err != nil {
 			if err != ErrPolicyNotFound {
-				return errors.Wrapf(err, "failed to obtain another policy by object: name=%s, id=%d", p.ObjectName, p.ObjectID)
+				return u, errors.Wrapf(err, "failed to obtain another policy by object: name=%s, id=%d", p.ObjectName, p.ObjectID)
 			}
 		} else {
 			if anotherPolicy.ID != p.ID {
-				return ErrPolicyObjectConflict
+				return u, ErrPolicyObjectConflict
 			}
 		}
 	}
 
 	r, err := m.RosterByPolicyID(ctx, p.ID)
 	if err != nil {
-		return errors.Wrap(err, "failed to obtain policy roster")
+		return u, errors.Wrap(err, "failed to obtain policy roster")
 	}
 
-	// making changes to the store backend
-	if err = m.store.UpdatePolicy(ctx, p, r); err != nil {
-		return errors.Wrap(err, "failed to save updated accesspolicy policy")
+	// the update is based on the version this manager has seen last,
+	// so that whatever has been stored elsewhere since then isn't overwritten
+	p.Version = currentPolicy.Version
+	if cached, err := m.lookupPolicy(p.ID); err == nil {
+		p.Version = cached.Version
+	}
+
+	// collecting the events and audit records before the changes are cleared
+	u = pendingUpdate{
+		policy:  p,
+		roster:  r,
+		events:  rosterEvents(p.ID, r),
+		records: r.auditRecords(),
 	}
 
+	return u, nil
+}
+
+// commitUpdate finalizes a policy update once it's been stored
+func (m *Manager) commitUpdate(ctx context.Context, u pendingUpdate) error {
+	u.policy.Version++
+
 	// clearing roster changes and backup because the policy update was successful
-	r.clearChanges()
+	u.roster.clearChanges()
+	m.cache.clear()
+	m.emitEvents(u.events)
+	m.flushAudit(ctx, u.records)
 
-	return m.putPolicy(p, r)
+	return m.putPolicy(u.policy, u.roster)
 }
 
 // PolicyByID returns an accesspolicy policy by its ObjectID
-func (m *Manager) PolicyByID(ctx context.Context, id uuid.UUID) (p Policy, err error) {
+// NOTE: soft-deleted policy is only returned if includeDeleted is set,
+// and it's never cached
+func (m *Manager) PolicyByID(ctx context.Context, id uuid.UUID, includeDeleted bool) (p Policy, err error) {
 	if id == uuid.Nil {
 		return p, ErrNilPolicyID
 	}
@@ -277,13 +781,25 @@ func (m *Manager) PolicyByID(ctx context.Context, id uuid.UUID) (p Policy, err e
 	}
 
 	// attempting to obtain policy from the store
+	start := time.Now()
 	p, err = m.store.FetchPolicyByID(ctx, id)
+	m.observeStore("FetchPolicyByID", start)
 	if err != nil {
 		return p, errors.Wrapf(err, "failed to fetch accesspolicy policy: %d", id)
 	}
 
+	if p.IsDeleted() {
+		if !includeDeleted {
+			return Policy{}, errors.Wrapf(ErrPolicyNotFound, "policy is deleted: policy_id=%s", id)
+		}
+
+		return p, nil
+	}
+
 	// fetching roster
+	start = time.Now()
 	r, err := m.store.FetchRosterByPolicyID(ctx, p.ID)
+	m.observeStore("FetchRosterByPolicyID", start)
 	if err != nil {
 		return p, errors.Wrapf(err, "failed to fetch rights roster: %d", p.ID)
 	}
@@ -291,8 +807,60 @@ func (m *Manager) PolicyByID(ctx context.Context, id uuid.UUID) (p Policy, err e
 	return p, m.putPolicy(p, r)
 }
 
-// PolicyByKey returns an accesspolicy policy by its key
-func (m *Manager) PolicyByKey(ctx context.Context, name string) (p Policy, err error) {
+// Warm loads given policies along with their rosters at once, so that
+// the subsequent lookups don't have to reach the store one by one
+// NOTE: policies that are already loaded, soft-deleted or don't exist are skipped
+func (m *Manager) Warm(ctx context.Context, policyIDs []uuid.UUID) error {
+	ids := make([]uuid.UUID, 0, len(policyIDs))
+
+	m.RLock()
+	for _, id := range policyIDs {
+		if _, ok := m.policies[id]; !ok && id != uuid.Nil {
+			ids = append(ids, id)
+		}
+	}
+	m.RUnlock()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	ps, err := m.store.FetchPoliciesByIDs(ctx, ids)
+	m.observeStore("FetchPoliciesByIDs", start)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch policies")
+	}
+
+	start = time.Now()
+	rosters, err := m.store.FetchRostersByPolicyIDs(ctx, ids)
+	m.observeStore("FetchRostersByPolicyIDs", start)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch rights rosters")
+	}
+
+	for _, p := range ps {
+		if p.IsDeleted() {
+			continue
+		}
+
+		r, ok := rosters[p.ID]
+		if !ok {
+			r = NewRoster(0)
+		}
+
+		if err = m.putPolicy(p, r); err != nil {
+			return errors.Wrapf(err, "policy_id=%s", p.ID)
+		}
+	}
+
+	return nil
+}
+
+// PolicyByKey returns an accesspolicy policy by its key, the live policy
+// takes precedence over the soft-deleted ones which may have had that key
+// NOTE: soft-deleted policy is only returned if includeDeleted is set
+func (m *Manager) PolicyByKey(ctx context.Context, name string, includeDeleted bool) (p Policy, err error) {
 	m.RLock()
 	p, ok := m.policies[m.keyMap[name]]
 	m.RUnlock()
@@ -303,13 +871,25 @@ func (m *Manager) PolicyByKey(ctx context.Context, name string) (p Policy, err e
 	}
 
 	// attempting to obtain policy from the store
+	start := time.Now()
 	p, err = m.store.FetchPolicyByKey(ctx, name)
+	m.observeStore("FetchPolicyByKey", start)
 	if err != nil {
 		return p, err
 	}
 
+	if p.IsDeleted() {
+		if !includeDeleted {
+			return Policy{}, ErrPolicyNotFound
+		}
+
+		return p, nil
+	}
+
 	// fetching roster
+	start = time.Now()
 	r, err := m.store.FetchRosterByPolicyID(ctx, p.ID)
+	m.observeStore("FetchRosterByPolicyID", start)
 	if err != nil {
 		return p, errors.Wrapf(err, "failed to fetch rights roster: %d", p.ID)
 	}
@@ -322,16 +902,44 @@ func (m *Manager) PolicyByKey(ctx context.Context, name string) (p Policy, err e
 	return p, nil
 }
 
-// PolicyByObject returns an accesspolicy policy by its kind and id
-func (m *Manager) PolicyByObject(ctx context.Context, obj Object) (p Policy, err error) {
+// PolicyByObject returns an accesspolicy policy by its kind and id, the live
+// policy takes precedence over the soft-deleted ones of the same object
+// NOTE: soft-deleted policy is only returned if includeDeleted is set
+func (m *Manager) PolicyByObject(ctx context.Context, obj Object, includeDeleted bool) (p Policy, err error) {
+	m.RLock()
+	p, ok := m.policies[m.objMap[obj]]
+	m.RUnlock()
+
+	// return if found in cache
+	if ok {
+		return p, nil
+	}
+
 	// attempting to obtain policy from the store
+	start := time.Now()
 	p, err = m.store.FetchPolicyByObject(ctx, obj)
+	m.observeStore("FetchPolicyByObject", start)
 	if err != nil {
 		return p, err
 	}
 
+	if p.IsDeleted() {
+		if !includeDeleted {
+			return Policy{}, ErrPolicyNotFound
+		}
+
+		return p, nil
+	}
+
+	// keeping the cached policy, because its roster may have unsaved changes
+	if cached, err := m.lookupPolicy(p.ID); err == nil {
+		return cached, nil
+	}
+
 	// fetching roster
+	start = time.Now()
 	r, err := m.store.FetchRosterByPolicyID(ctx, p.ID)
+	m.observeStore("FetchRosterByPolicyID", start)
 	if err != nil {
 		return p, errors.Wrapf(err, "failed to fetch rights roster: %d", p.ID)
 	}
@@ -344,18 +952,457 @@ func (m *Manager) PolicyByObject(ctx context.Context, obj Object) (p Policy, err
 	return p, nil
 }
 
-// DeletePolicy returns an accesspolicy policy by its ObjectID
-func (m *Manager) DeletePolicy(ctx context.Context, p Policy) (err error) {
-	if err = p.Validate(); err != nil {
-		return errors.Wrap(err, "failed to delete accesspolicy policy")
+// PolicyExists checks whether a policy exists, without
+// fetching and caching the policy and its roster
+func (m *Manager) PolicyExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	if id == uuid.Nil {
+		return false, ErrNilPolicyID
+	}
+
+	if _, err := m.lookupPolicy(id); err == nil {
+		return true, nil
+	}
+
+	start := time.Now()
+	ok, err := m.store.HasPolicy(ctx, id)
+	m.observeStore("HasPolicy", start)
+
+	return ok, err
+}
+
+// PolicyExistsByKey checks whether a policy exists by its key, without
+// fetching and caching the policy and its roster
+func (m *Manager) PolicyExistsByKey(ctx context.Context, key string) (bool, error) {
+	if key == "" {
+		return false, ErrEmptyKey
+	}
+
+	m.RLock()
+	_, ok := m.keyMap[key]
+	m.RUnlock()
+
+	if ok {
+		return true, nil
+	}
+
+	start := time.Now()
+	ok, err := m.store.HasPolicyByKey(ctx, key)
+	m.observeStore("HasPolicyByKey", start)
+
+	return ok, err
+}
+
+// CountPoliciesByOwner returns the number of policies owned by a given owner,
+// including the archived ones
+func (m *Manager) CountPoliciesByOwner(ctx context.Context, ownerID uuid.UUID) (int, error) {
+	n, err := m.store.CountPoliciesByOwner(ctx, ownerID, true)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to count policies by owner: owner_id=%s", ownerID)
+	}
+
+	return n, nil
+}
+
+// PoliciesByOwner returns a page of policies owned by a given owner, along with
+// the total number of its policies, non-positive limit means no limit
+// NOTE: archived policies are neither listed nor counted unless included
+// NOTE: listed policies are cached, same as when obtained one by one
+func (m *Manager) PoliciesByOwner(ctx context.Context, ownerID uuid.UUID, limit, offset int, includeArchived bool) (ps []Policy, total int, err error) {
+	if ownerID == uuid.Nil {
+		return nil, 0, ErrNilOwnerID
+	}
+
+	start := time.Now()
+	ps, err = m.store.ListPoliciesByOwner(ctx, ownerID, limit, offset, includeArchived)
+	m.observeStore("ListPoliciesByOwner", start)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "failed to list policies by owner: owner_id=%s", ownerID)
+	}
+
+	if err = m.cacheListed(ctx, ps); err != nil {
+		return nil, 0, err
+	}
+
+	start = time.Now()
+	total, err = m.store.CountPoliciesByOwner(ctx, ownerID, includeArchived)
+	m.observeStore("CountPoliciesByOwner", start)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "failed to count policies by owner: owner_id=%s", ownerID)
+	}
+
+	return ps, total, nil
+}
+
+// PoliciesByObjectName returns a page of policies which protect objects
+// of a given name (i.e. all policies of a type), non-positive limit means no limit
+// NOTE: archived policies are not listed unless included
+// NOTE: listed policies are cached, same as when obtained one by one
+func (m *Manager) PoliciesByObjectName(ctx context.Context, name string, limit, offset int, includeArchived bool) (ps []Policy, err error) {
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return nil, ErrEmptyObjectName
+	}
+
+	start := time.Now()
+	ps, err = m.store.FetchPoliciesByObjectName(ctx, name, limit, offset, includeArchived)
+	m.observeStore("FetchPoliciesByObjectName", start)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch policies by object name: object_name=%s", name)
+	}
+
+	if err = m.cacheListed(ctx, ps); err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+// cacheListed caches listed policies along with their rosters, replacing
+// those which are already cached, because their rosters may have unsaved changes
+func (m *Manager) cacheListed(ctx context.Context, ps []Policy) error {
+	for i, p := range ps {
+		if cached, err := m.lookupPolicy(p.ID); err == nil {
+			ps[i] = cached
+			continue
+		}
+
+		start := time.Now()
+		r, err := m.store.FetchRosterByPolicyID(ctx, p.ID)
+		m.observeStore("FetchRosterByPolicyID", start)
+		if err != nil {
+			return errors.Wrapf(err, "failed to fetch rights roster: %s", p.ID)
+		}
+
+		if err = m.putPolicy(p, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) checkOwnerQuota(ctx context.Context, ownerID uuid.UUID) error {
+	m.RLock()
+	limit := m.maxPerOwner
+	m.RUnlock()
+
+	if limit <= 0 || ownerID == uuid.Nil {
+		return nil
+	}
+
+	n, err := m.CountPoliciesByOwner(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+
+	if n >= limit {
+		return errors.Wrapf(ErrOwnerQuotaExceeded, "owner_id=%s, limit=%d", ownerID, limit)
+	}
+
+	return nil
+}
+
+// PurgeExpired deletes all expired roster entries,
+// returns the number of deleted entries
+// NOTE: expired entries have no effect even if they're not purged
+func (m *Manager) PurgeExpired(ctx context.Context) (n int64, err error) {
+	now := time.Now()
+
+	n, err = m.store.DeleteExpiredRosterEntries(ctx, now)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to purge expired roster entries")
+	}
+
+	// following the store
+	m.rosterLock.RLock()
+	for _, r := range m.roster {
+		r.purgeExpired(now)
+	}
+	m.rosterLock.RUnlock()
+
+	m.cache.clear()
+
+	return n, nil
+}
+
+// ObjectTypesInUse returns a distinct list of object names which
+// currently have policies, policies without an object are not included
+func (m *Manager) ObjectTypesInUse(ctx context.Context) ([]string, error) {
+	start := time.Now()
+	names, err := m.store.FetchObjectNames(ctx)
+	m.observeStore("FetchObjectNames", start)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch object names in use")
+	}
+
+	return names, nil
+}
+
+// ReplaceActor moves the rights of one actor to another across all
+// policy rosters, the rights are combined if the latter already has any
+// NOTE: grantor checks are bypassed, this is meant for maintenance
+func (m *Manager) ReplaceActor(ctx context.Context, from, to Actor) (err error) {
+	if from.Kind == AKEveryone || to.Kind == AKEveryone {
+		return errors.Wrap(ErrUnrecognizedActorKind, "public actor cannot be replaced")
+	}
+
+	if from.ID == uuid.Nil || to.ID == uuid.Nil {
+		return ErrNilActorID
+	}
+
+	// rights must not be moved to an archived group
+	if to.Kind == AKGroup || to.Kind == AKRoleGroup {
+		if m.groups == nil {
+			return ErrNilGroupManager
+		}
+
+		g, err := m.groups.GroupByID(ctx, to.ID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to obtain %s: id=%s", to.Kind, to.ID)
+		}
+
+		if g.IsArchived() {
+			return errors.Wrapf(group.ErrGroupInactive, "group_id=%s", g.ID)
+		}
+	}
+
+	start := time.Now()
+	ids, err := m.store.FetchPolicyIDsByActor(ctx, from)
+	m.observeStore("FetchPolicyIDsByActor", start)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch policies by actor: kind=%s, id=%s", from.Kind, from.ID)
+	}
+
+	// the cached rosters may hold grants which are yet to be stored,
+	// and since every roster is stored as a whole, nothing is moved
+	// if any of them has unsaved changes of some other actor
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, pid := range ids {
+		seen[pid] = true
+	}
+
+	m.rosterLock.RLock()
+	for pid, r := range m.roster {
+		if !seen[pid] && r.has(from) {
+			seen[pid] = true
+			ids = append(ids, pid)
+		}
+	}
+
+	for _, pid := range ids {
+		if r, ok := m.roster[pid]; ok && r.hasUnrelatedChanges(from, to) {
+			m.rosterLock.RUnlock()
+			return errors.Wrapf(ErrUnrelatedChanges, "policy_id=%s", pid)
+		}
+	}
+	m.rosterLock.RUnlock()
+
+	for _, pid := range ids {
+		if err = m.replaceActor(ctx, pid, from, to); err != nil {
+			return errors.Wrapf(err, "failed to replace actor: policy_id=%s", pid)
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) replaceActor(ctx context.Context, pid uuid.UUID, from, to Actor) (err error) {
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return err
+	}
+
+	r, err := m.RosterByPolicyID(ctx, pid)
+	if err != nil {
+		return err
+	}
+
+	// storing the policy below stores all of its changes
+	if r.hasUnrelatedChanges(from, to) {
+		return errors.Wrapf(ErrUnrelatedChanges, "policy_id=%s", pid)
+	}
+
+	// safety fuse
+	restoreBackup := true
+	defer func() {
+		if restoreBackup {
+			r.restoreBackup()
+		}
+	}()
+
+	// the entry is moved whole, along with its expiration, denials
+	// and grant limits, and combined with the one of the target if any
+	now := time.Now()
+	cell, ok := r.cell(from)
+
+	r.change(RUnset, from, APNoAccess)
+
+	if ok && !cell.IsExpired(now) {
+		cell.Key = to
+		if existing, found := r.cell(to); found {
+			cell = mergeCells(existing, cell, now)
+		}
+
+		r.changeCell(cell)
+	}
+
+	if err = m.Update(ctx, p); err != nil {
+		return err
+	}
+
+	restoreBackup = false
+
+	return nil
+}
+
+// groupMerged is a group merge hook which moves the rights
+// of the merged group to the group it was merged into
+func (m *Manager) groupMerged(ctx context.Context, keep, merged group.Group) error {
+	kind := AKGroup
+	if merged.IsRole() {
+		kind = AKRoleGroup
+	}
+
+	return m.ReplaceActor(ctx, NewActor(kind, merged.ID), NewActor(kind, keep.ID))
+}
+
+// groupDeleted is a group delete hook which discards the rights
+// of the deleted group from every roster
+func (m *Manager) groupDeleted(ctx context.Context, g group.Group) error {
+	kind := AKGroup
+	if g.IsRole() {
+		kind = AKRoleGroup
+	}
+
+	return m.RemoveActor(ctx, NewActor(kind, g.ID))
+}
+
+// RemoveActor discards the roster entries of a given actor from
+// every policy, including its denials, persisting each policy
+// NOTE: unlike RevokeAllForActor, no grantor is checked, meant for
+// the actors which cease to exist
+func (m *Manager) RemoveActor(ctx context.Context, a Actor) (err error) {
+	if a.Kind != AKUser && a.Kind != AKGroup && a.Kind != AKRoleGroup {
+		return errors.Wrapf(ErrUnrecognizedActorKind, "kind=%s", a.Kind)
+	}
+
+	if a.ID == uuid.Nil {
+		return ErrNilActorID
+	}
+
+	start := time.Now()
+	ids, err := m.store.FetchPolicyIDsByActor(ctx, a)
+	m.observeStore("FetchPolicyIDsByActor", start)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch policies by actor: kind=%s, id=%s", a.Kind, a.ID)
+	}
+
+	for _, pid := range ids {
+		if err = m.removeActor(ctx, pid, a); err != nil {
+			return errors.Wrapf(err, "failed to remove actor: policy_id=%s", pid)
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) removeActor(ctx context.Context, pid uuid.UUID, a Actor) (err error) {
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return err
+	}
+
+	r, err := m.RosterByPolicyID(ctx, pid)
+	if err != nil {
+		return err
+	}
+
+	// safety fuse
+	restoreBackup := true
+	defer func() {
+		if restoreBackup {
+			r.restoreBackup()
+		}
+	}()
+
+	r.change(RUnset, a, APNoAccess)
+
+	if err = m.Update(ctx, p); err != nil {
+		return err
+	}
+
+	restoreBackup = false
+
+	return nil
+}
+
+// groupMembershipChanged is a group membership hook which discards
+// the cached rights of the affected user
+// NOTE: if the asset isn't a user, then all cached rights are discarded
+func (m *Manager) groupMembershipChanged(ctx context.Context, rel group.Relation) {
+	m.rosterLock.RLock()
+	defer m.rosterLock.RUnlock()
+
+	if rel.Asset.Kind != group.AKUser {
+		m.cache.clear()
+		return
+	}
+
+	m.cache.forget(rel.Asset.ID)
+}
+
+// groupReparented is a group parent hook which discards the cached
+// rights derived from a re-parented group
+// NOTE: if they can't be told apart, then all cached rights are discarded
+func (m *Manager) groupReparented(ctx context.Context, g group.Group) {
+	if err := m.InvalidateGroup(ctx, g.ID); err != nil {
+		m.reportError(ctx, errors.Wrapf(err, "failed to invalidate re-parented group: group_id=%s", g.ID))
+		m.cache.clear()
+	}
+}
+
+// InvalidateGroup discards the cached rights derived from a given group,
+// i.e. the rights of all of its members, including those of its descendant
+// groups, whose rights are inherited through it
+func (m *Manager) InvalidateGroup(ctx context.Context, groupID uuid.UUID) (err error) {
+	if m.groups == nil {
+		return ErrNilGroupManager
+	}
+
+	if _, err = m.groups.GroupByID(ctx, groupID); err != nil {
+		return err
+	}
+
+	members, err := m.groups.MembersOf(ctx, groupID, true)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain group members: group_id=%s", groupID)
+	}
+
+	for _, uid := range members {
+		m.cache.forget(uid)
+	}
+
+	return nil
+}
+
+// DeletePolicy returns an accesspolicy policy by its ObjectID
+func (m *Manager) DeletePolicy(ctx context.Context, p Policy) (err error) {
+	if err = p.Validate(); err != nil {
+		return errors.Wrap(err, "failed to delete accesspolicy policy")
 	}
 
 	// deleting policy from the store
 	// NOTE: also deletes roster
-	if err = m.store.DeletePolicy(ctx, p); err != nil {
+	start := time.Now()
+	err = m.store.DeletePolicy(ctx, p)
+	m.observeStore("DeletePolicy", start)
+	if err != nil {
 		return err
 	}
 
+	m.cache.clear()
+
 	// adding policy to registry
 	if err = m.removePolicy(p.ID); err != nil {
 		if err == ErrPolicyNotFound {
@@ -368,6 +1415,181 @@ func (m *Manager) DeletePolicy(ctx context.Context, p Policy) (err error) {
 	return nil
 }
 
+// SoftDeletePolicy marks a policy as deleted instead of removing it, so that
+// it remains for the audit trail and foreign references, while no longer
+// being looked up by default nor holding its key
+// NOTE: policy which still has live children can't be soft-deleted
+func (m *Manager) SoftDeletePolicy(ctx context.Context, p Policy) (err error) {
+	if err = p.Validate(); err != nil {
+		return errors.Wrap(err, "failed to soft-delete accesspolicy policy")
+	}
+
+	hasChildren, err := m.store.HasChildPolicies(ctx, p.ID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check child policies: policy_id=%s", p.ID)
+	}
+
+	if hasChildren {
+		return ErrPolicyHasChildren
+	}
+
+	deletedAt := time.Now()
+	if err = m.store.SetPolicyDeletedAt(ctx, p.ID, &deletedAt); err != nil {
+		return errors.Wrapf(err, "failed to soft-delete policy: policy_id=%s", p.ID)
+	}
+
+	m.cache.clear()
+
+	if err = m.removePolicy(p.ID); err != nil && err != ErrPolicyNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// RestorePolicy clears the deletion mark of a soft-deleted policy, provided
+// that neither its key nor its object have been taken in the meantime,
+// and that its parent still exists
+func (m *Manager) RestorePolicy(ctx context.Context, id uuid.UUID) (err error) {
+	p, err := m.PolicyByID(ctx, id, true)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain policy: policy_id=%s", id)
+	}
+
+	if !p.IsDeleted() {
+		return nil
+	}
+
+	if p.Key != "" {
+		if _, err = m.PolicyByKey(ctx, p.Key, false); err == nil {
+			return ErrPolicyKeyTaken
+		} else if errors.Cause(err) != ErrPolicyNotFound {
+			return err
+		}
+	}
+
+	if p.ObjectName != "" && p.ObjectID != uuid.Nil {
+		if _, err = m.PolicyByObject(ctx, NewObject(p.ObjectID, p.ObjectName), false); err == nil {
+			return ErrPolicyObjectConflict
+		} else if errors.Cause(err) != ErrPolicyNotFound {
+			return err
+		}
+	}
+
+	if p.ParentID != uuid.Nil {
+		if _, err = m.PolicyByID(ctx, p.ParentID, false); err != nil {
+			return errors.Wrapf(ErrInvalidParentPolicy, "failed to obtain parent policy: parent_id=%s: %s", p.ParentID, err)
+		}
+	}
+
+	if err = m.store.SetPolicyDeletedAt(ctx, p.ID, nil); err != nil {
+		return errors.Wrapf(err, "failed to restore policy: policy_id=%s", p.ID)
+	}
+
+	m.cache.clear()
+
+	return nil
+}
+
+// DeletePolicies deletes multiple policies along with their rosters within
+// a single store transaction; a policy which can't be deleted does not abort
+// the rest, instead the errors are returned per policy in the same order as
+// given IDs (nil for the deleted ones), whereas a failure of the store rolls
+// back the whole batch and is returned as the error
+// NOTE: policies which still have children are not deleted, so the children
+// must either precede their parents within the same batch, or be deleted first
+// NOTE: the acting actor must be able to manage access to each policy
+func (m *Manager) DeletePolicies(ctx context.Context, pids []uuid.UUID, acting Actor) (errs []error, err error) {
+	if acting.Kind != AKUser && acting.Kind != AKGroup && acting.Kind != AKRoleGroup {
+		return nil, errors.Wrapf(ErrUnrecognizedActorKind, "kind=%s", acting.Kind)
+	}
+
+	errs = make([]error, len(pids))
+
+	// checking access ahead of the transaction, as the checks read
+	// through the manager rather than the transaction
+	ps := make([]Policy, len(pids))
+	for i, pid := range pids {
+		ps[i], errs[i] = m.deletablePolicy(ctx, pid, acting)
+	}
+
+	deleted := make([]uuid.UUID, 0, len(pids))
+
+	start := time.Now()
+	err = m.store.WithTx(ctx, func(tx Store) error {
+		for i, p := range ps {
+			if errs[i] != nil {
+				continue
+			}
+
+			// checked within the transaction to see the children deleted by this batch
+			hasChildren, err := tx.HasChildPolicies(ctx, p.ID)
+			if err != nil {
+				return errors.Wrapf(err, "failed to check child policies: policy_id=%s", p.ID)
+			}
+
+			if hasChildren {
+				errs[i] = ErrPolicyHasChildren
+				continue
+			}
+
+			if err = tx.DeletePolicy(ctx, p); err != nil {
+				return errors.Wrapf(err, "policy_id=%s", p.ID)
+			}
+
+			deleted = append(deleted, p.ID)
+		}
+
+		return nil
+	})
+	m.observeStore("WithTx", start)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to delete accesspolicy policies")
+	}
+
+	if len(deleted) == 0 {
+		return errs, nil
+	}
+
+	m.cache.clear()
+
+	// auditing only what has been committed
+	records := make([]AuditRecord, 0, len(deleted))
+	for _, pid := range deleted {
+		// it fails only if the policy isn't cached
+		m.removePolicy(pid)
+
+		records = append(records, AuditRecord{
+			Timestamp: time.Now(),
+			PolicyID:  pid,
+			Grantor:   acting,
+			Action:    AADeletePolicy,
+		})
+	}
+
+	m.flushAudit(ctx, records)
+
+	return errs, nil
+}
+
+// deletablePolicy returns a policy which the acting actor is allowed to delete
+func (m *Manager) deletablePolicy(ctx context.Context, pid uuid.UUID, acting Actor) (p Policy, err error) {
+	p, err = m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return p, err
+	}
+
+	if !m.HasRights(ctx, p.ID, acting, APManageAccess) {
+		return p, ErrAccessDenied
+	}
+
+	if err = p.Validate(); err != nil {
+		return p, errors.Wrap(err, "failed to delete accesspolicy policy")
+	}
+
+	return p, nil
+}
+
 // RosterByPolicy returns the rights roster by its accesspolicy policy
 func (m *Manager) RosterByPolicyID(ctx context.Context, id uuid.UUID) (r *Roster, err error) {
 	if id == uuid.Nil {
@@ -385,13 +1607,22 @@ func (m *Manager) RosterByPolicyID(ctx context.Context, id uuid.UUID) (r *Roster
 	}
 
 	// attempting to obtain policy from the store
+	start := time.Now()
 	p, err := m.store.FetchPolicyByID(ctx, id)
+	m.observeStore("FetchPolicyByID", start)
 	if err != nil {
 		return r, errors.Wrapf(err, "failed to fetch policy roster: policy_id=%d", id)
 	}
 
+	// soft-deleted policies are never cached, see PolicyByID
+	if p.IsDeleted() {
+		return nil, errors.Wrapf(ErrPolicyNotFound, "policy is deleted: policy_id=%s", id)
+	}
+
 	// fetching rights roster
+	start = time.Now()
 	r, err = m.store.FetchRosterByPolicyID(ctx, p.ID)
+	m.observeStore("FetchRosterByPolicyID", start)
 	if err != nil {
 		// if no roster records are found, then initializing new roster object
 		if err == ErrEmptyRoster {
@@ -400,129 +1631,442 @@ func (m *Manager) RosterByPolicyID(ctx context.Context, id uuid.UUID) (r *Roster
 			m.rosterLock.Unlock()
 		}
 
-		return r, errors.Wrapf(err, "failed to fetch rights roster: policy_id=%d", p.ID)
+		return r, errors.Wrapf(err, "failed to fetch rights roster: policy_id=%d", p.ID)
+	}
+
+	// adding policy to registry
+	if err = m.putPolicy(p, r); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// PendingChanges returns the roster changes of a given policy
+// which are yet to be stored by updating the policy
+func (m *Manager) PendingChanges(ctx context.Context, pid uuid.UUID) ([]Change, error) {
+	r, err := m.RosterByPolicyID(ctx, pid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to obtain rights roster: policy_id=%s", pid)
+	}
+
+	return r.PendingChanges(), nil
+}
+
+// HasRights checks whether a given actor entity has the inquired rights
+// NOTE: fails closed, any error is reported to the error reporter (if set) and
+// is treated as if the actor has no rights
+func (m *Manager) HasRights(ctx context.Context, pid uuid.UUID, actor Actor, rights Right) bool {
+	ok, err := m.HasRightsE(ctx, pid, actor, rights)
+	if err != nil {
+		m.reportError(ctx, err)
+		return false
+	}
+
+	return ok
+}
+
+// HasRightsE is the same as HasRights, but returns an error if the rights
+// couldn't be determined, so that the caller could distinguish a genuine
+// denial from a backend failure
+func (m *Manager) HasRightsE(ctx context.Context, pid uuid.UUID, actor Actor, rights Right) (bool, error) {
+	ok, err := m.hasRights(ctx, pid, actor, rights)
+	if err != nil {
+		return false, err
+	}
+
+	m.recorder().IncHasRights(ok)
+
+	return ok, nil
+}
+
+func (m *Manager) hasRights(ctx context.Context, pid uuid.UUID, actor Actor, rights Right) (bool, error) {
+	if pid == uuid.Nil {
+		return false, ErrNilPolicyID
+	}
+
+	switch actor.Kind {
+	case AKEveryone:
+		return m.hasPublicRights(ctx, pid, rights)
+	case AKUser:
+		return m.userHasAccess(ctx, pid, actor.ID, rights)
+	case AKRoleGroup, AKGroup:
+		access, err := m.actorAccess(ctx, pid, actor)
+		if err != nil {
+			return false, err
+		}
+
+		return (access & rights) == rights, nil
+	}
+
+	return false, errors.Wrapf(ErrUnrecognizedActorKind, "kind=%d", actor.Kind)
+}
+
+// HasAnyRights is the same as HasRights, but it's enough for the actor
+// to have at least one of the given rights
+func (m *Manager) HasAnyRights(ctx context.Context, pid uuid.UUID, actor Actor, rights Right) bool {
+	if pid == uuid.Nil {
+		m.reportError(ctx, ErrNilPolicyID)
+		return false
+	}
+
+	access, err := m.actorAccess(ctx, pid, actor)
+	if err != nil {
+		m.reportError(ctx, err)
+		return false
+	}
+
+	return (access & rights) != 0
+}
+
+// GrantAccess grants accesspolicy rights on a given policy, by grantor to grantee
+// NOTE: can be called multiple times before policy changes are persisted
+// NOTE: rights rosters changes are not persisted unless explicitly saved
+// NOTE: changes made with this function will be cancelled and backup restored
+// if there will be any errors when saving this policy
+func (m *Manager) GrantAccess(ctx context.Context, pid uuid.UUID, grantor, grantee Actor, access Right) (err error) {
+	// otherwise the rights check would simply deny it
+	if !grantor.isRecognized() {
+		return errors.Wrapf(ErrUnrecognizedActorKind, "grantor kind=%d", grantor.Kind)
+	}
+
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return errors.Wrap(err, "failed to obtain accesspolicy policy")
+	}
+
+	r, err := m.RosterByPolicyID(ctx, p.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to obtain rights roster")
+	}
+
+	// setting rights depending on the type of a subject
+	switch grantee.Kind {
+	case AKEveryone:
+		err = m.GrantPublicAccess(ctx, pid, grantor, access)
+	case AKUser:
+		err = m.GrantUserAccess(ctx, pid, grantor, grantee.ID, access)
+	case AKRoleGroup:
+		err = m.GrantRoleAccess(ctx, pid, grantor, grantee.ID, access)
+	case AKGroup:
+		err = m.GrantGroupAccess(ctx, pid, grantor, grantee.ID, access)
+	default:
+		err = errors.Wrapf(ErrUnrecognizedActorKind, "kind=%d", grantee.Kind)
+	}
+
+	// rolling back the changes in case of an error
+	if err != nil {
+		r.restoreBackup()
+	}
+
+	return err
+}
+
+// RevokeAccess takes away current rights of a kind on this policy,
+// from an grantee, as angrantor
+// NOTE: this function only removes exclusive rights of this grantee,
+// but the grantee still retains its public level rights to whatver
+// that this policy protects
+// NOTE: if you wish to completely deny somebody an accesspolicy through
+// this policy, then set exclusive rights explicitly (i.e. APNoAccess, 0)
+func (m *Manager) RevokeAccess(ctx context.Context, pid uuid.UUID, grantor, grantee Actor) (err error) {
+	// safety fuse
+	restoreBackup := true
+
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain accesspolicy policy: policy_id=%d", pid)
+	}
+
+	r, err := m.RosterByPolicyID(ctx, pid)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain rights roster: policy_id=%d", p.ID)
+	}
+
+	// will restore backup unless successfully cancelled
+	defer func() {
+		if restoreBackup {
+			r.restoreBackup()
+		}
+	}()
+
+	if grantor.ID == uuid.Nil {
+		return ErrZeroGrantorID
+	}
+
+	// otherwise the rights check would simply deny it
+	if !grantor.isRecognized() {
+		return errors.Wrapf(ErrUnrecognizedActorKind, "grantor kind=%d", grantor.Kind)
+	}
+
+	// the grantor must have a right to manage accesspolicy rights (APManageAccess) and have all the
+	// rights himself that he's attempting to assign to others
+	if !m.HasRights(ctx, pid, grantor, APManageAccess) {
+		return ErrAccessDenied
+	}
+
+	// weighting the rights of who strips whose rights, the grantor must hold
+	// at least every right the grantee has, so that a lower-ranked manager
+	// isn't able to strip a higher-ranked one
+	grantorAccess, err := m.actorAccess(ctx, pid, grantor)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain grantor rights: policy_id=%s", pid)
+	}
+
+	granteeAccess, err := m.actorAccess(ctx, pid, grantee)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain grantee rights: policy_id=%s", pid)
+	}
+
+	if granteeAccess&^grantorAccess != APNoAccess {
+		return ErrAccessDenied
+	}
+
+	// deleting assigneeID from the rosters (depending on its type)
+	// NOTE: revoking is idempotent, nothing is changed if the
+	// grantee has no exclusive rights to begin with
+	switch grantee.Kind {
+	case AKEveryone:
+		if r.Everyone == APNoAccess {
+			restoreBackup = false
+			return nil
+		}
+
+		r.change(RSet, NewActor(AKEveryone, uuid.Nil), APNoAccess)
+	case AKUser, AKRoleGroup, AKGroup:
+		if !r.has(grantee) {
+			restoreBackup = false
+			return nil
+		}
+
+		r.change(RUnset, grantee, APNoAccess)
+	default:
+		return errors.Wrapf(ErrUnrecognizedActorKind, "kind=%d", grantee.Kind)
+	}
+
+	r.addAudit(AARevoke, pid, grantor, grantee, APNoAccess)
+	m.cache.clear()
+	m.recorder().IncRevoke()
+
+	// all is good, cancelling restoration
+	restoreBackup = false
+
+	return nil
+}
+
+// UnsetPublicAccess takes away the public rights of a given policy,
+// leaving the rights of the users, groups and roles intact
+// NOTE: the same as revoking the access of PublicActor(), thus the grantor
+// must hold APManageAccess, and the change is pending until the policy is updated
+func (m *Manager) UnsetPublicAccess(ctx context.Context, pid uuid.UUID, grantor Actor) error {
+	return m.RevokeAccess(ctx, pid, grantor, PublicActor())
+}
+
+// PolicyErrors holds the errors which occurred per policy
+// during an operation spanning multiple policies
+type PolicyErrors map[uuid.UUID]error
+
+func (pe PolicyErrors) Error() string {
+	ids := make([]uuid.UUID, 0, len(pe))
+	for id := range pe {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	var b strings.Builder
+	for i, id := range ids {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+
+		fmt.Fprintf(&b, "policy_id=%s: %s", id, pe[id])
+	}
+
+	return b.String()
+}
+
+// RevokeAllForActor takes away the rights of a given actor from every
+// policy whose roster has an entry for it, persisting each policy
+// NOTE: policies to which the grantor can't manage access are skipped,
+// their errors, as well as any other, are returned as PolicyErrors
+func (m *Manager) RevokeAllForActor(ctx context.Context, grantor, grantee Actor) error {
+	if grantee.Kind != AKUser && grantee.Kind != AKGroup && grantee.Kind != AKRoleGroup {
+		return errors.Wrapf(ErrUnrecognizedActorKind, "kind=%s", grantee.Kind)
+	}
+
+	if grantee.ID == uuid.Nil {
+		return ErrNilActorID
+	}
+
+	start := time.Now()
+	ids, err := m.store.FetchPolicyIDsByActor(ctx, grantee)
+	m.observeStore("FetchPolicyIDsByActor", start)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch policies by actor: kind=%s, id=%s", grantee.Kind, grantee.ID)
+	}
+
+	errs := make(PolicyErrors)
+	for _, pid := range ids {
+		if err = m.revokeAndUpdate(ctx, pid, grantor, grantee); err != nil {
+			errs[pid] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (m *Manager) revokeAndUpdate(ctx context.Context, pid uuid.UUID, grantor, grantee Actor) (err error) {
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return err
+	}
+
+	if err = m.RevokeAccess(ctx, pid, grantor, grantee); err != nil {
+		return err
+	}
+
+	return m.Update(ctx, p)
+}
+
+// TransferOwnership hands a policy over to a new owner, only the current
+// owner or one of the co-owners can do that, the new owner is subject
+// to the per-owner policy limit (see SetMaxPoliciesPerOwner)
+// NOTE: the previous owner retains no implicit rights afterwards
+func (m *Manager) TransferOwnership(ctx context.Context, pid, currentOwnerID, newOwnerID uuid.UUID) (err error) {
+	if newOwnerID == uuid.Nil {
+		return ErrNilOwnerID
+	}
+
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain policy: policy_id=%s", pid)
+	}
+
+	// same as sharing the ownership, managing access isn't enough
+	if !p.IsOwner(currentOwnerID) {
+		return ErrAccessDenied
+	}
+
+	if p.OwnerID == newOwnerID {
+		return nil
 	}
 
-	// adding policy to registry
-	if err = m.putPolicy(p, r); err != nil {
-		return r, err
+	if err = m.checkOwnerQuota(ctx, newOwnerID); err != nil {
+		return err
 	}
 
-	return r, nil
-}
+	p.OwnerID = newOwnerID
 
-// hasRights checks whether a given actor entity has the inquired rights
-func (m *Manager) HasRights(ctx context.Context, pid uuid.UUID, actor Actor, rights Right) bool {
-	if pid == uuid.Nil {
-		return false
-	}
+	// the new owner is no longer just a co-owner
+	p.CoOwners = withoutCoOwner(p.CoOwners, newOwnerID)
 
-	switch actor.Kind {
-	case AKEveryone:
-		return m.HasPublicRights(ctx, pid, rights)
-	case AKUser:
-		return m.UserHasAccess(ctx, pid, actor.ID, rights)
-	case AKRoleGroup:
-		return m.HasRoleRights(ctx, pid, actor.ID, rights)
-	case AKGroup:
-		return m.HasGroupRights(ctx, pid, actor.ID, rights)
+	// persisting changes
+	if err = m.Update(ctx, p); err != nil {
+		return errors.Wrapf(err, "failed to update policy after transferring ownership: policy_id=%s, new_owner_id=%s", p.ID, newOwnerID)
 	}
 
-	return false
+	return nil
 }
 
-// GrantAccess grants accesspolicy rights on a given policy, by grantor to grantee
-// NOTE: can be called multiple times before policy changes are persisted
-// NOTE: rights rosters changes are not persisted unless explicitly saved
-// NOTE: changes made with this function will be cancelled and backup restored
-// if there will be any errors when saving this policy
-func (m *Manager) GrantAccess(ctx context.Context, pid uuid.UUID, grantor, grantee Actor, access Right) (err error) {
-	p, err := m.PolicyByID(ctx, pid)
-	if err != nil {
-		return errors.Wrap(err, "failed to obtain accesspolicy policy")
+// AddCoOwner adds a user to the owner set of a given policy, co-owners are
+// given the same full access as the primary owner
+// NOTE: only the owners are allowed to share the ownership, because having
+// the right to manage access isn't enough to obtain full access
+func (m *Manager) AddCoOwner(ctx context.Context, pid uuid.UUID, grantor Actor, coOwnerID uuid.UUID) (err error) {
+	if grantor.ID == uuid.Nil {
+		return ErrZeroGrantorID
 	}
 
-	r, err := m.RosterByPolicyID(ctx, p.ID)
-	if err != nil {
-		return errors.Wrap(err, "failed to obtain rights roster")
+	if coOwnerID == uuid.Nil {
+		return ErrNilOwnerID
 	}
 
-	// setting rights depending on the type of a subject
-	switch grantee.Kind {
-	case AKEveryone:
-		err = m.GrantPublicAccess(ctx, pid, grantor, access)
-	case AKUser:
-		err = m.GrantUserAccess(ctx, pid, grantor, grantee.ID, access)
-	case AKRoleGroup:
-		err = m.GrantRoleAccess(ctx, pid, grantor, grantee.ID, access)
-	case AKGroup:
-		err = m.GrantGroupAccess(ctx, pid, grantor, grantee.ID, access)
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain policy: policy_id=%s", pid)
 	}
 
-	// clearing changes in case of an error
-	if err != nil {
-		r.clearChanges()
+	if grantor.Kind != AKUser || !p.IsOwner(grantor.ID) {
+		return ErrAccessDenied
 	}
 
-	return err
-}
+	if p.IsOwner(coOwnerID) {
+		return nil
+	}
 
-// RevokeAccess takes away current rights of a kind on this policy,
-// from an grantee, as angrantor
-// NOTE: this function only removes exclusive rights of this grantee,
-// but the grantee still retains its public level rights to whatver
-// that this policy protects
-// NOTE: if you wish to completely deny somebody an accesspolicy through
-// this policy, then set exclusive rights explicitly (i.e. APNoAccess, 0)
-func (m *Manager) RevokeAccess(ctx context.Context, pid uuid.UUID, grantor, grantee Actor) (err error) {
-	// safety fuse
-	restoreBackup := true
+	// copying to avoid altering the slice shared with the cached policy
+	coOwners := make([]uuid.UUID, len(p.CoOwners), len(p.CoOwners)+1)
+	copy(coOwners, p.CoOwners)
+	p.CoOwners = append(coOwners, coOwnerID)
 
-	p, err := m.PolicyByID(ctx, pid)
-	if err != nil {
-		return errors.Wrapf(err, "failed to obtain accesspolicy policy: policy_id=%d", pid)
-	}
+	// keeping the same order as the stores return
+	sort.Slice(p.CoOwners, func(i, j int) bool {
+		return p.CoOwners[i].String() < p.CoOwners[j].String()
+	})
 
-	r, err := m.RosterByPolicyID(ctx, pid)
-	if err != nil {
-		return errors.Wrapf(err, "failed to obtain rights roster: policy_id=%d", p.ID)
+	// persisting changes
+	if err = m.Update(ctx, p); err != nil {
+		return errors.Wrapf(err, "failed to update policy after adding co-owner: policy_id=%s, co_owner_id=%s", p.ID, coOwnerID)
 	}
 
-	// will restore backup unless successfully cancelled
-	defer func() {
-		if restoreBackup {
-			r.restoreBackup()
-		}
-	}()
+	return nil
+}
 
+// RemoveCoOwner removes a user from the owner set of a given policy
+// NOTE: the primary owner can only be replaced by TransferOwnership
+func (m *Manager) RemoveCoOwner(ctx context.Context, pid uuid.UUID, grantor Actor, coOwnerID uuid.UUID) (err error) {
 	if grantor.ID == uuid.Nil {
 		return ErrZeroGrantorID
 	}
 
-	// the grantor must have a right to manage accesspolicy rights (APManageAccess) and have all the
-	// rights himself that he's attempting to assign to others
-	// TODO: consider weighting the rights of who strips whose rights
-	if !m.HasRights(ctx, pid, grantor, APManageAccess) {
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain policy: policy_id=%s", pid)
+	}
+
+	if grantor.Kind != AKUser || !p.IsOwner(grantor.ID) {
 		return ErrAccessDenied
 	}
 
-	// deleting assigneeID from the rosters (depending on its type)
-	switch grantee.Kind {
-	case AKEveryone:
-		r.change(RSet, NewActor(AKEveryone, uuid.Nil), APNoAccess)
-	case AKUser, AKRoleGroup, AKGroup:
-		r.change(RUnset, grantee, APNoAccess)
+	if p.OwnerID == coOwnerID {
+		return ErrPrimaryOwner
 	}
 
-	// all is good, cancelling restoration
-	restoreBackup = false
+	if !p.IsCoOwner(coOwnerID) {
+		return nil
+	}
+
+	p.CoOwners = withoutCoOwner(p.CoOwners, coOwnerID)
+
+	// persisting changes
+	if err = m.Update(ctx, p); err != nil {
+		return errors.Wrapf(err, "failed to update policy after removing co-owner: policy_id=%s, co_owner_id=%s", p.ID, coOwnerID)
+	}
 
 	return nil
 }
 
+// withoutCoOwner returns a copy of the co-owner set without a given user
+func withoutCoOwner(coOwners []uuid.UUID, id uuid.UUID) (result []uuid.UUID) {
+	for _, coOwnerID := range coOwners {
+		if coOwnerID != id {
+			result = append(result, coOwnerID)
+		}
+	}
+
+	return result
+}
+
 // SetParentID setting a new parent policy
 func (m *Manager) SetParent(ctx context.Context, policyID, parentID uuid.UUID) (err error) {
-	p, err := m.PolicyByID(ctx, policyID)
+	p, err := m.PolicyByID(ctx, policyID, false)
 	if err != nil {
 		return errors.Wrapf(err, "policy_id=%d, new_parent_id=%d", policyID, parentID)
 	}
@@ -534,137 +2078,503 @@ func (m *Manager) SetParent(ctx context.Context, policyID, parentID uuid.UUID) (
 		p.ParentID = uuid.Nil
 	} else {
 		// checking parent policy existence
-		if _, err = m.PolicyByID(ctx, parentID); err != nil {
+		parent, err := m.PolicyByID(ctx, parentID, false)
+		if err != nil {
 			return errors.Wrapf(err, "failed to obtain new parent policy: policy_id=%d, new_parent_id=%d", policyID, parentID)
 		}
 
+		if err = m.checkParentType(p, parent); err != nil {
+			return err
+		}
+
+		if err = m.checkCircularParent(ctx, p.ID, parent); err != nil {
+			return err
+		}
+
 		p.ParentID = parentID
 	}
 
-	// obtaining roster
-	r, err := m.RosterByPolicyID(ctx, p.ID)
+	// persisting changes, which also discards the calculated cache
+	if err = m.Update(ctx, p); err != nil {
+		return errors.Wrapf(err, "failed to update policy after setting new parent: policy_id=%d, new_parent_id=%d", policyID, parentID)
+	}
+
+	return nil
+}
+
+// checkCircularParent makes sure that a given policy is not
+// an ancestor of its would-be parent, nor the parent itself
+func (m *Manager) checkCircularParent(ctx context.Context, policyID uuid.UUID, parent Policy) error {
+	visited := make(map[uuid.UUID]bool)
+
+	for ancestor := parent; ; {
+		if ancestor.ID == policyID {
+			return errors.Wrapf(ErrCircularParent, "policy_id=%s, parent_id=%s", policyID, parent.ID)
+		}
+
+		// guarding against the loops which might already exist
+		if ancestor.ParentID == uuid.Nil || visited[ancestor.ID] {
+			return nil
+		}
+
+		visited[ancestor.ID] = true
+
+		next, err := m.PolicyByID(ctx, ancestor.ParentID, false)
+		if err != nil {
+			return errors.Wrapf(err, "failed to obtain ancestor policy: policy_id=%s", ancestor.ParentID)
+		}
+
+		ancestor = next
+	}
+}
+
+// Archive disables a policy without deleting it, an archived policy stops
+// granting access to anyone but its owner, who retains full access in order
+// to be able to inspect and restore it
+func (m *Manager) Archive(ctx context.Context, pid uuid.UUID) (err error) {
+	return m.setArchived(ctx, pid, true)
+}
+
+// Unarchive restores a previously archived policy
+func (m *Manager) Unarchive(ctx context.Context, pid uuid.UUID) (err error) {
+	return m.setArchived(ctx, pid, false)
+}
+
+func (m *Manager) setArchived(ctx context.Context, pid uuid.UUID, archived bool) (err error) {
+	p, err := m.PolicyByID(ctx, pid, false)
 	if err != nil {
-		return errors.Wrapf(err, "failed to obtain policy roster before setting new parent: policy_id=%d, new_parent_id=%d", policyID, parentID)
+		return errors.Wrapf(err, "failed to obtain policy: policy_id=%s", pid)
 	}
 
-	// persisting changes
-	if err = m.Update(ctx, p); err != nil {
-		return errors.Wrapf(err, "failed to update policy after setting new parent: policy_id=%d, new_parent_id=%d", policyID, parentID)
+	if p.IsArchived() == archived {
+		return nil
 	}
 
-	// updating cached policy
-	m.Lock()
-	m.policies[p.ID] = p
-	m.Unlock()
+	if archived {
+		p.Flags |= FArchived
+	} else {
+		p.Flags &^= FArchived
+	}
 
-	// clearing calculated cache in a roster
-	r.cacheLock.Lock()
-	r.calculatedCache = make(map[Actor]Right, 0)
-	r.cacheLock.Unlock()
+	if err = m.Update(ctx, p); err != nil {
+		return errors.Wrapf(err, "failed to update policy: policy_id=%s, archived=%t", pid, archived)
+	}
 
 	return nil
 }
 
 // Access returns a summarized accesspolicy bitmask for a given actor
+// NOTE: fails closed, any error is reported to the error reporter (if set)
 func (m *Manager) Access(ctx context.Context, policyID, userID uuid.UUID) (access Right) {
-	if userID == uuid.Nil {
+	access, err := m.AccessE(ctx, policyID, userID)
+	if err != nil {
+		m.reportError(ctx, errors.Wrapf(err, "policy_id=%s, user_id=%s", policyID, userID))
 		return APNoAccess
 	}
 
+	return access
+}
+
+// AccessE is the same as Access, but returns an error if the rights
+// couldn't be determined, including the cancellation of a given context
+func (m *Manager) AccessE(ctx context.Context, policyID, userID uuid.UUID) (access Right, err error) {
+	if err = ctx.Err(); err != nil {
+		return APNoAccess, err
+	}
+
+	if userID == uuid.Nil {
+		return APNoAccess, nil
+	}
+
+	// suspended users have no access at all, not even as owners
+	if suspended, err := m.IsActorSuspended(ctx, userID); err != nil || suspended {
+		return APNoAccess, err
+	}
+
 	// obtaining policy
-	ap, err := m.PolicyByID(ctx, policyID)
+	ap, err := m.PolicyByID(ctx, policyID, false)
 	if err != nil {
-		log.Printf("Access(policy_id=%d, user_id=%d): %s\n", policyID, userID, err)
-		return APNoAccess
+		return APNoAccess, err
 	}
 
 	// if this user is the owner, then returning maximum possible value for Right type
 	// TODO: consider possible exception resolution, for example when an owner may not have full access in certain cases
 	if ap.IsOwner(userID) {
-		return APFullAccess
+		return APFullAccess, nil
+	}
+
+	// owners of the policy's domain are treated as owners
+	isDomainOwner, err := m.isDomainOwner(ctx, ap, userID)
+	if err != nil {
+		return APNoAccess, err
+	}
+
+	if isDomainOwner {
+		return APFullAccess, nil
+	}
+
+	// archived policy grants nothing to anyone but the owner
+	if ap.IsArchived() {
+		return APNoAccess, nil
 	}
 
 	// NOTE: determining access rights based on whether this policy has a parent
 	// calculating parents accesspolicy if parent ActorID is set
 	if ap.ParentID != uuid.Nil {
 		// obtaining parent object
-		parent, err := m.PolicyByID(ctx, ap.ParentID)
+		parent, err := m.PolicyByID(ctx, ap.ParentID, false)
 		if err != nil {
-			panic(errors.Wrap(err, "accesspolicy policy has parent id set, but failed to obtain parent policy object"))
+			return APNoAccess, errors.Wrap(err, "accesspolicy policy has parent id set, but failed to obtain parent policy object")
 		}
 
 		// if this policy is flagged as inherited, then
 		// calling Access until we reach the actual policy
 		if ap.IsInherited() {
-			access = m.Access(ctx, ap.ParentID, userID)
-		} else {
-			// if extend is true and parent exists, then using parent's accesspolicy as a base value
-			if parent.ID != uuid.Nil && ap.IsExtended() {
-				// addressing the parent because it traces back until it finds
-				// the first uninherited, actual policy
-				access = m.SummarizedUserAccess(ctx, parent.ID, userID)
+			deeper, err := m.descend(ctx)
+			if err != nil {
+				return APNoAccess, errors.Wrapf(err, "policy_id=%s", ap.ID)
+			}
+
+			if access, err = m.AccessE(deeper, ap.ParentID, userID); err != nil {
+				return APNoAccess, err
 			}
+		} else if parent.ID != uuid.Nil && ap.IsExtended() {
+			// if extend is true and parent exists, then using parent's rights
+			// as a base value, followed by the own grants and the public rights,
+			// the denials of both are subtracted only after all are combined
+			// (see userAccess), otherwise the public rights of this policy
+			// would override the denials of its parent
+			granted, denied, err := m.userRights(ctx, parent.ID, userID)
+			if err != nil {
+				return APNoAccess, err
+			}
+
+			own, ownDenied, err := m.userRights(ctx, ap.ID, userID)
+			if err != nil {
+				return APNoAccess, err
+			}
+
+			return (granted | own) &^ (denied | ownDenied), nil
 		}
 
 		// calculating access based on policy lineage
-		access |= m.SummarizedUserAccess(ctx, ap.ID, userID)
+		own, err := m.SummarizedUserAccessE(ctx, ap.ID, userID)
+		if err != nil {
+			return APNoAccess, err
+		}
+
+		access |= own
 	} else {
 		// this policy has no parent, thus assuming its own access rights
-		access = m.Access(ctx, ap.ID, userID)
+		if access, err = m.SummarizedUserAccessE(ctx, ap.ID, userID); err != nil {
+			return APNoAccess, err
+		}
 	}
 
-	return access
+	return access, nil
+}
+
+// RightsForObject returns the rights of a given user on the object protected
+// by a policy, fails with ErrPolicyNotFound if the object has no policy
+func (m *Manager) RightsForObject(ctx context.Context, obj Object, userID uuid.UUID) (Right, error) {
+	if obj.Name == "" {
+		return APNoAccess, ErrEmptyObjectName
+	}
+
+	if obj.ID == uuid.Nil {
+		return APNoAccess, ErrNilObjectID
+	}
+
+	p, err := m.PolicyByObject(ctx, obj, false)
+	if err != nil {
+		return APNoAccess, errors.Wrapf(err, "failed to obtain policy: object_name=%s, object_id=%s", obj.Name, obj.ID)
+	}
+
+	access, err := m.AccessE(ctx, p.ID, userID)
+	if err != nil {
+		return APNoAccess, errors.Wrapf(err, "failed to obtain access: policy_id=%s, user_id=%s", p.ID, userID)
+	}
+
+	return access, nil
+}
+
+// EffectiveRights returns the rights of everyone explicitly listed in the
+// roster of a given policy, including the public rights and the owner,
+// group and role entries are keyed by their group IDs
+// NOTE: rights inherited from the parent policies are not included
+func (m *Manager) EffectiveRights(ctx context.Context, pid uuid.UUID) (map[Actor]Right, error) {
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to obtain policy: policy_id=%s", pid)
+	}
+
+	r, err := m.RosterByPolicyID(ctx, p.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to obtain rights roster: policy_id=%s", p.ID)
+	}
+
+	rights := r.activeRights(time.Now())
+	rights[PublicActor()] = r.Everyone
+
+	// archived policy grants nothing to anyone but the owner
+	if p.IsArchived() {
+		rights = map[Actor]Right{PublicActor(): APNoAccess}
+	}
+
+	// the owners always have full access
+	if p.OwnerID != uuid.Nil {
+		rights[UserActor(p.OwnerID)] = APFullAccess
+	}
+
+	for _, coOwnerID := range p.CoOwners {
+		rights[UserActor(coOwnerID)] = APFullAccess
+	}
+
+	return rights, nil
+}
+
+// RightsDiff compares the resulting rights of two actors on a given policy,
+// returning the rights which only a has, which only b has, and which both have
+// NOTE: the rights are resolved the same way as for any rights check, thus
+// including the group and role rights, inheritance and ownership
+// NOTE: fails closed, any error is reported to the error reporter (if set)
+func (m *Manager) RightsDiff(ctx context.Context, pid uuid.UUID, a, b Actor) (onlyA, onlyB, both Right) {
+	onlyA, onlyB, both, err := m.RightsDiffE(ctx, pid, a, b)
+	if err != nil {
+		m.reportError(ctx, err)
+		return APNoAccess, APNoAccess, APNoAccess
+	}
+
+	return onlyA, onlyB, both
+}
+
+// RightsDiffE is the same as RightsDiff, but returns an error
+// if the rights of either actor couldn't be determined
+func (m *Manager) RightsDiffE(ctx context.Context, pid uuid.UUID, a, b Actor) (onlyA, onlyB, both Right, err error) {
+	if pid == uuid.Nil {
+		return APNoAccess, APNoAccess, APNoAccess, ErrNilPolicyID
+	}
+
+	accessA, err := m.actorAccess(ctx, pid, a)
+	if err != nil {
+		return APNoAccess, APNoAccess, APNoAccess, errors.Wrapf(err, "failed to obtain rights: actor=%s", a)
+	}
+
+	accessB, err := m.actorAccess(ctx, pid, b)
+	if err != nil {
+		return APNoAccess, APNoAccess, APNoAccess, errors.Wrapf(err, "failed to obtain rights: actor=%s", b)
+	}
+
+	return accessA &^ accessB, accessB &^ accessA, accessA & accessB, nil
 }
 
 // GroupAccess returns the rights of a given group if set explicitly,
 // otherwise returns the rights of the first ancestor group that has
 // any rights record explicitly set
 func (m *Manager) GroupAccess(ctx context.Context, pid, groupID uuid.UUID) (access Right) {
+	access, err := m.GroupAccessE(ctx, pid, groupID)
+	if err != nil {
+		m.reportError(ctx, err)
+		return APNoAccess
+	}
+
+	return access
+}
+
+// GroupAccessE is the same as GroupAccess, but returns an error if the rights
+// couldn't be determined, including the cancellation of a given context
+func (m *Manager) GroupAccessE(ctx context.Context, pid, groupID uuid.UUID) (access Right, err error) {
+	if err = ctx.Err(); err != nil {
+		return APNoAccess, err
+	}
+
+	return m.groupAccess(ctx, pid, groupID)
+}
+
+func (m *Manager) groupAccess(ctx context.Context, pid, groupID uuid.UUID) (access Right, err error) {
+	granted, denied, err := m.groupRights(ctx, pid, groupID)
+	if err != nil {
+		return APNoAccess, err
+	}
+
+	return granted &^ denied, nil
+}
+
+// SummarizedGroupAccess returns the rights of each of the given groups, resolved
+// the same way as GroupAccess, keyed by group ID; the roster is loaded once
+// and the rights of the shared ancestors are resolved only once
+func (m *Manager) SummarizedGroupAccess(ctx context.Context, pid uuid.UUID, groupIDs []uuid.UUID) (_ map[uuid.UUID]Right, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if pid == uuid.Nil {
+		return nil, ErrNilPolicyID
+	}
+
+	if _, err = m.RosterByPolicyID(ctx, pid); err != nil {
+		return nil, errors.Wrapf(err, "failed to obtain rights roster: policy_id=%s", pid)
+	}
+
+	ctx = withGroupMemo(ctx)
+
+	access := make(map[uuid.UUID]Right, len(groupIDs))
+	for _, id := range groupIDs {
+		if _, ok := access[id]; ok {
+			continue
+		}
+
+		if access[id], err = m.groupAccess(ctx, pid, id); err != nil {
+			return nil, errors.Wrapf(err, "failed to obtain group access: group_id=%s", id)
+		}
+	}
+
+	return access, nil
+}
+
+// groupRights returns the rights granted to a group and the rights
+// explicitly denied to it, as set by the group itself or its first
+// ancestor which has any rights set
+func (m *Manager) groupRights(ctx context.Context, pid, groupID uuid.UUID) (granted, denied Right, err error) {
+	// reusing the rights resolved earlier within the same batch
+	memo := groupMemoFromContext(ctx)
+	if memo != nil {
+		if e, ok := memo.get(pid, groupID); ok {
+			return e.granted, e.denied, nil
+		}
+	}
+
+	if granted, denied, err = m.resolveGroupRights(ctx, pid, groupID); err != nil {
+		return APNoAccess, APNoAccess, err
+	}
+
+	if memo != nil {
+		memo.put(pid, groupID, granted, denied)
+	}
+
+	return granted, denied, nil
+}
+
+func (m *Manager) resolveGroupRights(ctx context.Context, pid, groupID uuid.UUID) (granted, denied Right, err error) {
 	if pid == uuid.Nil || groupID == uuid.Nil {
-		return APNoAccess
+		return APNoAccess, APNoAccess, nil
 	}
 
 	// group manager is mandatory at this point
 	if m.groups == nil {
-		log.Printf("GroupAccess(policy_id=%d, group_id=%d): group manager is nil\n", pid, groupID)
-		return APNoAccess
+		return APNoAccess, APNoAccess, ErrNilGroupManager
+	}
+
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return APNoAccess, APNoAccess, err
+	}
+
+	if p.IsArchived() {
+		return APNoAccess, APNoAccess, nil
 	}
 
 	// obtaining roster
 	r, err := m.RosterByPolicyID(ctx, pid)
 	if err != nil {
-		log.Printf("GroupAccess(policy_id=%d, group_id=%d): failed to obtain rights roster\n", pid, groupID)
-		return APNoAccess
+		return APNoAccess, APNoAccess, errors.Wrap(err, "failed to obtain rights roster")
 	}
 
 	// obtaining target group
+	// NOTE: a non-existing group has no access, which is not an error
 	g, err := m.groups.GroupByID(ctx, groupID)
 	if err != nil {
-		return APNoAccess
+		if errors.Cause(err) == group.ErrGroupNotFound {
+			return APNoAccess, APNoAccess, nil
+		}
+
+		return APNoAccess, APNoAccess, errors.Wrapf(err, "failed to obtain group: %s", groupID)
 	}
 
+	var cell Cell
+
 	switch true {
 	case g.IsGroup():
-		access = r.lookup(NewActor(AKGroup, g.ID))
+		cell = r.activeCell(NewActor(AKGroup, g.ID), time.Now())
 	case g.IsRole():
-		access = r.lookup(NewActor(AKRoleGroup, g.ID))
+		cell = r.activeCell(NewActor(AKRoleGroup, g.ID), time.Now())
 	}
 
-	// returning if any positive accesspolicy right is found
-	if access != APNoAccess {
-		return access
+	// returning if any accesspolicy right is set
+	if cell.Rights != APNoAccess || cell.Denied != APNoAccess {
+		return cell.Rights, cell.Denied, nil
 	}
 
 	// otherwise, looking for the first set accesspolicy by tracing back
 	// through its parents
 	if g.ParentID != uuid.Nil {
-		return m.GroupAccess(ctx, pid, g.ParentID)
+		deeper, err := m.descend(ctx)
+		if err != nil {
+			return APNoAccess, APNoAccess, errors.Wrapf(err, "group_id=%s", g.ID)
+		}
+
+		return m.groupRights(deeper, pid, g.ParentID)
+	}
+
+	return APNoAccess, APNoAccess, nil
+}
+
+// SetGrantableRights limits the rights which a given actor may grant to others,
+// even if it holds more rights itself, zero mask lifts the limit
+// NOTE: as any other roster change, it isn't persisted unless the policy is saved
+func (m *Manager) SetGrantableRights(ctx context.Context, pid uuid.UUID, actor Actor, mask Right) (err error) {
+	if actor.Kind == AKEveryone {
+		return ErrPublicGrantLimit
+	}
+
+	if actor.ID == uuid.Nil {
+		return ErrNilActorID
+	}
+
+	r, err := m.RosterByPolicyID(ctx, pid)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain rights roster: policy_id=%s", pid)
+	}
+
+	r.changeGrantable(actor, mask&^APReserved)
+	m.cache.clear()
+
+	return nil
+}
+
+// isGrantable checks whether a grantor isn't limited in passing on given rights
+// NOTE: owners are never limited
+func (m *Manager) isGrantable(ctx context.Context, pid uuid.UUID, grantor Actor, rights Right) bool {
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		m.reportError(ctx, errors.Wrapf(err, "failed to obtain policy: policy_id=%s", pid))
+		return false
+	}
+
+	if grantor.Kind == AKUser && p.IsOwner(grantor.ID) {
+		return true
+	}
+
+	r, err := m.RosterByPolicyID(ctx, pid)
+	if err != nil {
+		m.reportError(ctx, errors.Wrapf(err, "failed to obtain rights roster: policy_id=%s", pid))
+		return false
+	}
+
+	grantable := r.activeCell(grantor, time.Now()).Grantable
+	if grantable == APNoAccess {
+		return true
 	}
 
-	return APNoAccess
+	return rights&^grantable == 0
 }
 
 // GrantPublicAccess setting base accesspolicy rights for everyone
+// NOTE: the grantor must hold APManageAccess along with every right it
+// grants, thus managing access alone isn't enough to pass on a right
+// which the grantor doesn't have itself
+// NOTE: the owners hold every right implicitly, so they don't need
+// to be granted anything to set the public rights
 func (m *Manager) GrantPublicAccess(ctx context.Context, pid uuid.UUID, grantor Actor, rights Right) error {
 	// safety fuse
 	restoreBackup := true
@@ -685,13 +2595,22 @@ func (m *Manager) GrantPublicAccess(ctx context.Context, pid uuid.UUID, grantor
 		return ErrZeroGrantorID
 	}
 
-	// checking whether the assignorID has at least the assigned rights
-	if !m.HasRights(ctx, pid, grantor, APManageAccess|rights) {
+	if rights.isReserved() {
+		return ErrReservedRight
+	}
+
+	// checking whether the assignorID has at least the assigned rights,
+	// which it's also allowed to pass on
+	// NOTE: the ownership is taken into account by the rights check itself
+	if !m.HasRights(ctx, pid, grantor, APManageAccess|rights) || !m.isGrantable(ctx, pid, grantor, rights) {
 		return ErrExcessOfRights
 	}
 
 	// deferred instruction for rosterChange
 	r.change(RSet, NewActor(AKEveryone, uuid.Nil), rights)
+	r.addAudit(AAGrant, pid, grantor, NewActor(AKEveryone, uuid.Nil), rights)
+	m.cache.clear()
+	m.recorder().IncGrant()
 
 	// all is good, cancelling restoration
 	restoreBackup = false
@@ -701,6 +2620,12 @@ func (m *Manager) GrantPublicAccess(ctx context.Context, pid uuid.UUID, grantor
 
 // GrantRoleAccess grants accesspolicy rights to the role
 func (m *Manager) GrantRoleAccess(ctx context.Context, pid uuid.UUID, grantor Actor, roleID uuid.UUID, rights Right) error {
+	return m.GrantRoleAccessUntil(ctx, pid, grantor, roleID, rights, time.Time{})
+}
+
+// GrantRoleAccessUntil is the same as GrantRoleAccess, but the granted rights
+// expire at a given moment, zero time means they never expire
+func (m *Manager) GrantRoleAccessUntil(ctx context.Context, pid uuid.UUID, grantor Actor, roleID uuid.UUID, rights Right, expiresAt time.Time) error {
 	// safety fuse
 	restoreBackup := true
 
@@ -720,6 +2645,10 @@ func (m *Manager) GrantRoleAccess(ctx context.Context, pid uuid.UUID, grantor Ac
 		return ErrZeroGrantorID
 	}
 
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return ErrExpiredGrant
+	}
+
 	if roleID == uuid.Nil {
 		return ErrZeroRoleID
 	}
@@ -738,14 +2667,27 @@ func (m *Manager) GrantRoleAccess(ctx context.Context, pid uuid.UUID, grantor Ac
 		)
 	}
 
+	// archived roles must not be granted anything
+	if g.IsArchived() {
+		return errors.Wrapf(group.ErrGroupInactive, "role_id=%s", roleID)
+	}
+
+	if rights.isReserved() {
+		return ErrReservedRight
+	}
+
 	// checking whether grantor has the right to manage,
-	// and has at least the assigned rights itself
-	if !m.HasRights(ctx, pid, grantor, APManageAccess|rights) {
+	// and has at least the assigned rights itself,
+	// which it's also allowed to pass on
+	if !m.HasRights(ctx, pid, grantor, APManageAccess|rights) || !m.isGrantable(ctx, pid, grantor, rights) {
 		return ErrExcessOfRights
 	}
 
 	// deferred instruction for rosterChange
-	r.change(RSet, NewActor(AKRoleGroup, roleID), rights)
+	r.changeUntil(RSet, NewActor(AKRoleGroup, roleID), rights, expiresAt)
+	r.addAudit(AAGrant, pid, grantor, NewActor(AKRoleGroup, roleID), rights)
+	m.cache.clear()
+	m.recorder().IncGrant()
 
 	// all is good, cancelling restoration
 	restoreBackup = false
@@ -755,6 +2697,12 @@ func (m *Manager) GrantRoleAccess(ctx context.Context, pid uuid.UUID, grantor Ac
 
 // GrantGroupAccess grants accesspolicy rights to a specific group
 func (m *Manager) GrantGroupAccess(ctx context.Context, pid uuid.UUID, grantor Actor, groupID uuid.UUID, rights Right) (err error) {
+	return m.GrantGroupAccessUntil(ctx, pid, grantor, groupID, rights, time.Time{})
+}
+
+// GrantGroupAccessUntil is the same as GrantGroupAccess, but the granted rights
+// expire at a given moment, zero time means they never expire
+func (m *Manager) GrantGroupAccessUntil(ctx context.Context, pid uuid.UUID, grantor Actor, groupID uuid.UUID, rights Right, expiresAt time.Time) (err error) {
 	// safety fuse
 	restoreBackup := true
 
@@ -774,6 +2722,10 @@ func (m *Manager) GrantGroupAccess(ctx context.Context, pid uuid.UUID, grantor A
 		return ErrZeroGrantorID
 	}
 
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return ErrExpiredGrant
+	}
+
 	if groupID == uuid.Nil {
 		return ErrZeroGroupID
 	}
@@ -792,14 +2744,27 @@ func (m *Manager) GrantGroupAccess(ctx context.Context, pid uuid.UUID, grantor A
 		)
 	}
 
+	// archived groups must not be granted anything
+	if g.IsArchived() {
+		return errors.Wrapf(group.ErrGroupInactive, "group_id=%s", groupID)
+	}
+
+	if rights.isReserved() {
+		return ErrReservedRight
+	}
+
 	// checking whether grantor has the right to manage,
-	// and has at least the assigned rights itself
-	if !m.HasRights(ctx, pid, grantor, APManageAccess|rights) {
+	// and has at least the assigned rights itself,
+	// which it's also allowed to pass on
+	if !m.HasRights(ctx, pid, grantor, APManageAccess|rights) || !m.isGrantable(ctx, pid, grantor, rights) {
 		return ErrExcessOfRights
 	}
 
 	// deferred instruction for rosterChange
-	r.change(RSet, NewActor(AKGroup, groupID), rights)
+	r.changeUntil(RSet, NewActor(AKGroup, groupID), rights, expiresAt)
+	r.addAudit(AAGrant, pid, grantor, NewActor(AKGroup, groupID), rights)
+	m.cache.clear()
+	m.recorder().IncGrant()
 
 	// all is good, cancelling restoration
 	restoreBackup = false
@@ -810,6 +2775,12 @@ func (m *Manager) GrantGroupAccess(ctx context.Context, pid uuid.UUID, grantor A
 // GrantUserAccess grants accesspolicy rights to a specific user actor
 // TODO: consider whether it's right to turn off inheritance (if enabled) when setting/changing anything on each accesspolicy policy instance
 func (m *Manager) GrantUserAccess(ctx context.Context, pid uuid.UUID, grantor Actor, userID uuid.UUID, rights Right) (err error) {
+	return m.GrantUserAccessUntil(ctx, pid, grantor, userID, rights, time.Time{})
+}
+
+// GrantUserAccessUntil is the same as GrantUserAccess, but the granted rights
+// expire at a given moment, zero time means they never expire
+func (m *Manager) GrantUserAccessUntil(ctx context.Context, pid uuid.UUID, grantor Actor, userID uuid.UUID, rights Right, expiresAt time.Time) (err error) {
 	// safety fuse
 	restoreBackup := true
 
@@ -829,18 +2800,123 @@ func (m *Manager) GrantUserAccess(ctx context.Context, pid uuid.UUID, grantor Ac
 		return ErrZeroGrantorID
 	}
 
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return ErrExpiredGrant
+	}
+
+	if userID == uuid.Nil {
+		return ErrZeroAssigneeID
+	}
+
+	if rights.isReserved() {
+		return ErrReservedRight
+	}
+
+	// checking whether grantor has the right to manage,
+	// and has at least the assigned rights itself,
+	// which it's also allowed to pass on
+	if !m.HasRights(ctx, pid, grantor, APManageAccess|rights) || !m.isGrantable(ctx, pid, grantor, rights) {
+		return ErrExcessOfRights
+	}
+
+	// deferred instruction for change
+	r.changeUntil(RSet, NewActor(AKUser, userID), rights, expiresAt)
+	r.addAudit(AAGrant, pid, grantor, NewActor(AKUser, userID), rights)
+	m.cache.clear()
+	m.recorder().IncGrant()
+
+	// all is good, cancelling restoration
+	restoreBackup = false
+
+	return nil
+}
+
+// DenyAccess explicitly denies rights to a given actor regardless of what
+// other roster entries grant, only the owners override denials
+// NOTE: replaces the rights previously denied to the actor, zero rights
+// lift the denial, the rights granted to the actor remain intact
+// NOTE: the grantor must hold APManageAccess along with every right it denies
+func (m *Manager) DenyAccess(ctx context.Context, pid uuid.UUID, grantor, grantee Actor, rights Right) error {
+	switch grantee.Kind {
+	case AKEveryone:
+		return ErrPublicDenial
+	case AKUser:
+		return m.DenyUserAccess(ctx, pid, grantor, grantee.ID, rights)
+	case AKRoleGroup:
+		return m.DenyRoleAccess(ctx, pid, grantor, grantee.ID, rights)
+	case AKGroup:
+		return m.DenyGroupAccess(ctx, pid, grantor, grantee.ID, rights)
+	default:
+		return errors.Wrapf(ErrUnrecognizedActorKind, "kind=%d", grantee.Kind)
+	}
+}
+
+// DenyUserAccess explicitly denies rights to a specific user, see DenyAccess
+func (m *Manager) DenyUserAccess(ctx context.Context, pid uuid.UUID, grantor Actor, userID uuid.UUID, rights Right) error {
 	if userID == uuid.Nil {
 		return ErrZeroAssigneeID
 	}
 
+	return m.deny(ctx, pid, grantor, NewActor(AKUser, userID), rights)
+}
+
+// DenyGroupAccess explicitly denies rights to a specific group, see DenyAccess
+func (m *Manager) DenyGroupAccess(ctx context.Context, pid uuid.UUID, grantor Actor, groupID uuid.UUID, rights Right) error {
+	if groupID == uuid.Nil {
+		return ErrZeroGroupID
+	}
+
+	if !m.isGroupKind(ctx, groupID, group.FGroup) {
+		return errors.Wrapf(group.ErrGroupKindMismatch, "expecting a group: group_id=%s", groupID)
+	}
+
+	return m.deny(ctx, pid, grantor, NewActor(AKGroup, groupID), rights)
+}
+
+// DenyRoleAccess explicitly denies rights to a specific role, see DenyAccess
+func (m *Manager) DenyRoleAccess(ctx context.Context, pid uuid.UUID, grantor Actor, roleID uuid.UUID, rights Right) error {
+	if roleID == uuid.Nil {
+		return ErrZeroRoleID
+	}
+
+	if !m.isGroupKind(ctx, roleID, group.FRole) {
+		return errors.Wrapf(group.ErrGroupKindMismatch, "expecting a role: role_id=%s", roleID)
+	}
+
+	return m.deny(ctx, pid, grantor, NewActor(AKRoleGroup, roleID), rights)
+}
+
+func (m *Manager) deny(ctx context.Context, pid uuid.UUID, grantor, grantee Actor, rights Right) error {
+	// safety fuse
+	restoreBackup := true
+
+	r, err := m.RosterByPolicyID(ctx, pid)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain rights roster: policy_id=%s", pid)
+	}
+
+	// will restore backup unless successfully cancelled
+	defer func() {
+		if restoreBackup {
+			r.restoreBackup()
+		}
+	}()
+
+	if grantor.ID == uuid.Nil {
+		return ErrZeroGrantorID
+	}
+
 	// checking whether grantor has the right to manage,
-	// and has at least the assigned rights itself
-	if !m.HasRights(ctx, pid, grantor, APManageAccess|rights) {
+	// and has at least the denied rights itself,
+	// which it's also allowed to pass on
+	if !m.HasRights(ctx, pid, grantor, APManageAccess|rights) || !m.isGrantable(ctx, pid, grantor, rights) {
 		return ErrExcessOfRights
 	}
 
 	// deferred instruction for change
-	r.change(RSet, NewActor(AKUser, userID), rights)
+	r.changeDenied(grantee, rights)
+	r.addAudit(AADeny, pid, grantor, grantee, rights)
+	m.cache.clear()
 
 	// all is good, cancelling restoration
 	restoreBackup = false
@@ -850,81 +2926,277 @@ func (m *Manager) GrantUserAccess(ctx context.Context, pid uuid.UUID, grantor Ac
 
 // UserHasAccess checks whether the user has specific rights
 // NOTE: returns true only if the user has every of specified rights permitted
-// TODO: maybe add some sort of a calculated cache with a short lifespan, like 10ms or something
+// NOTE: calculated rights are cached for a short time, see SetAccessCacheTTL
 func (m *Manager) UserHasAccess(ctx context.Context, pid uuid.UUID, userID uuid.UUID, rights Right) bool {
-	if userID == uuid.Nil {
+	ok, err := m.userHasAccess(ctx, pid, userID, rights)
+	if err != nil {
+		m.reportError(ctx, err)
 		return false
 	}
 
-	p, err := m.PolicyByID(ctx, pid)
+	return ok
+}
+
+func (m *Manager) userHasAccess(ctx context.Context, pid uuid.UUID, userID uuid.UUID, rights Right) (bool, error) {
+	access, err := m.userAccess(ctx, pid, userID)
 	if err != nil {
-		return false
+		return false, err
+	}
+
+	return (access & rights) == rights, nil
+}
+
+// userAccess calculates the resulting rights of a given user
+func (m *Manager) userAccess(ctx context.Context, pid uuid.UUID, userID uuid.UUID) (Right, error) {
+	if userID == uuid.Nil {
+		return APNoAccess, nil
+	}
+
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return APNoAccess, err
+	}
+
+	trace(ctx, TSVisit, p.ID, APNoAccess, "checking user %s", userID)
+
+	// deny if this user is suspended, even if it's an owner
+	if ok, err := m.IsActorSuspended(ctx, userID); err != nil || ok {
+		if err != nil {
+			return APNoAccess, err
+		}
+
+		trace(ctx, TSSuspended, p.ID, APNoAccess, "user is suspended")
+		return APNoAccess, nil
 	}
 
 	// allow if this user is an owner
 	if p.IsOwner(userID) {
-		return true
+		trace(ctx, TSOwner, p.ID, APFullAccess, "user is the owner")
+		return APFullAccess, nil
 	}
 
-	// calculated rights
-	var cr Right
+	// allow if this user owns the policy's domain
+	if ok, err := m.isDomainOwner(ctx, p, userID); err != nil || ok {
+		if err != nil {
+			return APNoAccess, err
+		}
 
-	// calculating parent-related rights if possible
-	if p.ParentID != uuid.Nil {
-		// if the current policy is flagged as inherited, then
-		// using its parent as the primary source of rights
-		if p.IsInherited() {
-			return m.UserHasAccess(ctx, p.ParentID, userID, rights)
+		trace(ctx, TSDomainOwner, p.ID, APFullAccess, "user owns the policy's domain")
+		return APFullAccess, nil
+	}
+
+	// archived policy grants nothing to anyone but the owner
+	if p.IsArchived() {
+		trace(ctx, TSArchived, p.ID, APNoAccess, "policy is archived")
+		return APNoAccess, nil
+	}
+
+	// if the current policy is flagged as inherited, then
+	// using its parent as the primary source of rights
+	if p.ParentID != uuid.Nil && p.IsInherited() {
+		trace(ctx, TSInherit, p.ID, APNoAccess, "inheriting from parent %s", p.ParentID)
+
+		deeper, err := m.descend(ctx)
+		if err != nil {
+			return APNoAccess, errors.Wrapf(err, "policy_id=%s", p.ID)
+		}
+
+		return m.userAccess(deeper, p.ParentID, userID)
+	}
+
+	// using previously calculated rights if they're still fresh
+	// NOTE: traced checks bypass the cache to record every step
+	if TraceFromContext(ctx) == nil {
+		if cr, ok := m.cache.get(p.ID, userID); ok {
+			return cr, nil
 		}
+	}
 
-		if p.IsExtended() {
-			cr = m.SummarizedUserAccess(ctx, p.ParentID, userID)
+	// calculated rights
+	var cr, denied Right
+
+	// NOTE: the rights are resolved in a fixed order: the rights of the
+	// extended parent are the base, then the own grants of this policy
+	// (groups, roles and the user), then its public rights; each roster
+	// contributes its public rights only once, and all denials are subtracted
+	// after everything is combined, so neither the parent's public rights
+	// are lost nor the child's public rights override a denial
+	if p.ParentID != uuid.Nil && p.IsExtended() {
+		if cr, denied, err = m.userRights(ctx, p.ParentID, userID); err != nil {
+			return APNoAccess, err
 		}
+
+		trace(ctx, TSExtend, p.ID, cr&^denied, "extending rights of parent %s", p.ParentID)
 	}
 
-	// merging with the actual policy's rights rosters rights
+	// merging with the actual policy's own and public rights
 	// TODO: consider overriding the extended rights with own
-	cr |= m.SummarizedUserAccess(ctx, pid, userID)
+	own, ownDenied, err := m.userRights(ctx, pid, userID)
+	if err != nil {
+		return APNoAccess, err
+	}
 
-	return (cr & rights) == rights
+	// denials are subtracted only after all granted rights are combined
+	cr = (cr | own) &^ (denied | ownDenied)
+
+	m.cache.put(p.ID, userID, cr)
+
+	return cr, nil
 }
 
 // HasPublicRights checks whether a given policy has specific public rights
 // NOTE: despite it's narrow purpose, it may still be useful to check public rights alone
 func (m *Manager) HasPublicRights(ctx context.Context, policyID uuid.UUID, rights Right) bool {
-	r, err := m.RosterByPolicyID(ctx, policyID)
+	ok, err := m.hasPublicRights(ctx, policyID, rights)
 	if err != nil {
+		m.reportError(ctx, err)
 		return false
 	}
 
-	return (r.Everyone & rights) == rights
+	return ok
+}
+
+func (m *Manager) hasPublicRights(ctx context.Context, policyID uuid.UUID, rights Right) (bool, error) {
+	access, err := m.publicAccess(ctx, policyID)
+	if err != nil {
+		return false, err
+	}
+
+	return (access & rights) == rights, nil
+}
+
+// publicAccess returns the rights granted to everyone
+func (m *Manager) publicAccess(ctx context.Context, policyID uuid.UUID) (Right, error) {
+	p, err := m.PolicyByID(ctx, policyID, false)
+	if err != nil {
+		return APNoAccess, err
+	}
+
+	if p.IsArchived() {
+		return APNoAccess, nil
+	}
+
+	r, err := m.RosterByPolicyID(ctx, policyID)
+	if err != nil {
+		return APNoAccess, err
+	}
+
+	return r.Everyone, nil
 }
 
 // HasGroupRights checks whether a group has the rights
+// NOTE: returns false if the given ID belongs to a role
 func (m *Manager) HasGroupRights(ctx context.Context, policyID, groupID uuid.UUID, rights Right) bool {
+	if !m.isGroupKind(ctx, groupID, group.FGroup) {
+		return false
+	}
+
 	return (m.GroupAccess(ctx, policyID, groupID) & rights) == rights
 }
 
-// HasGroupRights checks whether a role has the rights
-func (m *Manager) HasRoleRights(ctx context.Context, policyID, groupID uuid.UUID, rights Right) bool {
-	return (m.GroupAccess(ctx, policyID, groupID) & rights) == rights
+// HasRoleRights checks whether a role has the rights
+// NOTE: returns false if the given ID belongs to a group
+func (m *Manager) HasRoleRights(ctx context.Context, policyID, roleID uuid.UUID, rights Right) bool {
+	if !m.isGroupKind(ctx, roleID, group.FRole) {
+		return false
+	}
+
+	return (m.GroupAccess(ctx, policyID, roleID) & rights) == rights
+}
+
+// isGroupKind tells whether a group of a given ID exists and is of a given kind
+func (m *Manager) isGroupKind(ctx context.Context, groupID uuid.UUID, kind group.Flags) bool {
+	if m.groups == nil || groupID == uuid.Nil {
+		return false
+	}
+
+	g, err := m.groups.GroupByID(ctx, groupID)
+	if err != nil {
+		if errors.Cause(err) != group.ErrGroupNotFound {
+			m.reportError(ctx, errors.Wrapf(err, "failed to obtain group: group_id=%s", groupID))
+		}
+
+		return false
+	}
+
+	return g.Kind() == kind
 }
 
 // SummarizedUserAccess summarizing the resulting accesspolicy rights of a given user
+// NOTE: summarized rights are cached for a short time, see SetSummaryCacheTTL
 // TODO: use access resolver instead of just OR'ing
 func (m *Manager) SummarizedUserAccess(ctx context.Context, policyID, userID uuid.UUID) (access Right) {
-	p, err := m.PolicyByID(ctx, policyID)
+	access, err := m.SummarizedUserAccessE(ctx, policyID, userID)
 	if err != nil {
+		m.reportError(ctx, err)
 		return APNoAccess
 	}
 
-	r, err := m.RosterByPolicyID(ctx, policyID)
+	return access
+}
+
+// SummarizedUserAccessE is the same as SummarizedUserAccess, but returns an error if
+// the rights couldn't be determined, including the cancellation of a given context
+func (m *Manager) SummarizedUserAccessE(ctx context.Context, policyID, userID uuid.UUID) (access Right, err error) {
+	if err = ctx.Err(); err != nil {
+		return APNoAccess, err
+	}
+
+	return m.summarizedUserAccess(ctx, policyID, userID)
+}
+
+func (m *Manager) summarizedUserAccess(ctx context.Context, policyID, userID uuid.UUID) (access Right, err error) {
+	if suspended, err := m.IsActorSuspended(ctx, userID); err != nil || suspended {
+		return APNoAccess, err
+	}
+
+	p, err := m.PolicyByID(ctx, policyID, false)
 	if err != nil {
-		return APNoAccess
+		return APNoAccess, err
+	}
+
+	// archived policy grants nothing to anyone but the owners,
+	// which is never cached, see userRights
+	if p.IsArchived() {
+		granted, denied, err := m.userRights(ctx, p.ID, userID)
+		if err != nil {
+			return APNoAccess, err
+		}
+
+		return granted &^ denied, nil
+	}
+
+	// using previously summarized rights if they're still fresh
+	// NOTE: traced checks bypass the cache to record every step
+	if TraceFromContext(ctx) == nil {
+		if access, ok := m.cache.getSummary(policyID, userID); ok {
+			return access, nil
+		}
+	}
+
+	granted, denied, err := m.userRights(ctx, policyID, userID)
+	if err != nil {
+		return APNoAccess, err
+	}
+
+	access = granted &^ denied
+	m.cache.putSummary(policyID, userID, access)
+
+	return access, nil
+}
+
+// userRights returns the rights granted to a user by a given policy alone,
+// and the rights which are explicitly denied to the user
+func (m *Manager) userRights(ctx context.Context, policyID, userID uuid.UUID) (granted, denied Right, err error) {
+	p, err := m.PolicyByID(ctx, policyID, false)
+	if err != nil {
+		return APNoAccess, APNoAccess, err
 	}
 
-	// public accesspolicy is the base right
-	access = r.Everyone
+	r, err := m.RosterByPolicyID(ctx, policyID)
+	if err != nil {
+		return APNoAccess, APNoAccess, err
+	}
 
 	// calculating group rights only if policy manager has a reference
 	// to the group manager
@@ -934,20 +3206,65 @@ func (m *Manager) SummarizedUserAccess(ctx context.Context, policyID, userID uui
 		// attempting to obtain the rights of a first ancestor group,
 		// that has specific rights set
 		for _, g := range m.groups.GroupsByAssetID(ctx, group.FRole|group.FGroup, group.NewAsset(group.AKUser, userID)) {
-			access |= m.GroupAccess(ctx, policyID, g.ID)
+			gg, gd, err := m.groupRights(ctx, policyID, g.ID)
+			if err != nil {
+				return APNoAccess, APNoAccess, err
+			}
+
+			trace(ctx, TSGroup, policyID, gg, "member of %s (%s)", g.Key, g.ID)
+
+			if gd != APNoAccess {
+				trace(ctx, TSDeny, policyID, gd, "denied to %s (%s)", g.Key, g.ID)
+			}
+
+			granted |= gg
+			denied |= gd
 		}
 	}
 
+	// user-specific rights
+	cell := r.activeCell(NewActor(AKUser, userID), time.Now())
+	trace(ctx, TSUser, policyID, cell.Rights, "user-specific rights")
+
+	if cell.Denied != APNoAccess {
+		trace(ctx, TSDeny, policyID, cell.Denied, "denied to user")
+	}
+
+	granted |= cell.Rights
+	denied |= cell.Denied
+
+	// public rights come last, after all own grants
+	trace(ctx, TSPublic, policyID, r.Everyone, "public rights")
+	granted |= r.Everyone
+
 	//-!!!-[ WARNING ]-----------------------------------------------------------
 	// !!! USING USER'S OWNERSHIP TO OVERRIDE ITS ACCESS
 	// !!! THIS MEANS THAT OWNERS OF THE PARENT POLICIES WILL HAVE
 	// !!! FULL ACCESS TO ITS CHILDREN
 	// !!! TODO: CONSIDER THIS VERY STRONGLY
 	//-!!!-----------------------------------------------------------------------
+	// NOTE: ownership overrides denials as well
 	if p.IsOwner(userID) {
-		access = APFullAccess
+		trace(ctx, TSOwner, policyID, APFullAccess, "user is the owner")
+		return APFullAccess, APNoAccess, nil
 	}
 
-	// user-specific rights
-	return access | r.lookup(NewActor(AKUser, userID))
+	// domain owners are treated the same way
+	isDomainOwner, err := m.isDomainOwner(ctx, p, userID)
+	if err != nil {
+		return APNoAccess, APNoAccess, err
+	}
+
+	if isDomainOwner {
+		trace(ctx, TSDomainOwner, policyID, APFullAccess, "user owns the policy's domain")
+		return APFullAccess, APNoAccess, nil
+	}
+
+	// archived policy grants nothing to anyone but the owners
+	if p.IsArchived() {
+		trace(ctx, TSArchived, policyID, APNoAccess, "policy is archived")
+		return APNoAccess, APNoAccess, nil
+	}
+
+	return granted, denied, nil
 }
@@ -2,8 +2,11 @@ package accesspolicy_test
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/agubarev/hometown/pkg/database"
 	"github.com/agubarev/hometown/pkg/group"
@@ -13,6 +16,40 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// forEachBackend runs a store-agnostic manager test against every policy store backend
+// NOTE: group rights need a group manager, which is PostgreSQL-only, so the SQLite
+// run gets none, and the tests involving groups run against PostgreSQL alone
+func forEachBackend(t *testing.T, fn func(t *testing.T, s accesspolicy.Store, gm *group.Manager)) {
+	t.Run("postgres", func(t *testing.T) {
+		a := assert.New(t)
+
+		// data instance
+		db := database.PostgreSQLForTesting(nil)
+		a.NotNil(db)
+
+		// policy store
+		s, err := accesspolicy.NewPostgreSQLStore(db)
+		a.NoError(err)
+		a.NotNil(s)
+
+		// group store
+		gs, err := group.NewPostgreSQLStore(db)
+		a.NoError(err)
+		a.NotNil(gs)
+
+		// group manager
+		gm, err := group.NewManager(context.Background(), gs)
+		a.NoError(err)
+		a.NotNil(gm)
+
+		fn(t, s, gm)
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		fn(t, newSQLiteStore(t), nil)
+	})
+}
+
 func TestNewAccessPolicyManager(t *testing.T) {
 	a := assert.New(t)
 
@@ -470,13 +507,13 @@ func TestAccessPolicyManagerUpdate(t *testing.T) {
 	a.NoError(m.SetParent(ctx, p.ID, basePolicy.ID))
 
 	// re-obtaining updated policy
-	p, err = m.PolicyByID(ctx, p.ID)
+	p, err = m.PolicyByID(ctx, p.ID, false)
 	a.NoError(err)
 	a.Equal(basePolicy.ID, p.ParentID)
 
 	// re-obtaining updated policy
 	// NOTE: parent must be set
-	p, err = m.PolicyByID(ctx, p.ID)
+	p, err = m.PolicyByID(ctx, p.ID, false)
 	a.NoError(err)
 	a.Equal(basePolicy.ID, p.ParentID)
 	a.Equal(p.ParentID, basePolicy.ID)
@@ -486,7 +523,7 @@ func TestAccessPolicyManagerUpdate(t *testing.T) {
 
 	// re-obtaining updated policy
 	// NOTE: parent must be cleared
-	p, err = m.PolicyByID(ctx, p.ID)
+	p, err = m.PolicyByID(ctx, p.ID, false)
 	a.NoError(err)
 	a.Equal(uuid.Nil, p.ParentID)
 	a.Zero(p.ParentID)
@@ -498,10 +535,10 @@ func TestAccessPolicyManagerUpdate(t *testing.T) {
 	// attempting to rosterChange object id and save
 	p.ObjectName = "doesn't matter"
 	p.ObjectID = uuid.New()
-	a.EqualError(accesspolicy.ErrForbiddenChange, m.Update(ctx, p).Error())
+	a.True(errors.Is(m.Update(ctx, p), accesspolicy.ErrForbiddenChange))
 
 	// re-obtaining policy
-	p, err = m.PolicyByID(ctx, p.ID)
+	p, err = m.PolicyByID(ctx, p.ID, false)
 	a.NoError(err)
 
 	// set assignor rights
@@ -514,6 +551,135 @@ func TestAccessPolicyManagerUpdate(t *testing.T) {
 	a.False(m.HasRights(ctx, p.ID, act2, accesspolicy.APCreate))
 }
 
+func TestAccessPolicyManagerUpdateRosterOnly(t *testing.T) {
+	a := assert.New(t)
+
+	//---------------------------------------------------------------------------
+	// initializing dependencies
+	//---------------------------------------------------------------------------
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	act1 := accesspolicy.UserActor(uuid.New())
+	act2 := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(
+		ctx,
+		"roster only update", // key
+		act1.ID,              // owner
+		uuid.Nil,             // parent
+		accesspolicy.NilObject(),
+		0, // flags
+	)
+	a.NoError(err)
+
+	// obtaining the cached policy and altering its roster only
+	cached, err := m.PolicyByID(ctx, p.ID, false)
+	a.NoError(err)
+	a.NoError(m.GrantAccess(ctx, cached.ID, act1, act2, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.Update(ctx, cached))
+
+	// a fresh manager has no cache, thus everything comes from the store
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.True(m2.HasRights(ctx, p.ID, act2, accesspolicy.APView|accesspolicy.APChange))
+
+	// illegal field changes must still be rejected, even if the cached copy is altered
+	cached.ObjectName = "doesn't matter"
+	cached.ObjectID = uuid.New()
+	a.True(errors.Is(m.Update(ctx, cached), accesspolicy.ErrForbiddenChange))
+}
+
+func TestAccessPolicyManagerUpdateForbiddenChange(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	obj := accesspolicy.NewObject(uuid.New(), "forbidden change object")
+
+	p, err := m.Create(ctx, "forbidden change", uuid.New(), uuid.Nil, obj, 0)
+	a.NoError(err)
+
+	newObjectID := uuid.New()
+
+	cases := []struct {
+		field  string
+		from   string
+		to     string
+		mutate func(p *accesspolicy.Policy)
+	}{
+		{"key", p.Key, "another key", func(p *accesspolicy.Policy) { p.Key = "another key" }},
+		{"object_name", obj.Name, "another object", func(p *accesspolicy.Policy) { p.ObjectName = "another object" }},
+		{"object_id", obj.ID.String(), newObjectID.String(), func(p *accesspolicy.Policy) { p.ObjectID = newObjectID }},
+	}
+
+	for _, c := range cases {
+		altered := p
+		c.mutate(&altered)
+
+		err = m.Update(ctx, altered)
+		a.Error(err)
+		a.True(errors.Is(err, accesspolicy.ErrForbiddenChange))
+		a.Equal(accesspolicy.ErrForbiddenChange, errors.Cause(err))
+
+		var fce accesspolicy.ForbiddenChangeError
+		a.True(errors.As(err, &fce))
+		a.Len(fce.Changes, 1)
+		a.Equal(c.field, fce.Changes[0].Field)
+		a.Equal(c.from, fce.Changes[0].From)
+		a.Equal(c.to, fce.Changes[0].To)
+	}
+}
+
 func TestAccessPolicyManagerSetRights(t *testing.T) {
 	a := assert.New(t)
 
@@ -686,19 +852,22 @@ func TestAccessPolicyManagerDelete(t *testing.T) {
 	// persisting changes
 	a.NoError(m.Update(ctx, p))
 
+	// each update bumps the version
+	p.Version++
+
 	//---------------------------------------------------------------------------
 	// making sure it's inside the container
 	//---------------------------------------------------------------------------
-	fetchedPolicy, err := m.PolicyByID(ctx, p.ID)
+	fetchedPolicy, err := m.PolicyByID(ctx, p.ID, false)
 	a.NoError(err)
 	a.True(reflect.DeepEqual(p, fetchedPolicy))
 
-	fetchedPolicy, err = m.PolicyByKey(ctx, p.Key)
+	fetchedPolicy, err = m.PolicyByKey(ctx, p.Key, false)
 	a.NoError(err)
 	a.NotNil(fetchedPolicy)
 	a.True(reflect.DeepEqual(p, fetchedPolicy))
 
-	fetchedPolicy, err = m.PolicyByObject(ctx, accesspolicy.NewObject(p.ObjectID, p.ObjectName))
+	fetchedPolicy, err = m.PolicyByObject(ctx, accesspolicy.NewObject(p.ObjectID, p.ObjectName), false)
 	a.NoError(err)
 	a.NotNil(fetchedPolicy)
 	a.True(reflect.DeepEqual(p, fetchedPolicy))
@@ -711,22 +880,4928 @@ func TestAccessPolicyManagerDelete(t *testing.T) {
 	//---------------------------------------------------------------------------
 	// attempting to get policies after their deletion
 	//---------------------------------------------------------------------------
-	fetchedPolicy, err = m.PolicyByID(ctx, p.ID)
+	fetchedPolicy, err = m.PolicyByID(ctx, p.ID, false)
 	a.Error(err)
 	a.EqualError(accesspolicy.ErrPolicyNotFound, errors.Cause(err).Error())
 	a.Zero(fetchedPolicy.ID)
 
-	fetchedPolicy, err = m.PolicyByKey(ctx, p.Key)
+	fetchedPolicy, err = m.PolicyByKey(ctx, p.Key, false)
 	a.Error(err)
 	a.EqualError(accesspolicy.ErrPolicyNotFound, err.Error())
 	a.Zero(fetchedPolicy.ID)
 
-	fetchedPolicy, err = m.PolicyByObject(ctx, accesspolicy.NewObject(p.ObjectID, p.ObjectName))
+	fetchedPolicy, err = m.PolicyByObject(ctx, accesspolicy.NewObject(p.ObjectID, p.ObjectName), false)
 	a.Error(err)
 	a.EqualError(accesspolicy.ErrPolicyNotFound, err.Error())
 	a.Zero(fetchedPolicy.ID)
 }
 
+func TestAccessPolicyManagerExportImport(t *testing.T) {
+	a := assert.New(t)
+
+	//---------------------------------------------------------------------------
+	// initializing dependencies
+	//---------------------------------------------------------------------------
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	g1, err := gm.Create(ctx, group.FGroup, uuid.Nil, "bundle group", "bundle group")
+	a.NoError(err)
+
+	r1, err := gm.Create(ctx, group.FRole, uuid.Nil, "bundle role", "bundle role")
+	a.NoError(err)
+
+	act1 := accesspolicy.UserActor(uuid.New())
+	act2 := accesspolicy.UserActor(uuid.New())
+
+	//---------------------------------------------------------------------------
+	// environment A: creating and exporting a policy
+	//---------------------------------------------------------------------------
+	p, err := m.Create(ctx, "exported policy", act1.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantAccess(ctx, p.ID, act1, accesspolicy.PublicActor(), accesspolicy.APView))
+	a.NoError(m.GrantAccess(ctx, p.ID, act1, act2, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.GrantAccess(ctx, p.ID, act1, accesspolicy.GroupActor(g1.ID), accesspolicy.APView|accesspolicy.APCopy))
+	a.NoError(m.GrantAccess(ctx, p.ID, act1, accesspolicy.RoleActor(r1.ID), accesspolicy.APView|accesspolicy.APMove))
+	a.NoError(m.Update(ctx, p))
+
+	b, err := m.ExportPolicy(ctx, p.ID)
+	a.NoError(err)
+	a.Equal(p.Key, b.Key)
+	a.Equal(act1.ID, b.OwnerID)
+	a.Equal(accesspolicy.APView, b.Everyone)
+	a.Len(b.Entries, 3)
+
+	//---------------------------------------------------------------------------
+	// environment B: same group keys, different group IDs
+	//---------------------------------------------------------------------------
+	a.NoError(gm.DeleteGroup(ctx, g1.ID))
+	a.NoError(gm.DeleteGroup(ctx, r1.ID))
+
+	gm2, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+
+	g2, err := gm2.Create(ctx, group.FGroup, uuid.Nil, "bundle group", "bundle group")
+	a.NoError(err)
+	a.NotEqual(g1.ID, g2.ID)
+
+	r2, err := gm2.Create(ctx, group.FRole, uuid.Nil, "bundle role", "bundle role")
+	a.NoError(err)
+	a.NotEqual(r1.ID, r2.ID)
+
+	m2, err := accesspolicy.NewManager(s, gm2)
+	a.NoError(err)
+
+	imported, err := m2.ImportPolicy(ctx, b, accesspolicy.ImportOptions{Key: "imported policy"})
+	a.NoError(err)
+	a.NotEqual(p.ID, imported.ID)
+	a.Equal(act1.ID, imported.OwnerID)
+
+	// a fresh manager to make sure everything is read from the store
+	m3, err := accesspolicy.NewManager(s, gm2)
+	a.NoError(err)
+
+	a.True(m3.HasPublicRights(ctx, imported.ID, accesspolicy.APView))
+	a.True(m3.HasRights(ctx, imported.ID, act2, accesspolicy.APView|accesspolicy.APChange))
+	a.True(m3.HasGroupRights(ctx, imported.ID, g2.ID, accesspolicy.APView|accesspolicy.APCopy))
+	a.True(m3.HasRoleRights(ctx, imported.ID, r2.ID, accesspolicy.APView|accesspolicy.APMove))
+	a.False(m3.HasGroupRights(ctx, imported.ID, g1.ID, accesspolicy.APView))
+}
+
+func TestAccessPolicyManagerExportImportJSON(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "json group", "json group")
+	a.NoError(err)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	coOwner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+	denied := accesspolicy.UserActor(uuid.New())
+	contractor := accesspolicy.UserActor(uuid.New())
+	delegate := accesspolicy.UserActor(uuid.New())
+	obj := accesspolicy.NewObject(uuid.New(), "json object")
+
+	p, err := m.Create(ctx, "json policy", owner.ID, uuid.Nil, obj, 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.DenyUserAccess(ctx, p.ID, owner, denied.ID, accesspolicy.APView))
+	a.NoError(m.GrantUserAccessUntil(ctx, p.ID, owner, contractor.ID, accesspolicy.APDelete, time.Now().Add(time.Hour)))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, delegate.ID, accesspolicy.APView|accesspolicy.APChange|accesspolicy.APManageAccess))
+	a.NoError(m.SetGrantableRights(ctx, p.ID, delegate, accesspolicy.APView))
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, g.ID, accesspolicy.APView|accesspolicy.APCopy))
+	a.NoError(m.Update(ctx, p))
+	a.NoError(m.AddCoOwner(ctx, p.ID, owner, coOwner.ID))
+
+	type check struct {
+		actor  accesspolicy.Actor
+		rights accesspolicy.Right
+	}
+
+	checks := []check{
+		{owner, accesspolicy.APFullAccess},
+		{coOwner, accesspolicy.APFullAccess},
+		{user, accesspolicy.APView | accesspolicy.APChange},
+		{user, accesspolicy.APDelete},
+		{denied, accesspolicy.APView},
+		{contractor, accesspolicy.APDelete},
+		{delegate, accesspolicy.APView | accesspolicy.APChange},
+		{accesspolicy.GroupActor(g.ID), accesspolicy.APView | accesspolicy.APCopy},
+		{accesspolicy.PublicActor(), accesspolicy.APView},
+		{accesspolicy.PublicActor(), accesspolicy.APChange},
+		{accesspolicy.UserActor(uuid.New()), accesspolicy.APView},
+	}
+
+	before := make([]bool, len(checks))
+	for i, c := range checks {
+		before[i] = m.HasRights(ctx, p.ID, c.actor, c.rights)
+	}
+
+	data, err := m.ExportPolicyJSON(ctx, p.ID)
+	a.NoError(err)
+	a.Contains(string(data), `"key":"json policy"`)
+
+	// malformed data
+	_, err = m.ImportPolicyJSON(ctx, []byte("{"))
+	a.Error(err)
+
+	// both key and object are still taken by the original
+	_, err = m.ImportPolicyJSON(ctx, data)
+	a.Equal(accesspolicy.ErrPolicyKeyTaken, errors.Cause(err))
+
+	a.NoError(m.DeletePolicy(ctx, p))
+
+	imported, err := m.ImportPolicyJSON(ctx, data)
+	a.NoError(err)
+	a.NotEqual(p.ID, imported.ID)
+	a.Equal(p.Key, imported.Key)
+	a.Equal(obj.ID, imported.ObjectID)
+	a.True(imported.IsOwner(coOwner.ID))
+
+	// a fresh manager to make sure everything is read from the store
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	for i, c := range checks {
+		a.Equal(before[i], m2.HasRights(ctx, imported.ID, c.actor, c.rights), "check %d", i)
+	}
+
+	// the grant limit survives as well
+	a.Equal(accesspolicy.ErrExcessOfRights, m2.GrantUserAccess(ctx, imported.ID, delegate, user.ID, accesspolicy.APChange))
+}
+
+func TestAccessPolicyManagerMaxInheritanceDepth(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	var reported []error
+	m.SetErrorReporter(func(ctx context.Context, err error) {
+		reported = append(reported, err)
+	})
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	root, err := m.Create(ctx, "deep root policy", owner.ID, uuid.Nil, accesspolicy.NewObject(uuid.New(), "deep object"), 0)
+	a.NoError(err)
+	a.NoError(m.GrantUserAccess(ctx, root.ID, owner, user.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, root))
+
+	// building a chain of policies, each inheriting from the previous one
+	chain := []accesspolicy.Policy{root}
+	for i := 1; i <= 7; i++ {
+		p, err := m.Create(
+			ctx,
+			fmt.Sprintf("deep policy %d", i), // key
+			owner.ID,                         // owner
+			chain[i-1].ID,                    // parent
+			accesspolicy.NilObject(),         // object
+			accesspolicy.FInherit,            // flags
+		)
+		a.NoError(err)
+
+		chain = append(chain, p)
+	}
+
+	last := chain[len(chain)-1]
+
+	// the default limit is well above the chain length
+	ok, err := m.HasRightsE(ctx, last.ID, user, accesspolicy.APView)
+	a.NoError(err)
+	a.True(ok)
+
+	m.SetMaxInheritanceDepth(5)
+
+	// within the limit
+	ok, err = m.HasRightsE(ctx, chain[5].ID, user, accesspolicy.APView)
+	a.NoError(err)
+	a.True(ok)
+	a.Equal(accesspolicy.APView, m.Access(ctx, chain[5].ID, user.ID))
+
+	// exceeding the limit
+	ok, err = m.HasRightsE(ctx, last.ID, user, accesspolicy.APView)
+	a.Equal(accesspolicy.ErrInheritanceTooDeep, errors.Cause(err))
+	a.False(ok)
+
+	a.False(m.HasRights(ctx, last.ID, user, accesspolicy.APView))
+	a.False(m.UserHasAccess(ctx, last.ID, user.ID, accesspolicy.APView))
+
+	reported = nil
+	a.Equal(accesspolicy.APNoAccess, m.Access(ctx, last.ID, user.ID))
+	if a.NotEmpty(reported) {
+		a.Equal(accesspolicy.ErrInheritanceTooDeep, errors.Cause(reported[0]))
+	}
+
+	// lifting the limit
+	m.SetMaxInheritanceDepth(0)
+
+	ok, err = m.HasRightsE(ctx, last.ID, user, accesspolicy.APView)
+	a.NoError(err)
+	a.True(ok)
+}
+
+func TestAccessPolicyManagerCancelledContext(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	var reported []error
+	m.SetErrorReporter(func(ctx context.Context, err error) {
+		reported = append(reported, err)
+	})
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	base, err := m.Create(ctx, "cancelled base policy", owner.ID, uuid.Nil, accesspolicy.NewObject(uuid.New(), "cancelled object"), 0)
+	a.NoError(err)
+
+	p, err := m.Create(ctx, "cancelled inherited policy", owner.ID, base.ID, accesspolicy.NilObject(), accesspolicy.FInherit)
+	a.NoError(err)
+
+	parent, err := gm.Create(ctx, group.FGroup, uuid.Nil, "cancelled parent group", "cancelled parent group")
+	a.NoError(err)
+
+	child, err := gm.Create(ctx, group.FGroup, parent.ID, "cancelled child group", "cancelled child group")
+	a.NoError(err)
+
+	a.NoError(m.GrantUserAccess(ctx, base.ID, owner, user.ID, accesspolicy.APView))
+	a.NoError(m.GrantGroupAccess(ctx, base.ID, owner, parent.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, base))
+
+	// everything resolves as long as the context is alive
+	access, err := m.AccessE(ctx, p.ID, user.ID)
+	a.NoError(err)
+	a.Equal(accesspolicy.APView, access)
+
+	access, err = m.GroupAccessE(ctx, base.ID, child.ID)
+	a.NoError(err)
+	a.Equal(accesspolicy.APView, access)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	access, err = m.AccessE(cancelled, p.ID, user.ID)
+	a.Equal(context.Canceled, errors.Cause(err))
+	a.Equal(accesspolicy.APNoAccess, access)
+
+	access, err = m.SummarizedUserAccessE(cancelled, base.ID, user.ID)
+	a.Equal(context.Canceled, errors.Cause(err))
+	a.Equal(accesspolicy.APNoAccess, access)
+
+	access, err = m.GroupAccessE(cancelled, base.ID, child.ID)
+	a.Equal(context.Canceled, errors.Cause(err))
+	a.Equal(accesspolicy.APNoAccess, access)
+
+	// cancellation stops the resolution at the first step up the hierarchy
+	ok, err := m.HasRightsE(cancelled, p.ID, user, accesspolicy.APView)
+	a.Equal(context.Canceled, errors.Cause(err))
+	a.False(ok)
+
+	// the variants without an error fail closed
+	reported = nil
+	a.Equal(accesspolicy.APNoAccess, m.Access(cancelled, p.ID, user.ID))
+	a.Equal(accesspolicy.APNoAccess, m.SummarizedUserAccess(cancelled, base.ID, user.ID))
+	a.Equal(accesspolicy.APNoAccess, m.GroupAccess(cancelled, base.ID, child.ID))
+	if a.Len(reported, 3) {
+		for _, err := range reported {
+			a.Equal(context.Canceled, errors.Cause(err))
+		}
+	}
+}
+
+// failingStore simulates a backend failure when fetching policies
+type failingStore struct {
+	accesspolicy.Store
+	err error
+}
+
+func (s *failingStore) FetchPolicyByID(ctx context.Context, id uuid.UUID) (accesspolicy.Policy, error) {
+	return accesspolicy.Policy{}, s.err
+}
+
+func TestAccessPolicyManagerHasRightsStoreError(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	storeErr := errors.New("backend is down")
+
+	// policy manager with a failing store
+	m, err := accesspolicy.NewManager(&failingStore{Store: s, err: storeErr}, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// observing reported errors
+	reported := make([]error, 0)
+	m.SetErrorReporter(func(ctx context.Context, err error) {
+		reported = append(reported, err)
+	})
+
+	actor := accesspolicy.UserActor(uuid.New())
+
+	ok, err := m.HasRightsE(ctx, uuid.New(), actor, accesspolicy.APView)
+	a.False(ok)
+	a.Error(err)
+	a.Equal(storeErr, errors.Cause(err))
+
+	// failing closed
+	a.False(m.HasRights(ctx, uuid.New(), actor, accesspolicy.APView))
+	a.Len(reported, 1)
+	a.Equal(storeErr, errors.Cause(reported[0]))
+
+	// group rights are reported likewise
+	a.Equal(accesspolicy.APNoAccess, m.GroupAccess(ctx, uuid.New(), uuid.New()))
+	a.Len(reported, 2)
+	a.Equal(storeErr, errors.Cause(reported[1]))
+}
+
+func TestAccessPolicyManagerAccessCache(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	m.SetAccessCacheTTL(time.Minute)
+
+	act1 := accesspolicy.UserActor(uuid.New())
+	act2 := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "cached access policy", act1.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	// caching the absence of rights
+	a.False(m.HasRights(ctx, p.ID, act2, accesspolicy.APView))
+
+	// granting must invalidate the cached rights
+	a.NoError(m.GrantAccess(ctx, p.ID, act1, act2, accesspolicy.APView))
+	a.True(m.HasRights(ctx, p.ID, act2, accesspolicy.APView))
+
+	// and so must revoking
+	a.NoError(m.RevokeAccess(ctx, p.ID, act1, act2))
+	a.False(m.HasRights(ctx, p.ID, act2, accesspolicy.APView))
+}
+
+func BenchmarkAccessPolicyManagerUserHasAccess(b *testing.B) {
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	act1 := accesspolicy.UserActor(uuid.New())
+	act2 := accesspolicy.UserActor(uuid.New())
+
+	base, err := m.Create(ctx, "benchmark base policy", act1.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	p, err := m.Create(ctx, "benchmark extended policy", act1.ID, base.ID, accesspolicy.NilObject(), accesspolicy.FExtend)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if err = m.GrantAccess(ctx, p.ID, act1, act2, accesspolicy.APView); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, ttl := range []time.Duration{0, 10 * time.Millisecond} {
+		b.Run(fmt.Sprintf("ttl=%s", ttl), func(b *testing.B) {
+			m.SetAccessCacheTTL(ttl)
+
+			for i := 0; i < b.N; i++ {
+				m.UserHasAccess(ctx, p.ID, act2.ID, accesspolicy.APView)
+			}
+		})
+	}
+}
+
+func BenchmarkAccessPolicyManagerHasRightsBatch(b *testing.B) {
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	owner := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "benchmark batch policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// a few users sharing a group, checked for several rights each
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "benchmark batch group", "benchmark batch group")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if err = m.GrantGroupAccess(ctx, p.ID, owner, g.ID, accesspolicy.APView); err != nil {
+		b.Fatal(err)
+	}
+
+	checks := make([]accesspolicy.AccessCheck, 0)
+	for i := 0; i < 8; i++ {
+		user := accesspolicy.UserActor(uuid.New())
+
+		if err = gm.CreateRelation(ctx, group.NewRelation(g.ID, group.AKUser, user.ID)); err != nil {
+			b.Fatal(err)
+		}
+
+		for _, rights := range []accesspolicy.Right{accesspolicy.APView, accesspolicy.APChange, accesspolicy.APDelete} {
+			checks = append(checks, accesspolicy.AccessCheck{Actor: user, Rights: rights})
+		}
+	}
+
+	// caching would hide the difference
+	m.SetAccessCacheTTL(0)
+
+	b.Run("individual", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, c := range checks {
+				m.HasRights(ctx, p.ID, c.Actor, c.Rights)
+			}
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m.HasRightsBatch(ctx, p.ID, checks)
+		}
+	})
+}
+
+func TestAccessPolicyManagerSummaryCache(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	m.SetSummaryCacheTTL(time.Hour)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "summary cache policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "summary cache group", "summary cache group")
+	a.NoError(err)
+
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, g.ID, accesspolicy.APView))
+	a.Equal(accesspolicy.APNoAccess, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+
+	// cached rights are discarded as soon as the group membership changes
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(g.ID, group.AKUser, user.ID)))
+	a.Equal(accesspolicy.APView, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+
+	// any roster change discards the cache as well
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APCopy))
+	a.Equal(accesspolicy.APView|accesspolicy.APCopy, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+
+	a.NoError(gm.DeleteRelation(ctx, group.NewRelation(g.ID, group.AKUser, user.ID)))
+	a.Equal(accesspolicy.APCopy, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+}
+
+func BenchmarkAccessPolicyManagerSummarizedUserAccess(b *testing.B) {
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "benchmark summarized policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	// the user is a member of the deepest group, while the rights
+	// are granted to the topmost one, thus the whole chain is walked
+	var parentID uuid.UUID
+	for i := 0; i < 4; i++ {
+		g, err := gm.Create(ctx, group.FGroup, parentID, fmt.Sprintf("summarized group %d", i), fmt.Sprintf("summarized group %d", i))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if parentID == uuid.Nil {
+			if err = m.GrantGroupAccess(ctx, p.ID, owner, g.ID, accesspolicy.APView); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		parentID = g.ID
+	}
+
+	if err = gm.CreateRelation(ctx, group.NewRelation(parentID, group.AKUser, user.ID)); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, ttl := range []time.Duration{0, accesspolicy.DefaultSummaryCacheTTL} {
+		b.Run(fmt.Sprintf("ttl=%s", ttl), func(b *testing.B) {
+			m.SetSummaryCacheTTL(ttl)
+
+			for i := 0; i < b.N; i++ {
+				m.SummarizedUserAccess(ctx, p.ID, user.ID)
+			}
+		})
+	}
+}
+
+func TestAccessPolicyManagerSoftDelete(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		user := accesspolicy.UserActor(uuid.New())
+		obj := accesspolicy.NewObject(uuid.New(), "soft deleted object")
+
+		p, err := m.Create(ctx, "soft deleted policy", owner.ID, uuid.Nil, obj, 0)
+		a.NoError(err)
+
+		child, err := m.Create(ctx, "soft deleted child policy", owner.ID, p.ID, accesspolicy.NilObject(), accesspolicy.FInherit)
+		a.NoError(err)
+
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView))
+		a.NoError(m.Update(ctx, p))
+
+		// the parent can't be soft-deleted while it has live children
+		p, err = m.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+		a.Equal(accesspolicy.ErrPolicyHasChildren, m.SoftDeletePolicy(ctx, p))
+
+		a.NoError(m.SoftDeletePolicy(ctx, child))
+		a.NoError(m.SoftDeletePolicy(ctx, p))
+
+		// soft-deleted policies are skipped by default
+		_, err = m.PolicyByID(ctx, p.ID, false)
+		a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+
+		_, err = m.PolicyByKey(ctx, p.Key, false)
+		a.Equal(accesspolicy.ErrPolicyNotFound, err)
+
+		_, err = m.PolicyByObject(ctx, obj, false)
+		a.Equal(accesspolicy.ErrPolicyNotFound, err)
+
+		a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+
+		ok, err := m.PolicyExists(ctx, p.ID)
+		a.NoError(err)
+		a.False(ok)
+
+		// but are still there if asked for
+		deleted, err := m.PolicyByID(ctx, p.ID, true)
+		a.NoError(err)
+		a.True(deleted.IsDeleted())
+
+		deleted, err = m.PolicyByKey(ctx, p.Key, true)
+		a.NoError(err)
+		a.Equal(p.ID, deleted.ID)
+
+		deleted, err = m.PolicyByObject(ctx, obj, true)
+		a.NoError(err)
+		a.Equal(p.ID, deleted.ID)
+
+		// soft-deleted policy can't be updated
+		a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(m.Update(ctx, deleted)))
+
+		// the child can't be restored before its parent
+		a.Equal(accesspolicy.ErrInvalidParentPolicy, errors.Cause(m.RestorePolicy(ctx, child.ID)))
+
+		// restoring
+		a.NoError(m.RestorePolicy(ctx, p.ID))
+		a.NoError(m.RestorePolicy(ctx, child.ID))
+
+		p, err = m.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+		a.False(p.IsDeleted())
+		a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+
+		_, err = m.PolicyByID(ctx, child.ID, false)
+		a.NoError(err)
+
+		// the key and the object of a soft-deleted policy may be taken again
+		a.NoError(m.SoftDeletePolicy(ctx, child))
+		a.NoError(m.SoftDeletePolicy(ctx, p))
+
+		recreated, err := m.Create(ctx, p.Key, owner.ID, uuid.Nil, obj, 0)
+		a.NoError(err)
+		a.NotEqual(p.ID, recreated.ID)
+
+		fetched, err := m.PolicyByKey(ctx, p.Key, true)
+		a.NoError(err)
+		a.Equal(recreated.ID, fetched.ID)
+
+		fetched, err = m.PolicyByObject(ctx, obj, true)
+		a.NoError(err)
+		a.Equal(recreated.ID, fetched.ID)
+
+		// which prevents the old one from being restored
+		a.Equal(accesspolicy.ErrPolicyKeyTaken, m.RestorePolicy(ctx, p.ID))
+	})
+}
+
+func TestAccessPolicyManagerExpiredMembership(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// the summarized rights must not be cached for this test
+	m.SetSummaryCacheTTL(0)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "expired membership policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "temporary team", "temporary team")
+	a.NoError(err)
+
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, g.ID, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.Update(ctx, p))
+
+	a.NoError(gm.CreateRelation(ctx, group.NewRelationUntil(g.ID, group.AKUser, user.ID, time.Now().Add(50*time.Millisecond))))
+	a.Equal(accesspolicy.APView|accesspolicy.APChange, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+
+	// the group-derived rights are gone along with the membership
+	time.Sleep(60 * time.Millisecond)
+
+	a.Equal(accesspolicy.APNoAccess, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+	a.False(m.UserHasAccess(ctx, p.ID, user.ID, accesspolicy.APView))
+}
+
+func TestAccessPolicyManagerMembershipInvalidatesCache(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	m.SetAccessCacheTTL(time.Hour)
+	m.SetSummaryCacheTTL(time.Hour)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "membership cache policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "membership cache group", "membership cache group")
+	a.NoError(err)
+
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, g.ID, accesspolicy.APView))
+
+	// not a member yet
+	a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+	a.Equal(accesspolicy.APNoAccess, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+
+	// joining the group, without clearing any caches manually
+	rel := group.NewRelation(g.ID, group.AKUser, user.ID)
+	a.NoError(gm.CreateRelation(ctx, rel))
+	a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+	a.Equal(accesspolicy.APView, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+
+	// leaving the group
+	a.NoError(gm.DeleteRelation(ctx, rel))
+	a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+	a.Equal(accesspolicy.APNoAccess, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+}
+
+func TestAccessPolicyManagerGroupReparentInvalidatesCache(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	m.SetAccessCacheTTL(time.Hour)
+	m.SetSummaryCacheTTL(time.Hour)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "reparent cache policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	viewers, err := gm.Create(ctx, group.FGroup, uuid.Nil, "reparent viewers", "reparent viewers")
+	a.NoError(err)
+
+	editors, err := gm.Create(ctx, group.FGroup, uuid.Nil, "reparent editors", "reparent editors")
+	a.NoError(err)
+
+	// the member group has no rights of its own, inheriting those of its parent
+	members, err := gm.Create(ctx, group.FGroup, viewers.ID, "reparent members", "reparent members")
+	a.NoError(err)
+
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(members.ID, group.AKUser, user.ID)))
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, viewers.ID, accesspolicy.APView))
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, editors.ID, accesspolicy.APChange))
+
+	a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+	a.Equal(accesspolicy.APView, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+
+	// moving the member group under another parent
+	a.NoError(gm.SetGroupParent(ctx, members.ID, editors.ID))
+	a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+	a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APChange))
+	a.Equal(accesspolicy.APChange, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+
+	// invalidating explicitly
+	a.NoError(m.InvalidateGroup(ctx, members.ID))
+	a.Equal(accesspolicy.APChange, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+	a.Error(m.InvalidateGroup(ctx, uuid.New()))
+}
+
+func TestAccessPolicyManagerPoliciesByObjectName(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	ownerID := uuid.New()
+	name := "paged object " + uuid.New().String()[:8]
+
+	created := make(map[uuid.UUID]bool)
+	for i := 0; i < 5; i++ {
+		p, err := m.Create(ctx, fmt.Sprintf("%s policy %d", name, i), ownerID, uuid.Nil, accesspolicy.NewObject(uuid.New(), name), 0)
+		a.NoError(err)
+
+		created[p.ID] = true
+	}
+
+	_, err = m.Create(ctx, name+" another policy", ownerID, uuid.Nil, accesspolicy.NewObject(uuid.New(), name+" another"), 0)
+	a.NoError(err)
+
+	_, err = m.PoliciesByObjectName(ctx, " ", 10, 0, false)
+	a.Equal(accesspolicy.ErrEmptyObjectName, err)
+
+	// a fresh manager to make sure everything is read from the store
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	// paging through, each policy once
+	seen := make(map[uuid.UUID]bool)
+	for offset := 0; offset < 6; offset += 2 {
+		ps, err := m2.PoliciesByObjectName(ctx, name, 2, offset, false)
+		a.NoError(err)
+
+		if offset < 4 {
+			a.Len(ps, 2)
+		} else {
+			a.Len(ps, 1)
+		}
+
+		for _, p := range ps {
+			a.Equal(name, p.ObjectName)
+			a.False(seen[p.ID])
+			seen[p.ID] = true
+		}
+	}
+
+	a.Equal(created, seen)
+
+	// ordered by object ID
+	all, err := m2.PoliciesByObjectName(ctx, name, 0, 0, false)
+	a.NoError(err)
+	if a.Len(all, 5) {
+		for i := 1; i < len(all); i++ {
+			a.True(all[i-1].ObjectID.String() < all[i].ObjectID.String())
+		}
+	}
+
+	ps, err := m2.PoliciesByObjectName(ctx, name, 2, 10, false)
+	a.NoError(err)
+	a.Empty(ps)
+
+	// soft-deleted policies are not listed
+	a.NoError(m2.SoftDeletePolicy(ctx, all[0]))
+
+	ps, err = m2.PoliciesByObjectName(ctx, name, 0, 0, false)
+	a.NoError(err)
+	a.Len(ps, 4)
+}
+
+func TestAccessPolicyManagerClonePolicy(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	coOwner := accesspolicy.UserActor(uuid.New())
+	viewer := accesspolicy.UserActor(uuid.New())
+	denied := accesspolicy.UserActor(uuid.New())
+	stranger := accesspolicy.UserActor(uuid.New())
+
+	parent, err := m.Create(ctx, "clone parent policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	src, err := m.Create(ctx, "clone source policy", owner.ID, parent.ID, accesspolicy.NewObject(uuid.New(), "clone source object"), 0)
+	a.NoError(err)
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "clone group", "clone group")
+	a.NoError(err)
+
+	a.NoError(m.AddCoOwner(ctx, src.ID, owner, coOwner.ID))
+	a.NoError(m.GrantPublicAccess(ctx, src.ID, owner, accesspolicy.APView))
+	a.NoError(m.GrantUserAccess(ctx, src.ID, owner, viewer.ID, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.DenyUserAccess(ctx, src.ID, owner, denied.ID, accesspolicy.APView))
+	a.NoError(m.GrantGroupAccess(ctx, src.ID, owner, g.ID, accesspolicy.APCopy))
+	a.NoError(m.SetGrantableRights(ctx, src.ID, viewer, accesspolicy.APView))
+
+	src, err = m.PolicyByID(ctx, src.ID, false)
+	a.NoError(err)
+	a.NoError(m.Update(ctx, src))
+
+	clone, err := m.ClonePolicy(ctx, src.ID, "clone target policy")
+	a.NoError(err)
+	a.NotEqual(src.ID, clone.ID)
+	a.Equal("clone target policy", clone.Key)
+	a.Equal(src.OwnerID, clone.OwnerID)
+	a.Equal(src.ParentID, clone.ParentID)
+	a.Equal(src.Flags, clone.Flags)
+	a.Equal([]uuid.UUID{coOwner.ID}, clone.CoOwners)
+
+	// the object isn't cloned
+	a.Empty(clone.ObjectName)
+	a.Equal(uuid.Nil, clone.ObjectID)
+
+	// a fresh manager to make sure everything is read from the store
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	actors := []accesspolicy.Actor{owner, coOwner, viewer, denied, stranger, accesspolicy.GroupActor(g.ID)}
+	rights := []accesspolicy.Right{
+		accesspolicy.APView,
+		accesspolicy.APChange,
+		accesspolicy.APCopy,
+		accesspolicy.APDelete,
+		accesspolicy.APManageAccess,
+	}
+
+	for _, actor := range actors {
+		for _, right := range rights {
+			a.Equal(
+				m2.HasRights(ctx, src.ID, actor, right),
+				m2.HasRights(ctx, clone.ID, actor, right),
+				"actor=%v, right=%s", actor, right,
+			)
+		}
+	}
+
+	a.False(m2.HasRights(ctx, clone.ID, denied, accesspolicy.APView))
+	a.True(m2.HasRights(ctx, clone.ID, viewer, accesspolicy.APChange))
+
+	// grant limits are cloned as well
+	a.Error(m2.GrantUserAccess(ctx, clone.ID, viewer, stranger.ID, accesspolicy.APChange))
+
+	// the key must be available
+	_, err = m.ClonePolicy(ctx, src.ID, "clone target policy")
+	a.Equal(accesspolicy.ErrPolicyKeyTaken, err)
+
+	_, err = m.ClonePolicy(ctx, uuid.New(), "clone of nothing")
+	a.Error(err)
+}
+
+type fakeRecorder struct {
+	grants   int
+	revokes  int
+	granted  int
+	denied   int
+	storeOps map[string]int
+}
+
+func (r *fakeRecorder) IncGrant()  { r.grants++ }
+func (r *fakeRecorder) IncRevoke() { r.revokes++ }
+
+func (r *fakeRecorder) IncHasRights(granted bool) {
+	if granted {
+		r.granted++
+	} else {
+		r.denied++
+	}
+}
+
+func (r *fakeRecorder) ObserveStoreLatency(op string, d time.Duration) {
+	r.storeOps[op]++
+}
+
+func TestAccessPolicyManagerMetrics(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	rec := &fakeRecorder{storeOps: make(map[string]int)}
+	m.SetMetrics(rec)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	grantee := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "metered policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+	a.Equal(1, rec.storeOps["CreatePolicy"])
+
+	a.NoError(m.GrantAccess(ctx, p.ID, owner, grantee, accesspolicy.APView))
+	a.Equal(1, rec.grants)
+
+	a.NoError(m.Update(ctx, p))
+	a.Equal(1, rec.storeOps["UpdatePolicy"])
+
+	rec.granted, rec.denied = 0, 0
+	a.True(m.HasRights(ctx, p.ID, grantee, accesspolicy.APView))
+	a.False(m.HasRights(ctx, p.ID, grantee, accesspolicy.APChange))
+	a.Equal(1, rec.granted)
+	a.Equal(1, rec.denied)
+
+	a.NoError(m.RevokeAccess(ctx, p.ID, owner, grantee))
+	a.Equal(1, rec.revokes)
+
+	// failed grants are not counted
+	a.Error(m.GrantAccess(ctx, p.ID, grantee, owner, accesspolicy.APView))
+	a.Equal(1, rec.grants)
+
+	// disabling metrics
+	m.SetMetrics(nil)
+	a.NoError(m.GrantAccess(ctx, p.ID, owner, grantee, accesspolicy.APView))
+	a.Equal(1, rec.grants)
+}
+
+type auditRecorder struct {
+	records []accesspolicy.AuditRecord
+}
+
+func (s *auditRecorder) Record(ctx context.Context, rec accesspolicy.AuditRecord) {
+	s.records = append(s.records, rec)
+}
+
+func TestAccessPolicyManagerAuditSink(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	sink := &auditRecorder{}
+	m.SetAuditSink(sink)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	grantee := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "audited policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	// nothing is recorded until the changes are stored
+	a.NoError(m.GrantAccess(ctx, p.ID, owner, grantee, accesspolicy.APView|accesspolicy.APChange))
+	a.Empty(sink.records)
+
+	a.NoError(m.Update(ctx, p))
+	a.Len(sink.records, 1)
+
+	rec := sink.records[0]
+	a.Equal(accesspolicy.AAGrant, rec.Action)
+	a.Equal(p.ID, rec.PolicyID)
+	a.Equal(owner, rec.Grantor)
+	a.Equal(grantee, rec.Grantee)
+	a.Equal(accesspolicy.APView|accesspolicy.APChange, rec.Rights)
+	a.False(rec.Timestamp.IsZero())
+
+	// a failed grant leaves no record
+	err = m.GrantAccess(ctx, p.ID, grantee, accesspolicy.UserActor(uuid.New()), accesspolicy.APView)
+	a.Equal(accesspolicy.ErrExcessOfRights, errors.Cause(err))
+
+	a.NoError(m.Update(ctx, p))
+	a.Len(sink.records, 1)
+
+	// revocation
+	a.NoError(m.RevokeAccess(ctx, p.ID, owner, grantee))
+	a.NoError(m.Update(ctx, p))
+	a.Len(sink.records, 2)
+
+	rec = sink.records[1]
+	a.Equal(accesspolicy.AARevoke, rec.Action)
+	a.Equal(owner, rec.Grantor)
+	a.Equal(grantee, rec.Grantee)
+	a.Equal(accesspolicy.APNoAccess, rec.Rights)
+}
+
+func TestAccessPolicyManagerUpdateBatch(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	grantee := accesspolicy.UserActor(uuid.New())
+
+	ps := make([]accesspolicy.Policy, 3)
+	for i := range ps {
+		ps[i], err = m.Create(ctx, fmt.Sprintf("batch update %d", i), owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		a.NoError(m.GrantAccess(ctx, ps[i].ID, owner, grantee, accesspolicy.APView))
+	}
+
+	// the second policy is updated elsewhere in the meantime
+	other, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	p, err := other.PolicyByID(ctx, ps[1].ID, false)
+	a.NoError(err)
+	a.NoError(other.Update(ctx, p))
+
+	err = m.UpdateBatch(ctx, ps)
+	a.Equal(accesspolicy.ErrStaleUpdate, errors.Cause(err))
+
+	// the first update is rolled back along with the rest
+	fresh, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	for _, p := range []accesspolicy.Policy{ps[0], ps[2]} {
+		stored, err := fresh.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+		a.Equal(p.Version, stored.Version)
+		a.False(fresh.HasRights(ctx, p.ID, grantee, accesspolicy.APView))
+
+		// unsaved changes are discarded as well
+		r, err := m.RosterByPolicyID(ctx, p.ID)
+		a.NoError(err)
+		a.False(r.HasChanges())
+		a.False(m.HasRights(ctx, p.ID, grantee, accesspolicy.APView))
+	}
+
+	// a successful batch stores everything
+	for i := range ps {
+		ps[i], err = m.PolicyByID(ctx, ps[i].ID, false)
+		a.NoError(err)
+
+		a.NoError(m.GrantAccess(ctx, ps[i].ID, owner, grantee, accesspolicy.APView))
+	}
+
+	a.NoError(m.UpdateBatch(ctx, ps))
+
+	fresh, err = accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	for _, p := range ps {
+		a.True(fresh.HasRights(ctx, p.ID, grantee, accesspolicy.APView))
+	}
+}
+
+func TestAccessPolicyManagerRightsDiff(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	userA := accesspolicy.UserActor(uuid.New())
+	userB := accesspolicy.UserActor(uuid.New())
+
+	admins, err := gm.Create(ctx, group.FGroup, uuid.Nil, "diff admins", "diff admins")
+	a.NoError(err)
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(admins.ID, group.AKUser, userA.ID)))
+
+	p, err := m.Create(ctx, "rights diff", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, admins.ID, accesspolicy.APChange|accesspolicy.APDelete))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, userB.ID, accesspolicy.APCopy))
+	a.NoError(m.Update(ctx, p))
+
+	onlyA, onlyB, both, err := m.RightsDiffE(ctx, p.ID, userA, userB)
+	a.NoError(err)
+	a.Equal(accesspolicy.APChange|accesspolicy.APDelete, onlyA)
+	a.Equal(accesspolicy.APCopy, onlyB)
+	a.Equal(accesspolicy.APView, both)
+
+	// the owner has everything
+	onlyA, onlyB, both = m.RightsDiff(ctx, p.ID, owner, userA)
+	a.Equal(accesspolicy.APFullAccess&^(accesspolicy.APView|accesspolicy.APChange|accesspolicy.APDelete), onlyA)
+	a.Equal(accesspolicy.APNoAccess, onlyB)
+	a.Equal(accesspolicy.APView|accesspolicy.APChange|accesspolicy.APDelete, both)
+
+	// a group passed as a role has nothing
+	onlyA, onlyB, both = m.RightsDiff(ctx, p.ID, accesspolicy.GroupActor(admins.ID), accesspolicy.NewActor(accesspolicy.AKRoleGroup, admins.ID))
+	a.Equal(accesspolicy.APChange|accesspolicy.APDelete, onlyA)
+	a.Equal(accesspolicy.APNoAccess, onlyB)
+	a.Equal(accesspolicy.APNoAccess, both)
+
+	_, _, _, err = m.RightsDiffE(ctx, uuid.Nil, userA, userB)
+	a.Equal(accesspolicy.ErrNilPolicyID, err)
+}
+
+func TestAccessPolicyManagerEnsurePolicyForObject(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	ownerID := uuid.New()
+	obj := accesspolicy.NewObject(uuid.New(), "ensured object")
+
+	const n = 10
+
+	var wg sync.WaitGroup
+	ids := make([]uuid.UUID, n)
+	errs := make([]error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			p, err := m.EnsurePolicyForObject(ctx, "ensured policy", ownerID, obj, 0)
+			ids[i], errs[i] = p.ID, err
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		a.NoError(errs[i])
+		a.NotEqual(uuid.Nil, ids[i])
+		a.Equal(ids[0], ids[i])
+	}
+
+	// existing policy is returned as is
+	p, err := m.EnsurePolicyForObject(ctx, "another key", uuid.New(), obj, 0)
+	a.NoError(err)
+	a.Equal(ids[0], p.ID)
+	a.Equal(ownerID, p.OwnerID)
+	a.Equal("ensured policy", p.Key)
+
+	_, err = m.EnsurePolicyForObject(ctx, "", ownerID, accesspolicy.NilObject(), 0)
+	a.Equal(accesspolicy.ErrEmptyObjectName, err)
+
+	_, err = m.EnsurePolicyForObject(ctx, "", ownerID, accesspolicy.NewObject(uuid.Nil, "ensured object"), 0)
+	a.Equal(accesspolicy.ErrNilObjectID, err)
+}
+
+func TestAccessPolicyManagerRightsForObject(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+	obj := accesspolicy.NewObject(uuid.New(), "protected object")
+
+	p, err := m.Create(ctx, "protected object policy", owner.ID, uuid.Nil, obj, 0)
+	a.NoError(err)
+	a.NoError(m.GrantAccess(ctx, p.ID, owner, user, accesspolicy.APView|accesspolicy.APChange))
+
+	// policy is present
+	access, err := m.RightsForObject(ctx, obj, user.ID)
+	a.NoError(err)
+	a.Equal(accesspolicy.APView|accesspolicy.APChange, access)
+
+	access, err = m.RightsForObject(ctx, obj, owner.ID)
+	a.NoError(err)
+	a.Equal(accesspolicy.APFullAccess, access)
+
+	access, err = m.RightsForObject(ctx, obj, uuid.New())
+	a.NoError(err)
+	a.Equal(accesspolicy.APNoAccess, access)
+
+	// policy is absent
+	access, err = m.RightsForObject(ctx, accesspolicy.NewObject(uuid.New(), "protected object"), user.ID)
+	a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+	a.Equal(accesspolicy.APNoAccess, access)
+
+	_, err = m.RightsForObject(ctx, accesspolicy.NilObject(), user.ID)
+	a.Equal(accesspolicy.ErrEmptyObjectName, err)
+
+	_, err = m.RightsForObject(ctx, accesspolicy.NewObject(uuid.Nil, "protected object"), user.ID)
+	a.Equal(accesspolicy.ErrNilObjectID, err)
+}
+
+func TestAccessPolicyManagerGrantPublicAccessAsOwner(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	manager := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "public by owner", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	// freshly created owner has nothing stored, yet may set the public rights
+	r, err := m.RosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+	a.Equal(accesspolicy.APNoAccess, r.Everyone)
+	a.Empty(r.PendingChanges())
+
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+	a.NoError(m.Update(ctx, p))
+	a.True(m.HasPublicRights(ctx, p.ID, accesspolicy.APView))
+
+	// managing access alone isn't enough to pass on a right
+	a.NoError(m.GrantAccess(ctx, p.ID, owner, manager, accesspolicy.APManageAccess|accesspolicy.APView))
+	a.NoError(m.Update(ctx, p))
+
+	err = m.GrantPublicAccess(ctx, p.ID, manager, accesspolicy.APChange)
+	a.Equal(accesspolicy.ErrExcessOfRights, err)
+
+	// but held rights may be passed on
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, manager, accesspolicy.APView))
+}
+
+func TestAccessPolicyManagerAccessBreakdown(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	ownerID := uuid.New()
+	userID := uuid.New()
+
+	editors, err := gm.Create(ctx, group.FGroup, uuid.Nil, "breakdown editors", "breakdown editors")
+	a.NoError(err)
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(editors.ID, group.AKUser, userID)))
+
+	moderators, err := gm.Create(ctx, group.FRole, uuid.Nil, "breakdown moderators", "breakdown moderators")
+	a.NoError(err)
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(moderators.ID, group.AKUser, userID)))
+
+	p, err := m.Create(ctx, "access breakdown", ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	owner := accesspolicy.UserActor(ownerID)
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, editors.ID, accesspolicy.APChange))
+	a.NoError(m.GrantRoleAccess(ctx, p.ID, owner, moderators.ID, accesspolicy.APDelete))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, userID, accesspolicy.APCopy))
+	a.NoError(m.Update(ctx, p))
+
+	e, err := m.AccessBreakdown(ctx, p.ID, userID)
+	a.NoError(err)
+	a.Equal(accesspolicy.APView, e.Public)
+	a.Equal(map[uuid.UUID]accesspolicy.Right{editors.ID: accesspolicy.APChange}, e.Groups)
+	a.Equal(map[uuid.UUID]accesspolicy.Right{moderators.ID: accesspolicy.APDelete}, e.Roles)
+	a.Equal(accesspolicy.APCopy, e.User)
+	a.Equal(accesspolicy.APNoAccess, e.Denied)
+	a.False(e.Owner)
+	a.Equal(accesspolicy.APView|accesspolicy.APChange|accesspolicy.APDelete|accesspolicy.APCopy, e.Access)
+	a.Equal(m.Access(ctx, p.ID, userID), e.Access)
+
+	// ownership forces full access
+	e, err = m.AccessBreakdown(ctx, p.ID, ownerID)
+	a.NoError(err)
+	a.True(e.Owner)
+	a.Equal(accesspolicy.APFullAccess, e.Access)
+
+	_, err = m.AccessBreakdown(ctx, uuid.Nil, userID)
+	a.Equal(accesspolicy.ErrNilPolicyID, err)
+}
+
+func TestAccessPolicyManagerUnrecognizedActorKind(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "unrecognized actor kind", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	var zero accesspolicy.Actor
+
+	// as a grantee
+	err = m.GrantAccess(ctx, p.ID, owner, zero, accesspolicy.APView)
+	a.Equal(accesspolicy.ErrUnrecognizedActorKind, errors.Cause(err))
+
+	err = m.RevokeAccess(ctx, p.ID, owner, zero)
+	a.Equal(accesspolicy.ErrUnrecognizedActorKind, errors.Cause(err))
+
+	// as a grantor, instead of a plain denial
+	err = m.GrantAccess(ctx, p.ID, zero, owner, accesspolicy.APView)
+	a.Equal(accesspolicy.ErrUnrecognizedActorKind, errors.Cause(err))
+
+	err = m.RevokeAccess(ctx, p.ID, accesspolicy.NewActor(0, uuid.New()), owner)
+	a.Equal(accesspolicy.ErrUnrecognizedActorKind, errors.Cause(err))
+
+	// rights checks
+	_, err = m.HasRightsE(ctx, p.ID, zero, accesspolicy.APView)
+	a.Equal(accesspolicy.ErrUnrecognizedActorKind, errors.Cause(err))
+	a.False(m.HasRights(ctx, p.ID, zero, accesspolicy.APView))
+
+	// nothing has changed
+	changes, err := m.PendingChanges(ctx, p.ID)
+	a.NoError(err)
+	a.Empty(changes)
+}
+
+func TestAccessPolicyManagerUnsetPublicAccess(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+	stranger := accesspolicy.UserActor(uuid.New())
+
+	editors, err := gm.Create(ctx, group.FGroup, uuid.Nil, "unset public editors", "unset public editors")
+	a.NoError(err)
+
+	p, err := m.Create(ctx, "unset public access", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APChange))
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, editors.ID, accesspolicy.APDelete))
+	a.NoError(m.Update(ctx, p))
+	a.True(m.HasRights(ctx, p.ID, stranger, accesspolicy.APView))
+
+	// only the owner or whoever manages access may unset it
+	a.Equal(accesspolicy.ErrAccessDenied, m.UnsetPublicAccess(ctx, p.ID, user))
+
+	a.NoError(m.UnsetPublicAccess(ctx, p.ID, owner))
+	a.NoError(m.Update(ctx, p))
+
+	a.False(m.HasRights(ctx, p.ID, stranger, accesspolicy.APView))
+	a.False(m.HasRights(ctx, p.ID, accesspolicy.PublicActor(), accesspolicy.APView))
+
+	// the rest keep their rights
+	a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APChange))
+	a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+	a.True(m.HasRights(ctx, p.ID, accesspolicy.GroupActor(editors.ID), accesspolicy.APDelete))
+
+	// nothing to unset anymore
+	a.NoError(m.UnsetPublicAccess(ctx, p.ID, owner))
+	changes, err := m.PendingChanges(ctx, p.ID)
+	a.NoError(err)
+	a.Empty(changes)
+}
+
+func TestAccessPolicyManagerExtendedPublicRights(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := uuid.New()
+	denied := uuid.New()
+
+	parent, err := m.Create(ctx, "public parent", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	child, err := m.Create(ctx, "public child", owner.ID, parent.ID, accesspolicy.NilObject(), accesspolicy.FExtend)
+	a.NoError(err)
+
+	// public rights are set only on the parent
+	a.NoError(m.GrantPublicAccess(ctx, parent.ID, owner, accesspolicy.APView))
+	a.NoError(m.DenyUserAccess(ctx, parent.ID, owner, denied, accesspolicy.APCopy))
+	a.NoError(m.Update(ctx, parent))
+
+	// the child has its own grants and public rights
+	a.NoError(m.GrantUserAccess(ctx, child.ID, owner, user, accesspolicy.APChange))
+	a.NoError(m.GrantPublicAccess(ctx, child.ID, owner, accesspolicy.APCopy))
+	a.NoError(m.Update(ctx, child))
+
+	// the extended child doesn't lose the parent's public rights
+	a.Equal(accesspolicy.APView|accesspolicy.APChange|accesspolicy.APCopy, m.Access(ctx, child.ID, user))
+	a.Equal(accesspolicy.APView|accesspolicy.APCopy, m.Access(ctx, child.ID, uuid.New()))
+
+	// the child's public rights don't override the parent's denial
+	a.Equal(accesspolicy.APView, m.Access(ctx, child.ID, denied))
+
+	// summarized access covers the own roster only
+	a.Equal(accesspolicy.APChange|accesspolicy.APCopy, m.SummarizedUserAccess(ctx, child.ID, user))
+	a.Equal(accesspolicy.APView, m.SummarizedUserAccess(ctx, parent.ID, user))
+
+	// the same result regardless of the cache
+	a.Equal(accesspolicy.APView|accesspolicy.APChange|accesspolicy.APCopy, m.Access(ctx, child.ID, user))
+}
+
+func TestAccessPolicyManagerSummarizedGroupAccess(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+
+	// three-level tree
+	top, err := gm.Create(ctx, group.FRole, uuid.Nil, "summarized-top", "Summarized Top")
+	a.NoError(err)
+
+	middle, err := gm.Create(ctx, group.FRole, top.ID, "summarized-middle", "Summarized Middle")
+	a.NoError(err)
+
+	bottom, err := gm.Create(ctx, group.FRole, middle.ID, "summarized-bottom", "Summarized Bottom")
+	a.NoError(err)
+
+	unrelated, err := gm.Create(ctx, group.FGroup, uuid.Nil, "summarized-unrelated", "Summarized Unrelated")
+	a.NoError(err)
+
+	p, err := m.Create(ctx, "summarized group policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	// rights are set at the top and at the bottom, the middle falls back to the top
+	a.NoError(m.GrantRoleAccess(ctx, p.ID, owner, top.ID, accesspolicy.APView|accesspolicy.APCopy))
+	a.NoError(m.GrantRoleAccess(ctx, p.ID, owner, bottom.ID, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.DenyRoleAccess(ctx, p.ID, owner, bottom.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, p))
+
+	// a fresh manager to count the store calls
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	rec := &fakeRecorder{storeOps: make(map[string]int)}
+	m2.SetMetrics(rec)
+
+	access, err := m2.SummarizedGroupAccess(ctx, p.ID, []uuid.UUID{bottom.ID, middle.ID, top.ID, unrelated.ID, bottom.ID})
+	a.NoError(err)
+	a.Equal(map[uuid.UUID]accesspolicy.Right{
+		top.ID:       accesspolicy.APView | accesspolicy.APCopy,
+		middle.ID:    accesspolicy.APView | accesspolicy.APCopy,
+		bottom.ID:    accesspolicy.APChange,
+		unrelated.ID: accesspolicy.APNoAccess,
+	}, access)
+	a.Equal(1, rec.storeOps["FetchRosterByPolicyID"])
+
+	// same as one by one
+	for id, r := range access {
+		a.Equal(r, m2.GroupAccess(ctx, p.ID, id))
+	}
+
+	// nothing to summarize
+	access, err = m2.SummarizedGroupAccess(ctx, p.ID, nil)
+	a.NoError(err)
+	a.Empty(access)
+
+	_, err = m2.SummarizedGroupAccess(ctx, uuid.Nil, []uuid.UUID{top.ID})
+	a.Equal(accesspolicy.ErrNilPolicyID, err)
+
+	_, err = m2.SummarizedGroupAccess(ctx, uuid.New(), []uuid.UUID{top.ID})
+	a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+}
+
+func TestAccessPolicyManagerSuspension(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		user := accesspolicy.UserActor(uuid.New())
+
+		p, err := m.Create(ctx, "suspension policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+		a.NoError(m.GrantAccess(ctx, p.ID, owner, user, accesspolicy.APView))
+
+		a.Equal(accesspolicy.APFullAccess, m.Access(ctx, p.ID, owner.ID))
+		a.True(m.UserHasAccess(ctx, p.ID, user.ID, accesspolicy.APView))
+
+		// suspended owner loses its full access override
+		a.NoError(m.SetActorSuspended(ctx, owner.ID, true))
+
+		suspended, err := m.IsActorSuspended(ctx, owner.ID)
+		a.NoError(err)
+		a.True(suspended)
+
+		a.Equal(accesspolicy.APNoAccess, m.Access(ctx, p.ID, owner.ID))
+		a.Equal(accesspolicy.APNoAccess, m.SummarizedUserAccess(ctx, p.ID, owner.ID))
+		a.False(m.UserHasAccess(ctx, p.ID, owner.ID, accesspolicy.APView))
+		a.False(m.HasRights(ctx, p.ID, owner, accesspolicy.APView))
+
+		e, err := m.AccessBreakdown(ctx, p.ID, owner.ID)
+		a.NoError(err)
+		a.True(e.Owner)
+		a.True(e.Suspended)
+		a.Equal(accesspolicy.APNoAccess, e.Access)
+
+		// other users are unaffected
+		a.True(m.UserHasAccess(ctx, p.ID, user.ID, accesspolicy.APView))
+
+		// suspension is persisted
+		m2, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.Equal(accesspolicy.APNoAccess, m2.Access(ctx, p.ID, owner.ID))
+		a.False(m2.UserHasAccess(ctx, p.ID, owner.ID, accesspolicy.APView))
+
+		// reinstating
+		a.NoError(m.SetActorSuspended(ctx, owner.ID, false))
+		a.Equal(accesspolicy.APFullAccess, m.Access(ctx, p.ID, owner.ID))
+		a.Equal(accesspolicy.APFullAccess, m.SummarizedUserAccess(ctx, p.ID, owner.ID))
+		a.True(m.UserHasAccess(ctx, p.ID, owner.ID, accesspolicy.APView))
+
+		m3, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.Equal(accesspolicy.APFullAccess, m3.Access(ctx, p.ID, owner.ID))
+
+		// suspensions made elsewhere take effect once reloaded
+		a.NoError(m.Update(ctx, p))
+
+		m4, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+
+		m4.SetSuspensionTTL(0)
+		a.True(m4.UserHasAccess(ctx, p.ID, user.ID, accesspolicy.APView))
+
+		// suspending twice is fine
+		a.NoError(m.SetActorSuspended(ctx, user.ID, true))
+		a.NoError(m.SetActorSuspended(ctx, user.ID, true))
+		a.False(m.UserHasAccess(ctx, p.ID, user.ID, accesspolicy.APView))
+		a.False(m4.UserHasAccess(ctx, p.ID, user.ID, accesspolicy.APView))
+
+		a.NoError(m.SetActorSuspended(ctx, user.ID, false))
+		a.True(m4.UserHasAccess(ctx, p.ID, user.ID, accesspolicy.APView))
+
+		a.Equal(accesspolicy.ErrNilActorID, m.SetActorSuspended(ctx, uuid.Nil, true))
+	})
+}
+
+func TestAccessPolicyManagerArchive(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	g1, err := gm.Create(ctx, group.FGroup, uuid.Nil, "archive test group", "archive test group")
+	a.NoError(err)
+
+	act1 := accesspolicy.UserActor(uuid.New())
+	act2 := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "archived policy", act1.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantAccess(ctx, p.ID, act1, accesspolicy.PublicActor(), accesspolicy.APView))
+	a.NoError(m.GrantAccess(ctx, p.ID, act1, act2, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.GrantAccess(ctx, p.ID, act1, accesspolicy.GroupActor(g1.ID), accesspolicy.APView))
+	a.NoError(m.Update(ctx, p))
+
+	a.True(m.HasRights(ctx, p.ID, act2, accesspolicy.APView|accesspolicy.APChange))
+	a.True(m.HasRights(ctx, p.ID, accesspolicy.PublicActor(), accesspolicy.APView))
+	a.True(m.HasRights(ctx, p.ID, accesspolicy.GroupActor(g1.ID), accesspolicy.APView))
+
+	// archiving
+	a.NoError(m.Archive(ctx, p.ID))
+
+	p, err = m.PolicyByID(ctx, p.ID, false)
+	a.NoError(err)
+	a.True(p.IsArchived())
+
+	// nobody but the owner has access
+	a.False(m.HasRights(ctx, p.ID, act2, accesspolicy.APView))
+	a.False(m.HasRights(ctx, p.ID, accesspolicy.PublicActor(), accesspolicy.APView))
+	a.False(m.HasRights(ctx, p.ID, accesspolicy.GroupActor(g1.ID), accesspolicy.APView))
+	a.True(m.HasRights(ctx, p.ID, act1, accesspolicy.APFullAccess))
+	a.Equal(accesspolicy.APNoAccess, m.SummarizedUserAccess(ctx, p.ID, act2.ID))
+	a.Equal(accesspolicy.APFullAccess, m.SummarizedUserAccess(ctx, p.ID, act1.ID))
+
+	// archived state must be persisted
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.False(m2.HasRights(ctx, p.ID, act2, accesspolicy.APView))
+	a.Equal(accesspolicy.APNoAccess, m2.SummarizedUserAccess(ctx, p.ID, act2.ID))
+
+	// archived policies are listed only if included
+	obj := accesspolicy.NewObject(uuid.New(), "archived object")
+	q, err := m.Create(ctx, "archived object policy", act1.ID, uuid.Nil, obj, 0)
+	a.NoError(err)
+	a.NoError(m.Archive(ctx, q.ID))
+
+	ps, total, err := m2.PoliciesByOwner(ctx, act1.ID, 0, 0, false)
+	a.NoError(err)
+	a.Empty(ps)
+	a.Zero(total)
+
+	ps, total, err = m2.PoliciesByOwner(ctx, act1.ID, 0, 0, true)
+	a.NoError(err)
+	a.Len(ps, 2)
+	a.Equal(2, total)
+
+	ps, err = m2.PoliciesByObjectName(ctx, obj.Name, 0, 0, false)
+	a.NoError(err)
+	a.Empty(ps)
+
+	ps, err = m2.PoliciesByObjectName(ctx, obj.Name, 0, 0, true)
+	a.NoError(err)
+	if a.Len(ps, 1) {
+		a.Equal(q.ID, ps[0].ID)
+	}
+
+	// unarchiving
+	a.NoError(m.Unarchive(ctx, p.ID))
+
+	p, err = m.PolicyByID(ctx, p.ID, false)
+	a.NoError(err)
+	a.False(p.IsArchived())
+
+	a.True(m.HasRights(ctx, p.ID, act2, accesspolicy.APView|accesspolicy.APChange))
+	a.True(m.HasRights(ctx, p.ID, accesspolicy.PublicActor(), accesspolicy.APView))
+	a.True(m.HasRights(ctx, p.ID, accesspolicy.GroupActor(g1.ID), accesspolicy.APView))
+}
+
+func TestAccessPolicyManagerParentObjectType(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	m.EnforceParentObjectType(true)
+
+	ownerID := uuid.New()
+
+	invoice, err := m.Create(ctx, "", ownerID, uuid.Nil, accesspolicy.NewObject(uuid.New(), "invoice"), 0)
+	a.NoError(err)
+
+	document, err := m.Create(ctx, "", ownerID, uuid.Nil, accesspolicy.NewObject(uuid.New(), "document"), 0)
+	a.NoError(err)
+
+	// cross-type parent on creation
+	_, err = m.Create(ctx, "", ownerID, invoice.ID, accesspolicy.NewObject(uuid.New(), "document"), accesspolicy.FExtend)
+	a.Error(err)
+	a.Equal(accesspolicy.ErrIncompatibleParentType, errors.Cause(err))
+
+	// cross-type parent link
+	err = m.SetParent(ctx, document.ID, invoice.ID)
+	a.Error(err)
+	a.Equal(accesspolicy.ErrIncompatibleParentType, errors.Cause(err))
+
+	// same type is fine
+	_, err = m.Create(ctx, "", ownerID, document.ID, accesspolicy.NewObject(uuid.New(), "document"), accesspolicy.FExtend)
+	a.NoError(err)
+
+	// declared subtype is fine
+	m.DeclareObjectSubtype("pdf document", "document")
+	_, err = m.Create(ctx, "", ownerID, document.ID, accesspolicy.NewObject(uuid.New(), "pdf document"), accesspolicy.FExtend)
+	a.NoError(err)
+
+	// everything goes when the check is disabled
+	m.EnforceParentObjectType(false)
+	a.NoError(m.SetParent(ctx, document.ID, invoice.ID))
+}
+
+func TestAccessPolicyManagerObjectTypesInUse(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	ownerID := uuid.New()
+	names := []string{"type in use 1", "type in use 2", "type in use 3"}
+
+	// two policies of each type, and a keyed-only policy
+	for _, name := range names {
+		for i := 0; i < 2; i++ {
+			_, err = m.Create(ctx, "", ownerID, uuid.Nil, accesspolicy.NewObject(uuid.New(), name), 0)
+			a.NoError(err)
+		}
+	}
+
+	_, err = m.Create(ctx, "keyed only policy", ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	inUse, err := m.ObjectTypesInUse(ctx)
+	a.NoError(err)
+	a.NotContains(inUse, "")
+
+	// deduplicated
+	seen := make(map[string]bool)
+	for _, name := range inUse {
+		a.False(seen[name])
+		seen[name] = true
+	}
+
+	for _, name := range names {
+		a.Contains(inUse, name)
+	}
+}
+
+// countingStore counts the reads and writes which reach the store
+type countingStore struct {
+	accesspolicy.Store
+	reads       int
+	writes      int
+	objectReads int
+}
+
+func (s *countingStore) FetchPolicyByID(ctx context.Context, id uuid.UUID) (accesspolicy.Policy, error) {
+	s.reads++
+	return s.Store.FetchPolicyByID(ctx, id)
+}
+
+func (s *countingStore) FetchRosterByPolicyID(ctx context.Context, pid uuid.UUID) (*accesspolicy.Roster, error) {
+	s.reads++
+	return s.Store.FetchRosterByPolicyID(ctx, pid)
+}
+
+func (s *countingStore) FetchPolicyByObject(ctx context.Context, obj accesspolicy.Object) (accesspolicy.Policy, error) {
+	s.reads++
+	s.objectReads++
+	return s.Store.FetchPolicyByObject(ctx, obj)
+}
+
+func (s *countingStore) UpdatePolicy(ctx context.Context, p accesspolicy.Policy, r *accesspolicy.Roster) error {
+	s.writes++
+	return s.Store.UpdatePolicy(ctx, p, r)
+}
+
+func (s *countingStore) UpdateRoster(ctx context.Context, pid uuid.UUID, r *accesspolicy.Roster) error {
+	s.writes++
+	return s.Store.UpdateRoster(ctx, pid, r)
+}
+
+func TestAccessPolicyManagerRevokeAbsent(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	ps, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(ps)
+
+	s := &countingStore{Store: ps}
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	act1 := accesspolicy.UserActor(uuid.New())
+	act2 := accesspolicy.UserActor(uuid.New())
+	act3 := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "revoke absent grant", act1.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	r, err := m.RosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+	a.False(r.HasChanges())
+
+	// revoking from a user who was never granted anything
+	a.NoError(m.RevokeAccess(ctx, p.ID, act1, act2))
+	a.NoError(m.RevokeAccess(ctx, p.ID, act1, accesspolicy.PublicActor()))
+	a.False(r.HasChanges())
+	a.Zero(s.writes)
+
+	// manage rights are still required
+	a.EqualError(m.RevokeAccess(ctx, p.ID, act3, act2), accesspolicy.ErrAccessDenied.Error())
+
+	// pending changes must survive a no-op revocation
+	a.NoError(m.GrantAccess(ctx, p.ID, act1, act3, accesspolicy.APView))
+	a.NoError(m.RevokeAccess(ctx, p.ID, act1, act2))
+	a.True(r.HasChanges())
+	a.True(m.HasRights(ctx, p.ID, act3, accesspolicy.APView))
+}
+
+func TestAccessPolicyManagerPolicyByObjectCache(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	ps, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(ps)
+
+	s := &countingStore{Store: ps}
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(ps, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	obj := accesspolicy.NewObject(uuid.New(), "cached object")
+
+	p, err := m.Create(ctx, "", uuid.New(), uuid.Nil, obj, 0)
+	a.NoError(err)
+
+	// a fresh manager which has nothing cached yet
+	m, err = accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	fetched, err := m.PolicyByObject(ctx, obj, false)
+	a.NoError(err)
+	a.Equal(p.ID, fetched.ID)
+	a.Equal(1, s.objectReads)
+
+	fetched, err = m.PolicyByObject(ctx, obj, false)
+	a.NoError(err)
+	a.Equal(p.ID, fetched.ID)
+	a.Equal(1, s.objectReads)
+
+	// deleted policy is no longer cached
+	a.NoError(m.DeletePolicy(ctx, fetched))
+
+	_, err = m.PolicyByObject(ctx, obj, false)
+	a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+	a.Equal(2, s.objectReads)
+}
+
+func TestAccessPolicyManagerWarm(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	ps, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(ps)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(ps, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	ids := make([]uuid.UUID, 0)
+	for i := 0; i < 3; i++ {
+		p, err := m.Create(ctx, fmt.Sprintf("warm policy %d", i), owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView))
+		a.NoError(m.Update(ctx, p))
+
+		ids = append(ids, p.ID)
+	}
+
+	// a fresh manager with nothing loaded yet
+	s := &countingStore{Store: ps}
+
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	// unknown policies are skipped
+	a.NoError(m2.Warm(ctx, append(ids, uuid.New(), uuid.Nil)))
+	a.Zero(s.reads)
+
+	for i, id := range ids {
+		p, err := m2.PolicyByID(ctx, id, false)
+		a.NoError(err)
+		a.Equal(fmt.Sprintf("warm policy %d", i), p.Key)
+
+		p, err = m2.PolicyByKey(ctx, fmt.Sprintf("warm policy %d", i), false)
+		a.NoError(err)
+		a.Equal(id, p.ID)
+
+		_, err = m2.RosterByPolicyID(ctx, id)
+		a.NoError(err)
+
+		a.True(m2.HasRights(ctx, id, user, accesspolicy.APView))
+		a.False(m2.HasRights(ctx, id, user, accesspolicy.APChange))
+	}
+
+	// nothing has reached the store
+	a.Zero(s.reads)
+
+	// warming up again changes nothing
+	a.NoError(m2.Warm(ctx, ids))
+	a.NoError(m2.Warm(ctx, nil))
+	a.Zero(s.reads)
+}
+
+func TestAccessPolicyManagerPolicyExists(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	p, err := m.Create(ctx, "existing policy", uuid.New(), uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	// a fresh manager has nothing cached
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	for _, mgr := range []*accesspolicy.Manager{m, m2} {
+		exists, err := mgr.PolicyExists(ctx, p.ID)
+		a.NoError(err)
+		a.True(exists)
+
+		exists, err = mgr.PolicyExists(ctx, uuid.New())
+		a.NoError(err)
+		a.False(exists)
+
+		exists, err = mgr.PolicyExistsByKey(ctx, p.Key)
+		a.NoError(err)
+		a.True(exists)
+
+		exists, err = mgr.PolicyExistsByKey(ctx, "missing policy")
+		a.NoError(err)
+		a.False(exists)
+	}
+
+	// checking existence neither fetches nor caches policies and rosters
+	m3, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	rec := &fakeRecorder{storeOps: make(map[string]int)}
+	m3.SetMetrics(rec)
+
+	for i := 0; i < 2; i++ {
+		exists, err := m3.PolicyExists(ctx, uuid.New())
+		a.NoError(err)
+		a.False(exists)
+
+		exists, err = m3.PolicyExists(ctx, p.ID)
+		a.NoError(err)
+		a.True(exists)
+	}
+
+	a.Equal(4, rec.storeOps["HasPolicy"])
+	a.Zero(rec.storeOps["FetchPolicyByID"])
+	a.Zero(rec.storeOps["FetchRosterByPolicyID"])
+
+	// the existing policy is fetched only once it's actually needed
+	_, err = m3.PolicyByID(ctx, p.ID, false)
+	a.NoError(err)
+	a.Equal(1, rec.storeOps["FetchPolicyByID"])
+}
+
+func TestAccessPolicyManagerScope(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// scoped policy managers
+	scopeA, scopeB := uuid.New(), uuid.New()
+
+	ma, err := accesspolicy.NewScopedManager(s, gm, scopeA)
+	a.NoError(err)
+	a.NotNil(ma)
+	a.Equal(scopeA, ma.Scope())
+
+	mb, err := accesspolicy.NewScopedManager(s, gm, scopeB)
+	a.NoError(err)
+	a.NotNil(mb)
+
+	p, err := ma.Create(ctx, "scoped policy", uuid.New(), uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+	a.Equal(scopeA, p.ScopeID)
+
+	// another scope must not see this policy
+	_, err = mb.PolicyByID(ctx, p.ID, false)
+	a.Error(err)
+	a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+
+	_, err = mb.PolicyByKey(ctx, p.Key, false)
+	a.Error(err)
+	a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+
+	exists, err := mb.PolicyExists(ctx, p.ID)
+	a.NoError(err)
+	a.False(exists)
+
+	// nor can its access requests be resolved
+	requestID, err := ma.RequestAccess(ctx, p.ID, accesspolicy.UserActor(uuid.New()), accesspolicy.APView, "")
+	a.NoError(err)
+
+	ar, err := ma.AccessRequestByID(ctx, requestID)
+	a.NoError(err)
+
+	ar.Status = accesspolicy.RSDenied
+	ar.ResolvedAt = time.Now()
+	scoper := s.(accesspolicy.Scoper)
+	a.Equal(accesspolicy.ErrAccessRequestNotFound, scoper.WithScope(scopeB).UpdateAccessRequest(ctx, ar))
+	a.NoError(scoper.WithScope(scopeA).UpdateAccessRequest(ctx, ar))
+
+	// neither can it be deleted from another scope
+	err = mb.DeletePolicy(ctx, p)
+	a.Error(err)
+
+	// while its own scope still can
+	ma2, err := accesspolicy.NewScopedManager(s, gm, scopeA)
+	a.NoError(err)
+
+	fp, err := ma2.PolicyByID(ctx, p.ID, false)
+	a.NoError(err)
+	a.Equal(p.ID, fp.ID)
+	a.Equal(scopeA, fp.ScopeID)
+}
+
+func TestAccessPolicyManagerGroupMerge(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	keep, err := gm.Create(ctx, group.FGroup, uuid.Nil, "engineers", "Engineers")
+	a.NoError(err)
+
+	merged, err := gm.Create(ctx, group.FGroup, uuid.Nil, "engineering-team", "Engineering Team")
+	a.NoError(err)
+
+	child, err := gm.Create(ctx, group.FGroup, merged.ID, "backend-engineers", "Backend Engineers")
+	a.NoError(err)
+
+	// both groups share one member, and each has its own
+	shared, onlyMerged := uuid.New(), uuid.New()
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(keep.ID, group.AKUser, shared)))
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(merged.ID, group.AKUser, shared)))
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(merged.ID, group.AKUser, onlyMerged)))
+
+	ownerID := uuid.New()
+	p, err := m.Create(ctx, "merged group policy", ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	// both groups have grants on the same policy
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, accesspolicy.UserActor(ownerID), keep.ID, accesspolicy.APView))
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, accesspolicy.UserActor(ownerID), merged.ID, accesspolicy.APChange))
+
+	// former members of the merged group are notified of
+	changed := make(map[uuid.UUID]bool)
+	gm.OnMembershipChange(func(ctx context.Context, rel group.Relation) {
+		if rel.GroupID == merged.ID {
+			changed[rel.Asset.ID] = true
+		}
+	})
+
+	// unsaved grants are moved as well
+	a.NoError(gm.Merge(ctx, keep.ID, merged.ID))
+	a.True(changed[shared])
+	a.True(changed[onlyMerged])
+
+	// merged group is gone
+	_, err = gm.GroupByID(ctx, merged.ID)
+	a.Error(err)
+
+	// relations are moved without duplicates
+	a.True(gm.IsAsset(ctx, keep.ID, group.UserAsset(shared)))
+	a.True(gm.IsAsset(ctx, keep.ID, group.UserAsset(onlyMerged)))
+
+	relations, err := gs.FetchGroupRelations(ctx, keep.ID)
+	a.NoError(err)
+	a.Len(relations, 2)
+
+	// children are reparented
+	child, err = gm.GroupByID(ctx, child.ID)
+	a.NoError(err)
+	a.Equal(keep.ID, child.ParentID)
+
+	// rights are combined
+	a.Equal(accesspolicy.APView|accesspolicy.APChange, m.GroupAccess(ctx, p.ID, keep.ID))
+	a.Equal(accesspolicy.APNoAccess, m.GroupAccess(ctx, p.ID, merged.ID))
+
+	// and persisted
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	r, err := m2.RosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+
+	n := 0
+	for _, cell := range r.Registry {
+		a.NotEqual(merged.ID, cell.Key.ID)
+
+		if cell.Key == accesspolicy.GroupActor(keep.ID) {
+			a.Equal(accesspolicy.APView|accesspolicy.APChange, cell.Rights)
+			n++
+		}
+	}
+	a.Equal(1, n)
+
+	// nothing is moved while a roster has unsaved changes of other actors
+	other, err := gm.Create(ctx, group.FGroup, uuid.Nil, "qa-engineers", "QA Engineers")
+	a.NoError(err)
+
+	userID := uuid.New()
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, accesspolicy.UserActor(ownerID), other.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, p))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, accesspolicy.UserActor(ownerID), userID, accesspolicy.APView))
+
+	err = m.ReplaceActor(ctx, accesspolicy.GroupActor(other.ID), accesspolicy.GroupActor(keep.ID))
+	a.Equal(accesspolicy.ErrUnrelatedChanges, errors.Cause(err))
+	a.Equal(accesspolicy.APView, m.GroupAccess(ctx, p.ID, other.ID))
+	a.Equal(accesspolicy.APView|accesspolicy.APChange, m.GroupAccess(ctx, p.ID, keep.ID))
+
+	// the unrelated grant is still pending
+	r, err = m.RosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+	a.True(r.HasChanges())
+
+	// merging into itself is not allowed
+	a.Equal(group.ErrSelfMerge, gm.Merge(ctx, keep.ID, keep.ID))
+}
+
+func TestAccessPolicyManagerGroupMergeExpiring(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	keep, err := gm.Create(ctx, group.FGroup, uuid.Nil, "contractors", "Contractors")
+	a.NoError(err)
+
+	merged, err := gm.Create(ctx, group.FGroup, uuid.Nil, "temporary-contractors", "Temporary Contractors")
+	a.NoError(err)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	p, err := m.Create(ctx, "expiring merged group policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	// only the merged group has a temporary, limited grant and a denial
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	a.NoError(m.GrantGroupAccessUntil(ctx, p.ID, owner, merged.ID, accesspolicy.APView|accesspolicy.APManageAccess, expiresAt))
+	a.NoError(m.SetGrantableRights(ctx, p.ID, accesspolicy.GroupActor(merged.ID), accesspolicy.APView))
+	a.NoError(m.DenyGroupAccess(ctx, p.ID, owner, merged.ID, accesspolicy.APChange))
+	a.NoError(m.Update(ctx, p))
+
+	a.NoError(gm.Merge(ctx, keep.ID, merged.ID))
+
+	// the entry is moved whole, the grant remains temporary
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	r, err := m2.RosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+
+	n := 0
+	for _, cell := range r.Registry {
+		a.NotEqual(merged.ID, cell.Key.ID)
+
+		if cell.Key == accesspolicy.GroupActor(keep.ID) {
+			a.Equal(accesspolicy.APView|accesspolicy.APManageAccess, cell.Rights)
+			a.Equal(accesspolicy.APView, cell.Grantable)
+			a.Equal(accesspolicy.APChange, cell.Denied)
+			a.True(expiresAt.Equal(cell.ExpiresAt))
+			n++
+		}
+	}
+	a.Equal(1, n)
+}
+
+func TestAccessPolicyManagerGroupDelete(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	parent, err := gm.Create(ctx, group.FGroup, uuid.Nil, "operations", "Operations")
+	a.NoError(err)
+
+	deleted, err := gm.Create(ctx, group.FGroup, parent.ID, "on-call", "On Call")
+	a.NoError(err)
+
+	child, err := gm.Create(ctx, group.FGroup, deleted.ID, "on-call-backup", "On Call Backup")
+	a.NoError(err)
+
+	role, err := gm.Create(ctx, group.FRole, uuid.Nil, "incident-commander", "Incident Commander")
+	a.NoError(err)
+
+	userID := uuid.New()
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(deleted.ID, group.AKUser, userID)))
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(role.ID, group.AKUser, userID)))
+
+	ownerID := uuid.New()
+	p, err := m.Create(ctx, "deleted group policy", ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, accesspolicy.UserActor(ownerID), deleted.ID, accesspolicy.APChange))
+	a.NoError(m.GrantRoleAccess(ctx, p.ID, accesspolicy.UserActor(ownerID), role.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, p))
+	a.Equal(accesspolicy.APView|accesspolicy.APChange, m.SummarizedUserAccess(ctx, p.ID, userID))
+
+	a.NoError(gm.DeleteGroup(ctx, deleted.ID))
+
+	// the group is gone along with its relations
+	_, err = gm.GroupByID(ctx, deleted.ID)
+	a.Error(err)
+	a.False(gm.IsAsset(ctx, deleted.ID, group.UserAsset(userID)))
+
+	relations, err := gs.FetchGroupRelations(ctx, deleted.ID)
+	a.NoError(err)
+	a.Empty(relations)
+
+	// children are moved under the parent of the deleted group
+	child, err = gm.GroupByID(ctx, child.ID)
+	a.NoError(err)
+	a.Equal(parent.ID, child.ParentID)
+
+	// the deleted group no longer counts, the role still does
+	a.Equal(accesspolicy.APView, m.SummarizedUserAccess(ctx, p.ID, userID))
+
+	r, err := m.RosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+
+	for _, cell := range r.Registry {
+		a.NotEqual(deleted.ID, cell.Key.ID)
+	}
+
+	// the rights of a deleted role are discarded the same way,
+	// and its children are detached when orphaning is enabled
+	roleChild, err := gm.Create(ctx, group.FRole, role.ID, "deputy-commander", "Deputy Commander")
+	a.NoError(err)
+
+	gm.SetOrphanOnDelete(true)
+	a.NoError(gm.DeleteGroup(ctx, role.ID))
+	a.Equal(accesspolicy.APNoAccess, m.SummarizedUserAccess(ctx, p.ID, userID))
+
+	roleChild, err = gm.GroupByID(ctx, roleChild.ID)
+	a.NoError(err)
+	a.Equal(uuid.Nil, roleChild.ParentID)
+
+	// and persisted
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	r, err = m2.RosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+
+	for _, cell := range r.Registry {
+		a.NotEqual(deleted.ID, cell.Key.ID)
+		a.NotEqual(role.ID, cell.Key.ID)
+	}
+
+	// soft-deleted policies don't stand in the way of deleting a group
+	archived, err := gm.Create(ctx, group.FGroup, uuid.Nil, "retired-operations", "Retired Operations")
+	a.NoError(err)
+
+	sp, err := m.Create(ctx, "soft-deleted group policy", ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantGroupAccess(ctx, sp.ID, accesspolicy.UserActor(ownerID), archived.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, sp))
+	a.NoError(m.SoftDeletePolicy(ctx, sp))
+	a.NoError(gm.DeleteGroup(ctx, archived.ID))
+
+	// nor are they cached by obtaining their rosters
+	_, err = m.RosterByPolicyID(ctx, sp.ID)
+	a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+
+	_, err = m.PolicyByID(ctx, sp.ID, false)
+	a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+}
+
+func TestAccessPolicyManagerOwnerQuota(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		m.SetMaxPoliciesPerOwner(2)
+
+		owner1, owner2 := uuid.New(), uuid.New()
+
+		for i := 0; i < 2; i++ {
+			_, err = m.Create(ctx, fmt.Sprintf("quota policy %d", i), owner1, uuid.Nil, accesspolicy.NilObject(), 0)
+			a.NoError(err)
+		}
+
+		n, err := m.CountPoliciesByOwner(ctx, owner1)
+		a.NoError(err)
+		a.Equal(2, n)
+
+		// owner at the limit
+		_, err = m.Create(ctx, "quota policy 2", owner1, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.Error(err)
+		a.True(errors.Is(err, accesspolicy.ErrOwnerQuotaExceeded))
+
+		// another owner is unaffected
+		_, err = m.Create(ctx, "quota policy 3", owner2, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		// lifting the limit
+		m.SetMaxPoliciesPerOwner(0)
+
+		_, err = m.Create(ctx, "quota policy 4", owner1, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+	})
+}
+
+// staticDomains is a fixed mapping of policies to domains and domains to their owners
+type staticDomains struct {
+	domains map[uuid.UUID]uuid.UUID
+	owners  map[uuid.UUID]uuid.UUID
+}
+
+func (d staticDomains) PolicyDomain(ctx context.Context, p accesspolicy.Policy) (uuid.UUID, error) {
+	return d.domains[p.ID], nil
+}
+
+func (d staticDomains) IsDomainOwner(ctx context.Context, domainID, userID uuid.UUID) (bool, error) {
+	return d.owners[domainID] == userID, nil
+}
+
+func TestAccessPolicyManagerDomainOwnership(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	ownerID, domainOwnerID, strangerID := uuid.New(), uuid.New(), uuid.New()
+
+	p, err := m.Create(ctx, "domain policy", ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	domainID := uuid.New()
+
+	// domain ownership is off by default
+	a.False(m.HasRights(ctx, p.ID, accesspolicy.UserActor(domainOwnerID), accesspolicy.APView))
+
+	m.SetDomainOwnershipResolver(staticDomains{
+		domains: map[uuid.UUID]uuid.UUID{p.ID: domainID},
+		owners:  map[uuid.UUID]uuid.UUID{domainID: domainOwnerID},
+	})
+
+	// domain owner has full access to a policy they don't own
+	a.True(m.HasRights(ctx, p.ID, accesspolicy.UserActor(domainOwnerID), accesspolicy.APFullAccess))
+	a.Equal(accesspolicy.APFullAccess, m.SummarizedUserAccess(ctx, p.ID, domainOwnerID))
+
+	// while others still have nothing
+	a.False(m.HasRights(ctx, p.ID, accesspolicy.UserActor(strangerID), accesspolicy.APView))
+	a.Equal(accesspolicy.APNoAccess, m.SummarizedUserAccess(ctx, p.ID, strangerID))
+
+	// disabling again
+	m.SetDomainOwnershipResolver(nil)
+	a.False(m.HasRights(ctx, p.ID, accesspolicy.UserActor(domainOwnerID), accesspolicy.APView))
+}
+
+func TestAccessPolicyManagerAccessRequests(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		alice := accesspolicy.UserActor(uuid.New())
+		bob := accesspolicy.UserActor(uuid.New())
+
+		p, err := m.Create(ctx, "requested policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		aliceReq, err := m.RequestAccess(ctx, p.ID, alice, accesspolicy.APView|accesspolicy.APChange, "need to edit the docs")
+		a.NoError(err)
+
+		bobReq, err := m.RequestAccess(ctx, p.ID, bob, accesspolicy.APView, "just curious")
+		a.NoError(err)
+
+		pending, err := m.ListPendingRequests(ctx, p.ID)
+		a.NoError(err)
+		a.Len(pending, 2)
+
+		//---------------------------------------------------------------------------
+		// approval
+		//---------------------------------------------------------------------------
+		// somebody who cannot manage access cannot approve
+		a.Error(m.Approve(ctx, aliceReq, bob))
+		a.False(m.HasRights(ctx, p.ID, alice, accesspolicy.APView))
+
+		a.NoError(m.Approve(ctx, aliceReq, owner))
+		a.True(m.HasRights(ctx, p.ID, alice, accesspolicy.APView|accesspolicy.APChange))
+
+		ar, err := m.AccessRequestByID(ctx, aliceReq)
+		a.NoError(err)
+		a.Equal(accesspolicy.RSApproved, ar.Status)
+		a.Equal(owner, ar.ResolvedBy)
+		a.False(ar.ResolvedAt.IsZero())
+
+		// the grant is persisted
+		m2, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.True(m2.HasRights(ctx, p.ID, alice, accesspolicy.APView|accesspolicy.APChange))
+
+		// resolved request cannot be resolved again
+		err = m.Deny(ctx, aliceReq, owner)
+		a.Error(err)
+		a.True(errors.Is(err, accesspolicy.ErrAccessRequestResolved))
+
+		//---------------------------------------------------------------------------
+		// denial
+		//---------------------------------------------------------------------------
+		a.NoError(m.Deny(ctx, bobReq, owner))
+		a.False(m.HasRights(ctx, p.ID, bob, accesspolicy.APView))
+
+		ar, err = m.AccessRequestByID(ctx, bobReq)
+		a.NoError(err)
+		a.Equal(accesspolicy.RSDenied, ar.Status)
+
+		pending, err = m.ListPendingRequests(ctx, p.ID)
+		a.NoError(err)
+		a.Len(pending, 0)
+
+		// nothing to request
+		_, err = m.RequestAccess(ctx, p.ID, bob, accesspolicy.APNoAccess, "")
+		a.Equal(accesspolicy.ErrNoRightsRequested, err)
+	})
+}
+
+func TestAccessPolicyManagerDeletePolicies(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		sink := &auditRecorder{}
+		m.SetAuditSink(sink)
+
+		owner := accesspolicy.UserActor(uuid.New())
+
+		parent, err := m.Create(ctx, "batch parent", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		child, err := m.Create(ctx, "batch child", owner.ID, parent.ID, accesspolicy.NilObject(), accesspolicy.FExtend)
+		a.NoError(err)
+
+		p1, err := m.Create(ctx, "batch policy 1", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		p2, err := m.Create(ctx, "batch policy 2", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		errs, err := m.DeletePolicies(ctx, []uuid.UUID{p1.ID, parent.ID, p2.ID}, owner)
+		a.NoError(err)
+		a.Len(errs, 3)
+		a.NoError(errs[0])
+		a.Equal(accesspolicy.ErrPolicyHasChildren, errs[1])
+		a.NoError(errs[2])
+
+		// deleted policies are gone, including the cache
+		for _, p := range []accesspolicy.Policy{p1, p2} {
+			exists, err := m.PolicyExistsByKey(ctx, p.Key)
+			a.NoError(err)
+			a.False(exists)
+
+			_, err = m.PolicyByID(ctx, p.ID, false)
+			a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+		}
+
+		// parent and its child remain intact
+		_, err = m.PolicyByID(ctx, parent.ID, false)
+		a.NoError(err)
+
+		_, err = m.PolicyByID(ctx, child.ID, false)
+		a.NoError(err)
+
+		// only the deleted policies are audited
+		a.Len(sink.records, 2)
+		for i, pid := range []uuid.UUID{p1.ID, p2.ID} {
+			a.Equal(accesspolicy.AADeletePolicy, sink.records[i].Action)
+			a.Equal(pid, sink.records[i].PolicyID)
+			a.Equal(owner, sink.records[i].Grantor)
+		}
+
+		// children preceding their parents are deleted along
+		errs, err = m.DeletePolicies(ctx, []uuid.UUID{child.ID, parent.ID}, owner)
+		a.NoError(err)
+		a.NoError(errs[0])
+		a.NoError(errs[1])
+
+		// a stranger cannot delete
+		p3, err := m.Create(ctx, "batch policy 3", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		errs, err = m.DeletePolicies(ctx, []uuid.UUID{p3.ID}, accesspolicy.UserActor(uuid.New()))
+		a.NoError(err)
+		a.Equal(accesspolicy.ErrAccessDenied, errs[0])
+
+		// a store failure rolls back the whole batch, and nothing is audited
+		p4, err := m.Create(ctx, "batch policy 4", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		m2, err := accesspolicy.NewManager(&failingDeleteStore{Store: s, pid: p4.ID}, gm)
+		a.NoError(err)
+
+		sink2 := &auditRecorder{}
+		m2.SetAuditSink(sink2)
+
+		errs, err = m2.DeletePolicies(ctx, []uuid.UUID{p3.ID, p4.ID}, owner)
+		a.Error(err)
+		a.Nil(errs)
+		a.Empty(sink2.records)
+
+		for _, pid := range []uuid.UUID{p3.ID, p4.ID} {
+			ok, err := s.HasPolicy(ctx, pid)
+			a.NoError(err)
+			a.True(ok)
+		}
+	})
+}
+
+// failingDeleteStore simulates a backend failure when deleting a given policy within a transaction
+type failingDeleteStore struct {
+	accesspolicy.Store
+	pid uuid.UUID
+}
+
+func (s *failingDeleteStore) WithTx(ctx context.Context, fn func(tx accesspolicy.Store) error) error {
+	return s.Store.WithTx(ctx, func(tx accesspolicy.Store) error {
+		return fn(&failingDeleteStore{Store: tx, pid: s.pid})
+	})
+}
+
+func (s *failingDeleteStore) DeletePolicy(ctx context.Context, p accesspolicy.Policy) error {
+	if p.ID == s.pid {
+		return errors.New("simulated store failure")
+	}
+
+	return s.Store.DeletePolicy(ctx, p)
+}
+
+func TestAccessPolicyManagerGrantToArchivedGroup(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	active, err := gm.Create(ctx, group.FGroup, uuid.Nil, "active group", "active group")
+	a.NoError(err)
+
+	archived, err := gm.Create(ctx, group.FGroup, uuid.Nil, "archived group", "archived group")
+	a.NoError(err)
+
+	archivedRole, err := gm.Create(ctx, group.FRole, uuid.Nil, "archived role", "archived role")
+	a.NoError(err)
+
+	a.NoError(gm.Archive(ctx, archived.ID))
+	a.NoError(gm.Archive(ctx, archivedRole.ID))
+
+	owner := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "archived group policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	err = m.GrantGroupAccess(ctx, p.ID, owner, archived.ID, accesspolicy.APView)
+	a.Error(err)
+	a.True(errors.Is(err, group.ErrGroupInactive))
+
+	err = m.GrantRoleAccess(ctx, p.ID, owner, archivedRole.ID, accesspolicy.APView)
+	a.Error(err)
+	a.True(errors.Is(err, group.ErrGroupInactive))
+
+	// rights cannot be moved to an archived group either
+	err = m.ReplaceActor(ctx, accesspolicy.GroupActor(active.ID), accesspolicy.GroupActor(archived.ID))
+	a.True(errors.Is(err, group.ErrGroupInactive))
+
+	// nor can anything be merged into it
+	err = gm.Merge(ctx, archived.ID, active.ID)
+	a.True(errors.Is(err, group.ErrGroupInactive))
+
+	// active group still works
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, active.ID, accesspolicy.APView))
+	a.Equal(accesspolicy.APView, m.GroupAccess(ctx, p.ID, active.ID))
+
+	// and so does the group once it's back
+	a.NoError(gm.Unarchive(ctx, archived.ID))
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, archived.ID, accesspolicy.APView))
+}
+
+func TestAccessPolicyManagerHasRightsTraced(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	// caching must not hide any steps
+	m.SetAccessCacheTTL(time.Minute)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "traced group", "traced group")
+	a.NoError(err)
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(g.ID, group.AKUser, user.ID)))
+
+	parent, err := m.Create(ctx, "traced parent", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	child, err := m.Create(ctx, "traced child", owner.ID, parent.ID, accesspolicy.NilObject(), accesspolicy.FExtend)
+	a.NoError(err)
+
+	a.NoError(m.GrantGroupAccess(ctx, parent.ID, owner, g.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, parent))
+
+	// warming up the cache
+	a.True(m.HasRights(ctx, child.ID, user, accesspolicy.APView))
+
+	// denied, because neither the parent nor the child allow changes
+	ok, tr, err := m.HasRightsTraced(ctx, child.ID, user, accesspolicy.APChange)
+	a.NoError(err)
+	a.False(ok)
+	a.NotNil(tr)
+
+	expected := []struct {
+		kind accesspolicy.TraceStepKind
+		pid  uuid.UUID
+	}{
+		{accesspolicy.TSVisit, child.ID},
+		{accesspolicy.TSGroup, parent.ID},
+		{accesspolicy.TSUser, parent.ID},
+		{accesspolicy.TSPublic, parent.ID},
+		{accesspolicy.TSExtend, child.ID},
+		{accesspolicy.TSGroup, child.ID},
+		{accesspolicy.TSUser, child.ID},
+		{accesspolicy.TSPublic, child.ID},
+		{accesspolicy.TSResult, child.ID},
+	}
+
+	steps := tr.Steps()
+	if a.Len(steps, len(expected), tr.String()) {
+		for i, e := range expected {
+			a.Equal(e.kind, steps[i].Kind, "step %d", i)
+			a.Equal(e.pid, steps[i].PolicyID, "step %d", i)
+		}
+	}
+
+	// group contribution comes from the parent only
+	a.Equal(accesspolicy.APView, steps[1].Rights)
+	a.Equal(accesspolicy.APView, steps[4].Rights)
+	a.Equal(accesspolicy.APNoAccess, steps[5].Rights)
+	a.Contains(steps[len(steps)-1].Note, "granted=false")
+
+	// the trace is also available through the context
+	tctx, tr2 := accesspolicy.WithTrace(ctx)
+	a.False(m.HasRights(tctx, child.ID, user, accesspolicy.APChange))
+	a.Equal(tr2, accesspolicy.TraceFromContext(tctx))
+	a.NotEmpty(tr2.Steps())
+
+	// untraced context carries nothing
+	a.Nil(accesspolicy.TraceFromContext(ctx))
+}
+
+func TestAccessPolicyManagerDeny(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "deny group", "deny group")
+	a.NoError(err)
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(g.ID, group.AKUser, user.ID)))
+
+	p, err := m.Create(ctx, "deny policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	// the group allows changes
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, g.ID, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APCopy))
+	a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APChange))
+
+	// but this user is explicitly denied to change
+	a.NoError(m.DenyUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APChange))
+	a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APChange))
+	a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APView|accesspolicy.APCopy))
+	a.Equal(accesspolicy.APView|accesspolicy.APCopy, m.SummarizedUserAccess(ctx, p.ID, user.ID))
+	a.NoError(m.Update(ctx, p))
+
+	// failed grant restores the backup, which must retain the denial
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, uuid.New(), accesspolicy.APView))
+	a.Error(m.GrantUserAccess(ctx, p.ID, user, user.ID, accesspolicy.APManageAccess))
+	a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APChange))
+	a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APCopy))
+
+	// denial survives the store round-trip
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.False(m2.HasRights(ctx, p.ID, user, accesspolicy.APChange))
+	a.True(m2.HasRights(ctx, p.ID, user, accesspolicy.APView|accesspolicy.APCopy))
+
+	// owner overrides denials
+	a.NoError(m.DenyUserAccess(ctx, p.ID, owner, owner.ID, accesspolicy.APView))
+	a.True(m.HasRights(ctx, p.ID, owner, accesspolicy.APFullAccess))
+
+	// public rights cannot be denied
+	a.Equal(accesspolicy.ErrPublicDenial, m.DenyAccess(ctx, p.ID, owner, accesspolicy.PublicActor(), accesspolicy.APView))
+
+	// denials are never inferred from the granted rights
+	a.Equal(accesspolicy.ErrReservedRight, m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APReserved|accesspolicy.APView))
+	a.Equal(accesspolicy.ErrReservedRight, m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APReserved|accesspolicy.APView))
+	a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APChange))
+
+	// group and role denials must match the kind of the group
+	a.Equal(group.ErrGroupKindMismatch, errors.Cause(m.DenyRoleAccess(ctx, p.ID, owner, g.ID, accesspolicy.APView)))
+	a.NoError(m.DenyAccess(ctx, p.ID, owner, accesspolicy.NewActor(accesspolicy.AKGroup, g.ID), accesspolicy.APCopy))
+	a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APCopy))
+	a.NoError(m.DenyGroupAccess(ctx, p.ID, owner, g.ID, accesspolicy.APNoAccess))
+
+	// lifting the denial
+	a.NoError(m.DenyUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APNoAccess))
+	a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APChange|accesspolicy.APCopy))
+}
+
+func TestAccessPolicyManagerFullAccessExcept(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		user := accesspolicy.UserActor(uuid.New())
+
+		p, err := m.Create(ctx, "full access except policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APDelete))
+		a.NoError(m.Update(ctx, p))
+
+		// the reserved bit of such mask is rejected rather than taken for a denial
+		a.Equal(accesspolicy.ErrReservedRight, m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APFullAccess&^accesspolicy.APDelete))
+		a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APView|accesspolicy.APDelete))
+
+		// without the reserved bit it's a plain grant
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APFullAccess&^accesspolicy.APReserved&^accesspolicy.APDelete))
+		a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APView|accesspolicy.APChange|accesspolicy.APManageAccess))
+		a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APDelete))
+		a.NoError(m.Update(ctx, p))
+
+		e, err := m.AccessBreakdown(ctx, p.ID, user.ID)
+		a.NoError(err)
+		a.Zero(e.Denied)
+
+		// full access itself is granted as is
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APFullAccess))
+		a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APDelete))
+	})
+}
+
+func TestAccessPolicyManagerExpiringRights(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	contractor := accesspolicy.UserActor(uuid.New())
+	member := accesspolicy.UserActor(uuid.New())
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "contractors", "contractors")
+	a.NoError(err)
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(g.ID, group.AKUser, member.ID)))
+
+	p, err := m.Create(ctx, "expiring policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	expiresAt := time.Now().Add(500 * time.Millisecond)
+
+	// grants which expire in the past are pointless
+	a.Equal(accesspolicy.ErrExpiredGrant, m.GrantUserAccessUntil(ctx, p.ID, owner, contractor.ID, accesspolicy.APView, time.Now().Add(-time.Second)))
+
+	a.NoError(m.GrantUserAccessUntil(ctx, p.ID, owner, contractor.ID, accesspolicy.APView|accesspolicy.APChange, expiresAt))
+	a.NoError(m.GrantGroupAccessUntil(ctx, p.ID, owner, g.ID, accesspolicy.APView, expiresAt))
+	a.NoError(m.Update(ctx, p))
+
+	a.True(m.HasRights(ctx, p.ID, contractor, accesspolicy.APView|accesspolicy.APChange))
+	a.True(m.HasRights(ctx, p.ID, member, accesspolicy.APView))
+
+	// expiration survives the store round-trip
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	r, err := m2.RosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+
+	n := 0
+	for _, cell := range r.Registry {
+		if cell.Key == contractor || cell.Key == accesspolicy.GroupActor(g.ID) {
+			a.WithinDuration(expiresAt, cell.ExpiresAt, time.Millisecond)
+			n++
+		}
+	}
+	a.Equal(2, n)
+
+	time.Sleep(time.Until(expiresAt) + 10*time.Millisecond)
+
+	// expired entries grant nothing
+	for _, mgr := range []*accesspolicy.Manager{m, m2} {
+		a.False(mgr.HasRights(ctx, p.ID, contractor, accesspolicy.APView))
+		a.False(mgr.HasRights(ctx, p.ID, member, accesspolicy.APView))
+		a.Equal(accesspolicy.APNoAccess, mgr.SummarizedUserAccess(ctx, p.ID, contractor.ID))
+	}
+
+	// purging
+	purged, err := m.PurgeExpired(ctx)
+	a.NoError(err)
+	a.True(purged >= 2)
+
+	r, err = m.RosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+
+	for _, cell := range r.Registry {
+		a.NotEqual(contractor, cell.Key)
+		a.NotEqual(accesspolicy.GroupActor(g.ID), cell.Key)
+	}
+
+	// nothing is left in the store either
+	m3, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	r, err = m3.RosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+
+	for _, cell := range r.Registry {
+		a.NotEqual(contractor, cell.Key)
+	}
+}
+
+func TestAccessPolicyManagerSetParentCycle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		ownerID := uuid.New()
+
+		// root <- middle <- leaf
+		root, err := m.Create(ctx, "cycle root", ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		middle, err := m.Create(ctx, "cycle middle", ownerID, root.ID, accesspolicy.NilObject(), accesspolicy.FExtend)
+		a.NoError(err)
+
+		leaf, err := m.Create(ctx, "cycle leaf", ownerID, middle.ID, accesspolicy.NilObject(), accesspolicy.FExtend)
+		a.NoError(err)
+
+		// self-parenting
+		err = m.SetParent(ctx, root.ID, root.ID)
+		a.True(errors.Is(err, accesspolicy.ErrCircularParent))
+
+		// parenting to a descendant
+		err = m.SetParent(ctx, root.ID, leaf.ID)
+		a.True(errors.Is(err, accesspolicy.ErrCircularParent))
+
+		err = m.SetParent(ctx, middle.ID, leaf.ID)
+		a.True(errors.Is(err, accesspolicy.ErrCircularParent))
+
+		// nothing has changed
+		for _, expected := range []accesspolicy.Policy{root, middle, leaf} {
+			p, err := m.PolicyByID(ctx, expected.ID, false)
+			a.NoError(err)
+			a.Equal(expected.ParentID, p.ParentID)
+
+			p, err = s.FetchPolicyByID(ctx, expected.ID)
+			a.NoError(err)
+			a.Equal(expected.ParentID, p.ParentID)
+		}
+
+		// reparenting across the chain is still fine
+		a.NoError(m.SetParent(ctx, leaf.ID, root.ID))
+	})
+}
+
+func TestAccessPolicyManagerAccessWithoutParent(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	ownerID := uuid.New()
+	userID := uuid.New()
+
+	p, err := m.Create(ctx, "standalone policy", ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+	a.Equal(uuid.Nil, p.ParentID)
+
+	// nothing granted yet
+	a.Equal(accesspolicy.APNoAccess, m.Access(ctx, p.ID, userID))
+
+	wantedRights := accesspolicy.APView | accesspolicy.APChange
+	a.NoError(m.GrantAccess(ctx, p.ID, accesspolicy.UserActor(ownerID), accesspolicy.UserActor(userID), wantedRights))
+	a.NoError(m.Update(ctx, p))
+
+	// parentless policy must report its own rights
+	a.Equal(wantedRights, m.Access(ctx, p.ID, userID))
+	a.Equal(accesspolicy.APFullAccess, m.Access(ctx, p.ID, ownerID))
+	a.Equal(accesspolicy.APNoAccess, m.Access(ctx, p.ID, uuid.New()))
+}
+
+func TestAccessPolicyManagerHasRightsBatch(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	member := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "batch policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "batch group", "batch group")
+	a.NoError(err)
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(g.ID, group.AKUser, member.ID)))
+
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, g.ID, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APDelete))
+	a.NoError(m.Update(ctx, p))
+
+	checks := []accesspolicy.AccessCheck{
+		{Actor: owner, Rights: accesspolicy.APFullAccess},
+		{Actor: member, Rights: accesspolicy.APChange},
+		{Actor: member, Rights: accesspolicy.APDelete},
+		{Actor: user, Rights: accesspolicy.APDelete},
+		{Actor: user, Rights: accesspolicy.APChange},
+		{Actor: accesspolicy.PublicActor(), Rights: accesspolicy.APView},
+		{Actor: accesspolicy.PublicActor(), Rights: accesspolicy.APChange},
+		{Actor: accesspolicy.GroupActor(g.ID), Rights: accesspolicy.APChange},
+		{Actor: accesspolicy.UserActor(uuid.New()), Rights: accesspolicy.APView},
+		{Actor: accesspolicy.UserActor(uuid.New()), Rights: accesspolicy.APChange},
+		{Actor: member, Rights: accesspolicy.APView},
+		{Actor: accesspolicy.NewActor(accesspolicy.AKRoleGroup, g.ID), Rights: accesspolicy.APView},
+	}
+
+	results := m.HasRightsBatch(ctx, p.ID, checks)
+	a.Equal([]bool{true, true, false, true, false, true, false, true, true, false, true, false}, results)
+
+	// the results must match individual checks
+	for i, c := range checks {
+		a.Equal(m.HasRights(ctx, p.ID, c.Actor, c.Rights), results[i], "check %d", i)
+	}
+
+	// nothing to check
+	a.Empty(m.HasRightsBatch(ctx, p.ID, nil))
+
+	// unknown policy fails closed
+	a.Equal([]bool{false, false}, m.HasRightsBatch(ctx, uuid.New(), checks[:2]))
+}
+
+func TestAccessPolicyManagerHasAnyRights(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+	member := accesspolicy.UserActor(uuid.New())
+	stranger := accesspolicy.UserActor(uuid.New())
+
+	requested := accesspolicy.APView | accesspolicy.APChange | accesspolicy.APDelete
+
+	parent, err := m.Create(ctx, "any rights parent", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	p, err := m.Create(ctx, "any rights policy", owner.ID, parent.ID, accesspolicy.NilObject(), accesspolicy.FExtend)
+	a.NoError(err)
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "any rights group", "any rights group")
+	a.NoError(err)
+	a.NoError(gm.CreateRelation(ctx, group.NewRelation(g.ID, group.AKUser, member.ID)))
+
+	// the user has exactly one of the three requested rights
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APChange))
+	a.NoError(m.Update(ctx, p))
+
+	a.True(m.HasAnyRights(ctx, p.ID, user, requested))
+	a.False(m.HasRights(ctx, p.ID, user, requested))
+	a.False(m.HasAnyRights(ctx, p.ID, user, accesspolicy.APView|accesspolicy.APDelete))
+
+	// the only right is extended from the parent
+	a.NoError(m.GrantGroupAccess(ctx, parent.ID, owner, g.ID, accesspolicy.APDelete))
+	a.NoError(m.Update(ctx, parent))
+
+	a.True(m.HasAnyRights(ctx, p.ID, member, requested))
+	a.True(m.HasAnyRights(ctx, parent.ID, accesspolicy.GroupActor(g.ID), requested))
+	a.False(m.HasAnyRights(ctx, parent.ID, accesspolicy.NewActor(accesspolicy.AKRoleGroup, g.ID), requested))
+	a.False(m.HasRights(ctx, p.ID, member, requested))
+	a.False(m.HasAnyRights(ctx, p.ID, member, accesspolicy.APView|accesspolicy.APChange))
+
+	// public rights
+	a.False(m.HasAnyRights(ctx, p.ID, accesspolicy.PublicActor(), requested))
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+	a.NoError(m.Update(ctx, p))
+	a.True(m.HasAnyRights(ctx, p.ID, accesspolicy.PublicActor(), requested))
+	a.True(m.HasAnyRights(ctx, p.ID, stranger, requested))
+
+	// nothing requested, nothing held
+	a.False(m.HasAnyRights(ctx, p.ID, owner, accesspolicy.APNoAccess))
+	a.False(m.HasAnyRights(ctx, uuid.New(), owner, requested))
+	a.True(m.HasAnyRights(ctx, p.ID, owner, requested))
+}
+
+func TestAccessPolicyManagerRevokeWeighting(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		junior := accesspolicy.UserActor(uuid.New())
+		senior := accesspolicy.UserActor(uuid.New())
+		peer := accesspolicy.UserActor(uuid.New())
+		user := accesspolicy.UserActor(uuid.New())
+
+		p, err := m.Create(ctx, "weighted policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		juniorRights := accesspolicy.APView | accesspolicy.APManageAccess
+		seniorRights := accesspolicy.APView | accesspolicy.APChange | accesspolicy.APDelete | accesspolicy.APManageAccess
+
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, junior.ID, juniorRights))
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, senior.ID, seniorRights))
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, peer.ID, juniorRights))
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView))
+		a.NoError(m.Update(ctx, p))
+
+		// a lower-ranked manager can't strip a higher-ranked one
+		a.Equal(accesspolicy.ErrAccessDenied, m.RevokeAccess(ctx, p.ID, junior, senior))
+		a.True(m.HasRights(ctx, p.ID, senior, seniorRights))
+
+		// nor the owner, who holds everything
+		a.Equal(accesspolicy.ErrAccessDenied, m.RevokeAccess(ctx, p.ID, senior, owner))
+
+		// but may strip those who hold less or the same
+		a.NoError(m.RevokeAccess(ctx, p.ID, junior, user))
+		a.NoError(m.RevokeAccess(ctx, p.ID, junior, peer))
+		a.NoError(m.Update(ctx, p))
+		a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+		a.False(m.HasRights(ctx, p.ID, peer, accesspolicy.APView))
+
+		// a higher-ranked manager strips a lower-ranked one
+		a.NoError(m.RevokeAccess(ctx, p.ID, senior, junior))
+		a.NoError(m.Update(ctx, p))
+		a.False(m.HasRights(ctx, p.ID, junior, accesspolicy.APView))
+	})
+}
+
+func TestAccessPolicyManagerEffectiveRights(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+	denied := accesspolicy.UserActor(uuid.New())
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "effective group", "effective group")
+	a.NoError(err)
+
+	r, err := gm.Create(ctx, group.FRole, uuid.Nil, "effective role", "effective role")
+	a.NoError(err)
+
+	p, err := m.Create(ctx, "effective policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.DenyUserAccess(ctx, p.ID, owner, denied.ID, accesspolicy.APView))
+	a.NoError(m.GrantGroupAccess(ctx, p.ID, owner, g.ID, accesspolicy.APCopy))
+	a.NoError(m.GrantRoleAccess(ctx, p.ID, owner, r.ID, accesspolicy.APMove))
+	a.NoError(m.Update(ctx, p))
+
+	expected := map[accesspolicy.Actor]accesspolicy.Right{
+		accesspolicy.PublicActor():    accesspolicy.APView,
+		owner:                         accesspolicy.APFullAccess,
+		user:                          accesspolicy.APView | accesspolicy.APChange,
+		denied:                        accesspolicy.APNoAccess,
+		accesspolicy.GroupActor(g.ID): accesspolicy.APCopy,
+		accesspolicy.RoleActor(r.ID):  accesspolicy.APMove,
+	}
+
+	// cached roster
+	rights, err := m.EffectiveRights(ctx, p.ID)
+	a.NoError(err)
+	a.Equal(expected, rights)
+
+	// roster read from the store by a fresh manager
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+
+	rights, err = m2.EffectiveRights(ctx, p.ID)
+	a.NoError(err)
+	a.Equal(expected, rights)
+
+	// non-existing policy
+	_, err = m.EffectiveRights(ctx, uuid.New())
+	a.Error(err)
+}
+
+func TestAccessPolicyManagerPendingChanges(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		user := accesspolicy.UserActor(uuid.New())
+		contractor := accesspolicy.UserActor(uuid.New())
+
+		p, err := m.Create(ctx, "pending changes policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		// nothing is pending yet
+		changes, err := m.PendingChanges(ctx, p.ID)
+		a.NoError(err)
+		a.NotNil(changes)
+		a.Empty(changes)
+
+		expiresAt := time.Now().Add(time.Hour)
+
+		a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APChange))
+		a.NoError(m.GrantUserAccessUntil(ctx, p.ID, owner, contractor.ID, accesspolicy.APView, expiresAt))
+		a.NoError(m.RevokeAccess(ctx, p.ID, owner, user))
+
+		changes, err = m.PendingChanges(ctx, p.ID)
+		a.NoError(err)
+		a.Equal([]accesspolicy.Change{
+			{Action: accesspolicy.RSet, Actor: accesspolicy.PublicActor(), Rights: accesspolicy.APView},
+			{Action: accesspolicy.RSet, Actor: user, Rights: accesspolicy.APView | accesspolicy.APChange},
+			{Action: accesspolicy.RSet, Actor: contractor, Rights: accesspolicy.APView, ExpiresAt: expiresAt},
+			{Action: accesspolicy.RUnset, Actor: user},
+		}, changes)
+
+		// previewing doesn't alter anything
+		r, err := m.RosterByPolicyID(ctx, p.ID)
+		a.NoError(err)
+		a.True(r.HasChanges())
+		a.Equal(changes, r.PendingChanges())
+
+		// nothing is pending once stored
+		a.NoError(m.Update(ctx, p))
+
+		changes, err = m.PendingChanges(ctx, p.ID)
+		a.NoError(err)
+		a.NotNil(changes)
+		a.Empty(changes)
+
+		_, err = m.PendingChanges(ctx, uuid.New())
+		a.Error(err)
+	})
+}
+
+func TestAccessPolicyManagerStaleUpdate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// two policy managers sharing the same store
+		m1, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m1)
+
+		m2, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m2)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		user1 := accesspolicy.UserActor(uuid.New())
+		user2 := accesspolicy.UserActor(uuid.New())
+
+		p, err := m1.Create(ctx, "stale update policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+		a.Zero(p.Version)
+
+		// both managers load the same version
+		p1, err := m1.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+
+		p2, err := m2.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+		a.Equal(p1.Version, p2.Version)
+
+		// both are granting at the same time, the first one wins
+		a.NoError(m1.GrantUserAccess(ctx, p.ID, owner, user1.ID, accesspolicy.APView))
+		a.NoError(m2.GrantUserAccess(ctx, p.ID, owner, user2.ID, accesspolicy.APView))
+
+		a.NoError(m1.Update(ctx, p1))
+
+		fetched, err := s.FetchPolicyByID(ctx, p.ID)
+		a.NoError(err)
+		a.Equal(int64(1), fetched.Version)
+
+		// while the second one would overwrite it
+		err = m2.Update(ctx, p2)
+		a.Equal(accesspolicy.ErrStaleUpdate, errors.Cause(err))
+
+		// nothing of the stale update has been stored
+		fetched, err = s.FetchPolicyByID(ctx, p.ID)
+		a.NoError(err)
+		a.Equal(int64(1), fetched.Version)
+
+		ids, err := s.FetchPolicyIDsByActor(ctx, user2)
+		a.NoError(err)
+		a.Empty(ids)
+
+		// reloading and retrying
+		p2, err = m2.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+		a.Equal(int64(1), p2.Version)
+		a.True(m2.HasRights(ctx, p.ID, user1, accesspolicy.APView))
+		a.False(m2.HasRights(ctx, p.ID, user2, accesspolicy.APView))
+
+		a.NoError(m2.GrantUserAccess(ctx, p.ID, owner, user2.ID, accesspolicy.APView))
+		a.NoError(m2.Update(ctx, p2))
+
+		p2, err = m2.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+		a.Equal(int64(2), p2.Version)
+
+		// now the first manager is the one behind
+		a.NoError(m1.GrantUserAccess(ctx, p.ID, owner, user2.ID, accesspolicy.APChange))
+		a.Equal(accesspolicy.ErrStaleUpdate, errors.Cause(m1.Update(ctx, p1)))
+
+		// both grants have made it
+		m3, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.True(m3.HasRights(ctx, p.ID, user1, accesspolicy.APView))
+		a.True(m3.HasRights(ctx, p.ID, user2, accesspolicy.APView))
+		a.False(m3.HasRights(ctx, p.ID, user2, accesspolicy.APChange))
+	})
+}
+
+func TestAccessPolicyManagerRevokePersisted(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		user := accesspolicy.UserActor(uuid.New())
+		other := accesspolicy.UserActor(uuid.New())
+
+		p, err := m.Create(ctx, "revoked policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		// granting and persisting
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APChange))
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, other.ID, accesspolicy.APView))
+		a.NoError(m.Update(ctx, p))
+
+		ids, err := s.FetchPolicyIDsByActor(ctx, user)
+		a.NoError(err)
+		a.Equal([]uuid.UUID{p.ID}, ids)
+
+		// revoking and persisting
+		a.NoError(m.RevokeAccess(ctx, p.ID, owner, user))
+		a.NoError(m.Update(ctx, p))
+
+		// the roster entry must be gone rather than left with no rights
+		ids, err = s.FetchPolicyIDsByActor(ctx, user)
+		a.NoError(err)
+		a.Empty(ids)
+
+		r, err := s.FetchRosterByPolicyID(ctx, p.ID)
+		a.NoError(err)
+		for _, cell := range r.Registry {
+			a.NotEqual(user, cell.Key)
+		}
+
+		// a fresh manager to make sure everything is read from the store
+		m2, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.False(m2.HasRights(ctx, p.ID, user, accesspolicy.APView))
+		a.True(m2.HasRights(ctx, p.ID, other, accesspolicy.APView))
+	})
+}
+
+func TestAccessPolicyManagerRevokeAllForActor(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// policy store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	stranger := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "offboarded group", "offboarded group")
+	a.NoError(err)
+
+	role, err := gm.Create(ctx, group.FRole, uuid.Nil, "offboarded role", "offboarded role")
+	a.NoError(err)
+
+	p1, err := m.Create(ctx, "offboarding policy 1", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	p2, err := m.Create(ctx, "offboarding policy 2", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	// the grantor can't manage access to this one
+	foreign, err := m.Create(ctx, "offboarding foreign policy", stranger.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantUserAccess(ctx, p1.ID, owner, user.ID, accesspolicy.APView))
+	a.NoError(m.GrantGroupAccess(ctx, p1.ID, owner, g.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, p1))
+
+	a.NoError(m.GrantUserAccess(ctx, p2.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.GrantRoleAccess(ctx, p2.ID, owner, role.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, p2))
+
+	a.NoError(m.GrantUserAccess(ctx, foreign.ID, stranger, user.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, foreign))
+
+	// user
+	err = m.RevokeAllForActor(ctx, owner, user)
+	if a.Error(err) {
+		errs, ok := err.(accesspolicy.PolicyErrors)
+		if a.True(ok) && a.Len(errs, 1) {
+			a.Equal(accesspolicy.ErrAccessDenied, errors.Cause(errs[foreign.ID]))
+		}
+	}
+
+	a.False(m.HasRights(ctx, p1.ID, user, accesspolicy.APView))
+	a.False(m.HasRights(ctx, p2.ID, user, accesspolicy.APView))
+	a.True(m.HasRights(ctx, foreign.ID, user, accesspolicy.APView))
+
+	ids, err := s.FetchPolicyIDsByActor(ctx, user)
+	a.NoError(err)
+	a.Equal([]uuid.UUID{foreign.ID}, ids)
+
+	// group
+	a.NoError(m.RevokeAllForActor(ctx, owner, accesspolicy.GroupActor(g.ID)))
+
+	ids, err = s.FetchPolicyIDsByActor(ctx, accesspolicy.GroupActor(g.ID))
+	a.NoError(err)
+	a.Empty(ids)
+
+	// role
+	a.NoError(m.RevokeAllForActor(ctx, owner, accesspolicy.RoleActor(role.ID)))
+
+	ids, err = s.FetchPolicyIDsByActor(ctx, accesspolicy.RoleActor(role.ID))
+	a.NoError(err)
+	a.Empty(ids)
+
+	// the rest is intact
+	a.True(m.HasRights(ctx, p1.ID, owner, accesspolicy.APFullAccess))
+
+	// a fresh manager to make sure everything is read from the store
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.False(m2.HasRights(ctx, p2.ID, user, accesspolicy.APView))
+	a.False(m2.HasRights(ctx, p1.ID, accesspolicy.GroupActor(g.ID), accesspolicy.APView))
+	a.False(m2.HasRights(ctx, p2.ID, accesspolicy.RoleActor(role.ID), accesspolicy.APView))
+
+	// revoking again changes nothing
+	a.NoError(m.RevokeAllForActor(ctx, owner, accesspolicy.GroupActor(g.ID)))
+
+	// public rights aren't revoked this way
+	a.Equal(accesspolicy.ErrUnrecognizedActorKind, errors.Cause(m.RevokeAllForActor(ctx, owner, accesspolicy.PublicActor())))
+	a.Equal(accesspolicy.ErrNilActorID, m.RevokeAllForActor(ctx, owner, accesspolicy.UserActor(uuid.Nil)))
+}
+
+func TestAccessPolicyManagerTransferOwnership(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		oldOwner := accesspolicy.UserActor(uuid.New())
+		newOwner := accesspolicy.UserActor(uuid.New())
+		manager := accesspolicy.UserActor(uuid.New())
+		stranger := accesspolicy.UserActor(uuid.New())
+
+		p, err := m.Create(ctx, "transferred policy", oldOwner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		a.NoError(m.GrantUserAccess(ctx, p.ID, oldOwner, manager.ID, accesspolicy.APView|accesspolicy.APManageAccess))
+		a.NoError(m.Update(ctx, p))
+
+		// invalid transfers
+		a.Equal(accesspolicy.ErrNilOwnerID, m.TransferOwnership(ctx, p.ID, oldOwner.ID, uuid.Nil))
+		a.Equal(accesspolicy.ErrAccessDenied, m.TransferOwnership(ctx, p.ID, stranger.ID, stranger.ID))
+
+		// the current owner hands the policy over
+		a.True(m.HasRights(ctx, p.ID, oldOwner, accesspolicy.APFullAccess))
+		a.NoError(m.TransferOwnership(ctx, p.ID, oldOwner.ID, newOwner.ID))
+
+		p, err = m.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+		a.Equal(newOwner.ID, p.OwnerID)
+
+		// the previous owner loses the implicit full access
+		a.False(m.HasRights(ctx, p.ID, oldOwner, accesspolicy.APView))
+		a.Equal(accesspolicy.APFullAccess, m.Access(ctx, p.ID, newOwner.ID))
+		a.Equal(accesspolicy.APNoAccess, m.Access(ctx, p.ID, oldOwner.ID))
+
+		// a fresh manager to make sure everything is read from the store
+		m2, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.True(m2.HasRights(ctx, p.ID, newOwner, accesspolicy.APFullAccess))
+		a.False(m2.HasRights(ctx, p.ID, oldOwner, accesspolicy.APView))
+
+		// managing access isn't enough to transfer it
+		a.Equal(accesspolicy.ErrAccessDenied, m.TransferOwnership(ctx, p.ID, manager.ID, stranger.ID))
+		a.True(m.HasRights(ctx, p.ID, newOwner, accesspolicy.APFullAccess))
+
+		// a co-owner can, as long as the new owner is within the limit
+		a.NoError(m.AddCoOwner(ctx, p.ID, newOwner, manager.ID))
+
+		_, err = m.Create(ctx, "stranger's own policy", stranger.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		m.SetMaxPoliciesPerOwner(1)
+
+		err = m.TransferOwnership(ctx, p.ID, manager.ID, stranger.ID)
+		a.True(errors.Is(err, accesspolicy.ErrOwnerQuotaExceeded))
+		a.True(m.HasRights(ctx, p.ID, newOwner, accesspolicy.APFullAccess))
+
+		m.SetMaxPoliciesPerOwner(0)
+
+		a.NoError(m.TransferOwnership(ctx, p.ID, manager.ID, stranger.ID))
+		a.True(m.HasRights(ctx, p.ID, stranger, accesspolicy.APFullAccess))
+		a.False(m.HasRights(ctx, p.ID, newOwner, accesspolicy.APView))
+	})
+}
+
+func TestAccessPolicyManagerCoOwners(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		coOwner := accesspolicy.UserActor(uuid.New())
+		manager := accesspolicy.UserActor(uuid.New())
+		stranger := accesspolicy.UserActor(uuid.New())
+
+		p, err := m.Create(ctx, "co-owned policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, manager.ID, accesspolicy.APView|accesspolicy.APManageAccess))
+		a.NoError(m.Update(ctx, p))
+
+		// invalid attempts
+		a.Equal(accesspolicy.ErrNilOwnerID, m.AddCoOwner(ctx, p.ID, owner, uuid.Nil))
+		a.Equal(accesspolicy.ErrAccessDenied, m.AddCoOwner(ctx, p.ID, stranger, stranger.ID))
+		a.Equal(accesspolicy.ErrAccessDenied, m.AddCoOwner(ctx, p.ID, manager, manager.ID))
+
+		// sharing the ownership
+		a.False(m.HasRights(ctx, p.ID, coOwner, accesspolicy.APView))
+		a.NoError(m.AddCoOwner(ctx, p.ID, owner, coOwner.ID))
+
+		p, err = m.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+		a.Equal(owner.ID, p.OwnerID)
+		a.True(p.IsOwner(coOwner.ID))
+		a.True(p.IsCoOwner(coOwner.ID))
+		a.False(p.IsCoOwner(owner.ID))
+
+		// co-owner is given the same full access as the owner
+		a.True(m.HasRights(ctx, p.ID, coOwner, accesspolicy.APFullAccess))
+		a.Equal(accesspolicy.APFullAccess, m.Access(ctx, p.ID, coOwner.ID))
+
+		rights, err := m.EffectiveRights(ctx, p.ID)
+		a.NoError(err)
+		a.Equal(accesspolicy.APFullAccess, rights[coOwner])
+
+		// a co-owner may share the ownership further
+		a.NoError(m.AddCoOwner(ctx, p.ID, coOwner, stranger.ID))
+		a.True(m.HasRights(ctx, p.ID, stranger, accesspolicy.APFullAccess))
+
+		// a fresh manager to make sure the owner set is read from the store
+		m2, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+
+		fetched, err := m2.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+		a.Len(fetched.CoOwners, 2)
+		a.True(m2.HasRights(ctx, p.ID, coOwner, accesspolicy.APFullAccess))
+		a.True(m2.HasRights(ctx, p.ID, stranger, accesspolicy.APFullAccess))
+
+		// the primary owner can't be removed
+		a.Equal(accesspolicy.ErrPrimaryOwner, m.RemoveCoOwner(ctx, p.ID, coOwner, owner.ID))
+		a.Equal(accesspolicy.ErrAccessDenied, m.RemoveCoOwner(ctx, p.ID, manager, coOwner.ID))
+
+		a.NoError(m.RemoveCoOwner(ctx, p.ID, owner, stranger.ID))
+		a.False(m.HasRights(ctx, p.ID, stranger, accesspolicy.APView))
+		a.True(m.HasRights(ctx, p.ID, coOwner, accesspolicy.APFullAccess))
+
+		m3, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.False(m3.HasRights(ctx, p.ID, stranger, accesspolicy.APView))
+		a.True(m3.HasRights(ctx, p.ID, coOwner, accesspolicy.APFullAccess))
+
+		// transferring the ownership to a co-owner
+		a.NoError(m.TransferOwnership(ctx, p.ID, owner.ID, coOwner.ID))
+
+		p, err = m.PolicyByID(ctx, p.ID, false)
+		a.NoError(err)
+		a.Equal(coOwner.ID, p.OwnerID)
+		a.Empty(p.CoOwners)
+		a.False(m.HasRights(ctx, p.ID, owner, accesspolicy.APView))
+	})
+}
+
+func TestAccessPolicyManagerSubscribe(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		user := accesspolicy.UserActor(uuid.New())
+
+		p, err := m.Create(ctx, "observed policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		events := m.Subscribe()
+
+		// nothing is emitted until the changes are persisted
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APChange))
+		a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+		a.Len(events, 0)
+
+		a.NoError(m.Update(ctx, p))
+		if a.Len(events, 2) {
+			e := <-events
+			a.Equal(accesspolicy.PEGrant, e.Kind)
+			a.Equal(p.ID, e.PolicyID)
+			a.Equal(user, e.Actor)
+			a.Equal(accesspolicy.APView|accesspolicy.APChange, e.Rights)
+
+			e = <-events
+			a.Equal(accesspolicy.PEGrant, e.Kind)
+			a.Equal(accesspolicy.PublicActor(), e.Actor)
+			a.Equal(accesspolicy.APView, e.Rights)
+		}
+
+		a.NoError(m.RevokeAccess(ctx, p.ID, owner, user))
+		a.NoError(m.Update(ctx, p))
+		if a.Len(events, 1) {
+			e := <-events
+			a.Equal(accesspolicy.PERevoke, e.Kind)
+			a.Equal(user, e.Actor)
+		}
+
+		// updating without roster changes emits nothing
+		a.NoError(m.Update(ctx, p))
+		a.Len(events, 0)
+
+		// an idle subscriber never blocks the updates, only the latest events are kept
+		idle := m.Subscribe()
+		for i := 0; i < 150; i++ {
+			a.NoError(m.GrantUserAccess(ctx, p.ID, owner, uuid.New(), accesspolicy.APView))
+			a.NoError(m.Update(ctx, p))
+		}
+
+		a.Equal(cap(idle), len(idle))
+
+		// unsubscribing closes the channel
+		m.Unsubscribe(events)
+		for range events {
+		}
+
+		_, ok := <-events
+		a.False(ok)
+	})
+}
+
+func TestAccessPolicyManagerGrantableRights(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner := accesspolicy.UserActor(uuid.New())
+		delegate := accesspolicy.UserActor(uuid.New())
+		user := accesspolicy.UserActor(uuid.New())
+
+		p, err := m.Create(ctx, "delegated policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		// the delegate can view and change, but may only pass on the view right
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, delegate.ID, accesspolicy.APView|accesspolicy.APChange|accesspolicy.APManageAccess))
+		a.NoError(m.SetGrantableRights(ctx, p.ID, delegate, accesspolicy.APView))
+		a.NoError(m.Update(ctx, p))
+
+		a.True(m.HasRights(ctx, p.ID, delegate, accesspolicy.APView|accesspolicy.APChange))
+
+		// invalid limits
+		a.Equal(accesspolicy.ErrPublicGrantLimit, m.SetGrantableRights(ctx, p.ID, accesspolicy.PublicActor(), accesspolicy.APView))
+		a.Equal(accesspolicy.ErrNilActorID, m.SetGrantableRights(ctx, p.ID, accesspolicy.UserActor(uuid.Nil), accesspolicy.APView))
+
+		// held, but not grantable
+		a.Equal(accesspolicy.ErrExcessOfRights, m.GrantUserAccess(ctx, p.ID, delegate, user.ID, accesspolicy.APView|accesspolicy.APChange))
+		a.Equal(accesspolicy.ErrExcessOfRights, m.GrantUserAccess(ctx, p.ID, delegate, user.ID, accesspolicy.APChange))
+		a.Equal(accesspolicy.ErrExcessOfRights, m.GrantPublicAccess(ctx, p.ID, delegate, accesspolicy.APChange))
+		a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+
+		// within the grantable mask
+		a.NoError(m.GrantUserAccess(ctx, p.ID, delegate, user.ID, accesspolicy.APView))
+		a.NoError(m.Update(ctx, p))
+		a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+		a.False(m.HasRights(ctx, p.ID, user, accesspolicy.APChange))
+
+		// the owner is never limited
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APChange))
+		a.NoError(m.Update(ctx, p))
+
+		// a fresh manager to make sure the limit is read from the store
+		m2, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.True(m2.HasRights(ctx, p.ID, delegate, accesspolicy.APView|accesspolicy.APChange))
+		a.Equal(accesspolicy.ErrExcessOfRights, m2.GrantUserAccess(ctx, p.ID, delegate, user.ID, accesspolicy.APChange))
+
+		// granting new rights keeps the limit intact
+		a.NoError(m.GrantUserAccess(ctx, p.ID, owner, delegate.ID, accesspolicy.APView|accesspolicy.APChange|accesspolicy.APDelete|accesspolicy.APManageAccess))
+		a.NoError(m.Update(ctx, p))
+		a.Equal(accesspolicy.ErrExcessOfRights, m.GrantUserAccess(ctx, p.ID, delegate, user.ID, accesspolicy.APDelete))
+
+		// lifting the limit
+		a.NoError(m.SetGrantableRights(ctx, p.ID, delegate, accesspolicy.APNoAccess))
+		a.NoError(m.Update(ctx, p))
+		a.NoError(m.GrantUserAccess(ctx, p.ID, delegate, user.ID, accesspolicy.APView|accesspolicy.APChange))
+	})
+}
+
+func TestAccessPolicyManagerPoliciesByOwner(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s accesspolicy.Store, gm *group.Manager) {
+		a := assert.New(t)
+
+		// test context
+		ctx := context.Background()
+
+		// policy manager
+		m, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+		a.NotNil(m)
+
+		owner, another := uuid.New(), uuid.New()
+
+		for i := 0; i < 5; i++ {
+			_, err = m.Create(ctx, fmt.Sprintf("owned policy %d", i), owner, uuid.Nil, accesspolicy.NilObject(), 0)
+			a.NoError(err)
+		}
+
+		_, err = m.Create(ctx, "not owned policy", another, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		_, _, err = m.PoliciesByOwner(ctx, uuid.Nil, 10, 0, false)
+		a.Equal(accesspolicy.ErrNilOwnerID, err)
+
+		// a fresh manager to make sure everything is read from the store
+		m2, err := accesspolicy.NewManager(s, gm)
+		a.NoError(err)
+
+		// pages are ordered by key
+		ps, total, err := m2.PoliciesByOwner(ctx, owner, 2, 0, false)
+		a.NoError(err)
+		a.Equal(5, total)
+		if a.Len(ps, 2) {
+			a.Equal("owned policy 0", ps[0].Key)
+			a.Equal("owned policy 1", ps[1].Key)
+		}
+
+		ps, total, err = m2.PoliciesByOwner(ctx, owner, 2, 4, false)
+		a.NoError(err)
+		a.Equal(5, total)
+		if a.Len(ps, 1) {
+			a.Equal("owned policy 4", ps[0].Key)
+		}
+
+		ps, _, err = m2.PoliciesByOwner(ctx, owner, 2, 10, false)
+		a.NoError(err)
+		a.Empty(ps)
+
+		// no limit
+		ps, _, err = m2.PoliciesByOwner(ctx, owner, 0, 0, false)
+		a.NoError(err)
+		a.Len(ps, 5)
+
+		for _, p := range ps {
+			a.Equal(owner, p.OwnerID)
+		}
+
+		// listed policies are cached along with their rosters
+		a.True(m2.HasRights(ctx, ps[0].ID, accesspolicy.UserActor(owner), accesspolicy.APFullAccess))
+	})
+}
+
 func TestAccessResolver(t *testing.T) {
 	//a := assert.New(t)
 
@@ -0,0 +1,57 @@
+package accesspolicy
+
+import (
+	"time"
+)
+
+// Recorder receives the metrics of the manager's operations
+// NOTE: the methods are called synchronously, so they must be fast
+// and safe for concurrent use
+type Recorder interface {
+	// IncGrant is called whenever rights are granted to an actor
+	IncGrant()
+
+	// IncRevoke is called whenever rights are revoked from an actor
+	IncRevoke()
+
+	// IncHasRights is called for every rights check which has
+	// been determined, granted tells whether the rights are held
+	IncHasRights(granted bool)
+
+	// ObserveStoreLatency is called after a store operation with
+	// the name of the store method and how long it took
+	ObserveStoreLatency(op string, d time.Duration)
+}
+
+// nopRecorder is used while no recorder is set
+type nopRecorder struct{}
+
+func (nopRecorder) IncGrant()                                      {}
+func (nopRecorder) IncRevoke()                                     {}
+func (nopRecorder) IncHasRights(granted bool)                      {}
+func (nopRecorder) ObserveStoreLatency(op string, d time.Duration) {}
+
+// SetMetrics sets an optional recorder of the manager's metrics,
+// nil disables recording (default)
+func (m *Manager) SetMetrics(rec Recorder) {
+	if rec == nil {
+		rec = nopRecorder{}
+	}
+
+	m.Lock()
+	m.metrics = rec
+	m.Unlock()
+}
+
+func (m *Manager) recorder() Recorder {
+	m.RLock()
+	rec := m.metrics
+	m.RUnlock()
+
+	return rec
+}
+
+// observeStore reports the latency of a store operation started at a given time
+func (m *Manager) observeStore(op string, start time.Time) {
+	m.recorder().ObserveStoreLatency(op, time.Since(start))
+}
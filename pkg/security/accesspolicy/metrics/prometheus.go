@@ -0,0 +1,171 @@
+// Package metrics provides recorders of the access policy manager's metrics
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/agubarev/hometown/pkg/security/accesspolicy"
+)
+
+// DefaultLatencyBuckets are the upper bounds (in seconds) of the store latency histogram
+var DefaultLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// latency is a cumulative histogram of a single store operation
+type latency struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// PrometheusRecorder is an accesspolicy.Recorder which exposes
+// its metrics in the Prometheus text exposition format
+// NOTE: mount it as an http.Handler to be scraped
+type PrometheusRecorder struct {
+	namespace string
+	buckets   []float64
+	grants    uint64
+	revokes   uint64
+	granted   uint64
+	denied    uint64
+	latencies map[string]*latency
+	sync.Mutex
+}
+
+// make sure it fits
+var _ accesspolicy.Recorder = (*PrometheusRecorder)(nil)
+
+// NewPrometheusRecorder returns a new recorder whose metric names
+// are prefixed by a given namespace, "accesspolicy" if it's empty
+func NewPrometheusRecorder(namespace string) *PrometheusRecorder {
+	if namespace == "" {
+		namespace = "accesspolicy"
+	}
+
+	return &PrometheusRecorder{
+		namespace: namespace,
+		buckets:   DefaultLatencyBuckets,
+		latencies: make(map[string]*latency),
+	}
+}
+
+// IncGrant counts a grant
+func (r *PrometheusRecorder) IncGrant() {
+	r.Lock()
+	r.grants++
+	r.Unlock()
+}
+
+// IncRevoke counts a revocation
+func (r *PrometheusRecorder) IncRevoke() {
+	r.Lock()
+	r.revokes++
+	r.Unlock()
+}
+
+// IncHasRights counts a rights check by its result
+func (r *PrometheusRecorder) IncHasRights(granted bool) {
+	r.Lock()
+	if granted {
+		r.granted++
+	} else {
+		r.denied++
+	}
+	r.Unlock()
+}
+
+// ObserveStoreLatency adds the duration of a store operation to its histogram
+func (r *PrometheusRecorder) ObserveStoreLatency(op string, d time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+
+	l, ok := r.latencies[op]
+	if !ok {
+		l = &latency{counts: make([]uint64, len(r.buckets))}
+		r.latencies[op] = l
+	}
+
+	seconds := d.Seconds()
+	for i, le := range r.buckets {
+		if seconds <= le {
+			l.counts[i]++
+		}
+	}
+
+	l.count++
+	l.sum += seconds
+}
+
+// WriteTo writes the current metrics in the Prometheus text format
+func (r *PrometheusRecorder) WriteTo(w io.Writer) (n int64, err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	cw := &countingWriter{w: w}
+	ns := r.namespace
+
+	fmt.Fprintf(cw, "# HELP %s_grants_total Number of rights granted.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_grants_total counter\n", ns)
+	fmt.Fprintf(cw, "%s_grants_total %d\n", ns, r.grants)
+
+	fmt.Fprintf(cw, "# HELP %s_revokes_total Number of rights revoked.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_revokes_total counter\n", ns)
+	fmt.Fprintf(cw, "%s_revokes_total %d\n", ns, r.revokes)
+
+	fmt.Fprintf(cw, "# HELP %s_rights_checks_total Number of rights checks by result.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_rights_checks_total counter\n", ns)
+	fmt.Fprintf(cw, "%s_rights_checks_total{result=\"granted\"} %d\n", ns, r.granted)
+	fmt.Fprintf(cw, "%s_rights_checks_total{result=\"denied\"} %d\n", ns, r.denied)
+
+	ops := make([]string, 0, len(r.latencies))
+	for op := range r.latencies {
+		ops = append(ops, op)
+	}
+
+	sort.Strings(ops)
+
+	fmt.Fprintf(cw, "# HELP %s_store_latency_seconds Latency of the store operations.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_store_latency_seconds histogram\n", ns)
+	for _, op := range ops {
+		l := r.latencies[op]
+
+		for i, le := range r.buckets {
+			fmt.Fprintf(cw, "%s_store_latency_seconds_bucket{op=%q,le=\"%g\"} %d\n", ns, op, le, l.counts[i])
+		}
+
+		fmt.Fprintf(cw, "%s_store_latency_seconds_bucket{op=%q,le=\"+Inf\"} %d\n", ns, op, l.count)
+		fmt.Fprintf(cw, "%s_store_latency_seconds_sum{op=%q} %g\n", ns, op, l.sum)
+		fmt.Fprintf(cw, "%s_store_latency_seconds_count{op=%q} %d\n", ns, op, l.count)
+	}
+
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the current metrics to a scraper
+func (r *PrometheusRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WriteTo(w)
+}
+
+// countingWriter keeps the number of bytes written and the first error
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+
+	return n, err
+}
@@ -0,0 +1,40 @@
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/agubarev/hometown/pkg/security/accesspolicy/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusRecorder(t *testing.T) {
+	a := assert.New(t)
+
+	r := metrics.NewPrometheusRecorder("")
+	r.IncGrant()
+	r.IncGrant()
+	r.IncRevoke()
+	r.IncHasRights(true)
+	r.IncHasRights(false)
+	r.IncHasRights(false)
+	r.ObserveStoreLatency("FetchPolicyByID", 2*time.Millisecond)
+	r.ObserveStoreLatency("FetchPolicyByID", 2*time.Second)
+
+	var b bytes.Buffer
+	n, err := r.WriteTo(&b)
+	a.NoError(err)
+	a.EqualValues(b.Len(), n)
+
+	out := b.String()
+	a.Contains(out, "accesspolicy_grants_total 2\n")
+	a.Contains(out, "accesspolicy_revokes_total 1\n")
+	a.Contains(out, "accesspolicy_rights_checks_total{result=\"granted\"} 1\n")
+	a.Contains(out, "accesspolicy_rights_checks_total{result=\"denied\"} 2\n")
+	a.Contains(out, "accesspolicy_store_latency_seconds_bucket{op=\"FetchPolicyByID\",le=\"0.001\"} 0\n")
+	a.Contains(out, "accesspolicy_store_latency_seconds_bucket{op=\"FetchPolicyByID\",le=\"0.0025\"} 1\n")
+	a.Contains(out, "accesspolicy_store_latency_seconds_bucket{op=\"FetchPolicyByID\",le=\"1\"} 1\n")
+	a.Contains(out, "accesspolicy_store_latency_seconds_bucket{op=\"FetchPolicyByID\",le=\"+Inf\"} 2\n")
+	a.Contains(out, "accesspolicy_store_latency_seconds_count{op=\"FetchPolicyByID\"} 2\n")
+}
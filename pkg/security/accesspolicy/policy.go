@@ -1,7 +1,9 @@
 package accesspolicy
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -13,6 +15,13 @@ const (
 	FInherit uint8 = 1 << iota
 	FExtend
 	FSealed
+	FArchived
+)
+
+// maximum lengths of the policy designators
+const (
+	MaxKeyLength        = 255
+	MaxObjectNameLength = 255
 )
 
 type Object struct {
@@ -27,6 +36,16 @@ func NewObject(id uuid.UUID, name string) Object {
 	}
 }
 
+// NewObjectChecked is the same as NewObject, but returns
+// ErrObjectNameTooLong if the name exceeds MaxObjectNameLength
+func NewObjectChecked(id uuid.UUID, name string) (Object, error) {
+	if len(name) > MaxObjectNameLength {
+		return NilObject(), ErrObjectNameTooLong
+	}
+
+	return NewObject(id, name), nil
+}
+
 func NilObject() Object {
 	return Object{
 		Name: "",
@@ -85,6 +104,22 @@ type rosterChange struct {
 	action      RAction
 	key         Actor
 	accessRight Right
+	denied      Right
+	grantable   Right
+	expiresAt   time.Time
+}
+
+// Change is a roster change which is yet to be stored, as seen from
+// the outside, i.e. to be previewed before the policy is updated
+// NOTE: Rights, Denied and Grantable carry the complete entry as it'll be
+// stored, thus they're meaningless for RUnset
+type Change struct {
+	Action    RAction   `json:"action"`
+	Actor     Actor     `json:"actor"`
+	Rights    Right     `json:"rights"`
+	Denied    Right     `json:"denied,omitempty"`
+	Grantable Right     `json:"grantable,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
 }
 
 // declaring discrete rights for all cases
@@ -104,10 +139,24 @@ const (
 	APManageAccess
 	APFullAccess = ^Right(0)
 
+	// APReserved is not a right, grants which set it are rejected,
+	// except for APFullAccess as a whole
+	APReserved = Right(1 << 31)
+
+	// APDeny is the former marker which turned a grant into a denial
+	//
+	// Deprecated: the rights are denied explicitly, see DenyAccess.
+	APDeny = APReserved
+
 	// this flag is used for accesspolicy bits without translation
 	APUnrecognizedFlag = "unrecognized accesspolicy flag"
 )
 
+// APManageRights is the former name of APManageAccess, kept for compatibility
+//
+// Deprecated: use APManageAccess instead.
+const APManageRights = APManageAccess
+
 func (r Right) Translate() string {
 	switch r {
 	case APNoAccess:
@@ -139,11 +188,22 @@ func (r Right) Translate() string {
 	case APFullAccess:
 		return "full_access"
 	default:
+		if name, ok := customRightName(r); ok {
+			return name
+		}
+
 		return APUnrecognizedFlag
 	}
 }
 
-// Dictionary returns a map of property flag values to their respective names
+// isReserved tells whether these rights set the reserved bit,
+// which only APFullAccess is allowed to
+func (r Right) isReserved() bool {
+	return r != APFullAccess && r&APReserved != 0
+}
+
+// Dictionary returns a map of property flag values to their respective names,
+// including the registered custom rights
 func Dictionary() map[uint32]string {
 	dict := make(map[uint32]string)
 
@@ -174,6 +234,41 @@ func (r Right) String() string {
 	return strings.Join(s, ",")
 }
 
+// Bits splits these rights into discrete rights, lowest first,
+// leaving out the denial marker
+// NOTE: APFullAccess is expanded only into the named rights,
+// including the registered custom rights
+func (r Right) Bits() []Right {
+	bits := make([]Right, 0)
+
+	for i := 0; i < 31; i++ {
+		bit := Right(1 << i)
+		if r&bit == 0 {
+			continue
+		}
+
+		if r == APFullAccess && bit.Translate() == APUnrecognizedFlag {
+			continue
+		}
+
+		bits = append(bits, bit)
+	}
+
+	return bits
+}
+
+// Names returns the names of the discrete rights, same order as Bits
+func (r Right) Names() []string {
+	bits := r.Bits()
+
+	names := make([]string, len(bits))
+	for i, bit := range bits {
+		names[i] = bit.Translate()
+	}
+
+	return names
+}
+
 // Policy is a generalized ruleset for an object
 // if IsInherited is true, then the policy's own rosters will point to it's parent
 // and everything else will be ignored as long as it's true
@@ -193,7 +288,20 @@ type Policy struct {
 	OwnerID    uuid.UUID `db:"owner_id" json:"owner_id"`
 	ObjectID   uuid.UUID `db:"object_id" json:"object_id"`
 	Flags      uint8     `db:"flags" json:"flags"`
-	_          struct{}
+	ScopeID    uuid.UUID `db:"scope_id" json:"scope_id"`
+
+	// Version is bumped by each update, so that the updates made
+	// elsewhere in the meantime aren't silently overwritten
+	Version int64 `db:"version" json:"version"`
+
+	// DeletedAt is set once the policy is soft-deleted, such policy
+	// is kept in the store, but is no longer looked up by default
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+
+	// CoOwners are the users who share the ownership with the primary owner
+	// NOTE: stored separately, so that the owner set isn't bound by a column
+	CoOwners []uuid.UUID `db:"-" json:"co_owners,omitempty"`
+	_        struct{}
 }
 
 // NewPolicy create a new Policy object
@@ -229,11 +337,34 @@ func NewPolicy(key string, ownerID, parentID uuid.UUID, obj Object, flags uint8)
 	return p, nil
 }
 
-// IsOwner checks whether a given user is the owner of this policy
+// IsOwner checks whether a given user is the owner or one of the co-owners of this policy
 // NOTE: owner of the policy (meaning: the main entity) has full rights on it
 // WARNING: *** DO NOT remove owner zero check, to reduce the risk of abuse or mistake ***
 func (ap Policy) IsOwner(id uuid.UUID) bool {
-	return ap.OwnerID != uuid.Nil && (ap.OwnerID == id)
+	if id == uuid.Nil {
+		return false
+	}
+
+	if ap.OwnerID == id {
+		return true
+	}
+
+	return ap.IsCoOwner(id)
+}
+
+// IsCoOwner checks whether a given user is one of the co-owners of this policy
+func (ap Policy) IsCoOwner(id uuid.UUID) bool {
+	if id == uuid.Nil {
+		return false
+	}
+
+	for _, coOwnerID := range ap.CoOwners {
+		if coOwnerID == id {
+			return true
+		}
+	}
+
+	return false
 }
 
 // ApplyChangelog applies changes described by a diff.Diff()'s changelog
@@ -264,33 +395,132 @@ func (ap *Policy) ApplyChangelog(changelog diff.Changelog) (err error) {
 	return nil
 }
 
+// validation error codes
+const (
+	VCEmptyDesignators  = "empty_designators"
+	VCMissingObjectID   = "missing_object_id"
+	VCMissingObjectName = "missing_object_name"
+	VCConflictingFlags  = "conflicting_flags"
+	VCMissingParent     = "missing_parent"
+)
+
+// FieldError is a validation error that identifies the offending field
+// along with a machine-readable code, so that it could be translated
+// into a field-level message by the API layer
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"msg"`
+	err     error
+}
+
+// NOTE: cause must not be nil, otherwise errors.Cause would return nil
+func newFieldError(field, code, msg string, cause error) FieldError {
+	return FieldError{
+		Field:   field,
+		Code:    code,
+		Message: msg,
+		err:     cause,
+	}
+}
+
+func (e FieldError) Error() string {
+	if e.err != nil {
+		return e.Message + ": " + e.err.Error()
+	}
+
+	return e.Message
+}
+
+// Unwrap returns the underlying sentinel error
+func (e FieldError) Unwrap() error {
+	return e.err
+}
+
+// Cause returns the underlying sentinel error, same as Unwrap
+func (e FieldError) Cause() error {
+	return e.err
+}
+
+// FieldChange describes a change of a single policy field
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// ForbiddenChangeError lists the fields which are not allowed
+// to change, along with their current and attempted values
+// NOTE: unwraps to ErrForbiddenChange
+type ForbiddenChangeError struct {
+	Changes []FieldChange `json:"changes"`
+}
+
+func (e ForbiddenChangeError) Error() string {
+	s := make([]string, len(e.Changes))
+	for i, c := range e.Changes {
+		s[i] = fmt.Sprintf("%s (%q -> %q)", c.Field, c.From, c.To)
+	}
+
+	return fmt.Sprintf("%s: %s", ErrForbiddenChange, strings.Join(s, ", "))
+}
+
+// Unwrap returns ErrForbiddenChange
+func (e ForbiddenChangeError) Unwrap() error {
+	return ErrForbiddenChange
+}
+
+// Cause returns ErrForbiddenChange, same as Unwrap
+func (e ForbiddenChangeError) Cause() error {
+	return ErrForbiddenChange
+}
+
+// forbiddenChanges compares the fields of a given policy which must
+// never change, returning a list of changed fields
+func forbiddenChanges(current, updated Policy) (changes []FieldChange) {
+	if updated.Key != current.Key {
+		changes = append(changes, FieldChange{Field: "key", From: current.Key, To: updated.Key})
+	}
+
+	if updated.ObjectName != current.ObjectName {
+		changes = append(changes, FieldChange{Field: "object_name", From: current.ObjectName, To: updated.ObjectName})
+	}
+
+	if updated.ObjectID != current.ObjectID {
+		changes = append(changes, FieldChange{Field: "object_id", From: current.ObjectID.String(), To: updated.ObjectID.String()})
+	}
+
+	return changes
+}
+
 // SanitizeAndValidate validates accesspolicy policy by performing basic self-check
+// NOTE: all validation errors are of FieldError type
 func (ap Policy) Validate() error {
 	// policy must have some designators
 	if ap.Key == "" && ap.ObjectName == "" {
-		return errors.Wrap(ErrAccessPolicyEmptyDesignators, "policy cannot have both key and object name empty")
+		return newFieldError("key", VCEmptyDesignators, "policy cannot have both key and object name empty", ErrAccessPolicyEmptyDesignators)
 	}
 
 	// making sure that both the object name and ActorID are set,
 	// if either one of them is provided
 	if ap.ObjectName == "" && ap.ObjectID != uuid.Nil {
-		return errors.New("empty object name with a non-zero object id")
+		return newFieldError("object_name", VCMissingObjectName, "empty object name with a non-zero object id", ErrEmptyObjectName)
 	}
 
 	// if object name is set, then ObjectID must also be set
 	if ap.ObjectName != "" && ap.ObjectID == uuid.Nil {
-		return errors.New("zero object id with a non-empty object name")
+		return newFieldError("object_id", VCMissingObjectID, "zero object id with a non-empty object name", ErrNilObjectID)
 	}
 
 	// inherited means that this is not a standalone policy but simply points
 	// to its parent policy (first standalone policy to be found)
 	if ap.IsInherited() && ap.IsExtended() {
-		return errors.New("policy cannot be both inherited and extended at the same time")
+		return newFieldError("flags", VCConflictingFlags, "policy cannot be both inherited and extended at the same time", ErrConflictingFlags)
 	}
 
 	// parent must be set if this policy inherits or extends
 	if ap.ParentID == uuid.Nil && (ap.IsInherited() || ap.IsExtended()) {
-		return errors.New("policy cannot inherit or extend without a parent")
+		return newFieldError("parent_id", VCMissingParent, "policy cannot inherit or extend without a parent", ErrNoParent)
 	}
 
 	return nil
@@ -304,12 +534,27 @@ func (ap Policy) IsExtended() bool {
 	return (ap.Flags & FExtend) == FExtend
 }
 
+// IsArchived tells whether this policy is archived, archived
+// policies grant no access to anyone but the owner
+func (ap Policy) IsArchived() bool {
+	return (ap.Flags & FArchived) == FArchived
+}
+
+// IsDeleted tells whether this policy is soft-deleted
+func (ap Policy) IsDeleted() bool {
+	return ap.DeletedAt != nil
+}
+
 // SetKey sets a key name to the group
 func (ap *Policy) SetKey(key string) error {
 	if ap.ID != uuid.Nil {
 		return ErrForbiddenChange
 	}
 
+	if len(key) > MaxKeyLength {
+		return ErrKeyTooLong
+	}
+
 	// setting new key
 	ap.Key = key
 
@@ -322,6 +567,10 @@ func (ap *Policy) SetObjectName(name string) error {
 		return ErrForbiddenChange
 	}
 
+	if len(name) > MaxObjectNameLength {
+		return ErrObjectNameTooLong
+	}
+
 	// setting new object name
 	ap.ObjectName = name
 
@@ -2,12 +2,14 @@ package accesspolicy_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/agubarev/hometown/pkg/database"
 	"github.com/agubarev/hometown/pkg/group"
 	"github.com/agubarev/hometown/pkg/security/accesspolicy"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -141,6 +143,90 @@ func TestNewAccessPolicy(t *testing.T) {
 	a.NotNil(pExtendedWithParent)
 }
 
+func TestPolicyValidationFieldError(t *testing.T) {
+	a := assert.New(t)
+
+	// inheritance without a parent
+	_, err := accesspolicy.NewPolicy(
+		"inherits without parent", // key
+		uuid.New(),                // owner
+		uuid.Nil,                  // parent
+		accesspolicy.NilObject(),
+		accesspolicy.FInherit, // flags
+	)
+	a.Error(err)
+
+	var fe accesspolicy.FieldError
+	a.True(errors.As(err, &fe))
+	a.Equal("parent_id", fe.Field)
+	a.Equal(accesspolicy.VCMissingParent, fe.Code)
+	a.Contains(err.Error(), fe.Message)
+
+	// both inherited and extended
+	_, err = accesspolicy.NewPolicy(
+		"inherits and extends", // key
+		uuid.New(),             // owner
+		uuid.New(),             // parent
+		accesspolicy.NilObject(),
+		accesspolicy.FInherit|accesspolicy.FExtend, // flags
+	)
+	a.Error(err)
+	a.True(errors.As(err, &fe))
+	a.Equal("flags", fe.Field)
+	a.Equal(accesspolicy.VCConflictingFlags, fe.Code)
+
+	// object name without an object id
+	_, err = accesspolicy.NewPolicy("", uuid.New(), uuid.Nil, accesspolicy.NewObject(uuid.Nil, "test object"), 0)
+	a.Error(err)
+	a.True(errors.As(err, &fe))
+	a.Equal("object_id", fe.Field)
+
+	// no designators, the sentinel error must still be reachable
+	_, err = accesspolicy.NewPolicy("", uuid.New(), uuid.Nil, accesspolicy.NilObject(), 0)
+	a.Error(err)
+	a.True(errors.Is(err, accesspolicy.ErrAccessPolicyEmptyDesignators))
+	a.Equal(accesspolicy.ErrAccessPolicyEmptyDesignators, errors.Cause(err))
+
+	// every field error has a cause
+	_, err = accesspolicy.NewPolicy("inherits and extends", uuid.New(), uuid.New(), accesspolicy.NilObject(), accesspolicy.FInherit|accesspolicy.FExtend)
+	a.Equal(accesspolicy.ErrConflictingFlags, errors.Cause(err))
+}
+
+func TestPolicyDesignatorLength(t *testing.T) {
+	a := assert.New(t)
+
+	longKey := strings.Repeat("k", accesspolicy.MaxKeyLength+1)
+	longName := strings.Repeat("n", accesspolicy.MaxObjectNameLength+1)
+
+	// object name
+	_, err := accesspolicy.NewObjectChecked(uuid.New(), longName)
+	a.Equal(accesspolicy.ErrObjectNameTooLong, err)
+
+	obj, err := accesspolicy.NewObjectChecked(uuid.New(), longName[1:])
+	a.NoError(err)
+	a.Equal(longName[1:], obj.Name)
+
+	_, err = accesspolicy.NewPolicy("", uuid.New(), uuid.Nil, accesspolicy.NewObject(uuid.New(), longName), 0)
+	a.Equal(accesspolicy.ErrObjectNameTooLong, errors.Cause(err))
+
+	// key
+	_, err = accesspolicy.NewPolicy(longKey, uuid.New(), uuid.Nil, accesspolicy.NilObject(), 0)
+	a.Equal(accesspolicy.ErrKeyTooLong, errors.Cause(err))
+
+	// the manager refuses to create such policies
+	m, err := accesspolicy.NewManager(accesspolicy.NewMemoryStore(), nil)
+	a.NoError(err)
+
+	_, err = m.Create(context.Background(), longKey, uuid.New(), uuid.Nil, accesspolicy.NilObject(), 0)
+	a.Equal(accesspolicy.ErrKeyTooLong, errors.Cause(err))
+
+	_, err = m.Create(context.Background(), "", uuid.New(), uuid.Nil, accesspolicy.NewObject(uuid.New(), longName), 0)
+	a.Equal(accesspolicy.ErrObjectNameTooLong, errors.Cause(err))
+
+	_, err = m.Create(context.Background(), longKey[1:], uuid.New(), uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+}
+
 func TestSetPublicRights(t *testing.T) {
 	a := assert.New(t)
 
@@ -223,7 +309,7 @@ func TestSetPublicRights(t *testing.T) {
 	a.NoError(err)
 	a.NotNil(parentRoster)
 
-	parent, err := m.PolicyByID(ctx, pWithInheritance.ParentID)
+	parent, err := m.PolicyByID(ctx, pWithInheritance.ParentID, false)
 	a.NoError(err)
 	a.True(m.HasRights(ctx, pWithInheritance.ID, act1, wantedRights))
 
@@ -245,7 +331,7 @@ func TestSetPublicRights(t *testing.T) {
 	a.NoError(err)
 	a.NotNil(parentRoster)
 
-	parent, err = m.PolicyByID(ctx, pExtendedNoOwn.ParentID)
+	parent, err = m.PolicyByID(ctx, pExtendedNoOwn.ParentID, false)
 	a.NoError(err)
 	a.True(m.HasRights(ctx, parent.ID, act1, wantedRights))
 	a.True(m.HasRights(ctx, pExtendedNoOwn.ID, act1, wantedRights))
@@ -267,7 +353,7 @@ func TestSetPublicRights(t *testing.T) {
 	a.NoError(m.GrantPublicAccess(ctx, pExtendedWithOwn.ID, act1, wantedRights|accesspolicy.APMove))
 	a.NoError(m.Update(ctx, pExtendedWithOwn))
 
-	parent, err = m.PolicyByID(ctx, pExtendedWithOwn.ParentID)
+	parent, err = m.PolicyByID(ctx, pExtendedWithOwn.ParentID, false)
 	a.NoError(err)
 
 	roster, err = m.RosterByPolicyID(ctx, pExtendedWithOwn.ID)
@@ -732,6 +818,169 @@ func TestIsOwner(t *testing.T) {
 	a.False(ap.IsOwner(act2.ID))
 }
 
+func TestRegisterRight(t *testing.T) {
+	a := assert.New(t)
+
+	//---------------------------------------------------------------------------
+	// initializing dependencies
+	//---------------------------------------------------------------------------
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// ap store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	//---------------------------------------------------------------------------
+	// registering
+	//---------------------------------------------------------------------------
+	publish, err := accesspolicy.RegisterRight("publish")
+	a.NoError(err)
+
+	approve, err := accesspolicy.RegisterRight("approve")
+	a.NoError(err)
+
+	// custom rights take their own bits
+	predefined := accesspolicy.APManageAccess<<1 - 1
+	a.NotEqual(publish, approve)
+	a.Zero(publish & (approve | predefined | accesspolicy.APReserved))
+	a.Zero(approve & (publish | predefined | accesspolicy.APReserved))
+
+	// registration is idempotent
+	again, err := accesspolicy.RegisterRight("publish")
+	a.NoError(err)
+	a.Equal(publish, again)
+
+	// predefined rights aren't registered again
+	view, err := accesspolicy.RegisterRight("view")
+	a.NoError(err)
+	a.Equal(accesspolicy.APView, view)
+
+	_, err = accesspolicy.RegisterRight(" ")
+	a.Equal(accesspolicy.ErrEmptyRightName, err)
+
+	// names
+	a.Equal("publish", publish.Translate())
+	a.Equal("approve", approve.Translate())
+	a.Equal("view,publish,approve", (accesspolicy.APView | publish | approve).String())
+
+	dict := accesspolicy.Dictionary()
+	a.Equal("publish", dict[uint32(publish)])
+	a.Equal("approve", dict[uint32(approve)])
+
+	//---------------------------------------------------------------------------
+	// granting
+	//---------------------------------------------------------------------------
+	owner := accesspolicy.UserActor(uuid.New())
+	editor := accesspolicy.UserActor(uuid.New())
+	reviewer := accesspolicy.UserActor(uuid.New())
+
+	ap, err := m.Create(
+		ctx,
+		"custom rights policy", // key
+		owner.ID,               // owner
+		uuid.Nil,               // parent
+		accesspolicy.NilObject(),
+		0, // flags
+	)
+	a.NoError(err)
+
+	a.NoError(m.GrantUserAccess(ctx, ap.ID, owner, editor.ID, accesspolicy.APView|publish))
+	a.NoError(m.GrantUserAccess(ctx, ap.ID, owner, reviewer.ID, accesspolicy.APView|approve))
+	a.NoError(m.Update(ctx, ap))
+
+	// owner has every right, custom ones included
+	a.True(m.HasRights(ctx, ap.ID, owner, publish|approve))
+
+	a.True(m.HasRights(ctx, ap.ID, editor, accesspolicy.APView|publish))
+	a.False(m.HasRights(ctx, ap.ID, editor, approve))
+
+	a.True(m.HasRights(ctx, ap.ID, reviewer, accesspolicy.APView|approve))
+	a.False(m.HasRights(ctx, ap.ID, reviewer, publish))
+
+	// a fresh manager to make sure custom rights survive the store
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.True(m2.HasRights(ctx, ap.ID, editor, publish))
+	a.False(m2.HasRights(ctx, ap.ID, editor, approve))
+
+	// and can be denied as any other right
+	a.NoError(m.DenyUserAccess(ctx, ap.ID, owner, editor.ID, publish))
+	a.False(m.HasRights(ctx, ap.ID, editor, publish))
+	a.True(m.HasRights(ctx, ap.ID, editor, accesspolicy.APView))
+}
+
+func TestRightBits(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal([]accesspolicy.Right{accesspolicy.APView, accesspolicy.APChange}, (accesspolicy.APView | accesspolicy.APChange).Bits())
+	a.Equal([]string{"view", "change"}, (accesspolicy.APView | accesspolicy.APChange).Names())
+
+	// denial marker is not a right
+	a.Equal([]string{"change"}, (accesspolicy.APReserved | accesspolicy.APChange).Names())
+
+	a.Empty(accesspolicy.APNoAccess.Bits())
+	a.NotNil(accesspolicy.APNoAccess.Bits())
+	a.Empty(accesspolicy.APNoAccess.Names())
+
+	// full access expands to the named rights only
+	full := accesspolicy.APFullAccess.Bits()
+	a.Subset(full, []accesspolicy.Right{
+		accesspolicy.APView,
+		accesspolicy.APViewDeleted,
+		accesspolicy.APViewHidden,
+		accesspolicy.APCreate,
+		accesspolicy.APChange,
+		accesspolicy.APDelete,
+		accesspolicy.APRestoreDeleted,
+		accesspolicy.APCopy,
+		accesspolicy.APDuplicate,
+		accesspolicy.APMove,
+		accesspolicy.APRename,
+		accesspolicy.APManageAccess,
+	})
+
+	for _, name := range accesspolicy.APFullAccess.Names() {
+		a.NotEqual(accesspolicy.APUnrecognizedFlag, name)
+	}
+}
+
+func TestManageRightsAlias(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal(accesspolicy.APManageAccess, accesspolicy.APManageRights)
+
+	// only the canonical name is reported
+	a.Equal("manage_access", accesspolicy.APManageRights.Translate())
+	a.Equal("view,manage_access", (accesspolicy.APView | accesspolicy.APManageRights).String())
+	a.Equal("manage_access", accesspolicy.Dictionary()[uint32(accesspolicy.APManageAccess)])
+
+	// the former name doesn't take a custom bit
+	r, err := accesspolicy.RegisterRight("manage_rights")
+	a.NoError(err)
+	a.Equal(accesspolicy.APManageAccess, r)
+}
+
 func TestAccessPolicyTestRosterBackup(t *testing.T) {
 	a := assert.New(t)
 
@@ -839,6 +1088,96 @@ func TestAccessPolicyTestRosterBackup(t *testing.T) {
 	a.False(ap.IsOwner(act3.ID))
 }
 
+func TestAccessPolicyRosterNestedBackup(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// ap store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	manager := accesspolicy.UserActor(uuid.New())
+	act1 := accesspolicy.UserActor(uuid.New())
+	act2 := accesspolicy.UserActor(uuid.New())
+	act3 := accesspolicy.UserActor(uuid.New())
+
+	ap, err := m.Create(ctx, "nested backup policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantUserAccess(ctx, ap.ID, owner, manager.ID, accesspolicy.APView|accesspolicy.APChange|accesspolicy.APManageAccess))
+	a.NoError(m.Update(ctx, ap))
+
+	// outer grant, pending
+	a.NoError(m.GrantUserAccess(ctx, ap.ID, manager, act1.ID, accesspolicy.APView))
+
+	// inner batch grant, its second grant exceeds the rights of the grantor
+	err = m.GrantAccessBatch(ctx, ap.ID, manager, []accesspolicy.Grant{
+		{Grantee: act2, Rights: accesspolicy.APView | accesspolicy.APChange},
+		{Grantee: act3, Rights: accesspolicy.APDelete},
+	})
+	a.Equal(accesspolicy.ErrExcessOfRights, errors.Cause(err))
+
+	// only the inner batch is rolled back
+	a.True(m.HasRights(ctx, ap.ID, act1, accesspolicy.APView))
+	a.False(m.HasRights(ctx, ap.ID, act2, accesspolicy.APView))
+	a.False(m.HasRights(ctx, ap.ID, act3, accesspolicy.APDelete))
+
+	changes, err := m.PendingChanges(ctx, ap.ID)
+	a.NoError(err)
+	if a.Len(changes, 1) {
+		a.Equal(act1, changes[0].Actor)
+	}
+
+	// the outer grant is persisted by the update, which leaves a clean state
+	a.NoError(m.Update(ctx, ap))
+
+	changes, err = m.PendingChanges(ctx, ap.ID)
+	a.NoError(err)
+	a.Empty(changes)
+
+	m2, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.True(m2.HasRights(ctx, ap.ID, act1, accesspolicy.APView))
+	a.False(m2.HasRights(ctx, ap.ID, act2, accesspolicy.APView))
+
+	// a successful batch keeps all of its grants
+	a.NoError(m.GrantAccessBatch(ctx, ap.ID, manager, []accesspolicy.Grant{
+		{Grantee: act2, Rights: accesspolicy.APView},
+		{Grantee: act3, Rights: accesspolicy.APChange},
+	}))
+	a.True(m.HasRights(ctx, ap.ID, act2, accesspolicy.APView))
+	a.True(m.HasRights(ctx, ap.ID, act3, accesspolicy.APChange))
+
+	// a failed grant outside of any batch still restores the last safe point
+	a.Error(m.GrantUserAccess(ctx, ap.ID, act1, act2.ID, accesspolicy.APChange))
+	a.False(m.HasRights(ctx, ap.ID, act2, accesspolicy.APView))
+	a.False(m.HasRights(ctx, ap.ID, act3, accesspolicy.APChange))
+	a.True(m.HasRights(ctx, ap.ID, act1, accesspolicy.APView))
+}
+
 func TestAccessPolicyUnsetRights(t *testing.T) {
 	a := assert.New(t)
 
@@ -1142,3 +1481,77 @@ func TestHasGroupRights(t *testing.T) {
 	a.False(m.HasRights(ctx, ap.ID, accesspolicy.GroupActor(g2.ID), wantedRights))
 	a.False(m.HasRights(ctx, ap.ID, accesspolicy.GroupActor(g3.ID), wantedRights))
 }
+
+func TestHasGroupAndRoleRightsKind(t *testing.T) {
+	a := assert.New(t)
+
+	//---------------------------------------------------------------------------
+	// initializing dependencies
+	//---------------------------------------------------------------------------
+	// test context
+	ctx := context.Background()
+
+	// data instance
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	// ap store
+	s, err := accesspolicy.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(s)
+
+	// group store
+	gs, err := group.NewPostgreSQLStore(db)
+	a.NoError(err)
+	a.NotNil(gs)
+
+	// group manager
+	gm, err := group.NewManager(ctx, gs)
+	a.NoError(err)
+	a.NotNil(gm)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, gm)
+	a.NoError(err)
+	a.NotNil(m)
+
+	act1 := accesspolicy.UserActor(uuid.New())
+
+	g, err := gm.Create(ctx, group.FGroup, uuid.Nil, "test group", "test group")
+	a.NoError(err)
+
+	r, err := gm.Create(ctx, group.FRole, uuid.Nil, "test role", "test role")
+	a.NoError(err)
+
+	wantedRights := accesspolicy.APView | accesspolicy.APChange
+
+	ap, err := m.Create(
+		ctx,
+		"test policy", // key
+		act1.ID,       // owner
+		uuid.Nil,      // parent
+		accesspolicy.NilObject(),
+		0, // flags
+	)
+	a.NoError(err)
+
+	a.NoError(m.GrantGroupAccess(ctx, ap.ID, act1, g.ID, wantedRights))
+	a.NoError(m.GrantRoleAccess(ctx, ap.ID, act1, r.ID, wantedRights))
+	a.NoError(m.Update(ctx, ap))
+
+	// matching kinds
+	a.True(m.HasGroupRights(ctx, ap.ID, g.ID, wantedRights))
+	a.True(m.HasRoleRights(ctx, ap.ID, r.ID, wantedRights))
+
+	// crossed kinds
+	a.False(m.HasRoleRights(ctx, ap.ID, g.ID, wantedRights))
+	a.False(m.HasGroupRights(ctx, ap.ID, r.ID, wantedRights))
+	a.False(m.HasRights(ctx, ap.ID, accesspolicy.NewActor(accesspolicy.AKRoleGroup, g.ID), wantedRights))
+	a.False(m.HasRights(ctx, ap.ID, accesspolicy.NewActor(accesspolicy.AKGroup, r.ID), wantedRights))
+	a.True(m.HasRights(ctx, ap.ID, accesspolicy.NewActor(accesspolicy.AKGroup, g.ID), wantedRights))
+	a.True(m.HasRights(ctx, ap.ID, accesspolicy.NewActor(accesspolicy.AKRoleGroup, r.ID), wantedRights))
+
+	// non-existing group
+	a.False(m.HasGroupRights(ctx, ap.ID, uuid.New(), accesspolicy.APView))
+	a.False(m.HasRoleRights(ctx, ap.ID, uuid.New(), accesspolicy.APView))
+}
@@ -0,0 +1,173 @@
+package accesspolicy
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// RequestStatus represents the state of an access request
+type RequestStatus uint8
+
+const (
+	RSPending RequestStatus = iota
+	RSApproved
+	RSDenied
+)
+
+func (s RequestStatus) String() string {
+	switch s {
+	case RSPending:
+		return "pending"
+	case RSApproved:
+		return "approved"
+	case RSDenied:
+		return "denied"
+	}
+
+	return "unrecognized request status"
+}
+
+// AccessRequest is a record of somebody asking for rights
+// they don't have, to be either approved or denied
+type AccessRequest struct {
+	ID            uuid.UUID     `db:"id" json:"id"`
+	PolicyID      uuid.UUID     `db:"policy_id" json:"policy_id"`
+	Requester     Actor         `json:"requester"`
+	Rights        Right         `db:"rights" json:"rights"`
+	Justification string        `db:"justification" json:"justification"`
+	Status        RequestStatus `db:"status" json:"status"`
+	ResolvedBy    Actor         `json:"resolved_by"`
+	CreatedAt     time.Time     `db:"created_at" json:"created_at"`
+	ResolvedAt    time.Time     `db:"resolved_at" json:"resolved_at"`
+}
+
+// IsPending tells whether this request is still awaiting a decision
+func (ar AccessRequest) IsPending() bool { return ar.Status == RSPending }
+
+// RequestAccess records a request for rights on a given policy, which
+// is then approved or denied by somebody who manages access to it
+func (m *Manager) RequestAccess(ctx context.Context, pid uuid.UUID, requester Actor, rights Right, justification string) (requestID uuid.UUID, err error) {
+	if requester.Kind == AKEveryone {
+		return uuid.Nil, errors.Wrap(ErrUnrecognizedActorKind, "public actor cannot request access")
+	}
+
+	if requester.ID == uuid.Nil {
+		return uuid.Nil, ErrNilActorID
+	}
+
+	if rights == APNoAccess {
+		return uuid.Nil, ErrNoRightsRequested
+	}
+
+	p, err := m.PolicyByID(ctx, pid, false)
+	if err != nil {
+		return uuid.Nil, errors.Wrapf(err, "failed to obtain policy: policy_id=%s", pid)
+	}
+
+	ar := AccessRequest{
+		ID:            uuid.New(),
+		PolicyID:      p.ID,
+		Requester:     requester,
+		Rights:        rights,
+		Justification: strings.TrimSpace(justification),
+		Status:        RSPending,
+		CreatedAt:     time.Now(),
+	}
+
+	if err = m.store.CreateAccessRequest(ctx, ar); err != nil {
+		return uuid.Nil, errors.Wrap(err, "failed to create access request")
+	}
+
+	return ar.ID, nil
+}
+
+// AccessRequestByID returns an access request by its ID
+func (m *Manager) AccessRequestByID(ctx context.Context, requestID uuid.UUID) (ar AccessRequest, err error) {
+	if requestID == uuid.Nil {
+		return ar, ErrAccessRequestNotFound
+	}
+
+	return m.store.FetchAccessRequestByID(ctx, requestID)
+}
+
+// ListPendingRequests returns the access requests of
+// a given policy which are still awaiting a decision
+func (m *Manager) ListPendingRequests(ctx context.Context, pid uuid.UUID) ([]AccessRequest, error) {
+	if pid == uuid.Nil {
+		return nil, ErrNilPolicyID
+	}
+
+	ars, err := m.store.FetchPendingAccessRequests(ctx, pid)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch pending access requests: policy_id=%s", pid)
+	}
+
+	return ars, nil
+}
+
+// Approve grants the requested rights under the authority of the approver,
+// thus the approver must be able to grant them
+func (m *Manager) Approve(ctx context.Context, requestID uuid.UUID, approver Actor) (err error) {
+	ar, err := m.pendingRequest(ctx, requestID)
+	if err != nil {
+		return err
+	}
+
+	p, err := m.PolicyByID(ctx, ar.PolicyID, false)
+	if err != nil {
+		return errors.Wrapf(err, "failed to obtain policy: policy_id=%s", ar.PolicyID)
+	}
+
+	if err = m.GrantAccess(ctx, p.ID, approver, ar.Requester, ar.Rights); err != nil {
+		return errors.Wrapf(err, "failed to grant requested access: request_id=%s", ar.ID)
+	}
+
+	if err = m.Update(ctx, p); err != nil {
+		return errors.Wrapf(err, "failed to persist requested access: request_id=%s", ar.ID)
+	}
+
+	return m.resolveRequest(ctx, ar, RSApproved, approver)
+}
+
+// Deny turns down an access request, the denier must be able to manage access
+func (m *Manager) Deny(ctx context.Context, requestID uuid.UUID, denier Actor) (err error) {
+	ar, err := m.pendingRequest(ctx, requestID)
+	if err != nil {
+		return err
+	}
+
+	if !m.HasRights(ctx, ar.PolicyID, denier, APManageAccess) {
+		return ErrAccessDenied
+	}
+
+	return m.resolveRequest(ctx, ar, RSDenied, denier)
+}
+
+func (m *Manager) pendingRequest(ctx context.Context, requestID uuid.UUID) (ar AccessRequest, err error) {
+	ar, err = m.AccessRequestByID(ctx, requestID)
+	if err != nil {
+		return ar, errors.Wrapf(err, "failed to obtain access request: request_id=%s", requestID)
+	}
+
+	if !ar.IsPending() {
+		return ar, errors.Wrapf(ErrAccessRequestResolved, "request_id=%s, status=%s", ar.ID, ar.Status)
+	}
+
+	return ar, nil
+}
+
+func (m *Manager) resolveRequest(ctx context.Context, ar AccessRequest, status RequestStatus, resolver Actor) error {
+	ar.Status = status
+	ar.ResolvedBy = resolver
+	ar.ResolvedAt = time.Now()
+
+	if err := m.store.UpdateAccessRequest(ctx, ar); err != nil {
+		return errors.Wrapf(err, "failed to update access request: request_id=%s", ar.ID)
+	}
+
+	return nil
+}
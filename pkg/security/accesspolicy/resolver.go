@@ -1,6 +1,10 @@
 package accesspolicy
 
-import "context"
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
 
 // AccessResolver is responsible for resolving the final access rights
 // when the rights are extended or inherited from a parent
@@ -27,3 +31,14 @@ func (d *defaultResolver) Resolve(
 ) (calculatedRight Right, err error) {
 	panic("implement me")
 }
+
+// DomainOwnershipResolver maps policies to the domains they belong to,
+// owners of a domain implicitly have full access to all of its policies
+type DomainOwnershipResolver interface {
+	// PolicyDomain returns the ID of a domain to which a given
+	// policy belongs, nil if it doesn't belong to any
+	PolicyDomain(ctx context.Context, p Policy) (domainID uuid.UUID, err error)
+
+	// IsDomainOwner tells whether a given user owns a given domain
+	IsDomainOwner(ctx context.Context, domainID, userID uuid.UUID) (bool, error)
+}
@@ -0,0 +1,83 @@
+package accesspolicy
+
+import (
+	"strings"
+	"sync"
+)
+
+// lowest and highest bits which are free to be taken by custom rights,
+// the ones below are taken by the predefined rights, and the one above
+// is taken by the denial marker
+const (
+	firstCustomRight = APManageAccess << 1
+	lastCustomRight  = APReserved >> 1
+)
+
+// customRights is a registry of application-specific rights
+var customRights = struct {
+	byName map[string]Right
+	names  map[Right]string
+	sync.RWMutex
+}{
+	byName: make(map[string]Right),
+	names:  make(map[Right]string),
+}
+
+// RegisterRight allocates the next free bit for an application-specific
+// right (i.e. "publish" or "approve"), which is then treated as any
+// predefined right, registering the same name again returns the same right
+// NOTE: rights are registered process-wide and are stored as bits, thus
+// the registration order must be the same every time the application starts
+func RegisterRight(name string) (Right, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APNoAccess, ErrEmptyRightName
+	}
+
+	// predefined rights can't be registered again
+	for _, r := range []Right{APNoAccess, APFullAccess} {
+		if r.Translate() == name {
+			return r, nil
+		}
+	}
+
+	for bit := Right(1); bit < firstCustomRight; bit <<= 1 {
+		if bit.Translate() == name {
+			return bit, nil
+		}
+	}
+
+	// the former name of APManageAccess must not take a custom bit
+	if name == "manage_rights" {
+		return APManageAccess, nil
+	}
+
+	customRights.Lock()
+	defer customRights.Unlock()
+
+	if r, ok := customRights.byName[name]; ok {
+		return r, nil
+	}
+
+	for bit := firstCustomRight; bit <= lastCustomRight; bit <<= 1 {
+		if _, ok := customRights.names[bit]; ok {
+			continue
+		}
+
+		customRights.byName[name] = bit
+		customRights.names[bit] = name
+
+		return bit, nil
+	}
+
+	return APNoAccess, ErrNoFreeRights
+}
+
+// customRightName returns the name of a registered application-specific right
+func customRightName(r Right) (string, bool) {
+	customRights.RLock()
+	name, ok := customRights.names[r]
+	customRights.RUnlock()
+
+	return name, ok
+}
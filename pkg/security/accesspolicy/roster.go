@@ -1,7 +1,12 @@
 package accesspolicy
 
 import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -18,18 +23,21 @@ type Roster struct {
 	// represents a mixed list of group/role/user rights
 	Registry []Cell `json:"registry"`
 
-	// holds a calculated summary cache of rights for a specific group/role/user
-	// NOTE: these values are reset should any related value change
-	calculatedCache map[Actor]Right
-
 	// this slice accumulates batch changes made to this roster
 	changes []rosterChange
 
+	// audit records of the grants and revocations among the changes,
+	// which are passed on only after the changes have been stored
+	audit []AuditRecord
+
 	registryLock sync.RWMutex
-	cacheLock    sync.RWMutex
 	changeLock   sync.RWMutex
 	backup       *Roster
 
+	// intermediate safe points of the unsaved changes, most recent last,
+	// which allow composite operations to roll back only their own changes
+	savepoints []rosterSavepoint
+
 	// represents the base public accesspolicy rights
 	Everyone Right `json:"everyone"`
 }
@@ -74,24 +82,102 @@ func RoleActor(id uuid.UUID) Actor {
 	}
 }
 
+// actorKindNames are the kind prefixes of the textual actor representation
+var actorKindNames = map[ActorKind]string{
+	AKEveryone:  "everyone",
+	AKUser:      "user",
+	AKGroup:     "group",
+	AKRoleGroup: "role_group",
+}
+
+// isRecognized tells whether this actor is of a known kind
+func (a Actor) isRecognized() bool {
+	_, ok := actorKindNames[a.Kind]
+	return ok
+}
+
+// Equal tells whether both actors are of the same kind and ID
+func (a Actor) Equal(other Actor) bool {
+	return a.Kind == other.Kind && a.ID == other.ID
+}
+
+// String returns a textual representation of this actor, i.e. "user:<uuid>",
+// and just "everyone" for the public actor
+func (a Actor) String() string {
+	name, ok := actorKindNames[a.Kind]
+	if !ok {
+		return fmt.Sprintf("%s:%s", a.Kind, a.ID)
+	}
+
+	if a.Kind == AKEveryone {
+		return name
+	}
+
+	return name + ":" + a.ID.String()
+}
+
+// ParseActor parses an actor from its textual representation, see Actor.String
+func ParseActor(s string) (Actor, error) {
+	if s == actorKindNames[AKEveryone] {
+		return PublicActor(), nil
+	}
+
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return Actor{}, errors.Wrapf(ErrUnrecognizedActorKind, "malformed actor: %q", s)
+	}
+
+	for kind, name := range actorKindNames {
+		if kind == AKEveryone || name != parts[0] {
+			continue
+		}
+
+		id, err := uuid.Parse(parts[1])
+		if err != nil {
+			return Actor{}, errors.Wrapf(err, "failed to parse actor id: %q", s)
+		}
+
+		if id == uuid.Nil {
+			return Actor{}, ErrNilActorID
+		}
+
+		return NewActor(kind, id), nil
+	}
+
+	return Actor{}, errors.Wrapf(ErrUnrecognizedActorKind, "kind=%q", parts[0])
+}
+
 // Cell represents a single access policy registry entry
 // TODO: consider overrides
 type Cell struct {
 	Key    Actor `json:"key"`
 	Rights Right `json:"rights"`
+	Denied Right `json:"denied,omitempty"`
+
+	// limits the rights this actor may pass on to others,
+	// zero means that it may grant whatever it holds itself
+	Grantable Right `json:"grantable,omitempty"`
+
+	// zero means that this entry never expires
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// IsExpired tells whether this entry has expired by a given moment,
+// expired entries grant and deny nothing
+func (c Cell) IsExpired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
 }
 
 // NewRoster is a shorthand initializer function
 func NewRoster(regsize int) *Roster {
 	return &Roster{
-		Registry:        make([]Cell, regsize),
-		calculatedCache: make(map[Actor]Right),
-		Everyone:        APNoAccess,
+		Registry: make([]Cell, regsize),
+		Everyone: APNoAccess,
 	}
 }
 
 // put adds a new or alters an existing accesspolicy cell
-func (r *Roster) put(key Actor, rights Right) {
+func (r *Roster) put(key Actor, rights Right, expiresAt time.Time) {
 	r.registryLock.Lock()
 
 	// finding existing cell
@@ -99,6 +185,7 @@ func (r *Roster) put(key Actor, rights Right) {
 		if cell.Key == key {
 			// altering the rights of an existing cell
 			r.Registry[i].Rights = rights
+			r.Registry[i].ExpiresAt = expiresAt
 
 			// unlocking before early return
 			r.registryLock.Unlock()
@@ -109,35 +196,122 @@ func (r *Roster) put(key Actor, rights Right) {
 
 	// appending new cell because it hasn't been found above
 	r.Registry = append(r.Registry, Cell{
-		Rights: rights,
-		Key:    key,
+		Rights:    rights,
+		Key:       key,
+		ExpiresAt: expiresAt,
 	})
 
 	r.registryLock.Unlock()
 }
 
-// lookup looks up the isolated rights of a specific subject of a kind
-// NOTE: does not summarize any rights, nor includes public accesspolicy rights
-func (r *Roster) lookup(key Actor) (access Right) {
-	access, err := r.lookupCache(key)
-	if err != nil && err != ErrCacheMiss {
-		return APNoAccess
+// deny sets explicitly denied rights of an existing or a new cell,
+// its granted rights remain intact
+func (r *Roster) deny(key Actor, denied Right) {
+	r.registryLock.Lock()
+	defer r.registryLock.Unlock()
+
+	for i, cell := range r.Registry {
+		if cell.Key == key {
+			r.Registry[i].Denied = denied
+			return
+		}
 	}
 
-	// finding accesspolicy rights
+	r.Registry = append(r.Registry, Cell{
+		Key:    key,
+		Denied: denied,
+	})
+}
+
+// limitGrants sets the rights which the actor of an existing or a new cell
+// may grant to others, its own rights remain intact
+func (r *Roster) limitGrants(key Actor, grantable Right) {
+	r.registryLock.Lock()
+	defer r.registryLock.Unlock()
+
+	for i, cell := range r.Registry {
+		if cell.Key == key {
+			r.Registry[i].Grantable = grantable
+			return
+		}
+	}
+
+	r.Registry = append(r.Registry, Cell{
+		Key:       key,
+		Grantable: grantable,
+	})
+}
+
+// cell returns a copy of the cell of a given actor
+func (r *Roster) cell(key Actor) (Cell, bool) {
 	r.registryLock.RLock()
+	defer r.registryLock.RUnlock()
+
 	for _, cell := range r.Registry {
 		if cell.Key == key {
-			access = cell.Rights
-			break
+			return cell, true
 		}
 	}
-	r.registryLock.RUnlock()
 
-	// caching
-	r.putCache(key, access)
+	return Cell{Key: key}, false
+}
+
+// activeCell returns a copy of the cell of a given actor,
+// expired cell is returned blank
+func (r *Roster) activeCell(key Actor, now time.Time) Cell {
+	cell, _ := r.cell(key)
+	if cell.IsExpired(now) {
+		return Cell{Key: key}
+	}
 
-	return access
+	return cell
+}
+
+// activeRights returns the effective rights of every registry entry
+// which hasn't expired by a given moment, denials are subtracted
+func (r *Roster) activeRights(now time.Time) map[Actor]Right {
+	r.registryLock.RLock()
+	defer r.registryLock.RUnlock()
+
+	rights := make(map[Actor]Right, len(r.Registry))
+	for _, cell := range r.Registry {
+		// skipping blank and expired cells
+		if cell.Key.Kind == 0 || cell.IsExpired(now) {
+			continue
+		}
+
+		rights[cell.Key] = cell.Rights &^ cell.Denied
+	}
+
+	return rights
+}
+
+// purgeExpired removes the entries which have expired by a given moment
+// NOTE: does not record any changes, meant to follow the store
+func (r *Roster) purgeExpired(now time.Time) (n int) {
+	r.registryLock.Lock()
+	defer r.registryLock.Unlock()
+
+	registry := r.Registry[:0]
+	for _, cell := range r.Registry {
+		if cell.IsExpired(now) {
+			n++
+			continue
+		}
+
+		registry = append(registry, cell)
+	}
+
+	r.Registry = registry
+
+	return n
+}
+
+// lookup looks up the isolated rights of a specific subject of a kind
+// NOTE: does not summarize any rights, nor includes public accesspolicy rights
+// NOTE: the calculated cache holds summarized rights, thus it's not used here
+func (r *Roster) lookup(key Actor) (access Right) {
+	return r.activeCell(key, time.Now()).Rights
 }
 
 // hasRights tests whether a given subject of a kind has specific accesspolicy rights
@@ -146,53 +320,77 @@ func (r *Roster) hasRights(key Actor, rights Right) bool {
 	return r.lookup(key)&rights == rights
 }
 
-func (r *Roster) delete(key Actor) {
-	// searching and removing registry accesspolicy cell
-	r.registryLock.Lock()
-	for i, cell := range r.Registry {
+// has tells whether a given actor has an explicit registry entry
+func (r *Roster) has(key Actor) bool {
+	r.registryLock.RLock()
+	defer r.registryLock.RUnlock()
+
+	for _, cell := range r.Registry {
 		if cell.Key == key {
-			r.Registry = append(r.Registry[:i], r.Registry[i+1:]...)
-			break
+			return true
 		}
 	}
-	r.registryLock.Unlock()
 
-	// clearing out specific calculated cache
-	r.deleteCache(key)
+	return false
 }
 
-// putCache caches calculated accesspolicy for user or a group/role
-// NOTE: this cache is cleared whenever any relevant policy are changed
-func (r *Roster) putCache(key Actor, rights Right) {
-	r.cacheLock.Lock()
-	r.calculatedCache[key] = rights
-	r.cacheLock.Unlock()
-}
+// Range calls fn for the public rights and then for every registry entry,
+// ordered by actor kind and ID; iteration stops when fn returns false
+// NOTE: fn is called on a snapshot, so it's safe to alter the roster from within
+func (r *Roster) Range(fn func(actor Actor, rights Right) bool) {
+	r.registryLock.RLock()
+	everyone := r.Everyone
+	cells := make([]Cell, 0, len(r.Registry))
+	for _, cell := range r.Registry {
+		// skipping blank cells
+		if cell.Key.Kind == 0 {
+			continue
+		}
+
+		cells = append(cells, cell)
+	}
+	r.registryLock.RUnlock()
 
-// lookupCache returns a cached, calculated accesspolicy for a given user or group
-func (r *Roster) lookupCache(key Actor) (Right, error) {
-	r.cacheLock.RLock()
-	right, ok := r.calculatedCache[key]
-	r.cacheLock.RUnlock()
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Key.Kind != cells[j].Key.Kind {
+			return cells[i].Key.Kind < cells[j].Key.Kind
+		}
 
-	if !ok {
-		return 0, ErrCacheMiss
+		return bytes.Compare(cells[i].Key.ID[:], cells[j].Key.ID[:]) < 0
+	})
+
+	if !fn(PublicActor(), everyone) {
+		return
 	}
 
-	return right, nil
+	for _, cell := range cells {
+		if !fn(cell.Key, cell.Rights) {
+			return
+		}
+	}
 }
 
-// deleteCache removes calculated accesspolicy cache
-// NOTE: it must be used for any subject whose rights were altered
-// directly or indirectly
-func (r *Roster) deleteCache(key Actor) {
-	r.cacheLock.Lock()
-	delete(r.calculatedCache, key)
-	r.cacheLock.Unlock()
+func (r *Roster) delete(key Actor) {
+	// searching and removing registry accesspolicy cell
+	r.registryLock.Lock()
+	for i, cell := range r.Registry {
+		if cell.Key == key {
+			r.Registry = append(r.Registry[:i], r.Registry[i+1:]...)
+			break
+		}
+	}
+	r.registryLock.Unlock()
 }
 
 // change adds a single deferred action to change policy before storing
 func (r *Roster) change(action RAction, key Actor, rights Right) {
+	r.changeUntil(action, key, rights, time.Time{})
+}
+
+// changeUntil is the same as change, but the granted rights expire
+// at a given moment, zero time means they never expire
+// NOTE: the denied rights of an entry remain intact, see changeDenied
+func (r *Roster) changeUntil(action RAction, key Actor, rights Right, expiresAt time.Time) {
 	// the roster must have a backup before any unsaved changes to be made
 	r.createBackup()
 
@@ -209,10 +407,19 @@ func (r *Roster) change(action RAction, key Actor, rights Right) {
 	switch action {
 	case RSet:
 		// if kind is Everyone(public), then there's no need update registry
+		// NOTE: the change always carries the complete entry to be stored
 		if key.Kind == AKEveryone {
 			r.Everyone = rights
 		} else {
-			r.put(key, rights)
+			r.put(key, rights, expiresAt)
+		}
+
+		if key.Kind != AKEveryone {
+			cell, _ := r.cell(key)
+			change.accessRight = cell.Rights
+			change.denied = cell.Denied
+			change.grantable = cell.Grantable
+			change.expiresAt = cell.ExpiresAt
 		}
 	case RUnset:
 		if key.Kind == AKEveryone {
@@ -239,14 +446,301 @@ func (r *Roster) change(action RAction, key Actor, rights Right) {
 	r.changeLock.Unlock()
 }
 
+// changeGrantable limits the rights an actor may grant to others,
+// and adds a deferred action to store its complete entry
+func (r *Roster) changeGrantable(key Actor, grantable Right) {
+	// the roster must have a backup before any unsaved changes to be made
+	r.createBackup()
+	r.limitGrants(key, grantable)
+
+	cell, _ := r.cell(key)
+
+	r.changeLock.Lock()
+	r.changes = append(r.changes, rosterChange{
+		action:      RSet,
+		key:         key,
+		accessRight: cell.Rights,
+		denied:      cell.Denied,
+		grantable:   cell.Grantable,
+		expiresAt:   cell.ExpiresAt,
+	})
+	r.changeLock.Unlock()
+}
+
+// changeCell replaces the complete entry of an actor,
+// and adds a deferred action to store it
+func (r *Roster) changeCell(cell Cell) {
+	// the roster must have a backup before any unsaved changes to be made
+	r.createBackup()
+
+	r.registryLock.Lock()
+	i := -1
+	for j := range r.Registry {
+		if r.Registry[j].Key == cell.Key {
+			i = j
+			break
+		}
+	}
+
+	if i == -1 {
+		r.Registry = append(r.Registry, cell)
+	} else {
+		r.Registry[i] = cell
+	}
+	r.registryLock.Unlock()
+
+	r.changeLock.Lock()
+	r.changes = append(r.changes, rosterChange{
+		action:      RSet,
+		key:         cell.Key,
+		accessRight: cell.Rights,
+		denied:      cell.Denied,
+		grantable:   cell.Grantable,
+		expiresAt:   cell.ExpiresAt,
+	})
+	r.changeLock.Unlock()
+}
+
+// changeDenied replaces the rights explicitly denied to an actor,
+// and adds a deferred action to store its complete entry
+// NOTE: the granted rights and the expiration of an entry remain intact
+func (r *Roster) changeDenied(key Actor, denied Right) {
+	// the roster must have a backup before any unsaved changes to be made
+	r.createBackup()
+	r.deny(key, denied)
+
+	cell, _ := r.cell(key)
+
+	r.changeLock.Lock()
+	r.changes = append(r.changes, rosterChange{
+		action:      RSet,
+		key:         key,
+		accessRight: cell.Rights,
+		denied:      cell.Denied,
+		grantable:   cell.Grantable,
+		expiresAt:   cell.ExpiresAt,
+	})
+	r.changeLock.Unlock()
+}
+
+// Merge folds another roster into this one: public rights are combined,
+// and so are the rights of the actors present in both rosters, the rest
+// of the other roster's actors are added as they are
+// NOTE: the merge is recorded as roster changes, thus it's persisted
+// the same way as any other change, by updating the policy
+// NOTE: expired entries of the other roster grant nothing, thus skipped
+func (r *Roster) Merge(other *Roster) {
+	if other == nil || other == r {
+		return
+	}
+
+	now := time.Now()
+
+	// taking a snapshot of the other roster first, so that
+	// both rosters are never locked at the same time
+	other.registryLock.RLock()
+	everyone := other.Everyone
+	cells := make([]Cell, 0, len(other.Registry))
+	for _, cell := range other.Registry {
+		if cell.Key.Kind != 0 && !cell.IsExpired(now) {
+			cells = append(cells, cell)
+		}
+	}
+	other.registryLock.RUnlock()
+
+	// the roster must have a backup before any unsaved changes to be made
+	r.createBackup()
+
+	changes := make([]rosterChange, 0, len(cells)+1)
+
+	r.registryLock.Lock()
+	if merged := r.Everyone | everyone; merged != r.Everyone {
+		r.Everyone = merged
+
+		changes = append(changes, rosterChange{
+			action:      RSet,
+			key:         PublicActor(),
+			accessRight: merged,
+		})
+	}
+
+	for _, cell := range cells {
+		i := -1
+		for j := range r.Registry {
+			if r.Registry[j].Key == cell.Key {
+				i = j
+				break
+			}
+		}
+
+		// the actor is new to this roster
+		if i == -1 {
+			r.Registry = append(r.Registry, cell)
+		} else {
+			merged := mergeCells(r.Registry[i], cell, now)
+			if merged == r.Registry[i] {
+				continue
+			}
+
+			r.Registry[i] = merged
+			cell = merged
+		}
+
+		changes = append(changes, rosterChange{
+			action:      RSet,
+			key:         cell.Key,
+			accessRight: cell.Rights,
+			denied:      cell.Denied,
+			grantable:   cell.Grantable,
+			expiresAt:   cell.ExpiresAt,
+		})
+	}
+	r.registryLock.Unlock()
+
+	r.changeLock.Lock()
+	r.changes = append(r.changes, changes...)
+	r.changeLock.Unlock()
+}
+
+// mergeCells combines two cells of the same actor, the result grants and denies
+// whatever either of them does, and expires whenever the longer-lasting one does
+func mergeCells(c, other Cell, now time.Time) Cell {
+	// an expired cell contributes nothing
+	if c.IsExpired(now) {
+		return other
+	}
+
+	merged := Cell{
+		Key:    c.Key,
+		Rights: c.Rights | other.Rights,
+		Denied: c.Denied | other.Denied,
+	}
+
+	// zero means that the actor isn't limited in granting
+	if c.Grantable != APNoAccess && other.Grantable != APNoAccess {
+		merged.Grantable = c.Grantable | other.Grantable
+	}
+
+	// zero means that the entry never expires
+	if !c.ExpiresAt.IsZero() && !other.ExpiresAt.IsZero() {
+		merged.ExpiresAt = c.ExpiresAt
+		if other.ExpiresAt.After(c.ExpiresAt) {
+			merged.ExpiresAt = other.ExpiresAt
+		}
+	}
+
+	return merged
+}
+
+// HasChanges tells whether this roster has unsaved changes
+func (r *Roster) HasChanges() bool {
+	r.changeLock.RLock()
+	defer r.changeLock.RUnlock()
+
+	return len(r.changes) > 0
+}
+
+// hasUnrelatedChanges tells whether this roster has unsaved
+// changes concerning any other than the given actors
+func (r *Roster) hasUnrelatedChanges(actors ...Actor) bool {
+	r.changeLock.RLock()
+	defer r.changeLock.RUnlock()
+
+next:
+	for _, c := range r.changes {
+		for _, a := range actors {
+			if c.key == a {
+				continue next
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// PendingChanges returns the changes made to this roster since
+// it has been stored, in the order they were made
+func (r *Roster) PendingChanges() []Change {
+	r.changeLock.RLock()
+	defer r.changeLock.RUnlock()
+
+	changes := make([]Change, len(r.changes))
+	for i, c := range r.changes {
+		changes[i] = Change{
+			Action:    c.action,
+			Actor:     c.key,
+			Rights:    c.accessRight,
+			Denied:    c.denied,
+			Grantable: c.grantable,
+			ExpiresAt: c.expiresAt,
+		}
+	}
+
+	return changes
+}
+
 func (r *Roster) clearChanges() {
 	r.changeLock.Lock()
 	r.changes = nil
+	r.audit = nil
 	r.backup = nil
+	r.savepoints = nil
+	r.changeLock.Unlock()
+}
+
+// addAudit buffers the audit record of a grant or a revocation
+// until the pending changes are stored
+func (r *Roster) addAudit(action AuditAction, pid uuid.UUID, grantor, grantee Actor, rights Right) {
+	r.changeLock.Lock()
+	r.audit = append(r.audit, AuditRecord{
+		Timestamp: time.Now(),
+		PolicyID:  pid,
+		Grantor:   grantor,
+		Grantee:   grantee,
+		Action:    action,
+		Rights:    rights,
+	})
 	r.changeLock.Unlock()
 }
 
+// auditRecords returns a copy of the buffered audit records
+func (r *Roster) auditRecords() []AuditRecord {
+	r.changeLock.RLock()
+	defer r.changeLock.RUnlock()
+
+	if len(r.audit) == 0 {
+		return nil
+	}
+
+	records := make([]AuditRecord, len(r.audit))
+	copy(records, r.audit)
+
+	return records
+}
+
 // createBackup returns a snapshot copy of the accesspolicy rights roster for this policy
+// clone returns a copy of the public rights and the registry,
+// without the calculated cache and pending changes
+func (r *Roster) clone() *Roster {
+	r.registryLock.RLock()
+	defer r.registryLock.RUnlock()
+
+	c := NewRoster(0)
+	c.Everyone = r.Everyone
+
+	for _, cell := range r.Registry {
+		if cell.Key.Kind == 0 {
+			continue
+		}
+
+		c.Registry = append(c.Registry, cell)
+	}
+
+	return c
+}
+
 func (r *Roster) createBackup() {
 	// it's fine if this roster already has a backup set,
 	// thus doing nothing, allowing roster changes to be accumulated
@@ -257,10 +751,8 @@ func (r *Roster) createBackup() {
 	// initializing backup roster
 	backup := NewRoster(len(r.Registry))
 
-	// double-locking registry and cache to freeze
-	// the most vital parts of this roster
+	// locking registry to freeze the most vital part of this roster
 	r.registryLock.RLock()
-	r.cacheLock.RLock()
 
 	// copying public rights
 	backup.Everyone = r.Everyone
@@ -270,33 +762,28 @@ func (r *Roster) createBackup() {
 		backup.Registry[i] = r.Registry[i]
 	}
 
-	// copying calculated cache (not essential but still saves redundant re-calculation)
-	for k := range r.calculatedCache {
-		backup.calculatedCache[k] = r.calculatedCache[k]
-	}
-
 	// storing backup inside the roster itself
 	r.backup = backup
 
-	// removing both locks
-	r.cacheLock.RUnlock()
 	r.registryLock.RUnlock()
 }
 
 func (r *Roster) restoreBackup() {
+	// rolling back only to the most recent save point, if there's any
+	if r.restoreSavepoint() {
+		return
+	}
+
 	// nothing to restore if there's no backup
 	if r.backup == nil {
 		return
 	}
 
-	// double-locking registry and cache to freeze
-	// the most vital parts of this roster
+	// locking registry to freeze the most vital part of this roster
 	r.registryLock.RLock()
-	r.cacheLock.RLock()
 
-	// re-initializing fresh registry and a cache
+	// re-initializing fresh registry
 	r.Registry = make([]Cell, len(r.backup.Registry))
-	r.calculatedCache = make(map[Actor]Right, len(r.backup.calculatedCache))
 
 	// restoring public rights
 	r.Everyone = r.backup.Everyone
@@ -306,17 +793,90 @@ func (r *Roster) restoreBackup() {
 		r.Registry[i] = r.backup.Registry[i]
 	}
 
-	// copying calculated cache (not essential but still saves redundant re-calculation)
-	for k := range r.calculatedCache {
-		r.backup.calculatedCache[k] = r.calculatedCache[k]
-	}
-
 	// backup is no longer needed at this point,
 	// clearing backup and all changes
 	r.backup = nil
 	r.changes = nil
+	r.audit = nil
 
-	// removing both locks
-	r.cacheLock.RUnlock()
 	r.registryLock.RUnlock()
 }
+
+// rosterSavepoint is an intermediate safe point of a roster,
+// along with the number of changes and audit records made before it
+type rosterSavepoint struct {
+	everyone Right
+	registry []Cell
+	changes  int
+	audit    int
+}
+
+// pushBackup creates a save point on top of the backup, so that a composite
+// operation is able to roll back its own changes without discarding the
+// changes made before it; restoreBackup rolls back to the most recent
+// save point, which remains until it's popped
+// NOTE: every pushed save point must be popped by the same operation
+func (r *Roster) pushBackup() {
+	// the backup is still the last safe point of the roster as a whole
+	r.createBackup()
+
+	r.registryLock.RLock()
+	sp := rosterSavepoint{
+		everyone: r.Everyone,
+		registry: make([]Cell, len(r.Registry)),
+	}
+	copy(sp.registry, r.Registry)
+	r.registryLock.RUnlock()
+
+	r.changeLock.Lock()
+	sp.changes = len(r.changes)
+	sp.audit = len(r.audit)
+	r.savepoints = append(r.savepoints, sp)
+	r.changeLock.Unlock()
+}
+
+// popBackup discards the most recent save point, rolling
+// back to it first if restore is set
+func (r *Roster) popBackup(restore bool) {
+	if restore {
+		r.restoreSavepoint()
+	}
+
+	r.changeLock.Lock()
+	if n := len(r.savepoints); n > 0 {
+		r.savepoints = r.savepoints[:n-1]
+	}
+	r.changeLock.Unlock()
+}
+
+// restoreSavepoint rolls back to the most recent save point,
+// returns false if there's none
+func (r *Roster) restoreSavepoint() bool {
+	r.changeLock.Lock()
+
+	n := len(r.savepoints)
+	if n == 0 {
+		r.changeLock.Unlock()
+		return false
+	}
+
+	sp := r.savepoints[n-1]
+
+	if sp.changes < len(r.changes) {
+		r.changes = r.changes[:sp.changes]
+	}
+
+	if sp.audit < len(r.audit) {
+		r.audit = r.audit[:sp.audit]
+	}
+
+	r.changeLock.Unlock()
+
+	r.registryLock.Lock()
+	r.Everyone = sp.everyone
+	r.Registry = make([]Cell, len(sp.registry))
+	copy(r.Registry, sp.registry)
+	r.registryLock.Unlock()
+
+	return true
+}
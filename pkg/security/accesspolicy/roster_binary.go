@@ -0,0 +1,145 @@
+package accesspolicy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// rosterBinaryVersion is the version of the binary roster format,
+// to be incremented whenever the layout changes
+const rosterBinaryVersion uint8 = 1
+
+// binaryRosterHeader precedes the registry records
+type binaryRosterHeader struct {
+	Version  uint8
+	Everyone Right
+	Count    uint32
+}
+
+// binaryCell is a fixed-size record of a single registry cell
+// NOTE: zero expiration time means that the cell never expires
+type binaryCell struct {
+	Kind      ActorKind
+	ID        uuid.UUID
+	Rights    Right
+	Denied    Right
+	Grantable Right
+	ExpiresAt int64
+}
+
+var (
+	binaryRosterHeaderSize = binary.Size(binaryRosterHeader{})
+	binaryCellSize         = binary.Size(binaryCell{})
+)
+
+// MarshalBinary encodes the public rights and the registry of this roster
+// into a compact binary form, followed by an xxhash checksum of the contents
+// NOTE: the calculated cache and the pending changes aren't encoded
+func (r *Roster) MarshalBinary() ([]byte, error) {
+	r.registryLock.RLock()
+	defer r.registryLock.RUnlock()
+
+	cells := make([]binaryCell, 0, len(r.Registry))
+	for _, cell := range r.Registry {
+		if cell.Key.Kind == 0 {
+			continue
+		}
+
+		bc := binaryCell{
+			Kind:      cell.Key.Kind,
+			ID:        cell.Key.ID,
+			Rights:    cell.Rights,
+			Denied:    cell.Denied,
+			Grantable: cell.Grantable,
+		}
+
+		if !cell.ExpiresAt.IsZero() {
+			bc.ExpiresAt = cell.ExpiresAt.UnixNano()
+		}
+
+		cells = append(cells, bc)
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Grow(binaryRosterHeaderSize + len(cells)*binaryCellSize + 8)
+
+	header := binaryRosterHeader{
+		Version:  rosterBinaryVersion,
+		Everyone: r.Everyone,
+		Count:    uint32(len(cells)),
+	}
+
+	if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+		return nil, errors.Wrap(err, "failed to write roster header")
+	}
+
+	if err := binary.Write(buf, binary.LittleEndian, cells); err != nil {
+		return nil, errors.Wrap(err, "failed to write roster registry")
+	}
+
+	if err := binary.Write(buf, binary.LittleEndian, xxhash.Sum64(buf.Bytes())); err != nil {
+		return nil, errors.Wrap(err, "failed to write roster checksum")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a roster encoded by MarshalBinary, replacing
+// the public rights and the registry of this roster
+// NOTE: returns ErrRosterChecksumMismatch if the data has been corrupted
+func (r *Roster) UnmarshalBinary(data []byte) error {
+	if len(data) < binaryRosterHeaderSize+8 {
+		return errors.Wrapf(ErrMalformedRoster, "data is too short: %d bytes", len(data))
+	}
+
+	contents, trailer := data[:len(data)-8], data[len(data)-8:]
+	if xxhash.Sum64(contents) != binary.LittleEndian.Uint64(trailer) {
+		return ErrRosterChecksumMismatch
+	}
+
+	rd := bytes.NewReader(contents)
+
+	var header binaryRosterHeader
+	if err := binary.Read(rd, binary.LittleEndian, &header); err != nil {
+		return errors.Wrap(err, "failed to read roster header")
+	}
+
+	if header.Version != rosterBinaryVersion {
+		return errors.Wrapf(ErrMalformedRoster, "unsupported version: %d", header.Version)
+	}
+
+	if rd.Len() != int(header.Count)*binaryCellSize {
+		return errors.Wrapf(ErrMalformedRoster, "expected %d records, got %d bytes", header.Count, rd.Len())
+	}
+
+	cells := make([]binaryCell, header.Count)
+	if err := binary.Read(rd, binary.LittleEndian, cells); err != nil {
+		return errors.Wrap(err, "failed to read roster registry")
+	}
+
+	registry := make([]Cell, len(cells))
+	for i, bc := range cells {
+		registry[i] = Cell{
+			Key:       NewActor(bc.Kind, bc.ID),
+			Rights:    bc.Rights,
+			Denied:    bc.Denied,
+			Grantable: bc.Grantable,
+		}
+
+		if bc.ExpiresAt != 0 {
+			registry[i].ExpiresAt = time.Unix(0, bc.ExpiresAt)
+		}
+	}
+
+	r.registryLock.Lock()
+	r.Everyone = header.Everyone
+	r.Registry = registry
+	r.registryLock.Unlock()
+
+	return nil
+}
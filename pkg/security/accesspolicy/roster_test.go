@@ -0,0 +1,258 @@
+package accesspolicy_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/agubarev/hometown/pkg/security/accesspolicy"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRosterRange(t *testing.T) {
+	a := assert.New(t)
+
+	u1 := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	u2 := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	g1 := uuid.MustParse("00000000-0000-0000-0000-000000000003")
+	r1 := uuid.MustParse("00000000-0000-0000-0000-000000000004")
+
+	// deliberately unordered, including a blank cell
+	r := accesspolicy.NewRoster(1)
+	r.Everyone = accesspolicy.APView
+	r.Registry = append(r.Registry,
+		accesspolicy.Cell{Key: accesspolicy.RoleActor(r1), Rights: accesspolicy.APChange},
+		accesspolicy.Cell{Key: accesspolicy.UserActor(u2), Rights: accesspolicy.APView},
+		accesspolicy.Cell{Key: accesspolicy.GroupActor(g1), Rights: accesspolicy.APDelete},
+		accesspolicy.Cell{Key: accesspolicy.UserActor(u1), Rights: accesspolicy.APCreate},
+	)
+
+	expected := []accesspolicy.Cell{
+		{Key: accesspolicy.PublicActor(), Rights: accesspolicy.APView},
+		{Key: accesspolicy.UserActor(u1), Rights: accesspolicy.APCreate},
+		{Key: accesspolicy.UserActor(u2), Rights: accesspolicy.APView},
+		{Key: accesspolicy.GroupActor(g1), Rights: accesspolicy.APDelete},
+		{Key: accesspolicy.RoleActor(r1), Rights: accesspolicy.APChange},
+	}
+
+	// every entry exactly once, in the same order every time
+	for i := 0; i < 3; i++ {
+		visited := make([]accesspolicy.Cell, 0)
+
+		r.Range(func(actor accesspolicy.Actor, rights accesspolicy.Right) bool {
+			visited = append(visited, accesspolicy.Cell{Key: actor, Rights: rights})
+			return true
+		})
+
+		a.Equal(expected, visited)
+	}
+
+	// stopping early
+	n := 0
+	r.Range(func(actor accesspolicy.Actor, rights accesspolicy.Right) bool {
+		n++
+		return n < 2
+	})
+	a.Equal(2, n)
+}
+
+func TestRosterMerge(t *testing.T) {
+	a := assert.New(t)
+
+	shared := accesspolicy.UserActor(uuid.New())
+	denied := accesspolicy.UserActor(uuid.New())
+	mine := accesspolicy.GroupActor(uuid.New())
+	theirs := accesspolicy.RoleActor(uuid.New())
+	expired := accesspolicy.UserActor(uuid.New())
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	r := accesspolicy.NewRoster(0)
+	r.Everyone = accesspolicy.APView
+	r.Registry = append(r.Registry,
+		accesspolicy.Cell{Key: shared, Rights: accesspolicy.APView, ExpiresAt: expiresAt},
+		accesspolicy.Cell{Key: denied, Rights: accesspolicy.APView | accesspolicy.APChange},
+		accesspolicy.Cell{Key: mine, Rights: accesspolicy.APDelete},
+	)
+
+	other := accesspolicy.NewRoster(0)
+	other.Everyone = accesspolicy.APCreate
+	other.Registry = append(other.Registry,
+		accesspolicy.Cell{Key: shared, Rights: accesspolicy.APChange},
+		accesspolicy.Cell{Key: denied, Denied: accesspolicy.APChange},
+		accesspolicy.Cell{Key: theirs, Rights: accesspolicy.APMove},
+		accesspolicy.Cell{Key: expired, Rights: accesspolicy.APView, ExpiresAt: time.Now().Add(-time.Hour)},
+	)
+
+	r.Merge(other)
+	a.True(r.HasChanges())
+
+	cells := make(map[accesspolicy.Actor]accesspolicy.Cell)
+	for _, cell := range r.Registry {
+		cells[cell.Key] = cell
+	}
+
+	// public rights and overlapping actors are combined
+	a.Equal(accesspolicy.APView|accesspolicy.APCreate, r.Everyone)
+	a.Equal(accesspolicy.APView|accesspolicy.APChange, cells[shared].Rights)
+	a.True(cells[shared].ExpiresAt.IsZero())
+	a.Equal(accesspolicy.APView|accesspolicy.APChange, cells[denied].Rights)
+	a.Equal(accesspolicy.APChange, cells[denied].Denied)
+
+	// disjoint actors are kept and added, expired ones are skipped
+	a.Equal(accesspolicy.APDelete, cells[mine].Rights)
+	a.Equal(accesspolicy.APMove, cells[theirs].Rights)
+	a.NotContains(cells, expired)
+	a.Len(r.Registry, 4)
+
+	// the other roster remains intact
+	a.Equal(accesspolicy.APCreate, other.Everyone)
+	a.Len(other.Registry, 4)
+
+	// merging into itself or merging nothing changes nothing
+	before := len(r.Registry)
+	r.Merge(r)
+	r.Merge(nil)
+	a.Len(r.Registry, before)
+}
+
+func TestRosterMergePersisted(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy store
+	s := accesspolicy.NewMemoryStore()
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, nil)
+	a.NoError(err)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user1 := accesspolicy.UserActor(uuid.New())
+	user2 := accesspolicy.UserActor(uuid.New())
+
+	kept, err := m.Create(ctx, "kept policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	duplicate, err := m.Create(ctx, "duplicate policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.GrantUserAccess(ctx, kept.ID, owner, user1.ID, accesspolicy.APView))
+	a.NoError(m.Update(ctx, kept))
+
+	a.NoError(m.GrantPublicAccess(ctx, duplicate.ID, owner, accesspolicy.APView))
+	a.NoError(m.GrantUserAccess(ctx, duplicate.ID, owner, user1.ID, accesspolicy.APChange))
+	a.NoError(m.GrantUserAccess(ctx, duplicate.ID, owner, user2.ID, accesspolicy.APDelete))
+	a.NoError(m.Update(ctx, duplicate))
+
+	r, err := m.RosterByPolicyID(ctx, kept.ID)
+	a.NoError(err)
+
+	dr, err := m.RosterByPolicyID(ctx, duplicate.ID)
+	a.NoError(err)
+
+	r.Merge(dr)
+	a.NoError(m.Update(ctx, kept))
+
+	// a fresh manager to make sure everything is read from the store
+	m2, err := accesspolicy.NewManager(s, nil)
+	a.NoError(err)
+	a.True(m2.HasPublicRights(ctx, kept.ID, accesspolicy.APView))
+	a.True(m2.HasRights(ctx, kept.ID, user1, accesspolicy.APView|accesspolicy.APChange))
+	a.True(m2.HasRights(ctx, kept.ID, user2, accesspolicy.APDelete))
+}
+
+func TestActorString(t *testing.T) {
+	a := assert.New(t)
+
+	id := uuid.New()
+
+	actors := []accesspolicy.Actor{
+		accesspolicy.PublicActor(),
+		accesspolicy.UserActor(id),
+		accesspolicy.GroupActor(id),
+		accesspolicy.RoleActor(id),
+		accesspolicy.NewActor(accesspolicy.AKUser, uuid.New()),
+	}
+
+	for _, actor := range actors {
+		parsed, err := accesspolicy.ParseActor(actor.String())
+		a.NoError(err)
+		a.True(actor.Equal(parsed), actor.String())
+	}
+
+	a.Equal("everyone", accesspolicy.PublicActor().String())
+	a.Equal("user:"+id.String(), accesspolicy.UserActor(id).String())
+	a.Equal("role_group:"+id.String(), accesspolicy.RoleActor(id).String())
+
+	// same ID, different kinds
+	a.False(accesspolicy.UserActor(id).Equal(accesspolicy.GroupActor(id)))
+	a.False(accesspolicy.UserActor(id).Equal(accesspolicy.UserActor(uuid.New())))
+
+	_, err := accesspolicy.ParseActor("user")
+	a.Equal(accesspolicy.ErrUnrecognizedActorKind, errors.Cause(err))
+
+	_, err = accesspolicy.ParseActor("robot:" + id.String())
+	a.Equal(accesspolicy.ErrUnrecognizedActorKind, errors.Cause(err))
+
+	_, err = accesspolicy.ParseActor("group:" + uuid.Nil.String())
+	a.Equal(accesspolicy.ErrNilActorID, err)
+
+	_, err = accesspolicy.ParseActor("group:nonsense")
+	a.Error(err)
+}
+
+func TestRosterMarshalBinary(t *testing.T) {
+	a := assert.New(t)
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	// including a blank cell, which isn't encoded
+	r := accesspolicy.NewRoster(1)
+	r.Everyone = accesspolicy.APView
+	r.Registry = append(r.Registry,
+		accesspolicy.Cell{Key: accesspolicy.UserActor(uuid.New()), Rights: accesspolicy.APCreate, ExpiresAt: expiresAt},
+		accesspolicy.Cell{Key: accesspolicy.GroupActor(uuid.New()), Rights: accesspolicy.APChange, Grantable: accesspolicy.APView},
+		accesspolicy.Cell{Key: accesspolicy.RoleActor(uuid.New()), Denied: accesspolicy.APDelete},
+	)
+
+	data, err := r.MarshalBinary()
+	a.NoError(err)
+
+	decoded := accesspolicy.NewRoster(0)
+	a.NoError(decoded.UnmarshalBinary(data))
+	a.Equal(r.Everyone, decoded.Everyone)
+	a.Len(decoded.Registry, 3)
+
+	for i, cell := range decoded.Registry {
+		original := r.Registry[i+1]
+
+		a.Equal(original.Key, cell.Key)
+		a.Equal(original.Rights, cell.Rights)
+		a.Equal(original.Denied, cell.Denied)
+		a.Equal(original.Grantable, cell.Grantable)
+		a.True(original.ExpiresAt.Equal(cell.ExpiresAt))
+	}
+
+	// an empty roster
+	data, err = accesspolicy.NewRoster(0).MarshalBinary()
+	a.NoError(err)
+	a.NoError(decoded.UnmarshalBinary(data))
+	a.Equal(accesspolicy.APNoAccess, decoded.Everyone)
+	a.Empty(decoded.Registry)
+
+	// corrupted data
+	data, err = r.MarshalBinary()
+	a.NoError(err)
+
+	data[5] ^= 0xff
+	a.Equal(accesspolicy.ErrRosterChecksumMismatch, decoded.UnmarshalBinary(data))
+
+	// truncated data
+	err = decoded.UnmarshalBinary(data[:4])
+	a.Equal(accesspolicy.ErrMalformedRoster, errors.Cause(err))
+}
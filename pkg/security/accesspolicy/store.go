@@ -2,21 +2,160 @@ package accesspolicy
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 // Store is a storage contract interface for the Policy objects
+// NOTE: UpdatePolicy must only succeed if the stored version of a policy is
+// still the same as p.Version, bumping it, otherwise it fails with ErrStaleUpdate
+// NOTE: soft-deleted policies are still fetched by ID, and by key or object
+// if there is no live policy to take precedence, but they're neither counted,
+// listed nor do they hold their key, nor are they found by the actors of their rosters
 // TODO: keep rights separate and segregated by it's kind i.e. Public, Policy, Role, User etc.
 type Store interface {
 	CreatePolicy(ctx context.Context, p Policy, r *Roster) (Policy, *Roster, error)
 	UpdatePolicy(ctx context.Context, p Policy, r *Roster) error
 	FetchPolicyByID(ctx context.Context, id uuid.UUID) (Policy, error)
+	FetchPoliciesByIDs(ctx context.Context, ids []uuid.UUID) ([]Policy, error)
 	FetchPolicyByKey(ctx context.Context, key string) (p Policy, err error)
 	FetchPolicyByObject(ctx context.Context, obj Object) (p Policy, err error)
+	FetchObjectNames(ctx context.Context) (names []string, err error)
+	FetchPoliciesByObjectName(ctx context.Context, name string, limit, offset int, includeArchived bool) ([]Policy, error)
+	HasPolicy(ctx context.Context, id uuid.UUID) (bool, error)
+	HasPolicyByKey(ctx context.Context, key string) (bool, error)
+	HasChildPolicies(ctx context.Context, pid uuid.UUID) (bool, error)
+	FetchPolicyIDsByActor(ctx context.Context, actor Actor) (ids []uuid.UUID, err error)
+	CountPoliciesByOwner(ctx context.Context, ownerID uuid.UUID, includeArchived bool) (int, error)
+	ListPoliciesByOwner(ctx context.Context, ownerID uuid.UUID, limit, offset int, includeArchived bool) ([]Policy, error)
 	DeletePolicy(ctx context.Context, p Policy) error
+	SetPolicyDeletedAt(ctx context.Context, pid uuid.UUID, deletedAt *time.Time) error
 	CreateRoster(ctx context.Context, policyID uuid.UUID, r *Roster) (err error)
 	FetchRosterByPolicyID(ctx context.Context, pid uuid.UUID) (r *Roster, err error)
+	FetchRostersByPolicyIDs(ctx context.Context, pids []uuid.UUID) (map[uuid.UUID]*Roster, error)
 	UpdateRoster(ctx context.Context, pid uuid.UUID, r *Roster) (err error)
 	DeleteRoster(ctx context.Context, pid uuid.UUID) (err error)
+	DeleteExpiredRosterEntries(ctx context.Context, now time.Time) (n int64, err error)
+	CreateAccessRequest(ctx context.Context, ar AccessRequest) error
+	FetchAccessRequestByID(ctx context.Context, id uuid.UUID) (ar AccessRequest, err error)
+	FetchPendingAccessRequests(ctx context.Context, pid uuid.UUID) (ars []AccessRequest, err error)
+	UpdateAccessRequest(ctx context.Context, ar AccessRequest) error
+	SetActorSuspended(ctx context.Context, userID uuid.UUID, suspended bool) error
+	FetchSuspendedActors(ctx context.Context) ([]uuid.UUID, error)
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// Scoper is implemented by the stores which are able to confine
+// themselves to the policies of a single scope (i.e. tenant)
+type Scoper interface {
+	WithScope(scope uuid.UUID) Store
+}
+
+// archivedCond returns the condition which excludes the archived
+// policies from a query, unless they're included
+// NOTE: shared by all SQL stores
+func archivedCond(includeArchived bool) string {
+	if includeArchived {
+		return ""
+	}
+
+	return fmt.Sprintf(" AND (flags & %d) = 0", FArchived)
+}
+
+// breakdownRoster decomposes roster entries into usable data records
+// NOTE: shared by all SQL stores
+func breakdownRoster(pid uuid.UUID, r *Roster) (records []RosterEntry) {
+	records = make([]RosterEntry, 0, len(r.Registry)+1)
+
+	// for everyone
+	records = append(records, RosterEntry{
+		PolicyID:        pid,
+		ActorKind:       AKEveryone,
+		Access:          r.Everyone,
+		AccessExplained: r.Everyone.String(),
+	})
+
+	// breakdown
+	r.registryLock.RLock()
+	for _, _r := range r.Registry {
+		switch _r.Key.Kind {
+		case AKRoleGroup, AKGroup, AKUser:
+			records = append(records, RosterEntry{
+				PolicyID:        pid,
+				ActorKind:       _r.Key.Kind,
+				ActorID:         _r.Key.ID,
+				Access:          _r.Rights,
+				AccessExplained: _r.Rights.String(),
+				Denied:          _r.Denied,
+				Grantable:       _r.Grantable,
+				ExpiresAt:       _r.ExpiresAt,
+			})
+		default:
+			log.Printf(
+				"unrecognized actor kind for accesspolicy policy: actor(kind=%s, id=%s), accesspolicy=(%s; %s)",
+				_r.Key.Kind,
+				_r.Key.ID,
+				_r.Rights,
+				_r.Rights.Translate(),
+			)
+		}
+	}
+	r.registryLock.RUnlock()
+
+	return records
+}
+
+// uuidArgs turns IDs into query arguments
+// NOTE: shared by all SQL stores
+func uuidArgs(ids []uuid.UUID) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	return args
+}
+
+// groupRosterEntries splits data records by the policies they belong to
+func groupRosterEntries(records []RosterEntry) map[uuid.UUID][]RosterEntry {
+	grouped := make(map[uuid.UUID][]RosterEntry)
+	for _, re := range records {
+		grouped[re.PolicyID] = append(grouped[re.PolicyID], re)
+	}
+
+	return grouped
+}
+
+func buildRoster(records []RosterEntry) (r *Roster) {
+	r = NewRoster(len(records))
+
+	// transforming data records into the roster object
+	for _, _r := range records {
+		switch _r.ActorKind {
+		case AKEveryone:
+			r.Everyone = _r.Access
+		case AKRoleGroup, AKGroup, AKUser:
+			r.put(NewActor(_r.ActorKind, _r.ActorID), _r.Access, _r.ExpiresAt)
+
+			if _r.Denied != APNoAccess {
+				r.deny(NewActor(_r.ActorKind, _r.ActorID), _r.Denied)
+			}
+
+			if _r.Grantable != APNoAccess {
+				r.limitGrants(NewActor(_r.ActorKind, _r.ActorID), _r.Grantable)
+			}
+		default:
+			log.Printf(
+				"unrecognized actor kind for accesspolicy policy (actor_kind=%d, actor_id=%d, access_right=%d)",
+				_r.ActorKind,
+				_r.ActorID,
+				_r.Access,
+			)
+		}
+	}
+
+	return r
 }
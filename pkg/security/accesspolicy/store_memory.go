@@ -0,0 +1,602 @@
+package accesspolicy
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// MemoryStore keeps everything in memory, it's meant for unit tests
+// which shouldn't depend on a database
+// NOTE: everything is copied on the way in and out, so that the callers
+// can't alter the stored state other than through the store itself
+type MemoryStore struct {
+	policies  map[uuid.UUID]Policy
+	rosters   map[uuid.UUID]map[Actor]RosterEntry
+	requests  map[uuid.UUID]AccessRequest
+	suspended map[uuid.UUID]bool
+	sync.RWMutex
+}
+
+func NewMemoryStore() Store {
+	return &MemoryStore{
+		policies:  make(map[uuid.UUID]Policy),
+		rosters:   make(map[uuid.UUID]map[Actor]RosterEntry),
+		requests:  make(map[uuid.UUID]AccessRequest),
+		suspended: make(map[uuid.UUID]bool),
+	}
+}
+
+// copyPolicy returns a copy of a policy which doesn't share its co-owner set
+// and deletion time
+func copyPolicy(p Policy) Policy {
+	if p.CoOwners != nil {
+		p.CoOwners = append([]uuid.UUID(nil), p.CoOwners...)
+	}
+
+	if p.DeletedAt != nil {
+		at := *p.DeletedAt
+		p.DeletedAt = &at
+	}
+
+	return p
+}
+
+// WithTx runs a given function against this store, restoring
+// everything it held before if the function fails
+// NOTE: unlike a database transaction, it doesn't isolate the changes,
+// thus it's only meant for tests which don't change the store concurrently
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	s.RLock()
+	policies := make(map[uuid.UUID]Policy, len(s.policies))
+	for id, p := range s.policies {
+		policies[id] = copyPolicy(p)
+	}
+
+	rosters := make(map[uuid.UUID]map[Actor]RosterEntry, len(s.rosters))
+	for pid, entries := range s.rosters {
+		rosters[pid] = make(map[Actor]RosterEntry, len(entries))
+		for key, re := range entries {
+			rosters[pid][key] = re
+		}
+	}
+
+	requests := make(map[uuid.UUID]AccessRequest, len(s.requests))
+	for id, ar := range s.requests {
+		requests[id] = ar
+	}
+
+	suspended := make(map[uuid.UUID]bool, len(s.suspended))
+	for id := range s.suspended {
+		suspended[id] = true
+	}
+	s.RUnlock()
+
+	if err := fn(s); err != nil && err != ErrNothingChanged {
+		s.Lock()
+		s.policies = policies
+		s.rosters = rosters
+		s.requests = requests
+		s.suspended = suspended
+		s.Unlock()
+
+		return errors.Wrap(err, "transaction failed")
+	}
+
+	return nil
+}
+
+// putRosterEntries must be called under the lock
+func (s *MemoryStore) putRosterEntries(pid uuid.UUID, r *Roster, overwrite bool) {
+	entries, ok := s.rosters[pid]
+	if !ok {
+		entries = make(map[Actor]RosterEntry)
+		s.rosters[pid] = entries
+	}
+
+	for _, re := range breakdownRoster(pid, r) {
+		// skipping blank cells
+		if re.ActorKind == 0 {
+			continue
+		}
+
+		key := NewActor(re.ActorKind, re.ActorID)
+		if _, ok := entries[key]; ok && !overwrite {
+			continue
+		}
+
+		entries[key] = re
+	}
+}
+
+// applyRosterChanges must be called under the lock
+func (s *MemoryStore) applyRosterChanges(pid uuid.UUID, r *Roster) error {
+	for _, c := range r.changes {
+		// actor Name must not be NIL for any other than Public actor kind
+		if c.key.Kind != AKEveryone && c.key.ID == uuid.Nil {
+			return ErrNilActorID
+		}
+
+		if c.action != RSet && c.action != RUnset {
+			return errors.Wrapf(ErrUnrecognizedRosterAction, "action=%d", c.action)
+		}
+	}
+
+	entries, ok := s.rosters[pid]
+	if !ok {
+		entries = make(map[Actor]RosterEntry)
+		s.rosters[pid] = entries
+	}
+
+	for _, c := range r.changes {
+		switch c.action {
+		case RSet:
+			entries[c.key] = RosterEntry{
+				PolicyID:        pid,
+				ActorID:         c.key.ID,
+				ActorKind:       c.key.Kind,
+				Access:          c.accessRight,
+				AccessExplained: c.accessRight.String(),
+				Denied:          c.denied,
+				Grantable:       c.grantable,
+				ExpiresAt:       c.expiresAt,
+			}
+		case RUnset:
+			delete(entries, c.key)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) CreatePolicy(ctx context.Context, p Policy, r *Roster) (Policy, *Roster, error) {
+	if p.ID == uuid.Nil {
+		return p, r, ErrNilPolicyID
+	}
+
+	if r == nil {
+		r = NewRoster(0)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// same as the SQL stores, silently keeping the existing policy
+	if _, ok := s.policies[p.ID]; ok {
+		return p, r, nil
+	}
+
+	s.policies[p.ID] = copyPolicy(p)
+	s.putRosterEntries(p.ID, r, false)
+
+	return p, r, nil
+}
+
+func (s *MemoryStore) UpdatePolicy(ctx context.Context, p Policy, r *Roster) error {
+	if p.ID == uuid.Nil {
+		return ErrNilPolicyID
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// the version must be the same as when the policy was loaded
+	stored, ok := s.policies[p.ID]
+	if !ok || stored.Version != p.Version {
+		return errors.Wrapf(ErrStaleUpdate, "policy_id=%s, version=%d", p.ID, p.Version)
+	}
+
+	stored.ParentID = p.ParentID
+	stored.OwnerID = p.OwnerID
+	stored.Flags = p.Flags
+	stored.Version++
+	stored.CoOwners = copyPolicy(p).CoOwners
+	s.policies[p.ID] = stored
+
+	if r == nil {
+		return nil
+	}
+
+	return s.applyRosterChanges(p.ID, r)
+}
+
+func (s *MemoryStore) FetchPolicyByID(ctx context.Context, id uuid.UUID) (Policy, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	p, ok := s.policies[id]
+	if !ok {
+		return Policy{}, ErrPolicyNotFound
+	}
+
+	return copyPolicy(p), nil
+}
+
+// FetchPoliciesByIDs returns the policies of given IDs at once,
+// the ones which don't exist are omitted
+func (s *MemoryStore) FetchPoliciesByIDs(ctx context.Context, ids []uuid.UUID) ([]Policy, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	ps := make([]Policy, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := s.policies[id]; ok {
+			ps = append(ps, copyPolicy(p))
+		}
+	}
+
+	return ps, nil
+}
+
+// firstPolicy returns the first policy that matches, live policies take
+// precedence over the soft-deleted ones, otherwise policies are tried in
+// the order of their IDs to keep the results stable
+func (s *MemoryStore) firstPolicy(match func(p Policy) bool) (Policy, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var found []Policy
+	for _, p := range s.policies {
+		if match(p) {
+			found = append(found, p)
+		}
+	}
+
+	if len(found) == 0 {
+		return Policy{}, ErrPolicyNotFound
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].IsDeleted() != found[j].IsDeleted() {
+			return !found[i].IsDeleted()
+		}
+
+		return found[i].ID.String() < found[j].ID.String()
+	})
+
+	return copyPolicy(found[0]), nil
+}
+
+func (s *MemoryStore) FetchPolicyByKey(ctx context.Context, key string) (Policy, error) {
+	return s.firstPolicy(func(p Policy) bool { return p.Key == key })
+}
+
+func (s *MemoryStore) FetchPolicyByObject(ctx context.Context, obj Object) (Policy, error) {
+	return s.firstPolicy(func(p Policy) bool { return p.ObjectName == obj.Name && p.ObjectID == obj.ID })
+}
+
+func (s *MemoryStore) FetchObjectNames(ctx context.Context) ([]string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, p := range s.policies {
+		if p.ObjectName == "" || p.IsDeleted() || seen[p.ObjectName] {
+			continue
+		}
+
+		seen[p.ObjectName] = true
+		names = append(names, p.ObjectName)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (s *MemoryStore) HasPolicy(ctx context.Context, id uuid.UUID) (bool, error) {
+	s.RLock()
+	p, ok := s.policies[id]
+	s.RUnlock()
+
+	return ok && !p.IsDeleted(), nil
+}
+
+func (s *MemoryStore) HasPolicyByKey(ctx context.Context, key string) (bool, error) {
+	p, err := s.FetchPolicyByKey(ctx, key)
+	if err != nil {
+		return false, nil
+	}
+
+	return !p.IsDeleted(), nil
+}
+
+func (s *MemoryStore) HasChildPolicies(ctx context.Context, pid uuid.UUID) (bool, error) {
+	_, err := s.firstPolicy(func(p Policy) bool { return p.ParentID == pid && !p.IsDeleted() })
+	return err == nil, nil
+}
+
+func (s *MemoryStore) FetchPolicyIDsByActor(ctx context.Context, actor Actor) ([]uuid.UUID, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	ids := make([]uuid.UUID, 0)
+	for pid, entries := range s.rosters {
+		if p, ok := s.policies[pid]; !ok || p.IsDeleted() {
+			continue
+		}
+
+		if _, ok := entries[actor]; ok {
+			ids = append(ids, pid)
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *MemoryStore) CountPoliciesByOwner(ctx context.Context, ownerID uuid.UUID, includeArchived bool) (n int, err error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, p := range s.policies {
+		if p.OwnerID == ownerID && !p.IsDeleted() && (includeArchived || !p.IsArchived()) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// ListPoliciesByOwner returns a page of policies owned by a given owner,
+// ordered by key and ID, non-positive limit means no limit
+func (s *MemoryStore) ListPoliciesByOwner(ctx context.Context, ownerID uuid.UUID, limit, offset int, includeArchived bool) ([]Policy, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	ps := make([]Policy, 0)
+	for _, p := range s.policies {
+		if p.OwnerID == ownerID && !p.IsDeleted() && (includeArchived || !p.IsArchived()) {
+			ps = append(ps, copyPolicy(p))
+		}
+	}
+
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].Key != ps[j].Key {
+			return ps[i].Key < ps[j].Key
+		}
+
+		return ps[i].ID.String() < ps[j].ID.String()
+	})
+
+	return pagePolicies(ps, limit, offset), nil
+}
+
+// FetchPoliciesByObjectName returns a page of policies which protect objects
+// of a given name, ordered by object ID, non-positive limit means no limit
+func (s *MemoryStore) FetchPoliciesByObjectName(ctx context.Context, name string, limit, offset int, includeArchived bool) ([]Policy, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	ps := make([]Policy, 0)
+	for _, p := range s.policies {
+		if p.ObjectName == name && !p.IsDeleted() && (includeArchived || !p.IsArchived()) {
+			ps = append(ps, copyPolicy(p))
+		}
+	}
+
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].ObjectID != ps[j].ObjectID {
+			return ps[i].ObjectID.String() < ps[j].ObjectID.String()
+		}
+
+		return ps[i].ID.String() < ps[j].ID.String()
+	})
+
+	return pagePolicies(ps, limit, offset), nil
+}
+
+// pagePolicies returns a page of sorted policies
+func pagePolicies(ps []Policy, limit, offset int) []Policy {
+	if offset < 0 {
+		offset = 0
+	}
+
+	if offset >= len(ps) {
+		return make([]Policy, 0)
+	}
+
+	ps = ps[offset:]
+	if limit > 0 && limit < len(ps) {
+		ps = ps[:limit]
+	}
+
+	return ps
+}
+
+func (s *MemoryStore) DeletePolicy(ctx context.Context, p Policy) error {
+	s.Lock()
+	delete(s.policies, p.ID)
+	delete(s.rosters, p.ID)
+	s.Unlock()
+
+	return nil
+}
+
+// SetPolicyDeletedAt marks a policy as soft-deleted at a given time,
+// nil restores it
+func (s *MemoryStore) SetPolicyDeletedAt(ctx context.Context, pid uuid.UUID, deletedAt *time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	p, ok := s.policies[pid]
+	if !ok {
+		return ErrNothingChanged
+	}
+
+	if deletedAt != nil {
+		at := *deletedAt
+		deletedAt = &at
+	}
+
+	p.DeletedAt = deletedAt
+	p.Version++
+	s.policies[pid] = p
+
+	return nil
+}
+
+func (s *MemoryStore) CreateRoster(ctx context.Context, policyID uuid.UUID, r *Roster) error {
+	s.Lock()
+	s.putRosterEntries(policyID, r, false)
+	s.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStore) FetchRosterByPolicyID(ctx context.Context, pid uuid.UUID) (*Roster, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	entries := s.rosters[pid]
+	if len(entries) == 0 {
+		return nil, ErrEmptyRoster
+	}
+
+	records := make([]RosterEntry, 0, len(entries))
+	for _, re := range entries {
+		records = append(records, re)
+	}
+
+	// a freshly built roster shares nothing with the stored state
+	return buildRoster(records), nil
+}
+
+// FetchRostersByPolicyIDs returns the rosters of given policies at once,
+// mapped by policy ID, the policies without any roster entries are omitted
+func (s *MemoryStore) FetchRostersByPolicyIDs(ctx context.Context, pids []uuid.UUID) (map[uuid.UUID]*Roster, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	rosters := make(map[uuid.UUID]*Roster)
+	for _, pid := range pids {
+		entries := s.rosters[pid]
+		if len(entries) == 0 {
+			continue
+		}
+
+		records := make([]RosterEntry, 0, len(entries))
+		for _, re := range entries {
+			records = append(records, re)
+		}
+
+		rosters[pid] = buildRoster(records)
+	}
+
+	return rosters, nil
+}
+
+func (s *MemoryStore) UpdateRoster(ctx context.Context, pid uuid.UUID, r *Roster) error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.applyRosterChanges(pid, r)
+}
+
+func (s *MemoryStore) DeleteRoster(ctx context.Context, pid uuid.UUID) error {
+	s.Lock()
+	delete(s.rosters, pid)
+	s.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpiredRosterEntries(ctx context.Context, now time.Time) (n int64, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, entries := range s.rosters {
+		for key, re := range entries {
+			if !re.ExpiresAt.IsZero() && !now.Before(re.ExpiresAt) {
+				delete(entries, key)
+				n++
+			}
+		}
+	}
+
+	return n, nil
+}
+
+func (s *MemoryStore) CreateAccessRequest(ctx context.Context, ar AccessRequest) error {
+	s.Lock()
+	s.requests[ar.ID] = ar
+	s.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStore) FetchAccessRequestByID(ctx context.Context, id uuid.UUID) (AccessRequest, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	ar, ok := s.requests[id]
+	if !ok {
+		return AccessRequest{}, ErrAccessRequestNotFound
+	}
+
+	return ar, nil
+}
+
+func (s *MemoryStore) FetchPendingAccessRequests(ctx context.Context, pid uuid.UUID) ([]AccessRequest, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	ars := make([]AccessRequest, 0)
+	for _, ar := range s.requests {
+		if ar.PolicyID == pid && ar.IsPending() {
+			ars = append(ars, ar)
+		}
+	}
+
+	sort.Slice(ars, func(i, j int) bool {
+		return ars[i].CreatedAt.Before(ars[j].CreatedAt)
+	})
+
+	return ars, nil
+}
+
+func (s *MemoryStore) UpdateAccessRequest(ctx context.Context, ar AccessRequest) error {
+	s.Lock()
+	defer s.Unlock()
+
+	stored, ok := s.requests[ar.ID]
+	if !ok {
+		return ErrAccessRequestNotFound
+	}
+
+	stored.Status = ar.Status
+	stored.ResolvedBy = ar.ResolvedBy
+	stored.ResolvedAt = ar.ResolvedAt
+	s.requests[ar.ID] = stored
+
+	return nil
+}
+
+func (s *MemoryStore) SetActorSuspended(ctx context.Context, userID uuid.UUID, suspended bool) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if suspended {
+		s.suspended[userID] = true
+	} else {
+		delete(s.suspended, userID)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) FetchSuspendedActors(ctx context.Context) ([]uuid.UUID, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	ids := make([]uuid.UUID, 0, len(s.suspended))
+	for id := range s.suspended {
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,111 @@
+package accesspolicy_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/agubarev/hometown/pkg/security/accesspolicy"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy store
+	s := accesspolicy.NewMemoryStore()
+	a.NotNil(s)
+
+	// policy manager
+	m, err := accesspolicy.NewManager(s, nil)
+	a.NoError(err)
+	a.NotNil(m)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+	obj := accesspolicy.NewObject(uuid.New(), "memory object")
+
+	// nothing is there yet
+	_, err = s.FetchPolicyByID(ctx, uuid.New())
+	a.Equal(accesspolicy.ErrPolicyNotFound, err)
+
+	_, err = s.FetchPolicyByKey(ctx, "memory policy")
+	a.Equal(accesspolicy.ErrPolicyNotFound, err)
+
+	_, err = s.FetchRosterByPolicyID(ctx, uuid.New())
+	a.Equal(accesspolicy.ErrEmptyRoster, err)
+
+	p, err := m.Create(ctx, "memory policy", owner.ID, uuid.Nil, obj, 0)
+	a.NoError(err)
+
+	fetched, err := s.FetchPolicyByKey(ctx, "memory policy")
+	a.NoError(err)
+	a.Equal(p, fetched)
+
+	fetched, err = s.FetchPolicyByObject(ctx, obj)
+	a.NoError(err)
+	a.Equal(p, fetched)
+
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APChange))
+
+	// nothing is stored until the policy is updated
+	r, err := s.FetchRosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+	a.Equal(accesspolicy.APNoAccess, r.Everyone)
+
+	a.NoError(m.Update(ctx, p))
+
+	// a fresh manager to make sure everything is read from the store
+	m2, err := accesspolicy.NewManager(s, nil)
+	a.NoError(err)
+	a.True(m2.HasPublicRights(ctx, p.ID, accesspolicy.APView))
+	a.True(m2.HasRights(ctx, p.ID, user, accesspolicy.APView|accesspolicy.APChange))
+
+	// altering the fetched roster must not affect the stored one
+	r, err = s.FetchRosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+	r.Everyone = accesspolicy.APFullAccess
+	for i := range r.Registry {
+		r.Registry[i].Rights = accesspolicy.APFullAccess
+	}
+
+	r, err = s.FetchRosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+	a.Equal(accesspolicy.APView, r.Everyone)
+	for _, cell := range r.Registry {
+		if cell.Key == user {
+			a.Equal(accesspolicy.APView|accesspolicy.APChange, cell.Rights)
+		}
+	}
+
+	// revoking removes the entry
+	a.NoError(m.RevokeAccess(ctx, p.ID, owner, user))
+	a.NoError(m.Update(ctx, p))
+
+	ids, err := s.FetchPolicyIDsByActor(ctx, user)
+	a.NoError(err)
+	a.Empty(ids)
+
+	// expiring entries
+	a.NoError(m.GrantUserAccessUntil(ctx, p.ID, owner, user.ID, accesspolicy.APView, time.Now().Add(time.Hour)))
+	a.NoError(m.Update(ctx, p))
+
+	n, err := s.DeleteExpiredRosterEntries(ctx, time.Now().Add(2*time.Hour))
+	a.NoError(err)
+	a.Equal(int64(1), n)
+
+	// deleting
+	a.NoError(m.DeletePolicy(ctx, p))
+
+	_, err = s.FetchPolicyByID(ctx, p.ID)
+	a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+
+	_, err = s.FetchRosterByPolicyID(ctx, p.ID)
+	a.Equal(accesspolicy.ErrEmptyRoster, errors.Cause(err))
+}
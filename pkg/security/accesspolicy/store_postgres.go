@@ -2,7 +2,9 @@ package accesspolicy
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx"
@@ -15,10 +17,23 @@ type RosterEntry struct {
 	ActorKind       ActorKind `db:"actor_kind"`
 	Access          Right     `db:"accesspolicy"`
 	AccessExplained string    `db:"access_explained"`
+	Denied          Right     `db:"denied"`
+	Grantable       Right     `db:"grantable"`
+	ExpiresAt       time.Time `db:"expires_at"`
 }
 
 type PostgreSQLStore struct {
-	db *pgx.Conn
+	db      *pgx.Conn
+	replica *pgx.Conn
+	tx      *pgx.Tx
+	scope   uuid.UUID
+}
+
+// pgxQueryer is implemented by both the connection and a transaction
+type pgxQueryer interface {
+	ExecEx(ctx context.Context, sql string, options *pgx.QueryExOptions, arguments ...interface{}) (pgx.CommandTag, error)
+	QueryEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...interface{}) (*pgx.Rows, error)
+	QueryRowEx(ctx context.Context, sql string, options *pgx.QueryExOptions, args ...interface{}) *pgx.Row
 }
 
 func NewPostgreSQLStore(db *pgx.Conn) (Store, error) {
@@ -26,10 +41,126 @@ func NewPostgreSQLStore(db *pgx.Conn) (Store, error) {
 		return nil, ErrNilDatabase
 	}
 
-	return &PostgreSQLStore{db}, nil
+	return &PostgreSQLStore{db: db}, nil
+}
+
+// NewPostgreSQLStoreWithReplica returns a store which writes to the primary
+// and reads from the replica, falling back to the primary whenever the row
+// which is looked up isn't found on the replica, as it may lag behind
+// NOTE: reads within a transaction, as well as the ones which precede writes
+// and have to be up to date (i.e. the owner quota), always use the primary
+func NewPostgreSQLStoreWithReplica(primary, replica *pgx.Conn) (Store, error) {
+	if primary == nil || replica == nil {
+		return nil, ErrNilDatabase
+	}
+
+	return &PostgreSQLStore{db: primary, replica: replica}, nil
+}
+
+// WithScope returns a copy of this store which only operates
+// on the policies that belong to a given scope
+func (s *PostgreSQLStore) WithScope(scope uuid.UUID) Store {
+	return &PostgreSQLStore{db: s.db, replica: s.replica, tx: s.tx, scope: scope}
+}
+
+// conn returns the transaction this store is bound to, if any
+func (s *PostgreSQLStore) conn() pgxQueryer {
+	if s.tx != nil {
+		return s.tx
+	}
+
+	return s.db
+}
+
+// reader returns the replica for the reads outside of a transaction, if it's set
+func (s *PostgreSQLStore) reader() pgxQueryer {
+	if s.tx == nil && s.replica != nil {
+		return s.replica
+	}
+
+	return s.conn()
+}
+
+// primary returns a copy of this store which reads from the primary,
+// nil if this store reads from the primary already
+func (s *PostgreSQLStore) primary() *PostgreSQLStore {
+	if s.tx != nil || s.replica == nil {
+		return nil
+	}
+
+	return &PostgreSQLStore{db: s.db, scope: s.scope}
+}
+
+// WithTx runs a given function against a copy of this store which is bound
+// to a single transaction, committing it only if the function succeeds
+// NOTE: nested calls join the transaction which is already in progress
+func (s *PostgreSQLStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	return s.withTransaction(ctx, func(tx *pgx.Tx) error {
+		return fn(&PostgreSQLStore{db: s.db, tx: tx, scope: s.scope})
+	})
+}
+
+// scopeCond returns an additional condition if this store is scoped,
+// n is the number of the query argument which holds the scope
+func (s *PostgreSQLStore) scopeCond(n int) string {
+	if s.scope == uuid.Nil {
+		return ""
+	}
+
+	return fmt.Sprintf(" AND scope_id = $%d", n)
+}
+
+// scopeArgs appends the scope to the query arguments if this store is scoped
+func (s *PostgreSQLStore) scopeArgs(args ...interface{}) []interface{} {
+	if s.scope != uuid.Nil {
+		args = append(args, s.scope)
+	}
+
+	return args
+}
+
+// postgresPlaceholders returns a list of n numbered query arguments starting from a given one
+func postgresPlaceholders(start, n int) string {
+	ps := make([]string, n)
+	for i := range ps {
+		ps[i] = fmt.Sprintf("$%d", start+i)
+	}
+
+	return strings.Join(ps, ", ")
+}
+
+// checkScope makes sure that a given policy belongs to the scope of this store
+func (s *PostgreSQLStore) checkScope(ctx context.Context, pid uuid.UUID) error {
+	if s.scope == uuid.Nil {
+		return nil
+	}
+
+	ok, err := s.HasPolicy(ctx, pid)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrPolicyNotFound
+	}
+
+	return nil
 }
 
 func (s *PostgreSQLStore) withTransaction(ctx context.Context, fn func(tx *pgx.Tx) error) (err error) {
+	// the transaction in progress is committed by whoever has started it
+	if s.tx != nil {
+		if err = fn(s.tx); err != nil && err != ErrNothingChanged {
+			return errors.Wrap(err, "transaction failed")
+		}
+
+		return nil
+	}
+
 	tx, err := s.db.BeginEx(ctx, nil)
 	if err != nil {
 		return errors.Wrap(err, "failed to begin transaction")
@@ -60,68 +191,8 @@ func (s *PostgreSQLStore) withTransaction(ctx context.Context, fn func(tx *pgx.T
 	return nil
 }
 
-// breakdownRoster decomposes roster entries into usable data records
-func (s *PostgreSQLStore) breakdownRoster(pid uuid.UUID, r *Roster) (records []RosterEntry) {
-	records = make([]RosterEntry, len(r.Registry))
-
-	// for everyone
-	records = append(records, RosterEntry{
-		PolicyID:        pid,
-		ActorKind:       AKEveryone,
-		Access:          r.Everyone,
-		AccessExplained: r.Everyone.String(),
-	})
-
-	// breakdown
-	r.registryLock.RLock()
-	for _, _r := range r.Registry {
-		switch _r.Key.Kind {
-		case AKRoleGroup, AKGroup, AKUser:
-			records = append(records, RosterEntry{
-				PolicyID:        pid,
-				ActorKind:       _r.Key.Kind,
-				ActorID:         _r.Key.ID,
-				Access:          _r.Rights,
-				AccessExplained: _r.Rights.String(),
-			})
-		default:
-			log.Printf(
-				"unrecognized actor kind for accesspolicy policy: actor(kind=%s, id=%s), accesspolicy=(%s; %s)",
-				_r.Key.Kind,
-				_r.Key.ID,
-				_r.Rights,
-				_r.Rights.Translate(),
-			)
-		}
-	}
-	r.registryLock.RUnlock()
-
-	return records
-}
-
-func (s *PostgreSQLStore) buildRoster(records []RosterEntry) (r *Roster) {
-	r = NewRoster(len(records))
-
-	// transforming data records into the roster object
-	for _, _r := range records {
-		switch _r.ActorKind {
-		case AKEveryone:
-			r.Everyone = _r.Access
-		case AKRoleGroup, AKGroup, AKUser:
-			r.put(NewActor(_r.ActorKind, _r.ActorID), _r.Access)
-		default:
-			log.Printf(
-				"unrecognized actor kind for accesspolicy policy (actor_kind=%d, actor_id=%d, access_right=%d)",
-				_r.ActorKind,
-				_r.ActorID,
-				_r.Access,
-			)
-		}
-	}
-
-	return r
-}
-
+// applyRosterChanges persists the changes of a roster, each set entry is upserted,
+// and each unset entry is deleted rather than stored with no rights
 func (s *PostgreSQLStore) applyRosterChanges(tx *pgx.Tx, pid uuid.UUID, r *Roster) (err error) {
 	// checking whether the rights rosters has any changes
 	// TODO: optimize by squashing inserts and deletes into single queries
@@ -137,10 +208,10 @@ func (s *PostgreSQLStore) applyRosterChanges(tx *pgx.Tx, pid uuid.UUID, r *Roste
 			// creating
 			//---------------------------------------------------------------------------
 			q := `
-			INSERT INTO accesspolicy_roster(policy_id, actor_kind, actor_id, access, access_explained) 
-			VALUES ($1, $2, $3, $4, $5) 
+			INSERT INTO accesspolicy_roster(policy_id, actor_kind, actor_id, access, access_explained, denied, grantable, expires_at) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
 			ON CONFLICT ON CONSTRAINT accesspolicy_roster_pk
-			DO UPDATE SET access = $6`
+			DO UPDATE SET access = $4, access_explained = $5, denied = $6, grantable = $7, expires_at = $8`
 
 			_, err = tx.Exec(
 				q,
@@ -149,7 +220,9 @@ func (s *PostgreSQLStore) applyRosterChanges(tx *pgx.Tx, pid uuid.UUID, r *Roste
 				c.key.ID,
 				c.accessRight,
 				c.accessRight.String(),
-				c.accessRight,
+				c.denied,
+				c.grantable,
+				nullTime(c.expiresAt),
 			)
 
 			if err != nil {
@@ -169,17 +242,35 @@ func (s *PostgreSQLStore) applyRosterChanges(tx *pgx.Tx, pid uuid.UUID, r *Roste
 			if err != nil {
 				return errors.Wrap(err, "failed to delete policy roster entry")
 			}
+		default:
+			return errors.Wrapf(ErrUnrecognizedRosterAction, "action=%d", c.action)
 		}
 	}
 
 	return nil
 }
 
+// nullTime turns zero time into NULL
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
 func (s *PostgreSQLStore) onePolicy(ctx context.Context, q string, args ...interface{}) (p Policy, err error) {
-	row := s.db.QueryRowEx(ctx, q, nil, args...)
+	row := s.reader().QueryRowEx(ctx, q, nil, args...)
 
-	switch err = row.Scan(&p.ID, &p.ParentID, &p.OwnerID, &p.Key, &p.ObjectName, &p.ObjectID, &p.Flags); err {
+	switch err = row.Scan(&p.ID, &p.ParentID, &p.OwnerID, &p.Key, &p.ObjectName, &p.ObjectID, &p.Flags, &p.ScopeID, &p.Version, &p.DeletedAt); err {
 	case nil:
+		owners, err := s.fetchCoOwners(ctx, p.ID)
+		if err != nil {
+			return p, err
+		}
+
+		p.CoOwners = owners[p.ID]
+
 		return p, nil
 	case pgx.ErrNoRows:
 		return p, ErrPolicyNotFound
@@ -191,7 +282,7 @@ func (s *PostgreSQLStore) onePolicy(ctx context.Context, q string, args ...inter
 func (s *PostgreSQLStore) manyPolicies(ctx context.Context, q string, args ...interface{}) (gs []Policy, err error) {
 	gs = make([]Policy, 0)
 
-	rows, err := s.db.QueryEx(ctx, q, nil, args...)
+	rows, err := s.reader().QueryEx(ctx, q, nil, args...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch policies")
 	}
@@ -200,36 +291,117 @@ func (s *PostgreSQLStore) manyPolicies(ctx context.Context, q string, args ...in
 	for rows.Next() {
 		var p Policy
 
-		if err = rows.Scan(&p.ID, &p.ParentID, &p.OwnerID, &p.Key, &p.ObjectName, &p.ObjectID, &p.Flags); err != nil {
+		if err = rows.Scan(&p.ID, &p.ParentID, &p.OwnerID, &p.Key, &p.ObjectName, &p.ObjectID, &p.Flags, &p.ScopeID, &p.Version, &p.DeletedAt); err != nil {
 			return gs, errors.Wrap(err, "failed to scan policies")
 		}
 
 		gs = append(gs, p)
 	}
 
+	// releasing the connection before fetching co-owners
+	rows.Close()
+
+	pids := make([]uuid.UUID, len(gs))
+	for i := range gs {
+		pids[i] = gs[i].ID
+	}
+
+	owners, err := s.fetchCoOwners(ctx, pids...)
+	if err != nil {
+		return gs, err
+	}
+
+	for i := range gs {
+		gs[i].CoOwners = owners[gs[i].ID]
+	}
+
 	return gs, nil
 }
 
+// fetchCoOwners returns the co-owner sets of given policies at once, mapped
+// by policy ID, each one ordered by ID to keep the results stable
+func (s *PostgreSQLStore) fetchCoOwners(ctx context.Context, pids ...uuid.UUID) (owners map[uuid.UUID][]uuid.UUID, err error) {
+	owners = make(map[uuid.UUID][]uuid.UUID, len(pids))
+	if len(pids) == 0 {
+		return owners, nil
+	}
+
+	q := `
+	SELECT policy_id, owner_id
+	FROM accesspolicy_owner
+	WHERE policy_id IN (` + postgresPlaceholders(1, len(pids)) + `)
+	ORDER BY policy_id, owner_id`
+
+	rows, err := s.reader().QueryEx(ctx, q, nil, uuidArgs(pids)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch policy co-owners")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid, id uuid.UUID
+
+		if err = rows.Scan(&pid, &id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan policy co-owner")
+		}
+
+		owners[pid] = append(owners[pid], id)
+	}
+
+	return owners, rows.Err()
+}
+
+// insertCoOwners adds a given co-owner set to a policy
+func (s *PostgreSQLStore) insertCoOwners(ctx context.Context, tx *pgx.Tx, pid uuid.UUID, ids []uuid.UUID) error {
+	for _, id := range ids {
+		q := `
+		INSERT INTO accesspolicy_owner(policy_id, owner_id)
+		VALUES($1, $2)
+		ON CONFLICT ON CONSTRAINT accesspolicy_owner_pk
+		DO NOTHING`
+
+		if _, err := tx.ExecEx(ctx, q, nil, pid, id); err != nil {
+			return errors.Wrapf(err, "failed to insert policy co-owner: policy_id=%s, owner_id=%s", pid, id)
+		}
+	}
+
+	return nil
+}
+
+// replaceCoOwners overwrites the co-owner set of a policy
+func (s *PostgreSQLStore) replaceCoOwners(ctx context.Context, tx *pgx.Tx, pid uuid.UUID, ids []uuid.UUID) error {
+	if _, err := tx.ExecEx(ctx, `DELETE FROM accesspolicy_owner WHERE policy_id = $1`, nil, pid); err != nil {
+		return errors.Wrapf(err, "failed to delete policy co-owners: policy_id=%s", pid)
+	}
+
+	return s.insertCoOwners(ctx, tx, pid, ids)
+}
+
 func (s *PostgreSQLStore) CreatePolicy(ctx context.Context, p Policy, r *Roster) (Policy, *Roster, error) {
 	if p.ID == uuid.Nil {
 		return p, r, ErrNilPolicyID
 	}
 
+	// policies of a scoped store always belong to its scope
+	if s.scope != uuid.Nil {
+		p.ScopeID = s.scope
+	}
+
 	err := s.withTransaction(ctx, func(tx *pgx.Tx) error {
 		//---------------------------------------------------------------------------
 		// creating policy
 		//---------------------------------------------------------------------------
 		q := `
-		INSERT INTO  accesspolicy(id, parent_id, owner_id, key, object_name, object_id, flags) 
-		VALUES($1, $2, $3, $4, $5, $6, $7)
-		ON CONFLICT ON CONSTRAINT accesspolicy_pk
+		INSERT INTO  accesspolicy(id, parent_id, owner_id, key, object_name, object_id, flags, scope_id) 
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT ON CONSTRAINT accesspolicy_id_pk
 		DO NOTHING`
 
 		_, err := tx.ExecEx(
 			ctx,
 			q,
 			nil,
-			p.ID, p.ParentID, p.OwnerID, p.Key, p.ObjectName, p.ObjectID, p.Flags,
+			p.ID, p.ParentID, p.OwnerID, p.Key, p.ObjectName, p.ObjectID, p.Flags, p.ScopeID,
 		)
 
 		switch err {
@@ -253,10 +425,10 @@ func (s *PostgreSQLStore) CreatePolicy(ctx context.Context, p Policy, r *Roster)
 			r = NewRoster(0)
 		}
 
-		for _, _r := range s.breakdownRoster(p.ID, r) {
+		for _, _r := range breakdownRoster(p.ID, r) {
 			q := `
-			INSERT INTO accesspolicy_roster(policy_id, actor_kind, actor_id, access, access_explained) 
-			VALUES($1, $2, $3, $4, $5)
+			INSERT INTO accesspolicy_roster(policy_id, actor_kind, actor_id, access, access_explained, denied, grantable, expires_at) 
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8)
 			ON CONFLICT ON CONSTRAINT accesspolicy_roster_pk
 			DO NOTHING`
 
@@ -264,7 +436,7 @@ func (s *PostgreSQLStore) CreatePolicy(ctx context.Context, p Policy, r *Roster)
 				ctx,
 				q,
 				nil,
-				_r.PolicyID, _r.ActorKind, _r.ActorID, _r.Access, _r.AccessExplained,
+				_r.PolicyID, _r.ActorKind, _r.ActorID, _r.Access, _r.AccessExplained, _r.Denied, _r.Grantable, nullTime(_r.ExpiresAt),
 			)
 
 			if err != nil {
@@ -272,7 +444,10 @@ func (s *PostgreSQLStore) CreatePolicy(ctx context.Context, p Policy, r *Roster)
 			}
 		}
 
-		return nil
+		//---------------------------------------------------------------------------
+		// creating co-owner set
+		//---------------------------------------------------------------------------
+		return s.insertCoOwners(ctx, tx, p.ID, p.CoOwners)
 	})
 
 	return p, r, err
@@ -291,19 +466,21 @@ func (s *PostgreSQLStore) UpdatePolicy(ctx context.Context, p Policy, r *Roster)
 		//---------------------------------------------------------------------------
 		// updating accesspolicy policy and its rights rosters (only the changes)
 		//---------------------------------------------------------------------------
+		// NOTE: the version must be the same as when the policy was loaded
 		q := `
 		UPDATE accesspolicy 
 		SET
 			parent_id	= $1,
 			owner_id	= $2,
-			flags		= $3
-		WHERE id = $4`
+			flags		= $3,
+			version		= version + 1
+		WHERE id = $4 AND version = $5` + s.scopeCond(6)
 
 		cmd, err := tx.ExecEx(
 			ctx,
 			q,
 			nil,
-			p.ParentID, p.OwnerID, p.Flags, p.ID,
+			s.scopeArgs(p.ParentID, p.OwnerID, p.Flags, p.ID, p.Version)...,
 		)
 
 		if err != nil {
@@ -311,7 +488,12 @@ func (s *PostgreSQLStore) UpdatePolicy(ctx context.Context, p Policy, r *Roster)
 		}
 
 		if cmd.RowsAffected() == 0 {
-			return ErrNothingChanged
+			return errors.Wrapf(ErrStaleUpdate, "policy_id=%s, version=%d", p.ID, p.Version)
+		}
+
+		// the owner set is small, thus simply overwriting it
+		if err = s.replaceCoOwners(ctx, tx, p.ID, p.CoOwners); err != nil {
+			return err
 		}
 
 		// applying roster changes to the data
@@ -331,39 +513,218 @@ func (s *PostgreSQLStore) UpdatePolicy(ctx context.Context, p Policy, r *Roster)
 
 func (s *PostgreSQLStore) FetchPolicyByID(ctx context.Context, id uuid.UUID) (Policy, error) {
 	q := `
-	SELECT id, parent_id, owner_id, key, object_name, object_id, flags 
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at 
 	FROM accesspolicy 
-	WHERE id = $1
+	WHERE id = $1` + s.scopeCond(2) + `
 	LIMIT 1`
 
-	return s.onePolicy(ctx, q, id)
+	p, err := s.onePolicy(ctx, q, s.scopeArgs(id)...)
+	if ps := s.primary(); ps != nil && err == ErrPolicyNotFound {
+		return ps.FetchPolicyByID(ctx, id)
+	}
+
+	return p, err
+}
+
+// FetchPoliciesByIDs returns the policies of given IDs at once,
+// the ones which don't exist are omitted
+func (s *PostgreSQLStore) FetchPoliciesByIDs(ctx context.Context, ids []uuid.UUID) ([]Policy, error) {
+	if len(ids) == 0 {
+		return make([]Policy, 0), nil
+	}
+
+	q := `
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at 
+	FROM accesspolicy 
+	WHERE id IN (` + postgresPlaceholders(1, len(ids)) + `)` + s.scopeCond(len(ids)+1)
+
+	return s.manyPolicies(ctx, q, s.scopeArgs(uuidArgs(ids)...)...)
 }
 
 func (s *PostgreSQLStore) FetchPolicyByKey(ctx context.Context, key string) (p Policy, err error) {
 	q := `
-	SELECT id, parent_id, owner_id, key, object_name, object_id, flags 
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at 
 	FROM accesspolicy 
-	WHERE key = $1
+	WHERE key = $1` + s.scopeCond(2) + `
+	ORDER BY deleted_at IS NOT NULL, deleted_at DESC
 	LIMIT 1`
 
-	return s.onePolicy(ctx, q, key)
+	p, err = s.onePolicy(ctx, q, s.scopeArgs(key)...)
+	if ps := s.primary(); ps != nil && err == ErrPolicyNotFound {
+		return ps.FetchPolicyByKey(ctx, key)
+	}
+
+	return p, err
 }
 
 func (s *PostgreSQLStore) FetchPolicyByObject(ctx context.Context, obj Object) (p Policy, err error) {
 	q := `
-	SELECT id, parent_id, owner_id, key, object_name, object_id, flags 
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at 
 	FROM accesspolicy 
 	WHERE 
 		object_name		= $1 
-		AND object_id	= $2
+		AND object_id	= $2` + s.scopeCond(3) + `
+	ORDER BY deleted_at IS NOT NULL, deleted_at DESC
 	LIMIT 1`
 
-	return s.onePolicy(ctx, q, obj.Name, obj.ID)
+	p, err = s.onePolicy(ctx, q, s.scopeArgs(obj.Name, obj.ID)...)
+	if ps := s.primary(); ps != nil && err == ErrPolicyNotFound {
+		return ps.FetchPolicyByObject(ctx, obj)
+	}
+
+	return p, err
+}
+
+func (s *PostgreSQLStore) exists(ctx context.Context, q string, args ...interface{}) (exists bool, err error) {
+	if err = s.reader().QueryRowEx(ctx, q, nil, args...).Scan(&exists); err != nil {
+		return false, errors.Wrap(err, "failed to check policy existence")
+	}
+
+	return exists, nil
+}
+
+func (s *PostgreSQLStore) HasPolicy(ctx context.Context, id uuid.UUID) (bool, error) {
+	ok, err := s.exists(ctx, `SELECT EXISTS(SELECT 1 FROM accesspolicy WHERE id = $1 AND deleted_at IS NULL`+s.scopeCond(2)+`)`, s.scopeArgs(id)...)
+	if ps := s.primary(); ps != nil && err == nil && !ok {
+		return ps.HasPolicy(ctx, id)
+	}
+
+	return ok, err
+}
+
+func (s *PostgreSQLStore) HasChildPolicies(ctx context.Context, pid uuid.UUID) (bool, error) {
+	return s.exists(ctx, `SELECT EXISTS(SELECT 1 FROM accesspolicy WHERE parent_id = $1 AND deleted_at IS NULL`+s.scopeCond(2)+`)`, s.scopeArgs(pid)...)
+}
+
+func (s *PostgreSQLStore) HasPolicyByKey(ctx context.Context, key string) (bool, error) {
+	ok, err := s.exists(ctx, `SELECT EXISTS(SELECT 1 FROM accesspolicy WHERE key = $1 AND deleted_at IS NULL`+s.scopeCond(2)+`)`, s.scopeArgs(key)...)
+	if ps := s.primary(); ps != nil && err == nil && !ok {
+		return ps.HasPolicyByKey(ctx, key)
+	}
+
+	return ok, err
+}
+
+func (s *PostgreSQLStore) FetchObjectNames(ctx context.Context) (names []string, err error) {
+	q := `
+	SELECT DISTINCT object_name 
+	FROM accesspolicy 
+	WHERE object_name <> '' AND deleted_at IS NULL` + s.scopeCond(1) + `
+	ORDER BY object_name`
+
+	rows, err := s.reader().QueryEx(ctx, q, nil, s.scopeArgs()...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch object names")
+	}
+	defer rows.Close()
+
+	names = make([]string, 0)
+	for rows.Next() {
+		var name string
+
+		if err = rows.Scan(&name); err != nil {
+			return nil, errors.Wrap(err, "failed to scan object name")
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// FetchPoliciesByObjectName returns a page of policies which protect objects
+// of a given name, ordered by object ID, non-positive limit means no limit
+func (s *PostgreSQLStore) FetchPoliciesByObjectName(ctx context.Context, name string, limit, offset int, includeArchived bool) ([]Policy, error) {
+	args := s.scopeArgs(name)
+
+	q := `
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at 
+	FROM accesspolicy 
+	WHERE object_name = $1 AND deleted_at IS NULL` + archivedCond(includeArchived) + s.scopeCond(2) + fmt.Sprintf(`
+	ORDER BY object_id, id
+	LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+
+	// NULL limit is the same as no limit at all
+	var lim interface{}
+	if limit > 0 {
+		lim = limit
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.manyPolicies(ctx, q, append(args, lim, offset)...)
+}
+
+func (s *PostgreSQLStore) FetchPolicyIDsByActor(ctx context.Context, actor Actor) (ids []uuid.UUID, err error) {
+	q := `
+	SELECT DISTINCT r.policy_id 
+	FROM accesspolicy_roster r
+	INNER JOIN accesspolicy p ON p.id = r.policy_id 
+	WHERE 
+		r.actor_kind	= $1 
+		AND r.actor_id	= $2
+		AND p.deleted_at IS NULL` + s.scopeCond(3)
+
+	rows, err := s.reader().QueryEx(ctx, q, nil, s.scopeArgs(actor.Kind, actor.ID)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch policy ids by actor")
+	}
+	defer rows.Close()
+
+	ids = make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+
+		if err = rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan policy id")
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (s *PostgreSQLStore) CountPoliciesByOwner(ctx context.Context, ownerID uuid.UUID, includeArchived bool) (n int, err error) {
+	q := `SELECT COUNT(*) FROM accesspolicy WHERE owner_id = $1 AND deleted_at IS NULL` + archivedCond(includeArchived) + s.scopeCond(2)
+
+	if err = s.conn().QueryRowEx(ctx, q, nil, s.scopeArgs(ownerID)...).Scan(&n); err != nil {
+		return 0, errors.Wrap(err, "failed to count policies by owner")
+	}
+
+	return n, nil
+}
+
+// ListPoliciesByOwner returns a page of policies owned by a given owner,
+// ordered by key and ID, non-positive limit means no limit
+func (s *PostgreSQLStore) ListPoliciesByOwner(ctx context.Context, ownerID uuid.UUID, limit, offset int, includeArchived bool) ([]Policy, error) {
+	args := s.scopeArgs(ownerID)
+
+	q := `
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at 
+	FROM accesspolicy 
+	WHERE owner_id = $1 AND deleted_at IS NULL` + archivedCond(includeArchived) + s.scopeCond(2) + fmt.Sprintf(`
+	ORDER BY key, id
+	LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+
+	// NULL limit is the same as no limit at all
+	var lim interface{}
+	if limit > 0 {
+		lim = limit
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.manyPolicies(ctx, q, append(args, lim, offset)...)
 }
 
 func (s *PostgreSQLStore) DeletePolicy(ctx context.Context, p Policy) error {
 	return s.withTransaction(ctx, func(tx *pgx.Tx) error {
-		cmd, err := tx.ExecEx(ctx, `DELETE FROM accesspolicy WHERE id = $1`, nil, p.ID)
+		cmd, err := tx.ExecEx(ctx, `DELETE FROM accesspolicy WHERE id = $1`+s.scopeCond(2), nil, s.scopeArgs(p.ID)...)
 		if err != nil {
 			return errors.Wrap(err, "failed to delete policy")
 		}
@@ -377,18 +738,44 @@ func (s *PostgreSQLStore) DeletePolicy(ctx context.Context, p Policy) error {
 			return errors.Wrap(err, "failed to delete policy roster")
 		}
 
+		_, err = tx.ExecEx(ctx, `DELETE FROM accesspolicy_owner WHERE policy_id = $1`, nil, p.ID)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete policy co-owners")
+		}
+
 		return nil
 	})
 }
 
+// SetPolicyDeletedAt marks a policy as soft-deleted at a given time,
+// nil restores it
+func (s *PostgreSQLStore) SetPolicyDeletedAt(ctx context.Context, pid uuid.UUID, deletedAt *time.Time) error {
+	q := `UPDATE accesspolicy SET deleted_at = $1, version = version + 1 WHERE id = $2` + s.scopeCond(3)
+
+	cmd, err := s.conn().ExecEx(ctx, q, nil, s.scopeArgs(deletedAt, pid)...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set policy deletion time: policy_id=%s", pid)
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrNothingChanged
+	}
+
+	return nil
+}
+
 func (s *PostgreSQLStore) CreateRoster(ctx context.Context, policyID uuid.UUID, r *Roster) error {
+	if err := s.checkScope(ctx, policyID); err != nil {
+		return err
+	}
+
 	return s.withTransaction(ctx, func(tx *pgx.Tx) error {
 		// looping over rights rosters to be created
 		// TODO: squash into a single insert statement
-		for _, _r := range s.breakdownRoster(policyID, r) {
+		for _, _r := range breakdownRoster(policyID, r) {
 			q := `
-			INSERT INTO accesspolicy_roster(policy_id, actor_kind, actor_id, access, access_explained) 
-			VALUES($1, $2, $3, $4, $5)
+			INSERT INTO accesspolicy_roster(policy_id, actor_kind, actor_id, access, access_explained, denied, grantable, expires_at) 
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8)
 			ON CONFLICT ON CONSTRAINT policy_roster_policy_id_subject_kind_subject_id_uindex
 			DO NOTHING`
 
@@ -396,7 +783,7 @@ func (s *PostgreSQLStore) CreateRoster(ctx context.Context, policyID uuid.UUID,
 				ctx,
 				q,
 				nil,
-				_r.ActorID, _r.PolicyID, _r.ActorKind, _r.ActorID, _r.Access, _r.AccessExplained,
+				_r.PolicyID, _r.ActorKind, _r.ActorID, _r.Access, _r.AccessExplained, _r.Denied, _r.Grantable, nullTime(_r.ExpiresAt),
 			)
 
 			if err != nil {
@@ -409,39 +796,91 @@ func (s *PostgreSQLStore) CreateRoster(ctx context.Context, policyID uuid.UUID,
 }
 
 func (s *PostgreSQLStore) FetchRosterByPolicyID(ctx context.Context, pid uuid.UUID) (*Roster, error) {
+	if err := s.checkScope(ctx, pid); err != nil {
+		return nil, err
+	}
+
 	q := `
-	SELECT policy_id, actor_kind, actor_id, access, access_explained
+	SELECT policy_id, actor_kind, actor_id, access, access_explained, denied, grantable, expires_at
 	FROM accesspolicy_roster 
 	WHERE policy_id = $1`
 
-	rows, err := s.db.QueryEx(ctx, q, nil, pid)
+	entries, err := s.rosterEntries(ctx, q, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		if ps := s.primary(); ps != nil {
+			return ps.FetchRosterByPolicyID(ctx, pid)
+		}
+
+		return nil, ErrEmptyRoster
+	}
+
+	return buildRoster(entries), nil
+}
+
+// FetchRostersByPolicyIDs returns the rosters of given policies at once,
+// mapped by policy ID, the policies without any roster entries are omitted
+func (s *PostgreSQLStore) FetchRostersByPolicyIDs(ctx context.Context, pids []uuid.UUID) (map[uuid.UUID]*Roster, error) {
+	rosters := make(map[uuid.UUID]*Roster)
+
+	if len(pids) == 0 {
+		return rosters, nil
+	}
+
+	q := `
+	SELECT r.policy_id, r.actor_kind, r.actor_id, r.access, r.access_explained, r.denied, r.grantable, r.expires_at
+	FROM accesspolicy_roster r
+	INNER JOIN accesspolicy p ON p.id = r.policy_id 
+	WHERE r.policy_id IN (` + postgresPlaceholders(1, len(pids)) + `)` + s.scopeCond(len(pids)+1)
+
+	entries, err := s.rosterEntries(ctx, q, s.scopeArgs(uuidArgs(pids)...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	for pid, records := range groupRosterEntries(entries) {
+		rosters[pid] = buildRoster(records)
+	}
+
+	return rosters, nil
+}
+
+func (s *PostgreSQLStore) rosterEntries(ctx context.Context, q string, args ...interface{}) ([]RosterEntry, error) {
+	rows, err := s.reader().QueryEx(ctx, q, nil, args...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch policy roster")
 	}
+	defer rows.Close()
 
 	// container
 	entries := make([]RosterEntry, 0)
 
-	count := 0
 	for rows.Next() {
 		var re RosterEntry
+		var expiresAt *time.Time
 
-		if err = rows.Scan(&re.PolicyID, &re.ActorKind, &re.ActorID, &re.Access, &re.AccessExplained); err != nil {
+		if err = rows.Scan(&re.PolicyID, &re.ActorKind, &re.ActorID, &re.Access, &re.AccessExplained, &re.Denied, &re.Grantable, &expiresAt); err != nil {
 			return nil, errors.Wrap(err, "failed to scan policy roster")
 		}
 
-		entries = append(entries, re)
-		count++
-	}
+		if expiresAt != nil {
+			re.ExpiresAt = *expiresAt
+		}
 
-	if count == 0 {
-		return nil, ErrEmptyRoster
+		entries = append(entries, re)
 	}
 
-	return s.buildRoster(entries), nil
+	return entries, rows.Err()
 }
 
 func (s *PostgreSQLStore) UpdateRoster(ctx context.Context, pid uuid.UUID, r *Roster) (err error) {
+	if err = s.checkScope(ctx, pid); err != nil {
+		return err
+	}
+
 	return s.withTransaction(ctx, func(tx *pgx.Tx) error {
 		if err = s.applyRosterChanges(tx, pid, r); err != nil {
 			return errors.Wrap(err, "failed to apply accesspolicy policy roster changes during roster update")
@@ -452,6 +891,10 @@ func (s *PostgreSQLStore) UpdateRoster(ctx context.Context, pid uuid.UUID, r *Ro
 }
 
 func (s *PostgreSQLStore) DeleteRoster(ctx context.Context, pid uuid.UUID) (err error) {
+	if err = s.checkScope(ctx, pid); err != nil {
+		return err
+	}
+
 	return s.withTransaction(ctx, func(tx *pgx.Tx) error {
 		cmd, err := tx.ExecEx(ctx, `DELETE FROM accesspolicy_roster WHERE policy_id = $1`, nil, pid)
 		if err != nil {
@@ -465,3 +908,194 @@ func (s *PostgreSQLStore) DeleteRoster(ctx context.Context, pid uuid.UUID) (err
 		return nil
 	})
 }
+
+func (s *PostgreSQLStore) CreateAccessRequest(ctx context.Context, ar AccessRequest) (err error) {
+	if err = s.checkScope(ctx, ar.PolicyID); err != nil {
+		return err
+	}
+
+	q := `
+	INSERT INTO accesspolicy_request(id, policy_id, requester_kind, requester_id, rights, justification, status, created_at) 
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err = s.conn().ExecEx(
+		ctx,
+		q,
+		nil,
+		ar.ID, ar.PolicyID, ar.Requester.Kind, ar.Requester.ID, ar.Rights, ar.Justification, ar.Status, ar.CreatedAt,
+	)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to insert access request")
+	}
+
+	return nil
+}
+
+func (s *PostgreSQLStore) FetchAccessRequestByID(ctx context.Context, id uuid.UUID) (ar AccessRequest, err error) {
+	q := `
+	SELECT r.id, r.policy_id, r.requester_kind, r.requester_id, r.rights, r.justification, r.status, 
+		r.resolver_kind, r.resolver_id, r.created_at, r.resolved_at
+	FROM accesspolicy_request r
+	INNER JOIN accesspolicy p ON p.id = r.policy_id
+	WHERE r.id = $1` + s.scopeCond(2) + `
+	LIMIT 1`
+
+	rows, err := s.reader().QueryEx(ctx, q, nil, s.scopeArgs(id)...)
+	if err != nil {
+		return ar, errors.Wrap(err, "failed to fetch access request")
+	}
+	defer rows.Close()
+
+	ars, err := s.scanAccessRequests(rows)
+	if err != nil {
+		return ar, err
+	}
+
+	if len(ars) == 0 {
+		if ps := s.primary(); ps != nil {
+			return ps.FetchAccessRequestByID(ctx, id)
+		}
+
+		return ar, ErrAccessRequestNotFound
+	}
+
+	return ars[0], nil
+}
+
+func (s *PostgreSQLStore) FetchPendingAccessRequests(ctx context.Context, pid uuid.UUID) (ars []AccessRequest, err error) {
+	if err = s.checkScope(ctx, pid); err != nil {
+		return nil, err
+	}
+
+	q := `
+	SELECT id, policy_id, requester_kind, requester_id, rights, justification, status, 
+		resolver_kind, resolver_id, created_at, resolved_at
+	FROM accesspolicy_request
+	WHERE 
+		policy_id	= $1
+		AND status	= $2
+	ORDER BY created_at`
+
+	rows, err := s.reader().QueryEx(ctx, q, nil, pid, RSPending)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch pending access requests")
+	}
+	defer rows.Close()
+
+	return s.scanAccessRequests(rows)
+}
+
+func (s *PostgreSQLStore) scanAccessRequests(rows *pgx.Rows) (ars []AccessRequest, err error) {
+	ars = make([]AccessRequest, 0)
+
+	for rows.Next() {
+		var ar AccessRequest
+		var resolvedAt *time.Time
+
+		err = rows.Scan(
+			&ar.ID, &ar.PolicyID, &ar.Requester.Kind, &ar.Requester.ID, &ar.Rights, &ar.Justification, &ar.Status,
+			&ar.ResolvedBy.Kind, &ar.ResolvedBy.ID, &ar.CreatedAt, &resolvedAt,
+		)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan access request")
+		}
+
+		if resolvedAt != nil {
+			ar.ResolvedAt = *resolvedAt
+		}
+
+		ars = append(ars, ar)
+	}
+
+	return ars, rows.Err()
+}
+
+func (s *PostgreSQLStore) UpdateAccessRequest(ctx context.Context, ar AccessRequest) error {
+	q := `
+	UPDATE accesspolicy_request r
+	SET 
+		status			= $1, 
+		resolver_kind	= $2, 
+		resolver_id		= $3, 
+		resolved_at		= $4
+	FROM accesspolicy p
+	WHERE p.id = r.policy_id AND r.id = $5` + s.scopeCond(6)
+
+	cmd, err := s.conn().ExecEx(
+		ctx,
+		q,
+		nil,
+		s.scopeArgs(ar.Status, ar.ResolvedBy.Kind, ar.ResolvedBy.ID, ar.ResolvedAt, ar.ID)...,
+	)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to update access request")
+	}
+
+	if cmd.RowsAffected() == 0 {
+		return ErrAccessRequestNotFound
+	}
+
+	return nil
+}
+
+func (s *PostgreSQLStore) DeleteExpiredRosterEntries(ctx context.Context, now time.Time) (n int64, err error) {
+	q := `
+	DELETE FROM accesspolicy_roster r
+	USING accesspolicy p
+	WHERE 
+		p.id = r.policy_id
+		AND r.expires_at IS NOT NULL 
+		AND r.expires_at <= $1` + s.scopeCond(2)
+
+	cmd, err := s.conn().ExecEx(ctx, q, nil, s.scopeArgs(now)...)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete expired roster entries")
+	}
+
+	return cmd.RowsAffected(), nil
+}
+
+func (s *PostgreSQLStore) SetActorSuspended(ctx context.Context, userID uuid.UUID, suspended bool) (err error) {
+	if suspended {
+		_, err = s.conn().ExecEx(
+			ctx,
+			`INSERT INTO accesspolicy_suspension(actor_id, suspended_at) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			nil,
+			userID, time.Now(),
+		)
+	} else {
+		_, err = s.conn().ExecEx(ctx, `DELETE FROM accesspolicy_suspension WHERE actor_id = $1`, nil, userID)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed to update actor suspension")
+	}
+
+	return nil
+}
+
+// FetchSuspendedActors reads from the primary, so that a suspension takes
+// effect as soon as the managers reload it, regardless of the replication lag
+func (s *PostgreSQLStore) FetchSuspendedActors(ctx context.Context) (ids []uuid.UUID, err error) {
+	rows, err := s.conn().QueryEx(ctx, `SELECT actor_id FROM accesspolicy_suspension`, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch suspended actors")
+	}
+	defer rows.Close()
+
+	ids = make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+
+		if err = rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan suspended actor id")
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,928 @@
+package accesspolicy
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// SQLiteStore is a dependency-free store, meant for embedded
+// deployments and tests, see database.SQLiteSchema for its schema
+// NOTE: scopes are not supported
+type SQLiteStore struct {
+	db      *sql.DB
+	replica *sql.DB
+	tx      *sql.Tx
+}
+
+// sqlQueryer is implemented by both the database and a transaction
+type sqlQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func NewSQLiteStore(db *sql.DB) (Store, error) {
+	if db == nil {
+		return nil, ErrNilDatabase
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// NewSQLiteStoreWithReplica returns a store which writes to the primary
+// and reads from the replica, falling back to the primary whenever the row
+// which is looked up isn't found on the replica, as it may lag behind
+// NOTE: reads within a transaction, as well as the ones which precede writes
+// and have to be up to date (i.e. the owner quota), always use the primary
+func NewSQLiteStoreWithReplica(primary, replica *sql.DB) (Store, error) {
+	if primary == nil || replica == nil {
+		return nil, ErrNilDatabase
+	}
+
+	return &SQLiteStore{db: primary, replica: replica}, nil
+}
+
+// conn returns the transaction this store is bound to, if any
+// NOTE: everything within a transaction must go through it, because
+// the database is limited to a single connection
+func (s *SQLiteStore) conn() sqlQueryer {
+	if s.tx != nil {
+		return s.tx
+	}
+
+	return s.db
+}
+
+// reader returns the replica for the reads outside of a transaction, if it's set
+func (s *SQLiteStore) reader() sqlQueryer {
+	if s.tx == nil && s.replica != nil {
+		return s.replica
+	}
+
+	return s.conn()
+}
+
+// primary returns a copy of this store which reads from the primary,
+// nil if this store reads from the primary already
+func (s *SQLiteStore) primary() *SQLiteStore {
+	if s.tx != nil || s.replica == nil {
+		return nil
+	}
+
+	return &SQLiteStore{db: s.db}
+}
+
+// WithTx runs a given function against a copy of this store which is bound
+// to a single transaction, committing it only if the function succeeds
+// NOTE: nested calls join the transaction which is already in progress
+func (s *SQLiteStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	return s.withTransaction(ctx, func(tx *sql.Tx) error {
+		return fn(&SQLiteStore{db: s.db, tx: tx})
+	})
+}
+
+func (s *SQLiteStore) withTransaction(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	// the transaction in progress is committed by whoever has started it
+	if s.tx != nil {
+		if err = fn(s.tx); err != nil && err != ErrNothingChanged {
+			return errors.Wrap(err, "transaction failed")
+		}
+
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+
+	// applying function
+	if err = fn(tx); err != nil && err != ErrNothingChanged {
+		if txerr := tx.Rollback(); txerr != nil {
+			err = errors.Wrapf(err, "failed to rollback transaction: %s", txerr)
+		}
+
+		return errors.Wrap(err, "transaction failed")
+	}
+
+	// committing transaction
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit transaction")
+	}
+
+	return nil
+}
+
+// sqliteTime turns zero time into NULL, and keeps the rest in UTC,
+// so that stored time values compare in chronological order
+func sqliteTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.UTC()
+}
+
+// sqlitePlaceholders returns a list of n query arguments
+func sqlitePlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func (s *SQLiteStore) insertRosterEntries(ctx context.Context, tx *sql.Tx, pid uuid.UUID, r *Roster) error {
+	q := `
+	INSERT INTO accesspolicy_roster(policy_id, actor_kind, actor_id, access, access_explained, denied, grantable, expires_at)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(policy_id, actor_kind, actor_id)
+	DO NOTHING`
+
+	for _, re := range breakdownRoster(pid, r) {
+		// skipping blank cells
+		if re.ActorKind == 0 {
+			continue
+		}
+
+		_, err := tx.ExecContext(
+			ctx,
+			q,
+			re.PolicyID, re.ActorKind, re.ActorID, re.Access, re.AccessExplained, re.Denied, re.Grantable, sqliteTime(re.ExpiresAt),
+		)
+
+		if err != nil {
+			return errors.Wrap(err, "failed to execute insert roster entry")
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) applyRosterChanges(ctx context.Context, tx *sql.Tx, pid uuid.UUID, r *Roster) (err error) {
+	for _, c := range r.changes {
+		// actor Name must not be NIL for any other than Public actor kind
+		if c.key.Kind != AKEveryone && c.key.ID == uuid.Nil {
+			return ErrNilActorID
+		}
+
+		switch c.action {
+		case RSet:
+			q := `
+			INSERT INTO accesspolicy_roster(policy_id, actor_kind, actor_id, access, access_explained, denied, grantable, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(policy_id, actor_kind, actor_id)
+			DO UPDATE SET
+				access				= excluded.access,
+				access_explained	= excluded.access_explained,
+				denied				= excluded.denied,
+				grantable			= excluded.grantable,
+				expires_at			= excluded.expires_at`
+
+			_, err = tx.ExecContext(
+				ctx,
+				q,
+				pid, c.key.Kind, c.key.ID, c.accessRight, c.accessRight.String(), c.denied, c.grantable, sqliteTime(c.expiresAt),
+			)
+
+			if err != nil {
+				return errors.Wrap(err, "failed to upsert policy roster entry")
+			}
+		case RUnset:
+			_, err = tx.ExecContext(
+				ctx,
+				"DELETE FROM accesspolicy_roster WHERE policy_id = ? AND actor_kind = ? AND actor_id = ?",
+				pid, c.key.Kind, c.key.ID,
+			)
+
+			if err != nil {
+				return errors.Wrap(err, "failed to delete policy roster entry")
+			}
+		default:
+			return errors.Wrapf(ErrUnrecognizedRosterAction, "action=%d", c.action)
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) onePolicy(ctx context.Context, q string, args ...interface{}) (p Policy, err error) {
+	row := s.reader().QueryRowContext(ctx, q, args...)
+
+	switch err = row.Scan(&p.ID, &p.ParentID, &p.OwnerID, &p.Key, &p.ObjectName, &p.ObjectID, &p.Flags, &p.ScopeID, &p.Version, &p.DeletedAt); err {
+	case nil:
+		owners, err := s.fetchCoOwners(ctx, p.ID)
+		if err != nil {
+			return p, err
+		}
+
+		p.CoOwners = owners[p.ID]
+
+		return p, nil
+	case sql.ErrNoRows:
+		return p, ErrPolicyNotFound
+	default:
+		return p, errors.Wrap(err, "failed to scan policy")
+	}
+}
+
+// fetchCoOwners returns the co-owner sets of given policies at once, mapped
+// by policy ID, each one ordered by ID to keep the results stable
+func (s *SQLiteStore) fetchCoOwners(ctx context.Context, pids ...uuid.UUID) (owners map[uuid.UUID][]uuid.UUID, err error) {
+	owners = make(map[uuid.UUID][]uuid.UUID, len(pids))
+	if len(pids) == 0 {
+		return owners, nil
+	}
+
+	q := `
+	SELECT policy_id, owner_id
+	FROM accesspolicy_owner
+	WHERE policy_id IN (` + sqlitePlaceholders(len(pids)) + `)
+	ORDER BY policy_id, owner_id`
+
+	rows, err := s.reader().QueryContext(ctx, q, uuidArgs(pids)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch policy co-owners")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid, id uuid.UUID
+
+		if err = rows.Scan(&pid, &id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan policy co-owner")
+		}
+
+		owners[pid] = append(owners[pid], id)
+	}
+
+	return owners, rows.Err()
+}
+
+// insertCoOwners adds a given co-owner set to a policy
+func (s *SQLiteStore) insertCoOwners(ctx context.Context, tx *sql.Tx, pid uuid.UUID, ids []uuid.UUID) error {
+	for _, id := range ids {
+		q := `
+		INSERT INTO accesspolicy_owner(policy_id, owner_id)
+		VALUES(?, ?)
+		ON CONFLICT
+		DO NOTHING`
+
+		if _, err := tx.ExecContext(ctx, q, pid, id); err != nil {
+			return errors.Wrapf(err, "failed to insert policy co-owner: policy_id=%s, owner_id=%s", pid, id)
+		}
+	}
+
+	return nil
+}
+
+// replaceCoOwners overwrites the co-owner set of a policy
+func (s *SQLiteStore) replaceCoOwners(ctx context.Context, tx *sql.Tx, pid uuid.UUID, ids []uuid.UUID) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM accesspolicy_owner WHERE policy_id = ?`, pid); err != nil {
+		return errors.Wrapf(err, "failed to delete policy co-owners: policy_id=%s", pid)
+	}
+
+	return s.insertCoOwners(ctx, tx, pid, ids)
+}
+
+func (s *SQLiteStore) CreatePolicy(ctx context.Context, p Policy, r *Roster) (Policy, *Roster, error) {
+	if p.ID == uuid.Nil {
+		return p, r, ErrNilPolicyID
+	}
+
+	err := s.withTransaction(ctx, func(tx *sql.Tx) error {
+		q := `
+		INSERT INTO accesspolicy(id, parent_id, owner_id, key, object_name, object_id, flags, scope_id)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`
+
+		_, err := tx.ExecContext(
+			ctx,
+			q,
+			p.ID, p.ParentID, p.OwnerID, p.Key, p.ObjectName, p.ObjectID, p.Flags, p.ScopeID,
+		)
+
+		if err != nil {
+			return errors.Wrap(err, "failed to execute insert policy")
+		}
+
+		if err = s.insertCoOwners(ctx, tx, p.ID, p.CoOwners); err != nil {
+			return err
+		}
+
+		if r == nil {
+			r = NewRoster(0)
+		}
+
+		return s.insertRosterEntries(ctx, tx, p.ID, r)
+	})
+
+	return p, r, err
+}
+
+func (s *SQLiteStore) UpdatePolicy(ctx context.Context, p Policy, r *Roster) (err error) {
+	if p.ID == uuid.Nil {
+		return ErrNilPolicyID
+	}
+
+	err = s.withTransaction(ctx, func(tx *sql.Tx) error {
+		// the version must be the same as when the policy was loaded
+		res, err := tx.ExecContext(
+			ctx,
+			`UPDATE accesspolicy SET parent_id = ?, owner_id = ?, flags = ?, version = version + 1 WHERE id = ? AND version = ?`,
+			p.ParentID, p.OwnerID, p.Flags, p.ID, p.Version,
+		)
+
+		if err != nil {
+			return errors.Wrapf(err, "failed to execute update policy: policy_id=%s", p.ID)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return errors.Wrapf(err, "failed to execute update policy: policy_id=%s", p.ID)
+		}
+
+		if n == 0 {
+			return errors.Wrapf(ErrStaleUpdate, "policy_id=%s, version=%d", p.ID, p.Version)
+		}
+
+		// the owner set is small, thus simply overwriting it
+		if err = s.replaceCoOwners(ctx, tx, p.ID, p.CoOwners); err != nil {
+			return err
+		}
+
+		if err = s.applyRosterChanges(ctx, tx, p.ID, r); err != nil {
+			return errors.Wrapf(err, "failed to apply accesspolicy policy roster changes during policy update: policy_id=%s", p.ID)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed to update policy")
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) FetchPolicyByID(ctx context.Context, id uuid.UUID) (Policy, error) {
+	q := `
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at
+	FROM accesspolicy
+	WHERE id = ?
+	LIMIT 1`
+
+	p, err := s.onePolicy(ctx, q, id)
+	if ps := s.primary(); ps != nil && err == ErrPolicyNotFound {
+		return ps.FetchPolicyByID(ctx, id)
+	}
+
+	return p, err
+}
+
+// FetchPoliciesByIDs returns the policies of given IDs at once,
+// the ones which don't exist are omitted
+func (s *SQLiteStore) FetchPoliciesByIDs(ctx context.Context, ids []uuid.UUID) ([]Policy, error) {
+	if len(ids) == 0 {
+		return make([]Policy, 0), nil
+	}
+
+	q := `
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at
+	FROM accesspolicy
+	WHERE id IN (` + sqlitePlaceholders(len(ids)) + `)`
+
+	return s.manyPolicies(ctx, q, uuidArgs(ids)...)
+}
+
+func (s *SQLiteStore) FetchPolicyByKey(ctx context.Context, key string) (p Policy, err error) {
+	q := `
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at
+	FROM accesspolicy
+	WHERE key = ?
+	ORDER BY deleted_at IS NOT NULL, deleted_at DESC
+	LIMIT 1`
+
+	p, err = s.onePolicy(ctx, q, key)
+	if ps := s.primary(); ps != nil && err == ErrPolicyNotFound {
+		return ps.FetchPolicyByKey(ctx, key)
+	}
+
+	return p, err
+}
+
+func (s *SQLiteStore) FetchPolicyByObject(ctx context.Context, obj Object) (p Policy, err error) {
+	q := `
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at
+	FROM accesspolicy
+	WHERE
+		object_name		= ?
+		AND object_id	= ?
+	ORDER BY deleted_at IS NOT NULL, deleted_at DESC
+	LIMIT 1`
+
+	p, err = s.onePolicy(ctx, q, obj.Name, obj.ID)
+	if ps := s.primary(); ps != nil && err == ErrPolicyNotFound {
+		return ps.FetchPolicyByObject(ctx, obj)
+	}
+
+	return p, err
+}
+
+func (s *SQLiteStore) exists(ctx context.Context, q string, args ...interface{}) (exists bool, err error) {
+	if err = s.reader().QueryRowContext(ctx, q, args...).Scan(&exists); err != nil {
+		return false, errors.Wrap(err, "failed to check policy existence")
+	}
+
+	return exists, nil
+}
+
+func (s *SQLiteStore) HasPolicy(ctx context.Context, id uuid.UUID) (bool, error) {
+	ok, err := s.exists(ctx, `SELECT EXISTS(SELECT 1 FROM accesspolicy WHERE id = ? AND deleted_at IS NULL)`, id)
+	if ps := s.primary(); ps != nil && err == nil && !ok {
+		return ps.HasPolicy(ctx, id)
+	}
+
+	return ok, err
+}
+
+func (s *SQLiteStore) HasChildPolicies(ctx context.Context, pid uuid.UUID) (bool, error) {
+	return s.exists(ctx, `SELECT EXISTS(SELECT 1 FROM accesspolicy WHERE parent_id = ? AND deleted_at IS NULL)`, pid)
+}
+
+func (s *SQLiteStore) HasPolicyByKey(ctx context.Context, key string) (bool, error) {
+	ok, err := s.exists(ctx, `SELECT EXISTS(SELECT 1 FROM accesspolicy WHERE key = ? AND deleted_at IS NULL)`, key)
+	if ps := s.primary(); ps != nil && err == nil && !ok {
+		return ps.HasPolicyByKey(ctx, key)
+	}
+
+	return ok, err
+}
+
+func (s *SQLiteStore) FetchObjectNames(ctx context.Context) (names []string, err error) {
+	rows, err := s.reader().QueryContext(ctx, `SELECT DISTINCT object_name FROM accesspolicy WHERE object_name <> '' AND deleted_at IS NULL ORDER BY object_name`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch object names")
+	}
+	defer rows.Close()
+
+	names = make([]string, 0)
+	for rows.Next() {
+		var name string
+
+		if err = rows.Scan(&name); err != nil {
+			return nil, errors.Wrap(err, "failed to scan object name")
+		}
+
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+func (s *SQLiteStore) FetchPolicyIDsByActor(ctx context.Context, actor Actor) (ids []uuid.UUID, err error) {
+	q := `
+	SELECT DISTINCT r.policy_id
+	FROM accesspolicy_roster r
+	INNER JOIN accesspolicy p ON p.id = r.policy_id
+	WHERE
+		r.actor_kind	= ?
+		AND r.actor_id	= ?
+		AND p.deleted_at IS NULL`
+
+	rows, err := s.reader().QueryContext(ctx, q, actor.Kind, actor.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch policy ids by actor")
+	}
+	defer rows.Close()
+
+	ids = make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+
+		if err = rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan policy id")
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (s *SQLiteStore) CountPoliciesByOwner(ctx context.Context, ownerID uuid.UUID, includeArchived bool) (n int, err error) {
+	q := `SELECT COUNT(*) FROM accesspolicy WHERE owner_id = ? AND deleted_at IS NULL` + archivedCond(includeArchived)
+
+	if err = s.conn().QueryRowContext(ctx, q, ownerID).Scan(&n); err != nil {
+		return 0, errors.Wrap(err, "failed to count policies by owner")
+	}
+
+	return n, nil
+}
+
+// ListPoliciesByOwner returns a page of policies owned by a given owner,
+// ordered by key and ID, non-positive limit means no limit
+func (s *SQLiteStore) ListPoliciesByOwner(ctx context.Context, ownerID uuid.UUID, limit, offset int, includeArchived bool) (ps []Policy, err error) {
+	q := `
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at
+	FROM accesspolicy
+	WHERE owner_id = ? AND deleted_at IS NULL` + archivedCond(includeArchived) + `
+	ORDER BY key, id
+	LIMIT ? OFFSET ?`
+
+	// negative limit is the same as no limit at all
+	if limit <= 0 {
+		limit = -1
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.manyPolicies(ctx, q, ownerID, limit, offset)
+}
+
+// FetchPoliciesByObjectName returns a page of policies which protect objects
+// of a given name, ordered by object ID, non-positive limit means no limit
+func (s *SQLiteStore) FetchPoliciesByObjectName(ctx context.Context, name string, limit, offset int, includeArchived bool) (ps []Policy, err error) {
+	q := `
+	SELECT id, parent_id, owner_id, key, object_name, object_id, flags, scope_id, version, deleted_at
+	FROM accesspolicy
+	WHERE object_name = ? AND deleted_at IS NULL` + archivedCond(includeArchived) + `
+	ORDER BY object_id, id
+	LIMIT ? OFFSET ?`
+
+	// negative limit is the same as no limit at all
+	if limit <= 0 {
+		limit = -1
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.manyPolicies(ctx, q, name, limit, offset)
+}
+
+func (s *SQLiteStore) manyPolicies(ctx context.Context, q string, args ...interface{}) (ps []Policy, err error) {
+	rows, err := s.reader().QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch policies")
+	}
+	defer rows.Close()
+
+	ps = make([]Policy, 0)
+	for rows.Next() {
+		var p Policy
+
+		if err = rows.Scan(&p.ID, &p.ParentID, &p.OwnerID, &p.Key, &p.ObjectName, &p.ObjectID, &p.Flags, &p.ScopeID, &p.Version, &p.DeletedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan policies")
+		}
+
+		ps = append(ps, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch policies")
+	}
+
+	// releasing the only connection before fetching co-owners
+	rows.Close()
+
+	pids := make([]uuid.UUID, len(ps))
+	for i := range ps {
+		pids[i] = ps[i].ID
+	}
+
+	owners, err := s.fetchCoOwners(ctx, pids...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ps {
+		ps[i].CoOwners = owners[ps[i].ID]
+	}
+
+	return ps, nil
+}
+
+func (s *SQLiteStore) DeletePolicy(ctx context.Context, p Policy) error {
+	return s.withTransaction(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM accesspolicy WHERE id = ?`, p.ID)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete policy")
+		}
+
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return ErrNothingChanged
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM accesspolicy_roster WHERE policy_id = ?`, p.ID); err != nil {
+			return errors.Wrap(err, "failed to delete policy roster")
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM accesspolicy_owner WHERE policy_id = ?`, p.ID); err != nil {
+			return errors.Wrap(err, "failed to delete policy co-owners")
+		}
+
+		return nil
+	})
+}
+
+// SetPolicyDeletedAt marks a policy as soft-deleted at a given time,
+// nil restores it
+func (s *SQLiteStore) SetPolicyDeletedAt(ctx context.Context, pid uuid.UUID, deletedAt *time.Time) error {
+	var at interface{}
+	if deletedAt != nil {
+		at = sqliteTime(*deletedAt)
+	}
+
+	res, err := s.conn().ExecContext(ctx, `UPDATE accesspolicy SET deleted_at = ?, version = version + 1 WHERE id = ?`, at, pid)
+	if err != nil {
+		return errors.Wrapf(err, "failed to set policy deletion time: policy_id=%s", pid)
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrNothingChanged
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) CreateRoster(ctx context.Context, policyID uuid.UUID, r *Roster) error {
+	return s.withTransaction(ctx, func(tx *sql.Tx) error {
+		return s.insertRosterEntries(ctx, tx, policyID, r)
+	})
+}
+
+func (s *SQLiteStore) FetchRosterByPolicyID(ctx context.Context, pid uuid.UUID) (*Roster, error) {
+	q := `
+	SELECT policy_id, actor_kind, actor_id, access, access_explained, denied, grantable, expires_at
+	FROM accesspolicy_roster
+	WHERE policy_id = ?`
+
+	entries, err := s.rosterEntries(ctx, q, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		if ps := s.primary(); ps != nil {
+			return ps.FetchRosterByPolicyID(ctx, pid)
+		}
+
+		return nil, ErrEmptyRoster
+	}
+
+	return buildRoster(entries), nil
+}
+
+// FetchRostersByPolicyIDs returns the rosters of given policies at once,
+// mapped by policy ID, the policies without any roster entries are omitted
+func (s *SQLiteStore) FetchRostersByPolicyIDs(ctx context.Context, pids []uuid.UUID) (map[uuid.UUID]*Roster, error) {
+	rosters := make(map[uuid.UUID]*Roster)
+
+	if len(pids) == 0 {
+		return rosters, nil
+	}
+
+	q := `
+	SELECT policy_id, actor_kind, actor_id, access, access_explained, denied, grantable, expires_at
+	FROM accesspolicy_roster
+	WHERE policy_id IN (` + sqlitePlaceholders(len(pids)) + `)`
+
+	entries, err := s.rosterEntries(ctx, q, uuidArgs(pids)...)
+	if err != nil {
+		return nil, err
+	}
+
+	for pid, records := range groupRosterEntries(entries) {
+		rosters[pid] = buildRoster(records)
+	}
+
+	return rosters, nil
+}
+
+func (s *SQLiteStore) rosterEntries(ctx context.Context, q string, args ...interface{}) ([]RosterEntry, error) {
+	rows, err := s.reader().QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch policy roster")
+	}
+	defer rows.Close()
+
+	entries := make([]RosterEntry, 0)
+	for rows.Next() {
+		var re RosterEntry
+		var explained sql.NullString
+		var expiresAt sql.NullTime
+
+		if err = rows.Scan(&re.PolicyID, &re.ActorKind, &re.ActorID, &re.Access, &explained, &re.Denied, &re.Grantable, &expiresAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan policy roster")
+		}
+
+		re.AccessExplained = explained.String
+
+		if expiresAt.Valid {
+			re.ExpiresAt = expiresAt.Time
+		}
+
+		entries = append(entries, re)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch policy roster")
+	}
+
+	return entries, nil
+}
+
+func (s *SQLiteStore) UpdateRoster(ctx context.Context, pid uuid.UUID, r *Roster) (err error) {
+	return s.withTransaction(ctx, func(tx *sql.Tx) error {
+		if err = s.applyRosterChanges(ctx, tx, pid, r); err != nil {
+			return errors.Wrap(err, "failed to apply accesspolicy policy roster changes during roster update")
+		}
+
+		return nil
+	})
+}
+
+func (s *SQLiteStore) DeleteRoster(ctx context.Context, pid uuid.UUID) (err error) {
+	return s.withTransaction(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM accesspolicy_roster WHERE policy_id = ?`, pid)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete policy")
+		}
+
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return ErrNothingChanged
+		}
+
+		return nil
+	})
+}
+
+func (s *SQLiteStore) DeleteExpiredRosterEntries(ctx context.Context, now time.Time) (n int64, err error) {
+	res, err := s.conn().ExecContext(
+		ctx,
+		`DELETE FROM accesspolicy_roster WHERE expires_at IS NOT NULL AND expires_at <= ?`,
+		now.UTC(),
+	)
+
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete expired roster entries")
+	}
+
+	return res.RowsAffected()
+}
+
+func (s *SQLiteStore) CreateAccessRequest(ctx context.Context, ar AccessRequest) error {
+	q := `
+	INSERT INTO accesspolicy_request(id, policy_id, requester_kind, requester_id, rights, justification, status, created_at)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := s.conn().ExecContext(
+		ctx,
+		q,
+		ar.ID, ar.PolicyID, ar.Requester.Kind, ar.Requester.ID, ar.Rights, ar.Justification, ar.Status, ar.CreatedAt.UTC(),
+	)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to insert access request")
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) FetchAccessRequestByID(ctx context.Context, id uuid.UUID) (ar AccessRequest, err error) {
+	q := `
+	SELECT id, policy_id, requester_kind, requester_id, rights, justification, status,
+		resolver_kind, resolver_id, created_at, resolved_at
+	FROM accesspolicy_request
+	WHERE id = ?
+	LIMIT 1`
+
+	ars, err := s.manyAccessRequests(ctx, q, id)
+	if err != nil {
+		return ar, err
+	}
+
+	if len(ars) == 0 {
+		if ps := s.primary(); ps != nil {
+			return ps.FetchAccessRequestByID(ctx, id)
+		}
+
+		return ar, ErrAccessRequestNotFound
+	}
+
+	return ars[0], nil
+}
+
+func (s *SQLiteStore) FetchPendingAccessRequests(ctx context.Context, pid uuid.UUID) ([]AccessRequest, error) {
+	q := `
+	SELECT id, policy_id, requester_kind, requester_id, rights, justification, status,
+		resolver_kind, resolver_id, created_at, resolved_at
+	FROM accesspolicy_request
+	WHERE
+		policy_id	= ?
+		AND status	= ?
+	ORDER BY created_at`
+
+	return s.manyAccessRequests(ctx, q, pid, RSPending)
+}
+
+func (s *SQLiteStore) manyAccessRequests(ctx context.Context, q string, args ...interface{}) (ars []AccessRequest, err error) {
+	rows, err := s.reader().QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch access requests")
+	}
+	defer rows.Close()
+
+	ars = make([]AccessRequest, 0)
+	for rows.Next() {
+		var ar AccessRequest
+		var resolvedAt sql.NullTime
+
+		err = rows.Scan(
+			&ar.ID, &ar.PolicyID, &ar.Requester.Kind, &ar.Requester.ID, &ar.Rights, &ar.Justification, &ar.Status,
+			&ar.ResolvedBy.Kind, &ar.ResolvedBy.ID, &ar.CreatedAt, &resolvedAt,
+		)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan access request")
+		}
+
+		if resolvedAt.Valid {
+			ar.ResolvedAt = resolvedAt.Time
+		}
+
+		ars = append(ars, ar)
+	}
+
+	return ars, rows.Err()
+}
+
+func (s *SQLiteStore) UpdateAccessRequest(ctx context.Context, ar AccessRequest) error {
+	q := `
+	UPDATE accesspolicy_request
+	SET
+		status			= ?,
+		resolver_kind	= ?,
+		resolver_id		= ?,
+		resolved_at		= ?
+	WHERE id = ?`
+
+	res, err := s.conn().ExecContext(ctx, q, ar.Status, ar.ResolvedBy.Kind, ar.ResolvedBy.ID, sqliteTime(ar.ResolvedAt), ar.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to update access request")
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrAccessRequestNotFound
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) SetActorSuspended(ctx context.Context, userID uuid.UUID, suspended bool) (err error) {
+	if suspended {
+		_, err = s.conn().ExecContext(
+			ctx,
+			`INSERT OR IGNORE INTO accesspolicy_suspension(actor_id, suspended_at) VALUES (?, ?)`,
+			userID, sqliteTime(time.Now()),
+		)
+	} else {
+		_, err = s.conn().ExecContext(ctx, `DELETE FROM accesspolicy_suspension WHERE actor_id = ?`, userID)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed to update actor suspension")
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) FetchSuspendedActors(ctx context.Context) (ids []uuid.UUID, err error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT actor_id FROM accesspolicy_suspension`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch suspended actors")
+	}
+	defer rows.Close()
+
+	ids = make([]uuid.UUID, 0)
+	for rows.Next() {
+		var id uuid.UUID
+
+		if err = rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan suspended actor id")
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
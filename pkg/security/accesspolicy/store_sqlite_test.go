@@ -0,0 +1,607 @@
+package accesspolicy_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/agubarev/hometown/pkg/database"
+	"github.com/agubarev/hometown/pkg/security/accesspolicy"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// newSQLiteStore returns a policy store backed by a fresh in-memory SQLite database
+func newSQLiteStore(t *testing.T) accesspolicy.Store {
+	db, err := database.SQLiteForTesting()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	s, err := accesspolicy.NewSQLiteStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// newSQLiteManager returns a policy manager backed by a fresh in-memory
+// SQLite database, along with its store
+// NOTE: group-related rights need a group manager, which is PostgreSQL-only
+func newSQLiteManager(t *testing.T) (*accesspolicy.Manager, accesspolicy.Store) {
+	s := newSQLiteStore(t)
+
+	m, err := accesspolicy.NewManager(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, s
+}
+
+func TestSQLiteStoreCreate(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy manager
+	m, s := newSQLiteManager(t)
+
+	ownerID := uuid.New()
+	obj := accesspolicy.NewObject(uuid.New(), "sqlite object")
+
+	p, err := m.Create(ctx, "sqlite policy", ownerID, uuid.Nil, obj, 0)
+	a.NoError(err)
+	a.NotZero(p.ID)
+
+	child, err := m.Create(ctx, "sqlite child policy", ownerID, p.ID, accesspolicy.NilObject(), accesspolicy.FExtend)
+	a.NoError(err)
+	a.Equal(p.ID, child.ParentID)
+
+	// reading back from the store
+	fetched, err := s.FetchPolicyByID(ctx, p.ID)
+	a.NoError(err)
+	a.Equal(p, fetched)
+
+	fetched, err = s.FetchPolicyByKey(ctx, "sqlite policy")
+	a.NoError(err)
+	a.Equal(p, fetched)
+
+	fetched, err = s.FetchPolicyByObject(ctx, obj)
+	a.NoError(err)
+	a.Equal(p, fetched)
+
+	fetched, err = s.FetchPolicyByID(ctx, child.ID)
+	a.NoError(err)
+	a.Equal(child, fetched)
+
+	_, err = s.FetchPolicyByID(ctx, uuid.New())
+	a.Equal(accesspolicy.ErrPolicyNotFound, err)
+
+	// existence
+	ok, err := s.HasPolicy(ctx, p.ID)
+	a.NoError(err)
+	a.True(ok)
+
+	ok, err = s.HasPolicyByKey(ctx, "non-existing key")
+	a.NoError(err)
+	a.False(ok)
+
+	ok, err = s.HasChildPolicies(ctx, p.ID)
+	a.NoError(err)
+	a.True(ok)
+
+	n, err := s.CountPoliciesByOwner(ctx, ownerID, false)
+	a.NoError(err)
+	a.Equal(2, n)
+
+	names, err := s.FetchObjectNames(ctx)
+	a.NoError(err)
+	a.Equal([]string{"sqlite object"}, names)
+
+	// policies without keys don't conflict
+	_, err = m.Create(ctx, "", ownerID, uuid.Nil, accesspolicy.NewObject(uuid.New(), "keyless 1"), 0)
+	a.NoError(err)
+
+	_, err = m.Create(ctx, "", ownerID, uuid.Nil, accesspolicy.NewObject(uuid.New(), "keyless 2"), 0)
+	a.NoError(err)
+
+	// listing by owner, keyless policies come first
+	ps, err := s.ListPoliciesByOwner(ctx, ownerID, 3, 1, false)
+	a.NoError(err)
+	if a.Len(ps, 3) {
+		a.Equal("", ps[0].Key)
+		a.Equal("sqlite child policy", ps[1].Key)
+		a.Equal("sqlite policy", ps[2].Key)
+	}
+
+	ps, err = s.ListPoliciesByOwner(ctx, ownerID, 0, 0, false)
+	a.NoError(err)
+	a.Len(ps, 4)
+
+	// fetching at once
+	ps, err = s.FetchPoliciesByIDs(ctx, []uuid.UUID{p.ID, child.ID, uuid.New()})
+	a.NoError(err)
+	a.ElementsMatch([]accesspolicy.Policy{p, child}, ps)
+
+	// deleting
+	a.NoError(m.DeletePolicy(ctx, child))
+
+	_, err = s.FetchPolicyByID(ctx, child.ID)
+	a.Equal(accesspolicy.ErrPolicyNotFound, err)
+}
+
+func TestSQLiteStoreRoster(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy manager
+	m, s := newSQLiteManager(t)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+	denied := accesspolicy.UserActor(uuid.New())
+	contractor := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "sqlite roster policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	a.NoError(m.GrantPublicAccess(ctx, p.ID, owner, accesspolicy.APView))
+	a.NoError(m.GrantUserAccess(ctx, p.ID, owner, user.ID, accesspolicy.APView|accesspolicy.APChange))
+	a.NoError(m.DenyUserAccess(ctx, p.ID, owner, denied.ID, accesspolicy.APView))
+	a.NoError(m.GrantUserAccessUntil(ctx, p.ID, owner, contractor.ID, accesspolicy.APDelete, expiresAt))
+	a.NoError(m.SetGrantableRights(ctx, p.ID, user, accesspolicy.APView))
+	a.NoError(m.Update(ctx, p))
+
+	// roster must round-trip through the store
+	r, err := s.FetchRosterByPolicyID(ctx, p.ID)
+	a.NoError(err)
+	a.Equal(accesspolicy.APView, r.Everyone)
+
+	cells := make(map[accesspolicy.Actor]accesspolicy.Cell)
+	for _, cell := range r.Registry {
+		cells[cell.Key] = cell
+	}
+
+	a.Equal(accesspolicy.APView|accesspolicy.APChange, cells[user].Rights)
+	a.Equal(accesspolicy.APView, cells[user].Grantable)
+	a.Equal(accesspolicy.APView, cells[denied].Denied)
+	a.Equal(accesspolicy.APDelete, cells[contractor].Rights)
+	a.True(expiresAt.Equal(cells[contractor].ExpiresAt))
+
+	// fetching at once
+	rosters, err := s.FetchRostersByPolicyIDs(ctx, []uuid.UUID{p.ID, uuid.New()})
+	a.NoError(err)
+	if a.Len(rosters, 1) {
+		a.Equal(r.Everyone, rosters[p.ID].Everyone)
+		a.ElementsMatch(r.Registry, rosters[p.ID].Registry)
+	}
+
+	// a fresh manager to make sure everything is read from the store
+	m2, err := accesspolicy.NewManager(s, nil)
+	a.NoError(err)
+
+	a.True(m2.HasRights(ctx, p.ID, owner, accesspolicy.APFullAccess))
+	a.True(m2.HasRights(ctx, p.ID, user, accesspolicy.APView|accesspolicy.APChange))
+	a.False(m2.HasRights(ctx, p.ID, user, accesspolicy.APDelete))
+	a.False(m2.HasRights(ctx, p.ID, denied, accesspolicy.APView))
+	a.True(m2.HasRights(ctx, p.ID, contractor, accesspolicy.APDelete))
+	a.True(m2.HasPublicRights(ctx, p.ID, accesspolicy.APView))
+
+	// revoking
+	a.NoError(m.RevokeAccess(ctx, p.ID, owner, user))
+	a.NoError(m.Update(ctx, p))
+
+	m3, err := accesspolicy.NewManager(s, nil)
+	a.NoError(err)
+	a.False(m3.HasRights(ctx, p.ID, user, accesspolicy.APView|accesspolicy.APChange))
+
+	ids, err := s.FetchPolicyIDsByActor(ctx, contractor)
+	a.NoError(err)
+	a.Equal([]uuid.UUID{p.ID}, ids)
+
+	// purging entries which have expired by then
+	n, err := s.DeleteExpiredRosterEntries(ctx, expiresAt.Add(-time.Minute))
+	a.NoError(err)
+	a.Zero(n)
+
+	n, err = s.DeleteExpiredRosterEntries(ctx, expiresAt)
+	a.NoError(err)
+	a.Equal(int64(1), n)
+
+	// deleting the whole roster
+	a.NoError(s.DeleteRoster(ctx, p.ID))
+
+	_, err = s.FetchRosterByPolicyID(ctx, p.ID)
+	a.Equal(accesspolicy.ErrEmptyRoster, errors.Cause(err))
+}
+
+func TestSQLiteStoreAccessRequests(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy manager
+	m, _ := newSQLiteManager(t)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	user := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "sqlite request policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	requestID, err := m.RequestAccess(ctx, p.ID, user, accesspolicy.APView, "please")
+	a.NoError(err)
+
+	pending, err := m.ListPendingRequests(ctx, p.ID)
+	a.NoError(err)
+	if a.Len(pending, 1) {
+		a.Equal(requestID, pending[0].ID)
+		a.Equal(user, pending[0].Requester)
+		a.Equal("please", pending[0].Justification)
+	}
+
+	a.NoError(m.Approve(ctx, requestID, owner))
+	a.True(m.HasRights(ctx, p.ID, user, accesspolicy.APView))
+
+	ar, err := m.AccessRequestByID(ctx, requestID)
+	a.NoError(err)
+	a.Equal(accesspolicy.RSApproved, ar.Status)
+	a.Equal(owner, ar.ResolvedBy)
+	a.False(ar.ResolvedAt.IsZero())
+
+	pending, err = m.ListPendingRequests(ctx, p.ID)
+	a.NoError(err)
+	a.Empty(pending)
+}
+
+func TestSQLiteStoreSuspension(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy manager
+	m, s := newSQLiteManager(t)
+
+	owner := uuid.New()
+
+	p, err := m.Create(ctx, "sqlite suspension policy", owner, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.SetActorSuspended(ctx, owner, true))
+	a.Equal(accesspolicy.APNoAccess, m.Access(ctx, p.ID, owner))
+
+	ids, err := s.FetchSuspendedActors(ctx)
+	a.NoError(err)
+	a.Equal([]uuid.UUID{owner}, ids)
+
+	a.NoError(m.SetActorSuspended(ctx, owner, false))
+	a.Equal(accesspolicy.APFullAccess, m.Access(ctx, p.ID, owner))
+
+	ids, err = s.FetchSuspendedActors(ctx)
+	a.NoError(err)
+	a.Empty(ids)
+}
+
+func TestSQLiteStoreCoOwners(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy manager
+	m, s := newSQLiteManager(t)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	coOwner := accesspolicy.UserActor(uuid.New())
+
+	p, err := m.Create(ctx, "sqlite co-owned policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.AddCoOwner(ctx, p.ID, owner, coOwner.ID))
+
+	fetched, err := s.FetchPolicyByID(ctx, p.ID)
+	a.NoError(err)
+	a.Equal([]uuid.UUID{coOwner.ID}, fetched.CoOwners)
+
+	// the co-owners of many policies are fetched at once
+	other, err := m.Create(ctx, "sqlite another co-owned policy", owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	ps, err := s.FetchPoliciesByIDs(ctx, []uuid.UUID{p.ID, other.ID})
+	a.NoError(err)
+	a.Len(ps, 2)
+
+	for _, fetched := range ps {
+		if fetched.ID == p.ID {
+			a.Equal([]uuid.UUID{coOwner.ID}, fetched.CoOwners)
+		} else {
+			a.Empty(fetched.CoOwners)
+		}
+	}
+
+	a.NoError(m.RemoveCoOwner(ctx, p.ID, owner, coOwner.ID))
+
+	fetched, err = s.FetchPolicyByID(ctx, p.ID)
+	a.NoError(err)
+	a.Empty(fetched.CoOwners)
+
+	// deleting the policy removes its owner set as well
+	a.NoError(m.AddCoOwner(ctx, p.ID, owner, coOwner.ID))
+	a.NoError(m.DeletePolicy(ctx, p))
+
+	_, err = s.FetchPolicyByID(ctx, p.ID)
+	a.Equal(accesspolicy.ErrPolicyNotFound, err)
+}
+
+func TestSQLiteStoreStaleUpdate(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy manager
+	m, s := newSQLiteManager(t)
+
+	ownerID := uuid.New()
+
+	p, err := m.Create(ctx, "sqlite versioned policy", ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(s.UpdatePolicy(ctx, p, accesspolicy.NewRoster(0)))
+
+	fetched, err := s.FetchPolicyByID(ctx, p.ID)
+	a.NoError(err)
+	a.Equal(p.Version+1, fetched.Version)
+
+	// the same version can't be updated twice
+	err = s.UpdatePolicy(ctx, p, accesspolicy.NewRoster(0))
+	a.Equal(accesspolicy.ErrStaleUpdate, errors.Cause(err))
+
+	a.NoError(s.UpdatePolicy(ctx, fetched, accesspolicy.NewRoster(0)))
+}
+
+func TestSQLiteStoreSoftDelete(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy manager
+	m, s := newSQLiteManager(t)
+
+	ownerID := uuid.New()
+
+	p, err := m.Create(ctx, "sqlite soft deleted policy", ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	a.NoError(m.SoftDeletePolicy(ctx, p))
+
+	fetched, err := s.FetchPolicyByID(ctx, p.ID)
+	a.NoError(err)
+	a.True(fetched.IsDeleted())
+	a.Equal(p.Version+1, fetched.Version)
+
+	// soft-deleted policy neither counts nor holds its key
+	ok, err := s.HasPolicyByKey(ctx, p.Key)
+	a.NoError(err)
+	a.False(ok)
+
+	n, err := s.CountPoliciesByOwner(ctx, ownerID, false)
+	a.NoError(err)
+	a.Zero(n)
+
+	recreated, err := m.Create(ctx, p.Key, ownerID, uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+
+	fetched, err = s.FetchPolicyByKey(ctx, p.Key)
+	a.NoError(err)
+	a.Equal(recreated.ID, fetched.ID)
+	a.Nil(fetched.DeletedAt)
+
+	// restoring
+	deletedAt := time.Now().Truncate(time.Second)
+	a.NoError(s.SetPolicyDeletedAt(ctx, recreated.ID, &deletedAt))
+
+	fetched, err = s.FetchPolicyByID(ctx, recreated.ID)
+	a.NoError(err)
+	if a.NotNil(fetched.DeletedAt) {
+		a.True(deletedAt.Equal(*fetched.DeletedAt))
+	}
+
+	a.NoError(s.SetPolicyDeletedAt(ctx, p.ID, nil))
+
+	fetched, err = s.FetchPolicyByKey(ctx, p.Key)
+	a.NoError(err)
+	a.Equal(p.ID, fetched.ID)
+	a.Nil(fetched.DeletedAt)
+
+	a.Equal(accesspolicy.ErrNothingChanged, s.SetPolicyDeletedAt(ctx, uuid.New(), nil))
+}
+
+func TestSQLiteStorePoliciesByObjectName(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy manager
+	m, s := newSQLiteManager(t)
+
+	ownerID := uuid.New()
+
+	for i := 0; i < 3; i++ {
+		_, err := m.Create(ctx, fmt.Sprintf("sqlite document %d", i), ownerID, uuid.Nil, accesspolicy.NewObject(uuid.New(), "document"), 0)
+		a.NoError(err)
+	}
+
+	_, err := m.Create(ctx, "sqlite folder", ownerID, uuid.Nil, accesspolicy.NewObject(uuid.New(), "folder"), 0)
+	a.NoError(err)
+
+	ps, err := s.FetchPoliciesByObjectName(ctx, "document", 2, 0, false)
+	a.NoError(err)
+	if a.Len(ps, 2) {
+		a.True(ps[0].ObjectID.String() < ps[1].ObjectID.String())
+	}
+
+	ps, err = s.FetchPoliciesByObjectName(ctx, "document", 2, 2, false)
+	a.NoError(err)
+	a.Len(ps, 1)
+
+	ps, err = s.FetchPoliciesByObjectName(ctx, "document", 0, 0, false)
+	a.NoError(err)
+	a.Len(ps, 3)
+
+	for _, p := range ps {
+		a.Equal("document", p.ObjectName)
+	}
+
+	// archived policies are excluded unless included
+	a.NoError(m.Archive(ctx, ps[0].ID))
+
+	ps, err = s.FetchPoliciesByObjectName(ctx, "document", 0, 0, false)
+	a.NoError(err)
+	a.Len(ps, 2)
+
+	ps, err = s.FetchPoliciesByObjectName(ctx, "document", 0, 0, true)
+	a.NoError(err)
+	a.Len(ps, 3)
+
+	n, err := s.CountPoliciesByOwner(ctx, ownerID, false)
+	a.NoError(err)
+	a.Equal(3, n)
+
+	n, err = s.CountPoliciesByOwner(ctx, ownerID, true)
+	a.NoError(err)
+	a.Equal(4, n)
+}
+
+func TestSQLiteStoreUpdateBatch(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// policy manager
+	m, s := newSQLiteManager(t)
+
+	owner := accesspolicy.UserActor(uuid.New())
+	grantee := accesspolicy.UserActor(uuid.New())
+
+	ps := make([]accesspolicy.Policy, 3)
+	for i := range ps {
+		p, err := m.Create(ctx, fmt.Sprintf("sqlite batch %d", i), owner.ID, uuid.Nil, accesspolicy.NilObject(), 0)
+		a.NoError(err)
+
+		a.NoError(m.GrantAccess(ctx, p.ID, owner, grantee, accesspolicy.APView))
+		ps[i] = p
+	}
+
+	// making the second update stale
+	stale, err := s.FetchPolicyByID(ctx, ps[1].ID)
+	a.NoError(err)
+	a.NoError(s.UpdatePolicy(ctx, stale, accesspolicy.NewRoster(0)))
+
+	err = m.UpdateBatch(ctx, ps)
+	a.Equal(accesspolicy.ErrStaleUpdate, errors.Cause(err))
+
+	// the first update has been rolled back
+	stored, err := s.FetchPolicyByID(ctx, ps[0].ID)
+	a.NoError(err)
+	a.Equal(ps[0].Version, stored.Version)
+
+	m2, err := accesspolicy.NewManager(s, nil)
+	a.NoError(err)
+	a.False(m2.HasRights(ctx, ps[0].ID, grantee, accesspolicy.APView))
+}
+
+func TestSQLiteStoreReplica(t *testing.T) {
+	a := assert.New(t)
+
+	// test context
+	ctx := context.Background()
+
+	// two separate databases, standing for the primary and its replica
+	primaryDB, err := database.SQLiteForTesting()
+	a.NoError(err)
+	defer primaryDB.Close()
+
+	replicaDB, err := database.SQLiteForTesting()
+	a.NoError(err)
+	defer replicaDB.Close()
+
+	primary, err := accesspolicy.NewSQLiteStore(primaryDB)
+	a.NoError(err)
+
+	replica, err := accesspolicy.NewSQLiteStore(replicaDB)
+	a.NoError(err)
+
+	s, err := accesspolicy.NewSQLiteStoreWithReplica(primaryDB, replicaDB)
+	a.NoError(err)
+
+	_, err = accesspolicy.NewSQLiteStoreWithReplica(primaryDB, nil)
+	a.Equal(accesspolicy.ErrNilDatabase, err)
+
+	// a policy which only exists on the replica, proving that the reads hit it
+	onReplica, err := accesspolicy.NewPolicy("replica policy", uuid.New(), uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+	onReplica.ID = uuid.New()
+
+	onReplica, _, err = replica.CreatePolicy(ctx, onReplica, accesspolicy.NewRoster(0))
+	a.NoError(err)
+
+	_, err = primary.FetchPolicyByID(ctx, onReplica.ID)
+	a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+
+	fp, err := s.FetchPolicyByID(ctx, onReplica.ID)
+	a.NoError(err)
+	a.Equal(onReplica.Key, fp.Key)
+
+	// writes go to the primary only
+	written, err := accesspolicy.NewPolicy("written policy", uuid.New(), uuid.Nil, accesspolicy.NilObject(), 0)
+	a.NoError(err)
+	written.ID = uuid.New()
+
+	r := accesspolicy.NewRoster(0)
+	r.Everyone = accesspolicy.APView
+
+	written, _, err = s.CreatePolicy(ctx, written, r)
+	a.NoError(err)
+
+	ok, err := replica.HasPolicy(ctx, written.ID)
+	a.NoError(err)
+	a.False(ok)
+
+	// falling back to the primary, as the replica lags behind
+	fp, err = s.FetchPolicyByID(ctx, written.ID)
+	a.NoError(err)
+	a.Equal(written.Key, fp.Key)
+
+	fp, err = s.FetchPolicyByKey(ctx, written.Key)
+	a.NoError(err)
+	a.Equal(written.ID, fp.ID)
+
+	ok, err = s.HasPolicy(ctx, written.ID)
+	a.NoError(err)
+	a.True(ok)
+
+	fr, err := s.FetchRosterByPolicyID(ctx, written.ID)
+	a.NoError(err)
+	a.Equal(accesspolicy.APView, fr.Everyone)
+
+	// missing everywhere
+	_, err = s.FetchPolicyByID(ctx, uuid.New())
+	a.Equal(accesspolicy.ErrPolicyNotFound, errors.Cause(err))
+}
@@ -0,0 +1,112 @@
+package accesspolicy
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// DefaultSuspensionTTL is the default period after which the suspended
+// users are reloaded, so that the suspensions made elsewhere take effect
+const DefaultSuspensionTTL = time.Second
+
+// SetSuspensionTTL sets the period after which the suspended users
+// are reloaded from the store, zero TTL reads them on every check
+func (m *Manager) SetSuspensionTTL(ttl time.Duration) {
+	m.suspensionLock.Lock()
+	m.suspensionTTL = ttl
+	m.suspensionLock.Unlock()
+}
+
+// SetActorSuspended suspends or reinstates a given user, a suspended user
+// has no access to any policy, not even to the ones it owns
+// NOTE: the suspension is persisted, and the other managers pick it up
+// once they reload the suspended users, see SetSuspensionTTL
+func (m *Manager) SetActorSuspended(ctx context.Context, userID uuid.UUID, suspended bool) error {
+	if userID == uuid.Nil {
+		return ErrNilActorID
+	}
+
+	m.suspensionLock.Lock()
+	defer m.suspensionLock.Unlock()
+
+	start := time.Now()
+	err := m.store.SetActorSuspended(ctx, userID, suspended)
+	m.observeStore("SetActorSuspended", start)
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to store actor suspension: user_id=%s, suspended=%t", userID, suspended)
+	}
+
+	// the loaded set follows the store, unless it's yet to be loaded
+	if m.suspended == nil {
+		return nil
+	}
+
+	if suspended {
+		m.suspended[userID] = true
+	} else {
+		delete(m.suspended, userID)
+	}
+
+	return nil
+}
+
+// IsActorSuspended returns true if a given user is suspended
+func (m *Manager) IsActorSuspended(ctx context.Context, userID uuid.UUID) (bool, error) {
+	if userID == uuid.Nil {
+		return false, nil
+	}
+
+	m.suspensionLock.RLock()
+	fresh := m.suspensionFresh()
+	suspended := m.suspended[userID]
+	m.suspensionLock.RUnlock()
+
+	if fresh {
+		return suspended, nil
+	}
+
+	m.suspensionLock.Lock()
+	defer m.suspensionLock.Unlock()
+
+	if err := m.loadSuspended(ctx); err != nil {
+		return false, err
+	}
+
+	return m.suspended[userID], nil
+}
+
+// suspensionFresh tells whether the loaded suspended users are still fresh
+// NOTE: must be called under the suspension lock
+func (m *Manager) suspensionFresh() bool {
+	return m.suspended != nil && time.Since(m.suspensionLoadedAt) < m.suspensionTTL
+}
+
+// loadSuspended loads the suspended users from the store, unless they've
+// been loaded recently, possibly while waiting for the lock
+// NOTE: must be called under the suspension write lock
+func (m *Manager) loadSuspended(ctx context.Context) error {
+	if m.suspensionFresh() {
+		return nil
+	}
+
+	start := time.Now()
+	ids, err := m.store.FetchSuspendedActors(ctx)
+	m.observeStore("FetchSuspendedActors", start)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch suspended actors")
+	}
+
+	m.suspended = make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		m.suspended[id] = true
+	}
+
+	m.suspensionLoadedAt = start
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package accesspolicy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// TraceStepKind designates what has happened at a certain step of access resolution
+type TraceStepKind string
+
+const (
+	TSVisit       TraceStepKind = "visit"
+	TSOwner       TraceStepKind = "owner"
+	TSDomainOwner TraceStepKind = "domain_owner"
+	TSSuspended   TraceStepKind = "suspended"
+	TSArchived    TraceStepKind = "archived"
+	TSInherit     TraceStepKind = "inherit"
+	TSExtend      TraceStepKind = "extend"
+	TSPublic      TraceStepKind = "public"
+	TSGroup       TraceStepKind = "group"
+	TSUser        TraceStepKind = "user"
+	TSDeny        TraceStepKind = "deny"
+	TSResult      TraceStepKind = "result"
+)
+
+// TraceStep is a single step of access resolution
+type TraceStep struct {
+	Kind     TraceStepKind `json:"kind"`
+	PolicyID uuid.UUID     `json:"policy_id"`
+	Rights   Right         `json:"rights"`
+	Note     string        `json:"note"`
+}
+
+func (s TraceStep) String() string {
+	return fmt.Sprintf("%s policy_id=%s rights=%s: %s", s.Kind, s.PolicyID, s.Rights, s.Note)
+}
+
+// Trace collects the steps of access resolution in the order they've been made
+// NOTE: meant for debugging, as it bypasses the calculated rights cache
+type Trace struct {
+	steps []TraceStep
+	sync.Mutex
+}
+
+// Steps returns a copy of the recorded steps
+func (t *Trace) Steps() []TraceStep {
+	t.Lock()
+	defer t.Unlock()
+
+	steps := make([]TraceStep, len(t.steps))
+	copy(steps, t.steps)
+
+	return steps
+}
+
+func (t *Trace) String() string {
+	steps := t.Steps()
+
+	lines := make([]string, len(steps))
+	for i, s := range steps {
+		lines[i] = s.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (t *Trace) add(s TraceStep) {
+	t.Lock()
+	t.steps = append(t.steps, s)
+	t.Unlock()
+}
+
+type traceContextKey struct{}
+
+// WithTrace returns a context which enables tracing of access resolution,
+// all rights checks made with this context record their steps into the trace
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{steps: make([]TraceStep, 0)}
+
+	return context.WithValue(ctx, traceContextKey{}, t), t
+}
+
+// TraceFromContext returns the trace attached to a given context, nil if there's none
+func TraceFromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceContextKey{}).(*Trace)
+	return t
+}
+
+// trace records a resolution step if tracing is enabled for this context
+func trace(ctx context.Context, kind TraceStepKind, pid uuid.UUID, rights Right, format string, args ...interface{}) {
+	t := TraceFromContext(ctx)
+	if t == nil {
+		return
+	}
+
+	t.add(TraceStep{
+		Kind:     kind,
+		PolicyID: pid,
+		Rights:   rights,
+		Note:     fmt.Sprintf(format, args...),
+	})
+}
+
+// HasRightsTraced checks rights the same way HasRightsE does,
+// additionally returning the trace of how the decision has been made
+func (m *Manager) HasRightsTraced(ctx context.Context, pid uuid.UUID, actor Actor, rights Right) (bool, *Trace, error) {
+	t := TraceFromContext(ctx)
+	if t == nil {
+		ctx, t = WithTrace(ctx)
+	}
+
+	ok, err := m.HasRightsE(ctx, pid, actor, rights)
+	if err != nil {
+		trace(ctx, TSResult, pid, rights, "%s %s: error: %s", actor.Kind, actor.ID, err)
+		return false, t, err
+	}
+
+	trace(ctx, TSResult, pid, rights, "%s %s: granted=%t", actor.Kind, actor.ID, ok)
+
+	return ok, t, nil
+}
@@ -12,4 +12,6 @@ var (
 	ErrLongPassword     = errors.New("password is too long")
 	ErrUnsafePassword   = errors.New("password is too unsafe")
 	ErrInfeasibleSafety = errors.New("password safety is infeasible with such length and score")
+	ErrPasswordReused   = errors.New("password has been used recently")
+	ErrNilHasher        = errors.New("password hasher is nil")
 )
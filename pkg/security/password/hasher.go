@@ -0,0 +1,203 @@
+package password
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithm identifies the algorithm a password hash has been made with
+type Algorithm uint8
+
+// password hashing algorithms
+const (
+	HABcrypt Algorithm = iota + 1
+	HAArgon2id
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case HABcrypt:
+		return "bcrypt"
+	case HAArgon2id:
+		return "argon2id"
+	default:
+		return "unrecognized hashing algorithm"
+	}
+}
+
+// Hasher hashes raw passwords and verifies them against their hashes
+// NOTE: every hash carries its own algorithm identifier and parameters,
+// so that passwords hashed by different algorithms can be verified side by side
+type Hasher interface {
+	Algorithm() Algorithm
+	Hash(raw []byte) ([]byte, error)
+	Compare(hash, raw []byte) bool
+}
+
+// DefaultHasher is used unless a password manager is given another one
+var DefaultHasher Hasher = BcryptHasher{Cost: bcrypt.DefaultCost}
+
+// AlgorithmOf tells which algorithm a given hash has been made with
+func AlgorithmOf(hash []byte) Algorithm {
+	switch true {
+	case bytes.HasPrefix(hash, []byte("$argon2id$")):
+		return HAArgon2id
+	case bytes.HasPrefix(hash, []byte("$2a$")),
+		bytes.HasPrefix(hash, []byte("$2b$")),
+		bytes.HasPrefix(hash, []byte("$2y$")):
+		return HABcrypt
+	default:
+		return 0
+	}
+}
+
+// CompareHash tests whether a given raw password matches a hash
+// made by any of the supported algorithms
+func CompareHash(hash, raw []byte) bool {
+	switch AlgorithmOf(hash) {
+	case HABcrypt:
+		return BcryptHasher{}.Compare(hash, raw)
+	case HAArgon2id:
+		return Argon2Hasher{}.Compare(hash, raw)
+	default:
+		return false
+	}
+}
+
+// BcryptHasher hashes passwords with bcrypt
+// NOTE: zero cost means bcrypt.DefaultCost
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Algorithm() Algorithm {
+	return HABcrypt
+}
+
+func (h BcryptHasher) Hash(raw []byte) ([]byte, error) {
+	cost := h.Cost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+
+	return bcrypt.GenerateFromPassword(raw, cost)
+}
+
+// Compare verifies a raw password against a bcrypt hash of any cost
+func (h BcryptHasher) Compare(hash, raw []byte) bool {
+	return bcrypt.CompareHashAndPassword(hash, raw) == nil
+}
+
+// default argon2id parameters
+const (
+	DefaultArgon2Time    = 1
+	DefaultArgon2Memory  = 64 * 1024
+	DefaultArgon2Threads = 4
+	DefaultArgon2KeyLen  = 32
+	DefaultArgon2SaltLen = 16
+)
+
+// Argon2Hasher hashes passwords with argon2id, hashes are encoded
+// as "$argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>"
+// NOTE: zero parameters are replaced by their defaults, and memory is in KiB
+type Argon2Hasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+func (h Argon2Hasher) Algorithm() Algorithm {
+	return HAArgon2id
+}
+
+func (h Argon2Hasher) withDefaults() Argon2Hasher {
+	if h.Time == 0 {
+		h.Time = DefaultArgon2Time
+	}
+
+	if h.Memory == 0 {
+		h.Memory = DefaultArgon2Memory
+	}
+
+	if h.Threads == 0 {
+		h.Threads = DefaultArgon2Threads
+	}
+
+	if h.KeyLen == 0 {
+		h.KeyLen = DefaultArgon2KeyLen
+	}
+
+	if h.SaltLen == 0 {
+		h.SaltLen = DefaultArgon2SaltLen
+	}
+
+	return h
+}
+
+func (h Argon2Hasher) Hash(raw []byte) ([]byte, error) {
+	h = h.withDefaults()
+
+	salt := make([]byte, h.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "failed to generate salt")
+	}
+
+	key := argon2.IDKey(raw, salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+
+	return []byte(fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.Memory,
+		h.Time,
+		h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)), nil
+}
+
+// Compare verifies a raw password against an argon2id hash,
+// using the parameters the hash has been made with
+func (h Argon2Hasher) Compare(hash, raw []byte) bool {
+	parts := bytes.Split(hash, []byte("$"))
+	if len(parts) != 6 || string(parts[1]) != "argon2id" {
+		return false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(string(parts[2]), "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+
+	var p Argon2Hasher
+	if _, err := fmt.Sscanf(string(parts[3]), "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return false
+	}
+
+	// argon2 panics on zero passes or threads
+	if p.Time == 0 || p.Threads == 0 {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(string(parts[4]))
+	if err != nil {
+		return false
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(string(parts[5]))
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	other := argon2.IDKey(raw, salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
@@ -0,0 +1,88 @@
+package password_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agubarev/hometown/pkg/security/password"
+	"github.com/google/uuid"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashers(t *testing.T) {
+	a := assert.New(t)
+
+	raw := []byte("s@fer!@()*!p@ssw0rd*!jahaajk8!*@^%")
+	wrong := []byte("wrongpassword")
+
+	hashers := []password.Hasher{
+		password.BcryptHasher{Cost: 4},
+		password.Argon2Hasher{Time: 1, Memory: 8 * 1024, Threads: 2},
+	}
+
+	for _, h := range hashers {
+		hash, err := h.Hash(raw)
+		a.NoError(err)
+		a.Equal(h.Algorithm(), password.AlgorithmOf(hash))
+
+		a.True(h.Compare(hash, raw))
+		a.False(h.Compare(hash, wrong))
+		a.True(password.CompareHash(hash, raw))
+		a.False(password.CompareHash(hash, wrong))
+
+		// salted, so hashing twice never gives the same hash
+		other, err := h.Hash(raw)
+		a.NoError(err)
+		a.NotEqual(hash, other)
+	}
+
+	a.Equal(password.Algorithm(0), password.AlgorithmOf([]byte("plaintext")))
+	a.False(password.CompareHash([]byte("plaintext"), []byte("plaintext")))
+	a.False(password.CompareHash([]byte("$argon2id$v=19$m=8192,t=1,p=2$$"), raw))
+
+	// malformed parameters are rejected rather than panicking
+	a.False(password.CompareHash([]byte("$argon2id$v=19$m=8192,t=0,p=2$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5"), raw))
+	a.False(password.CompareHash([]byte("$argon2id$v=19$m=8192,t=1,p=0$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5"), raw))
+}
+
+func TestManagerMixedHashers(t *testing.T) {
+	a := assert.New(t)
+
+	ctx := context.Background()
+	s := password.NewMemoryStore()
+
+	_, err := password.NewManagerWithHasher(s, nil)
+	a.Equal(password.ErrNilHasher, err)
+
+	// passwords hashed with bcrypt
+	m, err := password.NewManager(s)
+	a.NoError(err)
+	a.Equal(password.HABcrypt, m.Hasher().Algorithm())
+
+	o := password.NewOwner(password.OKUser, uuid.New())
+	original := []byte("s@fer!@()*!p@ssw0rd*!jahaajk8!*@^%")
+
+	p, err := m.Change(ctx, o, original, []string{})
+	a.NoError(err)
+	a.Equal(password.HABcrypt, p.Algorithm())
+
+	// migrating to argon2id, while the bcrypt hashes remain valid
+	m, err = password.NewManagerWithHasher(s, password.Argon2Hasher{Time: 1, Memory: 8 * 1024, Threads: 2})
+	a.NoError(err)
+
+	p, err = m.Get(ctx, o)
+	a.NoError(err)
+	a.True(p.Compare(original))
+
+	_, err = m.Change(ctx, o, original, []string{})
+	a.Equal(password.ErrPasswordReused, err)
+
+	changed := []byte("namelimilenivonalimalovili")
+
+	p, err = m.Change(ctx, o, changed, []string{})
+	a.NoError(err)
+	a.Equal(password.HAArgon2id, p.Algorithm())
+	a.True(p.Compare(changed))
+	a.False(p.Compare(original))
+}
@@ -1,36 +1,81 @@
 package password
 
 import (
+	"bytes"
 	"context"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
 
+// DefaultHistoryDepth is how many previous passwords are remembered
+// to prevent their reuse
+const DefaultHistoryDepth = 5
+
 // userManager describes the behaviour of a user password manager
 type Manager interface {
 	Upsert(ctx context.Context, p Password) error
+	Change(ctx context.Context, o Owner, rawpass []byte, data []string) (p Password, err error)
 	Get(ctx context.Context, o Owner) (p Password, err error)
 	Delete(ctx context.Context, o Owner) error
+	SetHistoryDepth(depth int)
+	Hasher() Hasher
 }
 
 type defaultManager struct {
-	store Store
+	store        Store
+	hasher       Hasher
+	historyDepth int
+	sync.RWMutex
 }
 
-// NewManager initializes the default user password manager
+// NewManager initializes the default user password manager,
+// which hashes new passwords with bcrypt
 func NewManager(store Store) (Manager, error) {
+	return NewManagerWithHasher(store, DefaultHasher)
+}
+
+// NewManagerWithHasher initializes the default user password manager
+// which hashes new passwords with a given hasher
+// NOTE: passwords hashed by any supported algorithm are still verified,
+// so that the existing passwords remain valid while migrating
+func NewManagerWithHasher(store Store, hasher Hasher) (Manager, error) {
 	if store == nil {
 		return nil, ErrNilPasswordStore
 	}
 
+	if hasher == nil {
+		return nil, ErrNilHasher
+	}
+
 	pm := &defaultManager{
-		store: store,
+		store:        store,
+		hasher:       hasher,
+		historyDepth: DefaultHistoryDepth,
 	}
 
 	return pm, nil
 }
 
+// Hasher returns the hasher which is used for new passwords
+func (m *defaultManager) Hasher() Hasher {
+	return m.hasher
+}
+
+// SetHistoryDepth sets how many previous passwords are remembered,
+// zero disables reuse prevention and forgets the history on the next change
+func (m *defaultManager) SetHistoryDepth(depth int) {
+	if depth < 0 {
+		depth = 0
+	}
+
+	m.Lock()
+	m.historyDepth = depth
+	m.Unlock()
+}
+
+// Upsert stores a password, the one it replaces is remembered in the history
 func (m *defaultManager) Upsert(ctx context.Context, p Password) (err error) {
 	if m.store == nil {
 		return ErrNilPasswordStore
@@ -40,7 +85,79 @@ func (m *defaultManager) Upsert(ctx context.Context, p Password) (err error) {
 		return errors.Wrap(err, "password validation failed")
 	}
 
-	return m.store.Upsert(ctx, p)
+	current, err := m.store.Get(ctx, p.Owner)
+	if err != nil && errors.Cause(err) != ErrPasswordNotFound {
+		return errors.Wrap(err, "failed to obtain current password")
+	}
+
+	if err = m.store.Upsert(ctx, p); err != nil {
+		return err
+	}
+
+	// remembering the replaced password
+	if len(current.Hash) > 0 && !bytes.Equal(current.Hash, p.Hash) {
+		m.RLock()
+		depth := m.historyDepth
+		m.RUnlock()
+
+		if err = m.store.PushHistory(ctx, p.Owner, current.Hash, depth); err != nil {
+			return errors.Wrap(err, "failed to update password history")
+		}
+	}
+
+	return nil
+}
+
+// Change sets a new password from a given raw password, unless
+// it matches the current password or any of the recent ones
+func (m *defaultManager) Change(ctx context.Context, o Owner, rawpass []byte, data []string) (p Password, err error) {
+	if m.store == nil {
+		return p, ErrNilPasswordStore
+	}
+
+	if o.ID == uuid.Nil {
+		return p, ErrNilOwnerID
+	}
+
+	current, err := m.store.Get(ctx, o)
+	if err != nil && errors.Cause(err) != ErrPasswordNotFound {
+		return p, errors.Wrap(err, "failed to obtain current password")
+	}
+
+	m.RLock()
+	depth := m.historyDepth
+	m.RUnlock()
+
+	if depth > 0 {
+		if len(current.Hash) > 0 && current.Compare(rawpass) {
+			return p, ErrPasswordReused
+		}
+
+		history, err := m.store.History(ctx, o)
+		if err != nil {
+			return p, errors.Wrap(err, "failed to obtain password history")
+		}
+
+		for i, hash := range history {
+			if i >= depth {
+				break
+			}
+
+			if CompareHash(hash, rawpass) {
+				return p, ErrPasswordReused
+			}
+		}
+	}
+
+	if p, err = NewFromInputWithHasher(o, rawpass, data, m.hasher); err != nil {
+		return p, err
+	}
+
+	if err = m.Upsert(ctx, p); err != nil {
+		return p, err
+	}
+
+	return p, nil
 }
 
 func (m *defaultManager) Get(ctx context.Context, o Owner) (p Password, err error) {
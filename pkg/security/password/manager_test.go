@@ -0,0 +1,66 @@
+package password_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agubarev/hometown/pkg/security/password"
+	"github.com/google/uuid"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagerChangeHistory(t *testing.T) {
+	a := assert.New(t)
+
+	ctx := context.Background()
+
+	m, err := password.NewManager(password.NewMemoryStore())
+	a.NoError(err)
+	a.NotNil(m)
+
+	o := password.NewOwner(password.OKUser, uuid.New())
+
+	original := []byte("s@fer!@()*!p@ssw0rd*!jahaajk8!*@^%")
+	changed := []byte("namelimilenivonalimalovili")
+
+	p, err := m.Change(ctx, o, original, []string{})
+	a.NoError(err)
+	a.True(p.Compare(original))
+
+	// neither the current password nor the previous one can be reused
+	_, err = m.Change(ctx, o, original, []string{})
+	a.Equal(password.ErrPasswordReused, err)
+
+	p, err = m.Change(ctx, o, changed, []string{})
+	a.NoError(err)
+	a.True(p.Compare(changed))
+
+	_, err = m.Change(ctx, o, original, []string{})
+	a.Equal(password.ErrPasswordReused, err)
+
+	current, err := m.Get(ctx, o)
+	a.NoError(err)
+	a.True(current.Compare(changed))
+
+	// the original password is forgotten beyond the history depth
+	m.SetHistoryDepth(2)
+
+	for _, raw := range []string{"oqiwe9817dhaslkdjqwe!!", "zmxncbv1029384@@lkjh"} {
+		_, err = m.Change(ctx, o, []byte(raw), []string{})
+		a.NoError(err)
+	}
+
+	_, err = m.Change(ctx, o, changed, []string{})
+	a.Equal(password.ErrPasswordReused, err)
+
+	p, err = m.Change(ctx, o, original, []string{})
+	a.NoError(err)
+	a.True(p.Compare(original))
+
+	// reuse prevention can be disabled
+	m.SetHistoryDepth(0)
+
+	_, err = m.Change(ctx, o, original, []string{})
+	a.NoError(err)
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/google/uuid"
 	zxcvbn "github.com/nbutton23/zxcvbn-go"
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // retryAttempts defines how many generation attempts a password
@@ -148,7 +147,7 @@ func New(owner Owner, length int, pscore int, flags GenFlags) (p Password, raw [
 	}
 
 	// generating password hash
-	h, err := bcrypt.GenerateFromPassword(raw, bcrypt.DefaultCost)
+	h, err := DefaultHasher.Hash(raw)
 	if err != nil {
 		return p, raw, err
 	}
@@ -219,11 +218,21 @@ func EvaluatePasswordStrength(rawpass []byte, pscore int, data []string) error {
 
 // NewFromInput creates a hash from a given raw password byte slice
 func NewFromInput(o Owner, rawpass []byte, data []string) (p Password, err error) {
+	return NewFromInputWithHasher(o, rawpass, data, DefaultHasher)
+}
+
+// NewFromInputWithHasher is the same as NewFromInput, but hashes
+// a given raw password with a given hasher
+func NewFromInputWithHasher(o Owner, rawpass []byte, data []string, hasher Hasher) (p Password, err error) {
+	if hasher == nil {
+		return p, ErrNilHasher
+	}
+
 	if err = EvaluatePasswordStrength(rawpass, 3, data); err != nil {
 		return p, err
 	}
 
-	h, err := bcrypt.GenerateFromPassword(rawpass, bcrypt.DefaultCost)
+	h, err := hasher.Hash(rawpass)
 	if err != nil {
 		return p, err
 	}
@@ -238,7 +247,13 @@ func NewFromInput(o Owner, rawpass []byte, data []string) (p Password, err error
 	return p, nil
 }
 
-// Compare tests whether a given plaintext password is valid
+// Compare tests whether a given plaintext password is valid,
+// regardless of the algorithm its hash has been made with
 func (p Password) Compare(rawpass []byte) bool {
-	return bcrypt.CompareHashAndPassword(p.Hash, rawpass) == nil
+	return CompareHash(p.Hash, rawpass)
+}
+
+// Algorithm returns the algorithm the password hash has been made with
+func (p Password) Algorithm() Algorithm {
+	return AlgorithmOf(p.Hash)
 }
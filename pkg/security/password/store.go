@@ -9,16 +9,22 @@ type Store interface {
 	Upsert(ctx context.Context, p Password) error
 	Get(ctx context.Context, o Owner) (Password, error)
 	Delete(ctx context.Context, o Owner) error
+
+	// previously used password hashes, the most recent first
+	PushHistory(ctx context.Context, o Owner, hash []byte, depth int) error
+	History(ctx context.Context, o Owner) ([][]byte, error)
 }
 
 func NewMemoryStore() Store {
 	return &memoryStore{
 		passwords: make(map[Owner]Password),
+		history:   make(map[Owner][][]byte),
 	}
 }
 
 type memoryStore struct {
 	passwords map[Owner]Password
+	history   map[Owner][][]byte
 	sync.RWMutex
 }
 
@@ -38,6 +44,36 @@ func (m *memoryStore) Get(ctx context.Context, o Owner) (Password, error) {
 func (m *memoryStore) Delete(ctx context.Context, o Owner) error {
 	m.Lock()
 	delete(m.passwords, o)
+	delete(m.history, o)
 	m.Unlock()
 	return nil
 }
+
+func (m *memoryStore) PushHistory(ctx context.Context, o Owner, hash []byte, depth int) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if depth <= 0 {
+		delete(m.history, o)
+		return nil
+	}
+
+	history := append([][]byte{hash}, m.history[o]...)
+	if len(history) > depth {
+		history = history[:depth]
+	}
+
+	m.history[o] = history
+
+	return nil
+}
+
+func (m *memoryStore) History(ctx context.Context, o Owner) ([][]byte, error) {
+	m.RLock()
+	defer m.RUnlock()
+
+	history := make([][]byte, len(m.history[o]))
+	copy(history, m.history[o])
+
+	return history, nil
+}
@@ -2,6 +2,7 @@ package password
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx"
@@ -78,7 +79,7 @@ func (s *PostgreSQLStore) Get(ctx context.Context, o Owner) (p Password, err err
 	}
 }
 
-// DeletePolicy deletes a stored password
+// DeletePolicy deletes a stored password along with its history
 func (s *PostgreSQLStore) Delete(ctx context.Context, o Owner) (err error) {
 	q := `DELETE FROM password WHERE kind = $1 AND owner_id = $2`
 
@@ -87,5 +88,79 @@ func (s *PostgreSQLStore) Delete(ctx context.Context, o Owner) (err error) {
 		return errors.Wrap(err, "failed to delete password")
 	}
 
+	q = `DELETE FROM password_history WHERE kind = $1 AND owner_id = $2`
+
+	_, err = s.db.ExecEx(ctx, q, nil, o.Kind, o.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete password history")
+	}
+
 	return err
 }
+
+// PushHistory records a previously used password hash,
+// keeping only a given number of the most recent ones
+func (s *PostgreSQLStore) PushHistory(ctx context.Context, o Owner, hash []byte, depth int) (err error) {
+	if o.ID == uuid.Nil {
+		return ErrNilOwnerID
+	}
+
+	if depth > 0 {
+		q := `
+		INSERT INTO password_history(kind, owner_id, hash, created_at)
+		VALUES($1, $2, $3, $4)`
+
+		_, err = s.db.ExecEx(ctx, q, nil, o.Kind, o.ID, hash, time.Now())
+		if err != nil {
+			return errors.Wrap(err, "failed to insert password history")
+		}
+	}
+
+	// pruning everything beyond the depth
+	q := `
+	DELETE FROM password_history
+	WHERE kind = $1 AND owner_id = $2 AND id NOT IN (
+		SELECT id FROM password_history
+		WHERE kind = $1 AND owner_id = $2
+		ORDER BY id DESC
+		LIMIT $3)`
+
+	_, err = s.db.ExecEx(ctx, q, nil, o.Kind, o.ID, depth)
+	if err != nil {
+		return errors.Wrap(err, "failed to prune password history")
+	}
+
+	return nil
+}
+
+// History retrieves previously used password hashes, the most recent first
+func (s *PostgreSQLStore) History(ctx context.Context, o Owner) (history [][]byte, err error) {
+	q := `
+	SELECT hash
+	FROM password_history
+	WHERE kind = $1 AND owner_id = $2
+	ORDER BY id DESC`
+
+	rows, err := s.db.QueryEx(ctx, q, nil, o.Kind, o.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query password history")
+	}
+	defer rows.Close()
+
+	history = make([][]byte, 0)
+	for rows.Next() {
+		var hash []byte
+
+		if err = rows.Scan(&hash); err != nil {
+			return nil, errors.Wrap(err, "failed to scan password history")
+		}
+
+		history = append(history, hash)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to iterate over password history")
+	}
+
+	return history, nil
+}
@@ -0,0 +1,34 @@
+package user
+
+import (
+	"context"
+
+	"github.com/agubarev/hometown/pkg/group"
+	"github.com/agubarev/hometown/pkg/security/accesspolicy"
+)
+
+// WithAccessPolicyManager returns a copy of a given context
+// which carries an access policy manager
+func WithAccessPolicyManager(ctx context.Context, apm *accesspolicy.Manager) context.Context {
+	return context.WithValue(ctx, CKAccessPolicyManager, apm)
+}
+
+// AccessPolicyManagerFromContext returns the access policy manager carried
+// by a given context, ok is false if there's none
+func AccessPolicyManagerFromContext(ctx context.Context) (apm *accesspolicy.Manager, ok bool) {
+	apm, ok = ctx.Value(CKAccessPolicyManager).(*accesspolicy.Manager)
+	return apm, ok && apm != nil
+}
+
+// WithGroupManager returns a copy of a given context
+// which carries a group manager
+func WithGroupManager(ctx context.Context, gm *group.Manager) context.Context {
+	return context.WithValue(ctx, CKGroupManager, gm)
+}
+
+// GroupManagerFromContext returns the group manager carried
+// by a given context, ok is false if there's none
+func GroupManagerFromContext(ctx context.Context) (gm *group.Manager, ok bool) {
+	gm, ok = ctx.Value(CKGroupManager).(*group.Manager)
+	return gm, ok && gm != nil
+}
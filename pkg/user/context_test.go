@@ -0,0 +1,53 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agubarev/hometown/pkg/group"
+	"github.com/agubarev/hometown/pkg/security/accesspolicy"
+	"github.com/agubarev/hometown/pkg/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextManagers(t *testing.T) {
+	a := assert.New(t)
+
+	ctx := context.Background()
+
+	// absent
+	apm, ok := user.AccessPolicyManagerFromContext(ctx)
+	a.False(ok)
+	a.Nil(apm)
+
+	gm, ok := user.GroupManagerFromContext(ctx)
+	a.False(ok)
+	a.Nil(gm)
+
+	// present
+	expectedAPM := &accesspolicy.Manager{}
+	expectedGM := &group.Manager{}
+
+	ctx = user.WithAccessPolicyManager(ctx, expectedAPM)
+	ctx = user.WithGroupManager(ctx, expectedGM)
+
+	apm, ok = user.AccessPolicyManagerFromContext(ctx)
+	a.True(ok)
+	a.Equal(expectedAPM, apm)
+
+	gm, ok = user.GroupManagerFromContext(ctx)
+	a.True(ok)
+	a.Equal(expectedGM, gm)
+
+	// a value of another type under the same key doesn't panic
+	ctx = context.WithValue(context.Background(), user.CKAccessPolicyManager, "not a manager")
+	apm, ok = user.AccessPolicyManagerFromContext(ctx)
+	a.False(ok)
+	a.Nil(apm)
+
+	// nil managers are the same as absent
+	ctx = user.WithGroupManager(context.Background(), nil)
+	gm, ok = user.GroupManagerFromContext(ctx)
+	a.False(ok)
+	a.Nil(gm)
+}
@@ -22,6 +22,9 @@ const (
 	CKAccessPolicyManager
 )
 
+// MaxSearchLimit is the maximum number of users returned by a single search
+const MaxSearchLimit = 100
+
 // userManager handles business logic of its underlying objects
 // TODO: consider naming first release `Lidia`
 type Manager struct {
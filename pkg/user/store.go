@@ -14,6 +14,7 @@ type Store interface {
 	FetchUserByUsername(ctx context.Context, username string) (u User, err error)
 	FetchUserByEmailAddr(ctx context.Context, addr string) (u User, err error)
 	FetchUserByPhoneNumber(ctx context.Context, number string) (u User, err error)
+	SearchUsers(ctx context.Context, query string, limit int) (us []User, err error)
 	DeleteUserByID(ctx context.Context, id uuid.UUID) (err error)
 
 	// emails
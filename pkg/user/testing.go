@@ -118,8 +118,8 @@ func ManagerForTesting(db *pgx.Conn) (*Manager, context.Context, error) {
 
 	// configuring context
 	ctx = context.WithValue(ctx, CKUserManager, um)
-	ctx = context.WithValue(ctx, CKGroupManager, gm)
-	ctx = context.WithValue(ctx, CKAccessPolicyManager, apm)
+	ctx = WithGroupManager(ctx, gm)
+	ctx = WithAccessPolicyManager(ctx, apm)
 
 	return um, ctx, nil
 }
@@ -214,7 +214,7 @@ func (m *Manager) CreateUser(ctx context.Context, fn func(ctx context.Context) (
 	}
 
 	// initializing new password
-	p, err := password.NewFromInput(password.NewOwner(password.OKUser, u.ID), newUser.Password, userdata)
+	p, err := password.NewFromInputWithHasher(password.NewOwner(password.OKUser, u.ID), newUser.Password, userdata, m.passwords.Hasher())
 	if err != nil {
 		panic(errors.Wrap(err, "failed to initialize new password"))
 	}
@@ -285,6 +285,29 @@ func (m *Manager) UserByEmailAddr(ctx context.Context, addr string) (u User, err
 	return u, nil
 }
 
+// SearchUsers returns users whose username starts with a given query,
+// whose email address is exactly the query, or whose display name contains
+// the words of the query, ordered by username
+// NOTE: limit is capped by MaxSearchLimit, which is also used if it's not positive
+func (m *Manager) SearchUsers(ctx context.Context, query string, limit int) (us []User, err error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	if query == "" {
+		return []User{}, nil
+	}
+
+	if limit <= 0 || limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	us, err = m.store.SearchUsers(ctx, query, limit)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to search users: %s", query)
+	}
+
+	return us, nil
+}
+
 // UpdateUser updates an existing object
 // NOTE: be very cautious about how you deal with metadata inside the user function
 func (m *Manager) UpdateUser(ctx context.Context, id uuid.UUID, fn func(ctx context.Context, u User) (_ User, err error)) (u User, essentialChangelog diff.Changelog, err error) {
@@ -7,6 +7,7 @@ import (
 	"github.com/agubarev/hometown/pkg/database"
 	"github.com/agubarev/hometown/pkg/security/password"
 	"github.com/agubarev/hometown/pkg/user"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -72,3 +73,87 @@ func TestUserManagerCreate(t *testing.T) {
 	a.NoError(err)
 	a.NotNil(u1)
 }
+
+func TestUserManagerSearchUsers(t *testing.T) {
+	a := assert.New(t)
+
+	db := database.PostgreSQLForTesting(nil)
+	a.NotNil(db)
+
+	userManager, ctx, err := user.ManagerForTesting(db)
+	a.NoError(err)
+	a.NotNil(userManager)
+
+	create := func(username, displayName, email string) user.User {
+		u, err := userManager.CreateUser(ctx, func(ctx context.Context) (object user.NewUserObject, err error) {
+			object = user.NewUserObject{
+				Essential: user.Essential{
+					Username:    username,
+					DisplayName: displayName,
+				},
+				ProfileEssential: user.ProfileEssential{
+					Firstname: "John",
+					Lastname:  "Smith",
+				},
+				EmailAddr:   email,
+				PhoneNumber: uuid.New().String()[:15],
+				Password:    password.NewRaw(32, 3, password.GFDefault),
+			}
+
+			return object, nil
+		})
+		a.NoError(err)
+
+		return u
+	}
+
+	u1 := create("searchalice", "Alice Wonderland", "alice@hometown.local")
+	u2 := create("searchalbert", "Albert Smith", "albert@hometown.local")
+	u3 := create("searchbob", "Bob Builder", "bob@hometown.local")
+
+	ids := func(us []user.User) []uuid.UUID {
+		ids := make([]uuid.UUID, len(us))
+		for i, u := range us {
+			ids[i] = u.ID
+		}
+
+		return ids
+	}
+
+	// partial username
+	us, err := userManager.SearchUsers(ctx, "searchal", 0)
+	a.NoError(err)
+	a.Equal([]uuid.UUID{u2.ID, u1.ID}, ids(us))
+
+	us, err = userManager.SearchUsers(ctx, "SearchAl", 1)
+	a.NoError(err)
+	a.Equal([]uuid.UUID{u2.ID}, ids(us))
+
+	// the username is only matched by its prefix
+	us, err = userManager.SearchUsers(ctx, "alice", 0)
+	a.NoError(err)
+	a.Empty(us)
+
+	// exact email
+	us, err = userManager.SearchUsers(ctx, "bob@hometown.local", 0)
+	a.NoError(err)
+	a.Equal([]uuid.UUID{u3.ID}, ids(us))
+
+	us, err = userManager.SearchUsers(ctx, "bob@hometown", 0)
+	a.NoError(err)
+	a.Empty(us)
+
+	// display name words
+	us, err = userManager.SearchUsers(ctx, "wonderland", 0)
+	a.NoError(err)
+	a.Equal([]uuid.UUID{u1.ID}, ids(us))
+
+	// pattern characters are taken literally
+	us, err = userManager.SearchUsers(ctx, "search%", 0)
+	a.NoError(err)
+	a.Empty(us)
+
+	us, err = userManager.SearchUsers(ctx, "  ", 0)
+	a.NoError(err)
+	a.Empty(us)
+}
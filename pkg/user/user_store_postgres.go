@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx"
@@ -204,6 +205,53 @@ func (s *PostgreSQLStore) FetchUserByPhoneNumber(ctx context.Context, number str
 	}
 }
 
+// SearchUsers matches the username by prefix, the email address exactly
+// and the display name by its words
+func (s *PostgreSQLStore) SearchUsers(ctx context.Context, query string, limit int) (us []User, err error) {
+	q := `
+	SELECT
+		u.id, u.username, u.display_name, u.last_login_at, u.last_login_ip, u.last_login_failed_at, u.last_login_failed_ip,
+		u.last_login_attempts, u.is_suspended, u.suspension_reason, u.suspension_expires_at, u.checksum,
+		u.confirmed_at, u.created_at, u.updated_at, u.deleted_at
+	FROM "user" u
+	WHERE u.username LIKE $1 ESCAPE '\'
+		OR EXISTS (SELECT 1 FROM user_email e WHERE e.user_id = u.id AND e.addr = $2)
+		OR to_tsvector('simple', u.display_name) @@ plainto_tsquery('simple', $2)
+	ORDER BY u.username
+	LIMIT $3`
+
+	// escaping pattern characters, so that the query is only matched as a prefix
+	prefix := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query) + "%"
+
+	rows, err := s.db.QueryEx(ctx, q, nil, prefix, query, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to search users")
+	}
+	defer rows.Close()
+
+	us = make([]User, 0)
+	for rows.Next() {
+		var u User
+
+		err = rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.LastLoginAt, &u.LastLoginIP, &u.LastLoginFailedAt,
+			&u.LastLoginFailedIP, &u.LastLoginAttempts, &u.IsSuspended, &u.SuspensionReason,
+			&u.SuspensionExpiresAt, &u.Checksum, &u.ConfirmedAt,
+			&u.CreatedAt, &u.UpdatedAt, &u.DeletedAt)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan user")
+		}
+
+		us = append(us, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to iterate over users")
+	}
+
+	return us, nil
+}
+
 func (s *PostgreSQLStore) DeleteUserByID(ctx context.Context, id uuid.UUID) (err error) {
 	if id == uuid.Nil {
 		return ErrZeroID